@@ -0,0 +1,614 @@
+// Package apikeys manages the API keys issued to consumers of the proxy: their plans,
+// hashed key material and usage quotas.
+package apikeys
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go-api-proxy/logger"
+)
+
+// keyPrefix marks keys generated by the proxy
+const keyPrefix = "gap_"
+
+// Errors returned when a key cannot be used
+var (
+	ErrUnknownKey  = errors.New("unknown API key")
+	ErrDisabledKey = errors.New("API key is disabled")
+)
+
+// Plan describes what the keys on it may do. Zero values mean unlimited.
+type Plan struct {
+	Name              string   `json:"name"`
+	RequestsPerSecond float64  `json:"requests_per_second,omitempty"`
+	Burst             int      `json:"burst,omitempty"`
+	DailyQuota        int64    `json:"daily_quota,omitempty"`
+	MonthlyQuota      int64    `json:"monthly_quota,omitempty"`
+	AllowedRoutes     []string `json:"allowed_routes,omitempty"`
+}
+
+// AllowsRoute reports whether the plan may use the named route
+func (p *Plan) AllowsRoute(name string) bool {
+	if len(p.AllowedRoutes) == 0 {
+		return true
+	}
+	for _, allowed := range p.AllowedRoutes {
+		if allowed == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Key is an issued API key. Only the SHA-256 hash of the key is kept; a plaintext
+// key in the keys file is hashed when the file is loaded.
+type Key struct {
+	ID        string    `json:"id"`
+	Owner     string    `json:"owner,omitempty"`
+	Plan      string    `json:"plan"`
+	Hash      string    `json:"hash,omitempty"`
+	Key       string    `json:"key,omitempty"`
+	Disabled  bool      `json:"disabled,omitempty"`
+	CreatedAt time.Time `json:"created_at,omitempty"`
+}
+
+// Usage counts a key's requests in the current day and month (UTC)
+type Usage struct {
+	Day          string `json:"day"`
+	DailyCount   int64  `json:"daily_count"`
+	Month        string `json:"month"`
+	MonthlyCount int64  `json:"monthly_count"`
+}
+
+// KeyInfo is a key as reported by the admin API, without its hash
+type KeyInfo struct {
+	ID        string    `json:"id"`
+	Owner     string    `json:"owner,omitempty"`
+	Plan      string    `json:"plan"`
+	Disabled  bool      `json:"disabled"`
+	CreatedAt time.Time `json:"created_at,omitempty"`
+	Usage     Usage     `json:"usage"`
+}
+
+// QuotaResult is the outcome of counting a request against a key's quotas
+type QuotaResult struct {
+	Allowed bool
+	// Remaining is the number of requests left in the tighter quota, or -1 without quotas
+	Remaining int64
+	// ResetAt is when the exhausted quota starts over
+	ResetAt time.Time
+}
+
+// keysFile is the on-disk format of the keys file
+type keysFile struct {
+	Plans []*Plan `json:"plans"`
+	Keys  []*Key  `json:"keys"`
+}
+
+// Store holds the plans and keys from the keys file and the usage of each key.
+// Keys added, changed or removed through the admin API are written back to the keys
+// file. Usage is flushed to the usage file periodically and on Close.
+type Store struct {
+	keysFile  string
+	usageFile string
+	now       func() time.Time
+
+	mu     sync.Mutex
+	plans  map[string]*Plan
+	keys   map[string]*Key // by ID
+	hashes map[string]*Key // by hash
+	usage  map[string]*Usage
+	dirty  bool
+
+	// saveMu serialises taking and writing snapshots, so an older snapshot is never
+	// written over a newer one
+	saveMu sync.Mutex
+
+	stopOnce sync.Once
+	stopCh   chan struct{}
+	wg       sync.WaitGroup
+}
+
+// Open loads the keys file and the usage file. The usage file defaults to the keys
+// file with a .usage.json suffix. Usage is flushed every flushInterval; zero flushes
+// only on Close.
+func Open(keysFile, usageFile string, flushInterval time.Duration) (*Store, error) {
+	if usageFile == "" {
+		usageFile = strings.TrimSuffix(keysFile, filepath.Ext(keysFile)) + ".usage.json"
+	}
+
+	s := &Store{
+		keysFile:  keysFile,
+		usageFile: usageFile,
+		now:       time.Now,
+		usage:     make(map[string]*Usage),
+		stopCh:    make(chan struct{}),
+	}
+
+	if err := s.loadKeys(); err != nil {
+		return nil, err
+	}
+	if err := s.loadUsage(); err != nil {
+		return nil, err
+	}
+
+	if flushInterval > 0 {
+		s.wg.Add(1)
+		go s.flushLoop(flushInterval)
+	}
+
+	return s, nil
+}
+
+// loadKeys reads and validates the keys file. Plaintext keys are replaced by their
+// hashes and the file is rewritten so the plaintext does not stay on disk.
+func (s *Store) loadKeys() error {
+	data, err := os.ReadFile(s.keysFile)
+	if err != nil {
+		return fmt.Errorf("failed to read API keys file %s: %w", s.keysFile, err)
+	}
+
+	var file keysFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse API keys file %s: %w", s.keysFile, err)
+	}
+
+	s.plans = make(map[string]*Plan)
+	for _, plan := range file.Plans {
+		if plan.Name == "" {
+			return fmt.Errorf("API keys file %s: plan without a name", s.keysFile)
+		}
+		if plan.RequestsPerSecond < 0 || plan.Burst < 0 || plan.DailyQuota < 0 || plan.MonthlyQuota < 0 {
+			return fmt.Errorf("API keys file %s: plan %q has negative limits", s.keysFile, plan.Name)
+		}
+		s.plans[plan.Name] = plan
+	}
+
+	s.keys = make(map[string]*Key)
+	s.hashes = make(map[string]*Key)
+	hashed := 0
+	for _, key := range file.Keys {
+		if key.Key != "" {
+			key.Hash = HashKey(key.Key)
+			key.Key = ""
+			hashed++
+		}
+		if err := s.validateKey(key); err != nil {
+			return fmt.Errorf("API keys file %s: %w", s.keysFile, err)
+		}
+		s.keys[key.ID] = key
+		s.hashes[key.Hash] = key
+	}
+
+	logger.AuthLogger.Info("Loaded API keys", map[string]interface{}{
+		"keys_file":  s.keysFile,
+		"plan_count": len(s.plans),
+		"key_count":  len(s.keys),
+	})
+
+	if hashed > 0 {
+		if err := s.saveKeys(); err != nil {
+			logger.AuthLogger.Warn("Failed to replace plaintext API keys with hashes in the keys file", map[string]interface{}{
+				"keys_file": s.keysFile,
+				"error":     err.Error(),
+			})
+		}
+	}
+	return nil
+}
+
+// validateKey checks a key against the plans and the keys already loaded
+func (s *Store) validateKey(key *Key) error {
+	if key.ID == "" {
+		return fmt.Errorf("key without an id")
+	}
+	if _, ok := s.keys[key.ID]; ok {
+		return fmt.Errorf("duplicate key id %q", key.ID)
+	}
+	if decoded, err := hex.DecodeString(key.Hash); err != nil || len(decoded) != sha256.Size {
+		return fmt.Errorf("key %q needs a key or a hex SHA-256 hash", key.ID)
+	}
+	if _, ok := s.hashes[key.Hash]; ok {
+		return fmt.Errorf("key %q duplicates another key", key.ID)
+	}
+	if _, ok := s.plans[key.Plan]; !ok {
+		return fmt.Errorf("key %q has unknown plan %q", key.ID, key.Plan)
+	}
+	return nil
+}
+
+// loadUsage reads the usage file, which may not exist yet
+func (s *Store) loadUsage() error {
+	data, err := os.ReadFile(s.usageFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read API key usage file %s: %w", s.usageFile, err)
+	}
+	if err := json.Unmarshal(data, &s.usage); err != nil {
+		return fmt.Errorf("failed to parse API key usage file %s: %w", s.usageFile, err)
+	}
+	return nil
+}
+
+// HashKey returns the hex SHA-256 hash under which a key is stored
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Authenticate looks up a key and its plan. Keys are found by their hash, so the
+// lookup reveals nothing about stored keys through timing. The returned values are
+// copies that later changes through the admin API do not affect.
+func (s *Store) Authenticate(rawKey string) (*Key, *Plan, error) {
+	hash := HashKey(rawKey)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.hashes[hash]
+	if !ok {
+		return nil, nil, ErrUnknownKey
+	}
+	key := *stored
+	plan := *s.plans[key.Plan]
+	if key.Disabled {
+		return &key, &plan, ErrDisabledKey
+	}
+	return &key, &plan, nil
+}
+
+// Consume counts one request against the key's daily and monthly quotas. Requests
+// over a quota are not counted.
+func (s *Store) Consume(key *Key, plan *Plan) QuotaResult {
+	return s.quota(key, plan, true)
+}
+
+// Check reports whether the key has quota left without counting a request, so
+// requests can be rejected early and only charged once they are served
+func (s *Store) Check(key *Key, plan *Plan) QuotaResult {
+	return s.quota(key, plan, false)
+}
+
+// quota evaluates the key's quotas, counting one request when consume is set
+func (s *Store) quota(key *Key, plan *Plan, consume bool) QuotaResult {
+	now := s.now().UTC()
+	day := now.Format("2006-01-02")
+	month := now.Format("2006-01")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	usage, ok := s.usage[key.ID]
+	if !ok {
+		usage = &Usage{}
+		s.usage[key.ID] = usage
+	}
+	if usage.Day != day {
+		usage.Day, usage.DailyCount = day, 0
+	}
+	if usage.Month != month {
+		usage.Month, usage.MonthlyCount = month, 0
+	}
+
+	result := QuotaResult{Allowed: true, Remaining: -1}
+	if plan.DailyQuota > 0 && usage.DailyCount >= plan.DailyQuota {
+		result.Allowed = false
+		result.ResetAt = time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	}
+	if plan.MonthlyQuota > 0 && usage.MonthlyCount >= plan.MonthlyQuota {
+		result.Allowed = false
+		result.ResetAt = time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	if !result.Allowed {
+		result.Remaining = 0
+		return result
+	}
+
+	if consume {
+		usage.DailyCount++
+		usage.MonthlyCount++
+		s.dirty = true
+	}
+
+	if plan.DailyQuota > 0 {
+		result.Remaining = plan.DailyQuota - usage.DailyCount
+	}
+	if plan.MonthlyQuota > 0 && (result.Remaining < 0 || plan.MonthlyQuota-usage.MonthlyCount < result.Remaining) {
+		result.Remaining = plan.MonthlyQuota - usage.MonthlyCount
+	}
+	return result
+}
+
+// Create issues a new key on a plan. The plaintext key is returned once and only its
+// hash is stored.
+func (s *Store) Create(id, owner, plan string) (string, KeyInfo, error) {
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return "", KeyInfo{}, fmt.Errorf("failed to generate key: %w", err)
+	}
+	rawKey := keyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	if id == "" {
+		idBytes := make([]byte, 6)
+		rand.Read(idBytes)
+		id = "key_" + hex.EncodeToString(idBytes)
+	}
+
+	key := &Key{
+		ID:        id,
+		Owner:     owner,
+		Plan:      plan,
+		Hash:      HashKey(rawKey),
+		CreatedAt: s.now().UTC(),
+	}
+
+	s.mu.Lock()
+	if err := s.validateKey(key); err != nil {
+		s.mu.Unlock()
+		return "", KeyInfo{}, err
+	}
+	s.keys[key.ID] = key
+	s.hashes[key.Hash] = key
+	info := s.info(key)
+	s.mu.Unlock()
+
+	if err := s.saveKeys(); err != nil {
+		// The plaintext is never returned, so a key that was not saved must not stay usable
+		s.mu.Lock()
+		if s.keys[key.ID] == key {
+			delete(s.keys, key.ID)
+			delete(s.hashes, key.Hash)
+		}
+		s.mu.Unlock()
+		return "", KeyInfo{}, err
+	}
+	return rawKey, info, nil
+}
+
+// Update changes a key's plan and disabled flag. Nil values are left unchanged.
+func (s *Store) Update(id string, plan *string, disabled *bool) (KeyInfo, error) {
+	s.mu.Lock()
+	key, ok := s.keys[id]
+	if !ok {
+		s.mu.Unlock()
+		return KeyInfo{}, ErrUnknownKey
+	}
+	if plan != nil {
+		if _, ok := s.plans[*plan]; !ok {
+			s.mu.Unlock()
+			return KeyInfo{}, fmt.Errorf("unknown plan %q", *plan)
+		}
+	}
+	previous := *key
+	if plan != nil {
+		key.Plan = *plan
+	}
+	if disabled != nil {
+		key.Disabled = *disabled
+	}
+	updated := *key
+	info := s.info(key)
+	s.mu.Unlock()
+
+	if err := s.saveKeys(); err != nil {
+		// Keep memory in line with the file, unless another update has changed the key since
+		s.mu.Lock()
+		if s.keys[id] == key && *key == updated {
+			*key = previous
+		}
+		s.mu.Unlock()
+		return KeyInfo{}, err
+	}
+	return info, nil
+}
+
+// Delete removes a key and its usage
+func (s *Store) Delete(id string) (bool, error) {
+	s.mu.Lock()
+	key, ok := s.keys[id]
+	usage := s.usage[id]
+	if ok {
+		delete(s.keys, id)
+		delete(s.hashes, key.Hash)
+		delete(s.usage, id)
+		s.dirty = true
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		return false, nil
+	}
+	if err := s.saveKeys(); err != nil {
+		// A key still in the file must stay usable, unless it has been recreated since
+		s.mu.Lock()
+		if _, exists := s.keys[id]; !exists {
+			s.keys[id] = key
+			s.hashes[key.Hash] = key
+			if usage != nil {
+				s.usage[id] = usage
+			}
+		}
+		s.mu.Unlock()
+		return false, err
+	}
+	return true, nil
+}
+
+// Get returns one key
+func (s *Store) Get(id string) (KeyInfo, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[id]
+	if !ok {
+		return KeyInfo{}, false
+	}
+	return s.info(key), true
+}
+
+// List returns every key sorted by ID
+func (s *Store) List() []KeyInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	infos := make([]KeyInfo, 0, len(s.keys))
+	for _, key := range s.keys {
+		infos = append(infos, s.info(key))
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ID < infos[j].ID })
+	return infos
+}
+
+// Plans returns every plan sorted by name
+func (s *Store) Plans() []Plan {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	plans := make([]Plan, 0, len(s.plans))
+	for _, plan := range s.plans {
+		plans = append(plans, *plan)
+	}
+	sort.Slice(plans, func(i, j int) bool { return plans[i].Name < plans[j].Name })
+	return plans
+}
+
+// info reports a key with its current usage; the caller holds the lock
+func (s *Store) info(key *Key) KeyInfo {
+	info := KeyInfo{
+		ID:        key.ID,
+		Owner:     key.Owner,
+		Plan:      key.Plan,
+		Disabled:  key.Disabled,
+		CreatedAt: key.CreatedAt,
+	}
+	if usage, ok := s.usage[key.ID]; ok {
+		info.Usage = *usage
+	}
+	return info
+}
+
+// saveKeys writes the plans and hashed keys back to the keys file
+func (s *Store) saveKeys() error {
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+
+	s.mu.Lock()
+	file := keysFile{Plans: make([]*Plan, 0, len(s.plans)), Keys: make([]*Key, 0, len(s.keys))}
+	for _, plan := range s.plans {
+		copied := *plan
+		file.Plans = append(file.Plans, &copied)
+	}
+	for _, key := range s.keys {
+		copied := *key
+		file.Keys = append(file.Keys, &copied)
+	}
+	s.mu.Unlock()
+
+	sort.Slice(file.Plans, func(i, j int) bool { return file.Plans[i].Name < file.Plans[j].Name })
+	sort.Slice(file.Keys, func(i, j int) bool { return file.Keys[i].ID < file.Keys[j].ID })
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode API keys: %w", err)
+	}
+	if err := writeFileAtomic(s.keysFile, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write API keys file %s: %w", s.keysFile, err)
+	}
+	return nil
+}
+
+// Flush writes the usage counters to disk if they changed since the last flush
+func (s *Store) Flush() error {
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+
+	s.mu.Lock()
+	if !s.dirty {
+		s.mu.Unlock()
+		return nil
+	}
+	data, err := json.Marshal(s.usage)
+	s.dirty = false
+	s.mu.Unlock()
+
+	if err != nil {
+		return fmt.Errorf("failed to encode API key usage: %w", err)
+	}
+	if err := writeFileAtomic(s.usageFile, data, 0o644); err != nil {
+		s.mu.Lock()
+		s.dirty = true
+		s.mu.Unlock()
+		return fmt.Errorf("failed to write API key usage file %s: %w", s.usageFile, err)
+	}
+	return nil
+}
+
+// Close stops the periodic flush and writes the usage one last time
+func (s *Store) Close() error {
+	s.stopOnce.Do(func() {
+		close(s.stopCh)
+	})
+	s.wg.Wait()
+	return s.Flush()
+}
+
+// flushLoop periodically flushes the usage until the store is closed
+func (s *Store) flushLoop(interval time.Duration) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.Flush(); err != nil {
+				logger.AuthLogger.Error("Failed to flush API key usage", err)
+			}
+		case <-s.stopCh:
+			return
+		}
+	}
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames it into
+// place, so a crash never leaves a partially written file behind
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmpName, perm)
+	}
+	if err == nil {
+		err = os.Rename(tmpName, path)
+	}
+	if err != nil {
+		os.Remove(tmpName)
+	}
+	return err
+}
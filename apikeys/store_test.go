@@ -0,0 +1,298 @@
+package apikeys
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testKeysFile = `{
+  "plans": [
+    {"name": "free", "daily_quota": 2, "allowed_routes": ["blocks"]},
+    {"name": "pro", "requests_per_second": 10, "monthly_quota": 1000}
+  ],
+  "keys": [
+    {"id": "alice", "owner": "Alice", "plan": "free", "key": "alice-secret"},
+    {"id": "bob", "plan": "pro", "hash": "` + "%s" + `", "disabled": true}
+  ]
+}`
+
+func writeKeysFile(t *testing.T) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "keys.json")
+	content := strings.Replace(testKeysFile, "%s", HashKey("bob-secret"), 1)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write keys file: %v", err)
+	}
+	return path
+}
+
+func TestOpen_HashesPlaintextKeys(t *testing.T) {
+	path := writeKeysFile(t)
+	store, err := Open(path, "", 0)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer store.Close()
+
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "alice-secret") {
+		t.Error("Expected the plaintext key to be removed from the keys file")
+	}
+	if !strings.Contains(string(data), HashKey("alice-secret")) {
+		t.Error("Expected the key's hash in the keys file")
+	}
+
+	// The rewritten file loads again with the same keys
+	reopened, err := Open(path, "", 0)
+	if err != nil {
+		t.Fatalf("Reopening the rewritten keys file failed: %v", err)
+	}
+	if _, _, err := reopened.Authenticate("alice-secret"); err != nil {
+		t.Errorf("Expected the hashed key to authenticate, got %v", err)
+	}
+}
+
+func TestOpen_InvalidFiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"unknown plan", `{"plans": [], "keys": [{"id": "a", "plan": "gold", "key": "k"}]}`},
+		{"duplicate id", `{"plans": [{"name": "p"}], "keys": [{"id": "a", "plan": "p", "key": "k1"}, {"id": "a", "plan": "p", "key": "k2"}]}`},
+		{"no key material", `{"plans": [{"name": "p"}], "keys": [{"id": "a", "plan": "p"}]}`},
+		{"negative quota", `{"plans": [{"name": "p", "daily_quota": -1}], "keys": []}`},
+		{"invalid JSON", `{"plans": [`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "keys.json")
+			os.WriteFile(path, []byte(tt.content), 0o600)
+			if _, err := Open(path, "", 0); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+func TestStore_Authenticate(t *testing.T) {
+	store, err := Open(writeKeysFile(t), "", 0)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer store.Close()
+
+	key, plan, err := store.Authenticate("alice-secret")
+	if err != nil {
+		t.Fatalf("Authenticate failed: %v", err)
+	}
+	if key.ID != "alice" || plan.Name != "free" {
+		t.Errorf("Expected alice on the free plan, got %s on %s", key.ID, plan.Name)
+	}
+	if !plan.AllowsRoute("blocks") || plan.AllowsRoute("tokens") {
+		t.Error("Expected the free plan to allow only the blocks route")
+	}
+
+	if _, _, err := store.Authenticate("bob-secret"); !errors.Is(err, ErrDisabledKey) {
+		t.Errorf("Expected ErrDisabledKey, got %v", err)
+	}
+	if _, _, err := store.Authenticate("nobody"); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Expected ErrUnknownKey, got %v", err)
+	}
+}
+
+func TestStore_ConsumeQuotas(t *testing.T) {
+	path := writeKeysFile(t)
+	store, err := Open(path, "", 0)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	now := time.Date(2024, 3, 31, 23, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+
+	key, plan, _ := store.Authenticate("alice-secret")
+	if result := store.Check(key, plan); !result.Allowed {
+		t.Fatalf("Expected quota to be left, got %+v", result)
+	}
+	for want := int64(1); want >= 0; want-- {
+		result := store.Consume(key, plan)
+		if !result.Allowed || result.Remaining != want {
+			t.Fatalf("Expected an allowed request with %d remaining, got %+v", want, result)
+		}
+	}
+	result := store.Consume(key, plan)
+	if result.Allowed || !result.ResetAt.Equal(time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the daily quota to be used up until midnight, got %+v", result)
+	}
+	if info, _ := store.Get("alice"); info.Usage.DailyCount != 2 {
+		t.Errorf("Expected checks and rejected requests not to be counted, got %d", info.Usage.DailyCount)
+	}
+	if result := store.Check(key, plan); result.Allowed {
+		t.Error("Expected Check to report the used up quota")
+	}
+
+	// Usage survives a restart
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	reopened, err := Open(path, "", 0)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	reopened.now = func() time.Time { return now }
+	if result := reopened.Consume(key, plan); result.Allowed {
+		t.Error("Expected the persisted usage to keep the quota used up")
+	}
+
+	// The next day starts a new count
+	now = now.Add(2 * time.Hour)
+	if result := reopened.Consume(key, plan); !result.Allowed || result.Remaining != 1 {
+		t.Errorf("Expected a new daily quota, got %+v", result)
+	}
+}
+
+func TestStore_CreateUpdateDelete(t *testing.T) {
+	path := writeKeysFile(t)
+	store, err := Open(path, "", 0)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer store.Close()
+
+	if _, _, err := store.Create("carol", "Carol", "gold"); err == nil {
+		t.Error("Expected an error for an unknown plan")
+	}
+	if _, _, err := store.Create("alice", "", "free"); err == nil {
+		t.Error("Expected an error for a duplicate id")
+	}
+
+	rawKey, info, err := store.Create("", "Carol", "pro")
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if !strings.HasPrefix(rawKey, keyPrefix) || info.ID == "" || info.Plan != "pro" {
+		t.Errorf("Unexpected new key %q with %+v", rawKey, info)
+	}
+	if key, _, err := store.Authenticate(rawKey); err != nil || key.ID != info.ID {
+		t.Errorf("Expected the new key to authenticate, got %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	var file keysFile
+	if err := json.Unmarshal(data, &file); err != nil || len(file.Keys) != 3 {
+		t.Fatalf("Expected the new key to be saved, got %s", data)
+	}
+	if strings.Contains(string(data), rawKey) {
+		t.Error("Expected only the hash of the new key to be saved")
+	}
+
+	disabled := true
+	if _, err := store.Update(info.ID, nil, &disabled); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if _, _, err := store.Authenticate(rawKey); !errors.Is(err, ErrDisabledKey) {
+		t.Errorf("Expected the key to be disabled, got %v", err)
+	}
+	if _, err := store.Update("nobody", nil, &disabled); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Expected ErrUnknownKey, got %v", err)
+	}
+
+	if deleted, err := store.Delete(info.ID); !deleted || err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, _, err := store.Authenticate(rawKey); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Expected the deleted key to be unknown, got %v", err)
+	}
+	if len(store.List()) != 2 {
+		t.Errorf("Expected 2 keys left, got %d", len(store.List()))
+	}
+}
+
+func TestStore_CreateRollsBackWhenSaveFails(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "keys")
+	os.Mkdir(dir, 0o755)
+	path := filepath.Join(dir, "keys.json")
+	os.WriteFile(path, []byte(strings.Replace(testKeysFile, "%s", HashKey("bob-secret"), 1)), 0o600)
+
+	store, err := Open(path, "", 0)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	os.RemoveAll(dir)
+
+	if _, _, err := store.Create("carol", "Carol", "pro"); err == nil {
+		t.Fatal("Expected Create to fail when the keys file cannot be written")
+	}
+	if _, ok := store.Get("carol"); ok {
+		t.Error("Expected the unsaved key to be rolled back")
+	}
+}
+
+func TestStore_UpdateAndDeleteRollBackWhenSaveFails(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "keys")
+	os.Mkdir(dir, 0o755)
+	path := filepath.Join(dir, "keys.json")
+	os.WriteFile(path, []byte(strings.Replace(testKeysFile, "%s", HashKey("bob-secret"), 1)), 0o600)
+
+	store, err := Open(path, "", 0)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	os.RemoveAll(dir)
+
+	plan, disabled := "pro", true
+	if _, err := store.Update("alice", &plan, &disabled); err == nil {
+		t.Fatal("Expected Update to fail when the keys file cannot be written")
+	}
+	if info, _ := store.Get("alice"); info.Plan != "free" || info.Disabled {
+		t.Errorf("Expected the unsaved update to be rolled back, got %+v", info)
+	}
+
+	if deleted, err := store.Delete("alice"); err == nil || deleted {
+		t.Fatalf("Expected Delete to fail when the keys file cannot be written, got %v, %v", deleted, err)
+	}
+	if _, ok := store.Get("alice"); !ok {
+		t.Error("Expected the unsaved deletion to be rolled back")
+	}
+	if _, _, err := store.Authenticate("alice-secret"); err != nil {
+		t.Errorf("Expected the key to stay usable, got %v", err)
+	}
+}
+
+func TestStore_ConcurrentCreatesAreAllSaved(t *testing.T) {
+	path := writeKeysFile(t)
+	store, err := Open(path, "", 0)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer store.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, _, err := store.Create(fmt.Sprintf("key-%02d", i), "", "pro"); err != nil {
+				t.Errorf("Create failed: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	reopened, err := Open(path, "", 0)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer reopened.Close()
+	if got := len(reopened.List()); got != 22 {
+		t.Errorf("Expected every created key to be on disk, got %d keys", got)
+	}
+}
@@ -0,0 +1,527 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go-api-proxy/logger"
+)
+
+const (
+	diskIndexFile    = "index.json"
+	diskObjectsDir   = "objects"
+	diskIndexVersion = 1
+)
+
+// diskRecord is the index metadata for one cached entry. The response body is
+// stored separately in a content-addressed object file named by its SHA-256.
+type diskRecord struct {
+	Key        string      `json:"key"`
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	Vary       []string    `json:"vary,omitempty"`
+	Object     string      `json:"object,omitempty"`
+	Size       int64       `json:"size"`
+	StoredAt   time.Time   `json:"stored_at"`
+	ExpiresAt  time.Time   `json:"expires_at"`
+	LastAccess time.Time   `json:"last_access"`
+}
+
+// diskIndex is the on-disk format of the index file
+type diskIndex struct {
+	Version int           `json:"version"`
+	Entries []*diskRecord `json:"entries"`
+}
+
+// Disk is a persistent cache tier: response bodies live in a content-addressed
+// objects directory and an index file maps cache keys to their metadata. Objects
+// and the index are written to a temporary file and renamed into place, so a
+// crash never leaves a partially written file behind. The index is flushed
+// periodically and on Close; objects not referenced by the index are removed on open.
+type Disk struct {
+	dir      string
+	maxBytes int64
+	now      func() time.Time
+
+	mu        sync.Mutex
+	records   map[string]*diskRecord
+	refs      map[string]int
+	bytes     int64
+	evictions int64
+	dirty     bool
+
+	// Accesses recorded by Touch, applied to the index on the next eviction or flush
+	touchMu sync.Mutex
+	touched map[string]time.Time
+
+	stopOnce sync.Once
+	stopCh   chan struct{}
+	wg       sync.WaitGroup
+}
+
+// OpenDisk opens or creates a disk cache in dir holding at most maxBytes of response
+// bodies. The index is flushed every flushInterval; zero flushes only on Close.
+func OpenDisk(dir string, maxBytes int64, flushInterval time.Duration) (*Disk, error) {
+	if err := os.MkdirAll(filepath.Join(dir, diskObjectsDir), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory %s: %w", dir, err)
+	}
+
+	d := &Disk{
+		dir:      dir,
+		maxBytes: maxBytes,
+		now:      time.Now,
+		records:  make(map[string]*diskRecord),
+		refs:     make(map[string]int),
+		touched:  make(map[string]time.Time),
+		stopCh:   make(chan struct{}),
+	}
+
+	if err := d.load(); err != nil {
+		return nil, err
+	}
+
+	if flushInterval > 0 {
+		d.wg.Add(1)
+		go d.flushLoop(flushInterval)
+	}
+
+	return d, nil
+}
+
+// load reads the index, drops records whose object is missing and removes unreferenced objects
+func (d *Disk) load() error {
+	var index diskIndex
+	data, err := os.ReadFile(filepath.Join(d.dir, diskIndexFile))
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return fmt.Errorf("failed to read cache index: %w", err)
+	default:
+		if err := json.Unmarshal(data, &index); err != nil || index.Version != diskIndexVersion {
+			logger.CacheLogger.Warn("Discarding unreadable cache index", map[string]interface{}{
+				"dir": d.dir,
+			})
+			index = diskIndex{}
+		}
+	}
+
+	for _, record := range index.Entries {
+		if _, seen := d.records[record.Key]; seen || record.Key == "" {
+			continue
+		}
+		if record.Object != "" {
+			if len(record.Object) != sha256.Size*2 {
+				continue
+			}
+			info, err := os.Stat(d.objectPath(record.Object))
+			if err != nil || info.Size() != record.Size {
+				continue
+			}
+			if d.refs[record.Object] == 0 {
+				d.bytes += record.Size
+			}
+			d.refs[record.Object]++
+		}
+		d.records[record.Key] = record
+	}
+
+	removed := d.removeOrphans()
+
+	logger.CacheLogger.Info("Opened disk cache", map[string]interface{}{
+		"dir":             d.dir,
+		"entries":         len(d.records),
+		"bytes":           d.bytes,
+		"orphans_removed": removed,
+	})
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.evict()
+	return nil
+}
+
+// removeOrphans deletes object and temporary files not referenced by the index
+func (d *Disk) removeOrphans() int {
+	removed := 0
+	filepath.WalkDir(filepath.Join(d.dir, diskObjectsDir), func(path string, entry os.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return nil
+		}
+		if d.refs[entry.Name()] == 0 {
+			if os.Remove(path) == nil {
+				removed++
+			}
+		}
+		return nil
+	})
+	return removed
+}
+
+// Get returns the entry for key, reading its body from the objects directory
+func (d *Disk) Get(key string) (*Entry, bool) {
+	d.mu.Lock()
+	record, ok := d.records[key]
+	if ok {
+		record.LastAccess = d.now()
+		d.dirty = true
+	}
+	d.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+
+	entry := &Entry{
+		Key:        record.Key,
+		StatusCode: record.StatusCode,
+		Header:     record.Header,
+		Vary:       record.Vary,
+		StoredAt:   record.StoredAt,
+		ExpiresAt:  record.ExpiresAt,
+	}
+	if record.Object != "" {
+		body, err := os.ReadFile(d.objectPath(record.Object))
+		if err != nil || int64(len(body)) != record.Size {
+			logger.CacheLogger.Warn("Dropping disk cache entry with unreadable object", map[string]interface{}{
+				"key":    key,
+				"object": record.Object,
+			})
+			d.mu.Lock()
+			if d.records[key] == record {
+				d.remove(record)
+			}
+			d.mu.Unlock()
+			return nil, false
+		}
+		entry.Body = body
+	}
+	return entry, true
+}
+
+// Touch records an access to key without taking the index lock, so hits served by
+// a faster tier still count towards the disk tier's LRU order
+func (d *Disk) Touch(key string) {
+	now := d.now()
+	d.touchMu.Lock()
+	d.touched[key] = now
+	d.touchMu.Unlock()
+}
+
+// applyTouches moves accesses recorded by Touch into the index; callers must hold the lock
+func (d *Disk) applyTouches() {
+	d.touchMu.Lock()
+	touched := d.touched
+	d.touched = make(map[string]time.Time)
+	d.touchMu.Unlock()
+
+	for key, at := range touched {
+		if record, ok := d.records[key]; ok && at.After(record.LastAccess) {
+			record.LastAccess = at
+			d.dirty = true
+		}
+	}
+}
+
+// Set writes the entry's body object and records it in the index. It reports
+// false if the entry is larger than the cache or could not be written. The object
+// is written and synced before the index lock is taken, so slow disk I/O does not
+// block readers.
+func (d *Disk) Set(entry *Entry) bool {
+	size := int64(len(entry.Body))
+	if size > d.maxBytes {
+		return false
+	}
+
+	record := &diskRecord{
+		Key:        entry.Key,
+		StatusCode: entry.StatusCode,
+		Header:     entry.Header,
+		Vary:       entry.Vary,
+		Size:       size,
+		StoredAt:   entry.StoredAt,
+		ExpiresAt:  entry.ExpiresAt,
+		LastAccess: d.now(),
+	}
+	if !entry.IsVariantIndex() {
+		sum := sha256.Sum256(entry.Body)
+		record.Object = hex.EncodeToString(sum[:])
+	}
+
+	d.mu.Lock()
+	stored := record.Object == "" || d.refs[record.Object] > 0
+	d.mu.Unlock()
+	if !stored && !d.storeObject(entry.Key, record.Object, entry.Body) {
+		return false
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if existing, ok := d.records[entry.Key]; ok {
+		d.remove(existing)
+	}
+	// The object may have been removed while the lock was released; rewrite it
+	// in that rare case rather than indexing a missing file
+	if record.Object != "" && d.refs[record.Object] == 0 {
+		if _, err := os.Stat(d.objectPath(record.Object)); err != nil && !d.storeObject(entry.Key, record.Object, entry.Body) {
+			return false
+		}
+	}
+
+	if record.Object != "" {
+		if d.refs[record.Object] == 0 {
+			d.bytes += size
+		}
+		d.refs[record.Object]++
+	}
+	d.records[entry.Key] = record
+	d.dirty = true
+
+	d.evict()
+	return true
+}
+
+// Delete removes the entry for key. It reports whether an entry was removed.
+func (d *Disk) Delete(key string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	record, ok := d.records[key]
+	if !ok {
+		return false
+	}
+	d.remove(record)
+	return true
+}
+
+// Len returns the number of entries in the index
+func (d *Disk) Len() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.records)
+}
+
+// Bytes returns the total size of the stored objects
+func (d *Disk) Bytes() int64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.bytes
+}
+
+// List returns the entries whose key starts with prefix, ordered by key
+func (d *Disk) List(prefix string) []EntryInfo {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var infos []EntryInfo
+	for key, record := range d.records {
+		if hasPrefix(key, prefix) {
+			infos = append(infos, EntryInfo{
+				Key:        record.Key,
+				StatusCode: record.StatusCode,
+				Size:       record.Size,
+				StoredAt:   record.StoredAt,
+				ExpiresAt:  record.ExpiresAt,
+				Vary:       record.Vary,
+			})
+		}
+	}
+	return sortInfos(infos)
+}
+
+// Stats returns the usage of the disk tier
+func (d *Disk) Stats() []TierStats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return []TierStats{{
+		Tier:      "disk",
+		Entries:   len(d.records),
+		Bytes:     d.bytes,
+		MaxBytes:  d.maxBytes,
+		Evictions: d.evictions,
+	}}
+}
+
+// remove drops a record and deletes its object once nothing references it; callers must hold the lock
+func (d *Disk) remove(record *diskRecord) {
+	delete(d.records, record.Key)
+	d.dirty = true
+
+	if record.Object == "" {
+		return
+	}
+	d.refs[record.Object]--
+	if d.refs[record.Object] > 0 {
+		return
+	}
+	delete(d.refs, record.Object)
+	d.bytes -= record.Size
+	os.Remove(d.objectPath(record.Object))
+}
+
+// evict removes entries until the objects fit in maxBytes, expired entries first
+// and then the least recently accessed; callers must hold the lock
+func (d *Disk) evict() {
+	if d.bytes <= d.maxBytes {
+		return
+	}
+	d.applyTouches()
+
+	now := d.now()
+	candidates := make([]*diskRecord, 0, len(d.records))
+	for _, record := range d.records {
+		if record.Object != "" {
+			candidates = append(candidates, record)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		expiredI, expiredJ := !now.Before(candidates[i].ExpiresAt), !now.Before(candidates[j].ExpiresAt)
+		if expiredI != expiredJ {
+			return expiredI
+		}
+		return candidates[i].LastAccess.Before(candidates[j].LastAccess)
+	})
+
+	evicted := 0
+	for _, record := range candidates {
+		if d.bytes <= d.maxBytes {
+			break
+		}
+		d.remove(record)
+		evicted++
+	}
+	d.evictions += int64(evicted)
+
+	logger.CacheLogger.Debug("Evicted disk cache entries", map[string]interface{}{
+		"evicted": evicted,
+		"bytes":   d.bytes,
+	})
+}
+
+// Flush writes the index to disk if it changed since the last flush
+func (d *Disk) Flush() error {
+	d.mu.Lock()
+	d.applyTouches()
+	if !d.dirty {
+		d.mu.Unlock()
+		return nil
+	}
+	index := diskIndex{Version: diskIndexVersion}
+	for _, record := range d.records {
+		copied := *record
+		index.Entries = append(index.Entries, &copied)
+	}
+	d.dirty = false
+	d.mu.Unlock()
+
+	data, err := json.Marshal(index)
+	if err != nil {
+		return fmt.Errorf("failed to encode cache index: %w", err)
+	}
+	if err := writeFileAtomic(filepath.Join(d.dir, diskIndexFile), data); err != nil {
+		d.mu.Lock()
+		d.dirty = true
+		d.mu.Unlock()
+		return fmt.Errorf("failed to write cache index: %w", err)
+	}
+	return nil
+}
+
+// Close stops the periodic flush and writes the index one last time
+func (d *Disk) Close() error {
+	d.stopOnce.Do(func() {
+		close(d.stopCh)
+	})
+	d.wg.Wait()
+	return d.Flush()
+}
+
+// flushLoop periodically flushes the index until the cache is closed
+func (d *Disk) flushLoop(interval time.Duration) {
+	defer d.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := d.Flush(); err != nil {
+				logger.CacheLogger.Error("Failed to flush disk cache index", err)
+			}
+		case <-d.stopCh:
+			return
+		}
+	}
+}
+
+// storeObject writes an object, logging failures
+func (d *Disk) storeObject(key, object string, body []byte) bool {
+	if err := d.writeObject(object, body); err != nil {
+		logger.CacheLogger.Error("Failed to write disk cache object", err, map[string]interface{}{
+			"key": key,
+		})
+		return false
+	}
+	return true
+}
+
+// writeObject stores a body under its content hash
+func (d *Disk) writeObject(object string, body []byte) error {
+	path := d.objectPath(object)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, body)
+}
+
+// objectPath returns the file path of an object, sharded by the first two hex digits
+func (d *Disk) objectPath(object string) string {
+	return filepath.Join(d.dir, diskObjectsDir, object[:2], object)
+}
+
+// writeFileAtomic writes data to a temporary file in the target directory, syncs it
+// and renames it into place, so readers see either the old or the new file
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+strings.TrimPrefix(filepath.Base(path), ".")+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	if err := os.Rename(tmpName, path); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+
+	// Persist the rename itself
+	if dirHandle, err := os.Open(dir); err == nil {
+		dirHandle.Sync()
+		dirHandle.Close()
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+package cache
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func openTestDisk(t *testing.T, dir string, maxBytes int64) *Disk {
+	t.Helper()
+
+	disk, err := OpenDisk(dir, maxBytes, 0)
+	if err != nil {
+		t.Fatalf("Failed to open disk cache: %v", err)
+	}
+	return disk
+}
+
+func TestDisk_PersistsAcrossRestarts(t *testing.T) {
+	dir := t.TempDir()
+	expires := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	disk := openTestDisk(t, dir, 1<<20)
+	disk.Set(&Entry{
+		Key:        "GET /api/v2/blocks/1",
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Etag": {`"v1"`}},
+		Body:       []byte(`{"height":1}`),
+		ExpiresAt:  expires,
+	})
+	disk.Set(&Entry{Key: "GET /api/v2/blocks/2", Vary: []string{"Accept-Encoding"}})
+	if err := disk.Close(); err != nil {
+		t.Fatalf("Failed to close disk cache: %v", err)
+	}
+
+	reopened := openTestDisk(t, dir, 1<<20)
+	defer reopened.Close()
+
+	entry, ok := reopened.Get("GET /api/v2/blocks/1")
+	if !ok {
+		t.Fatal("Expected entry to survive a restart")
+	}
+	if string(entry.Body) != `{"height":1}` || entry.ETag() != `"v1"` || !entry.ExpiresAt.Equal(expires) {
+		t.Errorf("Unexpected entry after restart: %+v", entry)
+	}
+	if index, ok := reopened.Get("GET /api/v2/blocks/2"); !ok || !index.IsVariantIndex() {
+		t.Errorf("Expected variant index to survive a restart, got %+v", index)
+	}
+}
+
+func TestDisk_DeduplicatesIdenticalBodies(t *testing.T) {
+	disk := openTestDisk(t, t.TempDir(), 1<<20)
+	defer disk.Close()
+
+	disk.Set(&Entry{Key: "a", StatusCode: http.StatusOK, Body: []byte("same")})
+	disk.Set(&Entry{Key: "b", StatusCode: http.StatusOK, Body: []byte("same")})
+	if disk.Bytes() != 4 {
+		t.Errorf("Expected shared object to be counted once, got %d bytes", disk.Bytes())
+	}
+
+	disk.Delete("a")
+	if entry, ok := disk.Get("b"); !ok || string(entry.Body) != "same" {
+		t.Error("Expected shared object to remain while still referenced")
+	}
+
+	// Replacing an entry with the same body keeps its object
+	disk.Set(&Entry{Key: "b", StatusCode: http.StatusOK, Body: []byte("same")})
+	if entry, ok := disk.Get("b"); !ok || string(entry.Body) != "same" {
+		t.Error("Expected replaced entry to be readable")
+	}
+}
+
+func TestDisk_EvictsExpiredThenLeastRecentlyUsed(t *testing.T) {
+	disk := openTestDisk(t, t.TempDir(), 250)
+	defer disk.Close()
+
+	now := time.Now()
+	disk.now = func() time.Time { return now }
+
+	body := func(c string) []byte { return []byte(strings.Repeat(c, 100)) }
+	disk.Set(&Entry{Key: "old", Body: body("a"), ExpiresAt: now.Add(time.Hour)})
+	now = now.Add(time.Second)
+	disk.Set(&Entry{Key: "expired", Body: body("b"), ExpiresAt: now})
+	now = now.Add(time.Second)
+	disk.Set(&Entry{Key: "new", Body: body("c"), ExpiresAt: now.Add(time.Hour)})
+
+	if _, ok := disk.Get("expired"); ok {
+		t.Error("Expected expired entry to be evicted first")
+	}
+
+	now = now.Add(time.Second)
+	disk.Get("old")
+	disk.Set(&Entry{Key: "newest", Body: body("d"), ExpiresAt: now.Add(time.Hour)})
+
+	if _, ok := disk.Get("new"); ok {
+		t.Error("Expected least recently used entry to be evicted")
+	}
+	if disk.Bytes() > 250 || disk.Len() != 2 {
+		t.Errorf("Expected 2 entries within the size limit, got %d entries and %d bytes", disk.Len(), disk.Bytes())
+	}
+}
+
+func TestDisk_RecoversFromCrash(t *testing.T) {
+	dir := t.TempDir()
+
+	disk := openTestDisk(t, dir, 1<<20)
+	disk.Set(&Entry{Key: "kept", Body: []byte("kept")})
+	disk.Set(&Entry{Key: "lost", Body: []byte("lost")})
+	lostObject := disk.records["lost"].Object
+	disk.Close()
+
+	// Simulate a crash: an object written after the last index flush, a leftover
+	// temporary file and an indexed object that never made it to disk
+	orphan := strings.Repeat("ab", 32)
+	os.MkdirAll(filepath.Join(dir, diskObjectsDir, "ab"), 0o755)
+	os.WriteFile(filepath.Join(dir, diskObjectsDir, "ab", orphan), []byte("unflushed"), 0o644)
+	os.WriteFile(filepath.Join(dir, diskObjectsDir, "ab", "."+orphan+".tmp-1"), []byte("part"), 0o644)
+	os.Remove(disk.objectPath(lostObject))
+
+	reopened := openTestDisk(t, dir, 1<<20)
+	defer reopened.Close()
+
+	if _, ok := reopened.Get("kept"); !ok {
+		t.Error("Expected intact entry to be loaded")
+	}
+	if _, ok := reopened.Get("lost"); ok {
+		t.Error("Expected entry with a missing object to be dropped")
+	}
+
+	var files []string
+	filepath.WalkDir(filepath.Join(dir, diskObjectsDir), func(path string, entry os.DirEntry, err error) error {
+		if err == nil && !entry.IsDir() {
+			files = append(files, entry.Name())
+		}
+		return nil
+	})
+	if len(files) != 1 {
+		t.Errorf("Expected orphaned and temporary files to be removed, got %v", files)
+	}
+}
+
+func TestDisk_DiscardsCorruptIndex(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(dir, 0o755)
+	os.WriteFile(filepath.Join(dir, diskIndexFile), []byte("{not json"), 0o644)
+
+	disk := openTestDisk(t, dir, 1<<20)
+	defer disk.Close()
+
+	if disk.Len() != 0 {
+		t.Errorf("Expected empty cache after a corrupt index, got %d entries", disk.Len())
+	}
+}
+
+func TestTiered_PromotesDiskHits(t *testing.T) {
+	disk := openTestDisk(t, t.TempDir(), 1<<20)
+	defer disk.Close()
+	memory := NewLRU(1 << 20)
+	tiered := NewTiered(memory, disk)
+
+	disk.Set(&Entry{Key: "a", Body: []byte("from disk")})
+
+	entry, ok := tiered.Get("a")
+	if !ok || string(entry.Body) != "from disk" {
+		t.Fatalf("Expected disk hit, got %+v", entry)
+	}
+	if _, ok := memory.Get("a"); !ok {
+		t.Error("Expected disk hit to be promoted into memory")
+	}
+
+	if !tiered.Delete("a") {
+		t.Error("Expected delete to remove the entry")
+	}
+	if _, ok := tiered.Get("a"); ok {
+		t.Error("Expected entry to be removed from both tiers")
+	}
+}
+
+func TestTiered_MemoryHitsKeepDiskEntriesWarm(t *testing.T) {
+	disk := openTestDisk(t, t.TempDir(), 250)
+	defer disk.Close()
+	tiered := NewTiered(NewLRU(1<<20), disk)
+
+	now := time.Now()
+	disk.now = func() time.Time { return now }
+
+	body := func(c string) []byte { return []byte(strings.Repeat(c, 100)) }
+	tiered.Set(&Entry{Key: "hot", Body: body("a"), ExpiresAt: now.Add(time.Hour)})
+	now = now.Add(time.Second)
+	tiered.Set(&Entry{Key: "cold", Body: body("b"), ExpiresAt: now.Add(time.Hour)})
+
+	// Served from memory only; the disk tier must still see the access
+	now = now.Add(time.Second)
+	if _, ok := tiered.Get("hot"); !ok {
+		t.Fatal("Expected memory hit")
+	}
+
+	now = now.Add(time.Second)
+	disk.Set(&Entry{Key: "third", Body: body("c"), ExpiresAt: now.Add(time.Hour)})
+
+	if _, ok := disk.Get("hot"); !ok {
+		t.Error("Expected the entry hit in memory to survive disk eviction")
+	}
+	if _, ok := disk.Get("cold"); ok {
+		t.Error("Expected the unused entry to be evicted from disk")
+	}
+}
@@ -0,0 +1,153 @@
+package cache
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Entry is a cached HTTP response. Entries are treated as immutable once stored;
+// updating an entry means storing a modified copy under the same key.
+type Entry struct {
+	Key        string      `json:"key"`
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+	StoredAt   time.Time   `json:"stored_at"`
+	ExpiresAt  time.Time   `json:"expires_at"`
+
+	// Vary is set on variant index entries: it lists the request headers the
+	// response varies on, and the response itself is stored under VariantKey.
+	Vary []string `json:"vary,omitempty"`
+}
+
+// IsVariantIndex reports whether the entry points to per-variant entries instead of holding a response
+func (e *Entry) IsVariantIndex() bool {
+	return len(e.Vary) > 0
+}
+
+// Fresh reports whether the entry can be served without revalidation
+func (e *Entry) Fresh(now time.Time) bool {
+	return now.Before(e.ExpiresAt)
+}
+
+// Age returns how long ago the entry was stored or last revalidated
+func (e *Entry) Age(now time.Time) time.Duration {
+	if age := now.Sub(e.StoredAt); age > 0 {
+		return age
+	}
+	return 0
+}
+
+// ETag returns the entity tag of the cached response, if any
+func (e *Entry) ETag() string {
+	return e.Header.Get("ETag")
+}
+
+// HasValidator reports whether the entry can be revalidated with a conditional request
+func (e *Entry) HasValidator() bool {
+	return e.ETag() != "" || e.Header.Get("Last-Modified") != ""
+}
+
+// Size returns the approximate memory footprint of the entry in bytes
+func (e *Entry) Size() int64 {
+	size := int64(len(e.Key) + len(e.Body))
+	for name, values := range e.Header {
+		for _, value := range values {
+			size += int64(len(name) + len(value))
+		}
+	}
+	for _, name := range e.Vary {
+		size += int64(len(name))
+	}
+	return size
+}
+
+// Key returns the base cache key for a request
+func Key(method, requestURI string) string {
+	return method + " " + requestURI
+}
+
+// VariantKey returns the cache key for the variant of baseKey selected by the request headers
+func VariantKey(baseKey string, vary []string, header http.Header) string {
+	var b strings.Builder
+	b.WriteString(baseKey)
+	for _, name := range vary {
+		b.WriteString("\n")
+		b.WriteString(name)
+		b.WriteString(":")
+		b.WriteString(strings.Join(header.Values(name), ","))
+	}
+	return b.String()
+}
+
+// ParseVary returns the sorted, canonicalized header names listed in the Vary header
+func ParseVary(header http.Header) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			if name != "*" {
+				name = http.CanonicalHeaderKey(name)
+			}
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseCacheControl parses a Cache-Control header into lower-cased directives and their values
+func ParseCacheControl(header http.Header) map[string]string {
+	directives := make(map[string]string)
+	for _, value := range header.Values("Cache-Control") {
+		for _, part := range strings.Split(value, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			name, arg, _ := strings.Cut(part, "=")
+			directives[strings.ToLower(strings.TrimSpace(name))] = strings.Trim(strings.TrimSpace(arg), `"`)
+		}
+	}
+	return directives
+}
+
+// MaxAge returns the shared-cache lifetime from parsed Cache-Control directives,
+// preferring s-maxage over max-age
+func MaxAge(directives map[string]string) (time.Duration, bool) {
+	for _, name := range []string{"s-maxage", "max-age"} {
+		if value, ok := directives[name]; ok {
+			seconds, err := strconv.Atoi(value)
+			if err != nil || seconds < 0 {
+				return 0, true
+			}
+			return time.Duration(seconds) * time.Second, true
+		}
+	}
+	return 0, false
+}
+
+// MatchETag reports whether an If-None-Match header value matches the entity tag using weak comparison
+func MatchETag(ifNoneMatch, etag string) bool {
+	if etag == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,134 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+)
+
+// LRU is an in-memory cache bounded by the total size of its entries.
+// The least recently used entries are evicted to make room for new ones.
+type LRU struct {
+	mu        sync.Mutex
+	maxBytes  int64
+	bytes     int64
+	ll        *list.List
+	items     map[string]*list.Element
+	evictions int64
+}
+
+// NewLRU creates an LRU cache holding at most maxBytes of entries
+func NewLRU(maxBytes int64) *LRU {
+	return &LRU{
+		maxBytes: maxBytes,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// Get returns the entry for key and marks it as recently used
+func (c *LRU) Get(key string) (*Entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.ll.MoveToFront(elem)
+	return elem.Value.(*Entry), true
+}
+
+// Set stores an entry, replacing any entry with the same key. It returns false
+// if the entry is larger than the whole cache and was not stored.
+func (c *LRU) Set(entry *Entry) bool {
+	size := entry.Size()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[entry.Key]; ok {
+		c.removeElement(elem)
+	}
+	if size > c.maxBytes {
+		return false
+	}
+
+	c.items[entry.Key] = c.ll.PushFront(entry)
+	c.bytes += size
+
+	for c.bytes > c.maxBytes {
+		c.removeElement(c.ll.Back())
+		c.evictions++
+	}
+	return true
+}
+
+// Delete removes the entry for key. It reports whether an entry was removed.
+func (c *LRU) Delete(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		return false
+	}
+	c.removeElement(elem)
+	return true
+}
+
+// Len returns the number of entries in the cache
+func (c *LRU) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+// Bytes returns the total size of the entries in the cache
+func (c *LRU) Bytes() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.bytes
+}
+
+// List returns the entries whose key starts with prefix, ordered by key
+func (c *LRU) List(prefix string) []EntryInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var infos []EntryInfo
+	for key, elem := range c.items {
+		if hasPrefix(key, prefix) {
+			infos = append(infos, elem.Value.(*Entry).info())
+		}
+	}
+	return sortInfos(infos)
+}
+
+// Stats returns the usage of the cache
+func (c *LRU) Stats() []TierStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return []TierStats{{
+		Tier:      "memory",
+		Entries:   c.ll.Len(),
+		Bytes:     c.bytes,
+		MaxBytes:  c.maxBytes,
+		Evictions: c.evictions,
+	}}
+}
+
+// Evictions returns the number of entries evicted to stay within the size limit
+func (c *LRU) Evictions() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.evictions
+}
+
+// removeElement unlinks an entry; callers must hold the lock
+func (c *LRU) removeElement(elem *list.Element) {
+	entry := elem.Value.(*Entry)
+	c.ll.Remove(elem)
+	delete(c.items, entry.Key)
+	c.bytes -= entry.Size()
+}
@@ -0,0 +1,122 @@
+package cache
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func newTestEntry(key string, bodySize int) *Entry {
+	return &Entry{
+		Key:        key,
+		StatusCode: http.StatusOK,
+		Header:     http.Header{},
+		Body:       []byte(strings.Repeat("x", bodySize)),
+	}
+}
+
+func TestLRU_EvictsLeastRecentlyUsed(t *testing.T) {
+	lru := NewLRU(300)
+
+	lru.Set(newTestEntry("a", 95))
+	lru.Set(newTestEntry("b", 95))
+	lru.Set(newTestEntry("c", 95))
+
+	// Touch "a" so "b" becomes the least recently used entry
+	if _, ok := lru.Get("a"); !ok {
+		t.Fatal("Expected entry a to be cached")
+	}
+	lru.Set(newTestEntry("d", 95))
+
+	if _, ok := lru.Get("b"); ok {
+		t.Error("Expected entry b to be evicted")
+	}
+	for _, key := range []string{"a", "c", "d"} {
+		if _, ok := lru.Get(key); !ok {
+			t.Errorf("Expected entry %s to be cached", key)
+		}
+	}
+	if lru.Bytes() != 288 {
+		t.Errorf("Expected 288 bytes, got %d", lru.Bytes())
+	}
+	if lru.Evictions() != 1 {
+		t.Errorf("Expected 1 eviction, got %d", lru.Evictions())
+	}
+}
+
+func TestLRU_ReplaceAndDelete(t *testing.T) {
+	lru := NewLRU(1000)
+
+	lru.Set(newTestEntry("a", 100))
+	lru.Set(newTestEntry("a", 10))
+	if lru.Len() != 1 || lru.Bytes() != 11 {
+		t.Errorf("Expected replacement to update accounting, got %d entries and %d bytes", lru.Len(), lru.Bytes())
+	}
+
+	if !lru.Delete("a") || lru.Delete("a") {
+		t.Error("Expected delete to remove the entry exactly once")
+	}
+	if lru.Len() != 0 || lru.Bytes() != 0 {
+		t.Errorf("Expected empty cache, got %d entries and %d bytes", lru.Len(), lru.Bytes())
+	}
+}
+
+func TestLRU_RejectsOversizedEntry(t *testing.T) {
+	lru := NewLRU(50)
+
+	if lru.Set(newTestEntry("big", 100)) {
+		t.Error("Expected entry larger than the cache to be rejected")
+	}
+	if lru.Len() != 0 {
+		t.Errorf("Expected empty cache, got %d entries", lru.Len())
+	}
+}
+
+func TestVariantKeyAndParseVary(t *testing.T) {
+	header := http.Header{}
+	header.Add("Vary", "accept-encoding, Accept")
+	header.Add("Vary", "Accept-Encoding")
+
+	vary := ParseVary(header)
+	if strings.Join(vary, ",") != "Accept,Accept-Encoding" {
+		t.Fatalf("Expected sorted canonical vary names, got %v", vary)
+	}
+
+	gzip := http.Header{"Accept-Encoding": {"gzip"}}
+	identity := http.Header{}
+	if VariantKey("GET /a", vary, gzip) == VariantKey("GET /a", vary, identity) {
+		t.Error("Expected different variant keys for different Accept-Encoding values")
+	}
+}
+
+func TestMatchETag(t *testing.T) {
+	tests := []struct {
+		ifNoneMatch string
+		etag        string
+		expected    bool
+	}{
+		{`"abc"`, `"abc"`, true},
+		{`W/"abc"`, `"abc"`, true},
+		{`"x", "abc"`, `W/"abc"`, true},
+		{`*`, `"abc"`, true},
+		{`"x"`, `"abc"`, false},
+		{`"abc"`, ``, false},
+	}
+
+	for _, tt := range tests {
+		if result := MatchETag(tt.ifNoneMatch, tt.etag); result != tt.expected {
+			t.Errorf("MatchETag(%q, %q) = %v, expected %v", tt.ifNoneMatch, tt.etag, result, tt.expected)
+		}
+	}
+}
+
+func TestMaxAge(t *testing.T) {
+	header := http.Header{"Cache-Control": {"public, max-age=60, s-maxage=30"}}
+	if maxAge, ok := MaxAge(ParseCacheControl(header)); !ok || maxAge.Seconds() != 30 {
+		t.Errorf("Expected s-maxage to take precedence, got %v", maxAge)
+	}
+
+	if _, ok := MaxAge(ParseCacheControl(http.Header{})); ok {
+		t.Error("Expected no max-age without Cache-Control")
+	}
+}
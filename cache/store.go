@@ -0,0 +1,126 @@
+package cache
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// Store is a cache of HTTP responses keyed by cache key
+type Store interface {
+	Get(key string) (*Entry, bool)
+	Set(entry *Entry) bool
+	Delete(key string) bool
+	List(prefix string) []EntryInfo
+	Stats() []TierStats
+}
+
+// EntryInfo describes a cached entry without its body
+type EntryInfo struct {
+	Key        string    `json:"key"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Size       int64     `json:"size"`
+	StoredAt   time.Time `json:"stored_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Vary       []string  `json:"vary,omitempty"`
+}
+
+// TierStats reports the usage of one cache tier
+type TierStats struct {
+	Tier      string `json:"tier"`
+	Entries   int    `json:"entries"`
+	Bytes     int64  `json:"bytes"`
+	MaxBytes  int64  `json:"max_bytes"`
+	Evictions int64  `json:"evictions"`
+}
+
+// info returns the metadata of an entry
+func (e *Entry) info() EntryInfo {
+	return EntryInfo{
+		Key:        e.Key,
+		StatusCode: e.StatusCode,
+		Size:       e.Size(),
+		StoredAt:   e.StoredAt,
+		ExpiresAt:  e.ExpiresAt,
+		Vary:       e.Vary,
+	}
+}
+
+// sortInfos orders entry metadata by key
+func sortInfos(infos []EntryInfo) []EntryInfo {
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Key < infos[j].Key
+	})
+	return infos
+}
+
+// hasPrefix reports whether key starts with prefix
+func hasPrefix(key, prefix string) bool {
+	return prefix == "" || strings.HasPrefix(key, prefix)
+}
+
+// Tiered is an in-memory LRU backed by a persistent disk tier. Reads are served
+// from memory when possible and disk hits are promoted into memory.
+type Tiered struct {
+	memory *LRU
+	disk   *Disk
+}
+
+// NewTiered combines an in-memory cache with a disk cache
+func NewTiered(memory *LRU, disk *Disk) *Tiered {
+	return &Tiered{
+		memory: memory,
+		disk:   disk,
+	}
+}
+
+// Get returns the entry for key from memory, falling back to disk. Memory hits
+// are recorded on the disk tier so its LRU order follows real usage.
+func (t *Tiered) Get(key string) (*Entry, bool) {
+	if entry, ok := t.memory.Get(key); ok {
+		t.disk.Touch(key)
+		return entry, true
+	}
+
+	entry, ok := t.disk.Get(key)
+	if !ok {
+		return nil, false
+	}
+	t.memory.Set(entry)
+	return entry, true
+}
+
+// Set stores the entry in both tiers. It reports whether either tier stored it.
+func (t *Tiered) Set(entry *Entry) bool {
+	inMemory := t.memory.Set(entry)
+	onDisk := t.disk.Set(entry)
+	return inMemory || onDisk
+}
+
+// Delete removes the entry from both tiers
+func (t *Tiered) Delete(key string) bool {
+	inMemory := t.memory.Delete(key)
+	onDisk := t.disk.Delete(key)
+	return inMemory || onDisk
+}
+
+// List returns the entries in either tier whose key starts with prefix, preferring
+// the in-memory copy when an entry is in both
+func (t *Tiered) List(prefix string) []EntryInfo {
+	infos := t.memory.List(prefix)
+	seen := make(map[string]bool, len(infos))
+	for _, info := range infos {
+		seen[info.Key] = true
+	}
+	for _, info := range t.disk.List(prefix) {
+		if !seen[info.Key] {
+			infos = append(infos, info)
+		}
+	}
+	return sortInfos(infos)
+}
+
+// Stats returns the usage of both tiers
+func (t *Tiered) Stats() []TierStats {
+	return append(t.memory.Stats(), t.disk.Stats()...)
+}
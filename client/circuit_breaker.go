@@ -0,0 +1,284 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go-api-proxy/config"
+	"go-api-proxy/logger"
+)
+
+// CircuitState represents the state of a circuit breaker
+type CircuitState int
+
+const (
+	CircuitClosed CircuitState = iota
+	CircuitOpen
+	CircuitHalfOpen
+)
+
+// String returns the string representation of the circuit state
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// CircuitBreakerStatus is a point-in-time view of a circuit breaker for health reporting
+type CircuitBreakerStatus struct {
+	State             string  `json:"state"`
+	Requests          int     `json:"requests"`
+	Failures          int     `json:"failures"`
+	FailureRatio      float64 `json:"failure_ratio"`
+	RetryAfterSeconds int     `json:"retry_after_seconds,omitempty"`
+}
+
+// CircuitBreaker stops sending requests to a failing backend. It opens when the
+// failure ratio within the current window crosses the threshold, rejects requests
+// during the cool-down, then lets a limited number of probe requests through in
+// the half-open state before closing again.
+type CircuitBreaker struct {
+	name             string
+	failureRatio     float64
+	minRequests      int
+	window           time.Duration
+	cooldown         time.Duration
+	halfOpenRequests int
+
+	mu               sync.Mutex
+	state            CircuitState
+	requests         int
+	failures         int
+	windowStart      time.Time
+	openedAt         time.Time
+	halfOpenInFlight int
+	generation       uint64
+	now              func() time.Time
+}
+
+// CircuitTicket identifies a request admitted by Allow. Its outcome only counts
+// while the breaker is still in the state the request was admitted in, so late
+// results from before a transition cannot close or reopen the circuit.
+type CircuitTicket struct {
+	generation uint64
+	probe      bool
+}
+
+// NewCircuitBreaker creates a circuit breaker from configuration. It returns nil
+// when the failure ratio is not configured, which disables the breaker.
+func NewCircuitBreaker(name string, cfg *config.Config) *CircuitBreaker {
+	if cfg.CircuitFailureRatio <= 0 {
+		return nil
+	}
+
+	cb := &CircuitBreaker{
+		name:             name,
+		failureRatio:     cfg.CircuitFailureRatio,
+		minRequests:      cfg.CircuitMinRequests,
+		window:           cfg.CircuitWindow,
+		cooldown:         cfg.CircuitCooldown,
+		halfOpenRequests: cfg.CircuitHalfOpenRequests,
+		now:              time.Now,
+	}
+
+	if cb.minRequests < 1 {
+		cb.minRequests = 1
+	}
+	if cb.halfOpenRequests < 1 {
+		cb.halfOpenRequests = 1
+	}
+	cb.windowStart = cb.now()
+
+	return cb
+}
+
+// Allow reports whether a request may be sent. It returns a CircuitOpenError while the
+// circuit is open; otherwise the ticket must be passed to RecordResult or Cancel.
+func (cb *CircuitBreaker) Allow() (CircuitTicket, error) {
+	if cb == nil {
+		return CircuitTicket{}, nil
+	}
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	now := cb.now()
+	switch cb.state {
+	case CircuitOpen:
+		if remaining := cb.cooldown - now.Sub(cb.openedAt); remaining > 0 {
+			return CircuitTicket{}, &CircuitOpenError{Backend: cb.name, RetryAfter: remaining}
+		}
+		cb.transition(CircuitHalfOpen, now)
+		fallthrough
+	case CircuitHalfOpen:
+		if cb.halfOpenInFlight >= cb.halfOpenRequests {
+			return CircuitTicket{}, &CircuitOpenError{Backend: cb.name, RetryAfter: time.Second}
+		}
+		cb.halfOpenInFlight++
+		return CircuitTicket{generation: cb.generation, probe: true}, nil
+	default:
+		if cb.window > 0 && now.Sub(cb.windowStart) >= cb.window {
+			cb.resetCounts(now)
+		}
+	}
+
+	return CircuitTicket{generation: cb.generation}, nil
+}
+
+// RecordResult records the outcome of a request that was allowed through. Results for
+// tickets issued before the last state change are ignored.
+func (cb *CircuitBreaker) RecordResult(ticket CircuitTicket, success bool) {
+	if cb == nil {
+		return
+	}
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if ticket.generation != cb.generation {
+		return
+	}
+
+	now := cb.now()
+	switch cb.state {
+	case CircuitHalfOpen:
+		if !ticket.probe {
+			return
+		}
+		cb.halfOpenInFlight--
+		if success {
+			cb.transition(CircuitClosed, now)
+		} else {
+			cb.transition(CircuitOpen, now)
+		}
+	case CircuitClosed:
+		cb.requests++
+		if !success {
+			cb.failures++
+		}
+		if cb.requests >= cb.minRequests && float64(cb.failures)/float64(cb.requests) >= cb.failureRatio {
+			cb.transition(CircuitOpen, now)
+		}
+	}
+}
+
+// Cancel releases a request slot without recording an outcome, e.g. when the client went away
+func (cb *CircuitBreaker) Cancel(ticket CircuitTicket) {
+	if cb == nil {
+		return
+	}
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if ticket.probe && ticket.generation == cb.generation && cb.state == CircuitHalfOpen {
+		cb.halfOpenInFlight--
+	}
+}
+
+// Status returns the current state of the circuit breaker
+func (cb *CircuitBreaker) Status() *CircuitBreakerStatus {
+	if cb == nil {
+		return nil
+	}
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	status := &CircuitBreakerStatus{
+		State:    cb.state.String(),
+		Requests: cb.requests,
+		Failures: cb.failures,
+	}
+	if cb.requests > 0 {
+		status.FailureRatio = float64(cb.failures) / float64(cb.requests)
+	}
+	if cb.state == CircuitOpen {
+		if remaining := cb.cooldown - cb.now().Sub(cb.openedAt); remaining > 0 {
+			status.RetryAfterSeconds = retryAfterSeconds(remaining)
+		}
+	}
+	return status
+}
+
+// State returns the current circuit state
+func (cb *CircuitBreaker) State() CircuitState {
+	if cb == nil {
+		return CircuitClosed
+	}
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.state
+}
+
+// transition moves the breaker to a new state; callers must hold the lock
+func (cb *CircuitBreaker) transition(state CircuitState, now time.Time) {
+	if cb.state == state {
+		return
+	}
+
+	logger.ClientLogger.Warn("Circuit breaker state changed", map[string]interface{}{
+		"backend":  cb.name,
+		"from":     cb.state.String(),
+		"to":       state.String(),
+		"requests": cb.requests,
+		"failures": cb.failures,
+	})
+
+	cb.state = state
+	cb.generation++
+	cb.halfOpenInFlight = 0
+	if state == CircuitOpen {
+		cb.openedAt = now
+	}
+	if state == CircuitClosed {
+		cb.resetCounts(now)
+	}
+}
+
+// resetCounts starts a new counting window; callers must hold the lock
+func (cb *CircuitBreaker) resetCounts(now time.Time) {
+	cb.requests = 0
+	cb.failures = 0
+	cb.windowStart = now
+}
+
+// CircuitOpenError is returned when a request is rejected by an open circuit breaker
+type CircuitOpenError struct {
+	Backend    string
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("circuit breaker open for %s, retry after %s", e.Backend, e.RetryAfter)
+}
+
+// RetryAfterSeconds returns the Retry-After value in whole seconds (at least 1)
+func (e *CircuitOpenError) RetryAfterSeconds() int {
+	return retryAfterSeconds(e.RetryAfter)
+}
+
+// IsCircuitOpenError checks if an error is a circuit breaker rejection
+func IsCircuitOpenError(err error) bool {
+	var circuitErr *CircuitOpenError
+	return errors.As(err, &circuitErr)
+}
+
+// retryAfterSeconds rounds a duration up to whole seconds, with a minimum of one
+func retryAfterSeconds(d time.Duration) int {
+	seconds := int((d + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		return 1
+	}
+	return seconds
+}
@@ -0,0 +1,198 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go-api-proxy/config"
+)
+
+func newTestCircuitBreaker(now *time.Time) *CircuitBreaker {
+	cb := NewCircuitBreaker("test-backend", &config.Config{
+		CircuitFailureRatio:     0.5,
+		CircuitMinRequests:      4,
+		CircuitWindow:           time.Minute,
+		CircuitCooldown:         10 * time.Second,
+		CircuitHalfOpenRequests: 1,
+	})
+	cb.now = func() time.Time { return *now }
+	return cb
+}
+
+// attempt admits a request through the breaker and records its outcome
+func attempt(cb *CircuitBreaker, success bool) {
+	ticket, _ := cb.Allow()
+	cb.RecordResult(ticket, success)
+}
+
+func TestCircuitBreaker_Transitions(t *testing.T) {
+	now := time.Now()
+	cb := newTestCircuitBreaker(&now)
+
+	// Below the minimum request count the breaker stays closed
+	for i := 0; i < 3; i++ {
+		ticket, err := cb.Allow()
+		if err != nil {
+			t.Fatalf("Expected request %d to be allowed, got %v", i, err)
+		}
+		cb.RecordResult(ticket, false)
+	}
+	if cb.State() != CircuitClosed {
+		t.Fatalf("Expected closed state below min requests, got %s", cb.State())
+	}
+
+	// Crossing the failure ratio opens the circuit
+	attempt(cb, false)
+	if cb.State() != CircuitOpen {
+		t.Fatalf("Expected open state, got %s", cb.State())
+	}
+
+	_, err := cb.Allow()
+	if !IsCircuitOpenError(err) {
+		t.Fatalf("Expected CircuitOpenError while open, got %v", err)
+	}
+	if retryAfter := err.(*CircuitOpenError).RetryAfterSeconds(); retryAfter != 10 {
+		t.Errorf("Expected Retry-After of 10 seconds, got %d", retryAfter)
+	}
+
+	// After the cool-down a single probe is let through
+	now = now.Add(11 * time.Second)
+	probe, err := cb.Allow()
+	if err != nil {
+		t.Fatalf("Expected probe request to be allowed, got %v", err)
+	}
+	if cb.State() != CircuitHalfOpen {
+		t.Fatalf("Expected half-open state, got %s", cb.State())
+	}
+	if _, err := cb.Allow(); !IsCircuitOpenError(err) {
+		t.Errorf("Expected second concurrent probe to be rejected, got %v", err)
+	}
+
+	// A failed probe re-opens the circuit
+	cb.RecordResult(probe, false)
+	if cb.State() != CircuitOpen {
+		t.Fatalf("Expected open state after failed probe, got %s", cb.State())
+	}
+
+	// A successful probe closes it
+	now = now.Add(11 * time.Second)
+	attempt(cb, true)
+	if cb.State() != CircuitClosed {
+		t.Fatalf("Expected closed state after successful probe, got %s", cb.State())
+	}
+	if status := cb.Status(); status.Requests != 0 || status.Failures != 0 {
+		t.Errorf("Expected counters to reset on close, got %+v", status)
+	}
+}
+
+func TestCircuitBreaker_WindowResetsCounts(t *testing.T) {
+	now := time.Now()
+	cb := newTestCircuitBreaker(&now)
+
+	for i := 0; i < 3; i++ {
+		attempt(cb, false)
+	}
+
+	now = now.Add(2 * time.Minute)
+	attempt(cb, false)
+
+	if cb.State() != CircuitClosed {
+		t.Errorf("Expected failures from an expired window to be forgotten, got %s", cb.State())
+	}
+}
+
+func TestCircuitBreaker_IgnoresResultsFromBeforeTransition(t *testing.T) {
+	now := time.Now()
+	cb := newTestCircuitBreaker(&now)
+
+	// A slow request admitted while closed is still in flight when the circuit opens
+	slow, _ := cb.Allow()
+	for i := 0; i < 4; i++ {
+		attempt(cb, false)
+	}
+	if cb.State() != CircuitOpen {
+		t.Fatalf("Expected open state, got %s", cb.State())
+	}
+
+	now = now.Add(11 * time.Second)
+	probe, err := cb.Allow()
+	if err != nil {
+		t.Fatalf("Expected probe request to be allowed, got %v", err)
+	}
+
+	// Its late success must neither close the circuit nor free the probe slot
+	cb.RecordResult(slow, true)
+	cb.Cancel(slow)
+	if cb.State() != CircuitHalfOpen {
+		t.Fatalf("Expected late result to be ignored, got %s", cb.State())
+	}
+	if _, err := cb.Allow(); !IsCircuitOpenError(err) {
+		t.Errorf("Expected the probe slot to stay taken, got %v", err)
+	}
+
+	cb.RecordResult(probe, true)
+	if cb.State() != CircuitClosed {
+		t.Errorf("Expected the probe result to close the circuit, got %s", cb.State())
+	}
+
+	// Results from the half-open period are ignored once closed
+	cb.RecordResult(probe, false)
+	if status := cb.Status(); status.Requests != 0 {
+		t.Errorf("Expected stale probe result to be ignored, got %+v", status)
+	}
+}
+
+func TestCircuitBreaker_Disabled(t *testing.T) {
+	cb := NewCircuitBreaker("test-backend", &config.Config{})
+	if cb != nil {
+		t.Fatal("Expected nil breaker when failure ratio is not configured")
+	}
+	ticket, err := cb.Allow()
+	if err != nil {
+		t.Errorf("Expected disabled breaker to allow requests, got %v", err)
+	}
+	cb.RecordResult(ticket, false)
+	if cb.Status() != nil {
+		t.Error("Expected nil status for disabled breaker")
+	}
+}
+
+func TestProxyRequest_CircuitOpenFailsFast(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := NewHTTPClient(&config.Config{
+		BackendHost:         server.URL,
+		Timeout:             5 * time.Second,
+		CircuitFailureRatio: 0.5,
+		CircuitMinRequests:  2,
+		CircuitCooldown:     time.Minute,
+	})
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.ProxyRequest(context.Background(), httptest.NewRequest("GET", "/stats", nil), "/stats")
+		if err != nil {
+			t.Fatalf("Expected backend response on request %d, got %v", i, err)
+		}
+		resp.Body.Close()
+	}
+
+	_, err := client.ProxyRequest(context.Background(), httptest.NewRequest("GET", "/stats", nil), "/stats")
+	if !IsCircuitOpenError(err) {
+		t.Fatalf("Expected CircuitOpenError, got %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("Expected backend to receive 2 requests, got %d", calls)
+	}
+	if status := client.CircuitBreakerStatus(); status == nil || status.State != "open" {
+		t.Errorf("Expected open circuit status, got %+v", status)
+	}
+}
@@ -11,40 +11,104 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	"go-api-proxy/config"
 	"go-api-proxy/logger"
 	"go-api-proxy/models"
+	"go-api-proxy/tlsutil"
 )
 
 // HTTPClient wraps the standard HTTP client with proxy-specific functionality
 type HTTPClient struct {
 	client      *http.Client
 	backendURL  string
+	rootURL     string
 	config      *config.Config
+	retryPolicy *RetryPolicy
+	breaker     *CircuitBreaker
+	standby     *CircuitBreaker
+	pool        *UpstreamPool
+	failover    *Failover
+	credentials *Credentials
 }
 
 // NewHTTPClient creates a new HTTP client configured for backend communication
 func NewHTTPClient(cfg *config.Config) *HTTPClient {
+	return NewHTTPClientWithTLS(cfg, nil)
+}
+
+// NewHTTPClientWithTLS creates an HTTP client that connects to the backend with the
+// given TLS settings, such as a client certificate and a private CA. A nil
+// backendTLS uses the standard library defaults.
+func NewHTTPClientWithTLS(cfg *config.Config, backendTLS *tlsutil.ClientTLS) *HTTPClient {
+	transport := &http.Transport{
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 10,
+		IdleConnTimeout:     90 * time.Second,
+	}
+	if backendTLS != nil {
+		transport.DialTLSContext = backendTLS.DialTLSContext
+		transport.ForceAttemptHTTP2 = true
+	}
+	
 	return &HTTPClient{
 		client: &http.Client{
-			Timeout: cfg.Timeout,
-			Transport: &http.Transport{
-				MaxIdleConns:        100,
-				MaxIdleConnsPerHost: 10,
-				IdleConnTimeout:     90 * time.Second,
-			},
+			Timeout:   cfg.Timeout,
+			Transport: transport,
 		},
-		backendURL: cfg.GetBackendAPIURL(),
-		config:     cfg,
+		backendURL:  cfg.GetBackendAPIURL(),
+		rootURL:     strings.TrimSuffix(cfg.BackendHost, "/"),
+		config:      cfg,
+		retryPolicy: NewRetryPolicy(cfg),
+		breaker:     NewCircuitBreaker(cfg.GetBackendAPIURL(), cfg),
+		standby:     newStandbyBreaker(cfg),
+		pool:        NewUpstreamPool(cfg, transport),
+		failover:    NewFailover(cfg, transport),
 	}
 }
 
+// newStandbyBreaker creates the failover secondary's own circuit breaker, so an open
+// primary breaker does not also reject the traffic that failed over
+func newStandbyBreaker(cfg *config.Config) *CircuitBreaker {
+	if cfg.SecondaryBackendHost == "" {
+		return nil
+	}
+	return NewCircuitBreaker(cfg.SecondaryBackendHost, cfg)
+}
+
+// StartHealthChecks starts active health probing of the upstream pool and the failover primary
+func (c *HTTPClient) StartHealthChecks() {
+	c.pool.Start()
+	c.failover.Start()
+}
+
+// StopHealthChecks stops active health probing of the upstream pool and the failover primary
+func (c *HTTPClient) StopHealthChecks() {
+	c.pool.Stop()
+	c.failover.Stop()
+}
+
+// FailoverStatus returns the primary/secondary failover state, or nil if failover is not configured
+func (c *HTTPClient) FailoverStatus() *FailoverStatus {
+	return c.failover.Status()
+}
+
+// UpstreamStatus returns the health of each upstream backend instance, or nil without a pool
+func (c *HTTPClient) UpstreamStatus() []UpstreamStatus {
+	return c.pool.Status()
+}
+
+// CircuitBreakerStatus returns the backend circuit breaker state, or nil if the breaker is disabled
+func (c *HTTPClient) CircuitBreakerStatus() *CircuitBreakerStatus {
+	return c.breaker.Status()
+}
+
 // ProxyRequest forwards an HTTP request to the backend API
 func (c *HTTPClient) ProxyRequest(ctx context.Context, originalReq *http.Request, endpoint string) (*http.Response, error) {
 	// Construct the full backend URL
-	targetURL := c.backendURL + endpoint
+	targetURL := c.getBackendURL(ctx) + endpoint
 	
 	requestID := getRequestIDFromContext(ctx)
 	clientLogger := logger.ClientLogger.WithRequestID(requestID)
@@ -55,17 +119,22 @@ func (c *HTTPClient) ProxyRequest(ctx context.Context, originalReq *http.Request
 		"target_url": targetURL,
 	})
 	
-	// Create new request with the same method and body
+	retryable := IsIdempotent(originalReq) && c.retryPolicy.MaxAttempts > 1
+	
+	// Stream the original body to the backend instead of buffering it. Bodies of
+	// retryable requests are buffered (within the route's size limit) so they can be replayed.
 	var body io.Reader
-	if originalReq.Body != nil {
-		bodyBytes, err := io.ReadAll(originalReq.Body)
-		if err != nil {
-			clientLogger.Error("Failed to read request body", err)
-			return nil, fmt.Errorf("failed to read request body: %w", err)
+	buffered := false
+	if originalReq.Body != nil && originalReq.Body != http.NoBody {
+		body = &clientBody{r: originalReq.Body}
+		if retryable {
+			bodyBytes, err := io.ReadAll(body)
+			if err != nil {
+				return nil, c.clientRequestError(err, targetURL, clientLogger)
+			}
+			body = bytes.NewReader(bodyBytes)
+			buffered = true
 		}
-		body = bytes.NewReader(bodyBytes)
-		// Reset original request body for potential reuse
-		originalReq.Body = io.NopCloser(bytes.NewReader(bodyBytes))
 	}
 	
 	req, err := http.NewRequestWithContext(ctx, originalReq.Method, targetURL, body)
@@ -74,18 +143,37 @@ func (c *HTTPClient) ProxyRequest(ctx context.Context, originalReq *http.Request
 		return nil, fmt.Errorf("failed to create backend request: %w", err)
 	}
 	
+	// Preserve the client's framing: a known length is sent as Content-Length,
+	// an unknown length (-1) makes the transport use chunked encoding
+	if body != nil && !buffered {
+		req.ContentLength = originalReq.ContentLength
+	}
+	
 	// Forward headers from original request
 	c.forwardHeaders(originalReq, req)
 	
 	// Make the request to backend
 	start := time.Now()
-	resp, err := c.client.Do(req)
+	resp, attempts, err := c.doWithRetry(ctx, req, endpoint, retryable, clientLogger)
 	duration := time.Since(start)
 	
 	if err != nil {
+		if IsRequestError(err) {
+			return nil, c.clientRequestError(err, targetURL, clientLogger)
+		}
+		
+		if IsCircuitOpenError(err) {
+			clientLogger.Warn("Backend request rejected by circuit breaker", map[string]interface{}{
+				"target_url": targetURL,
+				"attempts":   attempts,
+			})
+			return nil, err
+		}
+		
 		clientLogger.Error("Backend request failed", err, map[string]interface{}{
 			"target_url": targetURL,
 			"duration":   duration.String(),
+			"attempts":   attempts,
 		})
 		return nil, &NetworkError{
 			Operation: "backend_request",
@@ -97,15 +185,278 @@ func (c *HTTPClient) ProxyRequest(ctx context.Context, originalReq *http.Request
 	clientLogger.Info("Backend request completed", map[string]interface{}{
 		"status_code": resp.StatusCode,
 		"duration":    duration.String(),
-		"target_url":  targetURL,
+		"target_url":  c.credentialsFor(ctx).redact(resp.Request.URL.String()),
+		"attempts":    attempts,
 	})
 	
 	return resp, nil
 }
 
+// Backend labels reported in X-Upstream for single-backend and per-route backend requests
+const (
+	upstreamDefault = BackendPrimary
+	upstreamRoute   = "route"
+)
+
+// attemptTarget records which pool upstream or failover backend an attempt was sent to
+type attemptTarget struct {
+	upstream *Upstream
+	role     string
+	routed   bool
+}
+
+// label returns the name reported to clients for the target. Backend hosts are never
+// exposed; pool upstreams are numbered in BACKEND_HOSTS order.
+func (t attemptTarget) label() string {
+	switch {
+	case t.upstream != nil:
+		return t.upstream.Name
+	case t.role != "":
+		return t.role
+	case t.routed:
+		return upstreamRoute
+	}
+	return upstreamDefault
+}
+
+// breakerFor returns the circuit breaker guarding the target
+func (c *HTTPClient) breakerFor(target attemptTarget) *CircuitBreaker {
+	if target.role == BackendSecondary {
+		return c.standby
+	}
+	return c.breaker
+}
+
+// recordResult reports an attempt's outcome to the circuit breaker, the upstream pool and failover.
+// Network errors and 5xx responses count as failures. Cancellations by the caller and failures
+// of the client's own request, such as an oversized or aborted upload, are not counted.
+func (c *HTTPClient) recordResult(ctx context.Context, ticket CircuitTicket, target attemptTarget, resp *http.Response, err error) {
+	breaker := c.breakerFor(target)
+	if err != nil && (ctx.Err() != nil || IsRequestError(err)) {
+		breaker.Cancel(ticket)
+		return
+	}
+	
+	success := err == nil && resp.StatusCode < http.StatusInternalServerError
+	breaker.RecordResult(ticket, success)
+	
+	if target.upstream != nil {
+		errMsg := ""
+		if err != nil {
+			errMsg = err.Error()
+		} else if !success {
+			errMsg = "backend returned " + resp.Status
+		}
+		c.pool.RecordResult(target.upstream, success, errMsg)
+	}
+	
+	if target.role != "" {
+		c.failover.RecordResult(target.role, success)
+	}
+}
+
+// selectUpstream picks the backend for the attempt and points the request at it: a pool
+// upstream when balancing, otherwise the active failover backend. Endpoints are relative
+// to the backend's API URL, or to its root for routes marked with WithBackendRoot.
+// Requests routed to an explicit per-route backend bypass both.
+func (c *HTTPClient) selectUpstream(ctx context.Context, req *http.Request, endpoint string) (attemptTarget, error) {
+	if hasTargetBackend(ctx) {
+		return attemptTarget{routed: true}, nil
+	}
+	
+	var target attemptTarget
+	var baseURL string
+	switch {
+	case c.pool != nil:
+		target.upstream = c.pool.Next()
+		baseURL = target.upstream.URL
+	case c.failover != nil:
+		target.role, baseURL = c.failover.Current()
+	default:
+		return attemptTarget{}, nil
+	}
+	if err := setTargetURL(ctx, req, baseURL, endpoint); err != nil {
+		if target.upstream != nil {
+			target.upstream.Done()
+		}
+		return attemptTarget{}, err
+	}
+	return target, nil
+}
+
+// setTargetURL sends the request to the endpoint on a backend base URL
+func setTargetURL(ctx context.Context, req *http.Request, baseURL, endpoint string) error {
+	if !isBackendRoot(ctx) {
+		baseURL += "/api/v2"
+	}
+	
+	targetURL, err := url.Parse(baseURL + endpoint)
+	if err != nil {
+		return fmt.Errorf("failed to build upstream URL: %w", err)
+	}
+	req.URL = targetURL
+	req.Host = targetURL.Host
+	return nil
+}
+
+// clientRequestError converts a failure caused by the client's request, such as an unreadable
+// body, into the matching error
+func (c *HTTPClient) clientRequestError(err error, targetURL string, clientLogger *logger.Logger) error {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		clientLogger.Warn("Request body exceeded size limit", map[string]interface{}{
+			"target_url": targetURL,
+			"limit":      maxBytesErr.Limit,
+		})
+		return &RequestTooLargeError{Limit: maxBytesErr.Limit}
+	}
+	
+	clientLogger.Warn("Client request could not be forwarded", map[string]interface{}{
+		"target_url": targetURL,
+		"error":      err.Error(),
+	})
+	var requestErr *RequestError
+	if errors.As(err, &requestErr) {
+		return requestErr
+	}
+	return &RequestError{Err: err}
+}
+
+// checkHeaderValues rejects header values with control characters, which cannot be sent
+func checkHeaderValues(header http.Header) error {
+	for name, values := range header {
+		for _, value := range values {
+			if hasControlChars(value) {
+				return &RequestError{Err: fmt.Errorf("invalid value for header %s", name)}
+			}
+		}
+	}
+	return nil
+}
+
+// clientBody reads the client's request body, marking read errors as RequestErrors so that
+// a broken upload is not mistaken for a backend failure
+type clientBody struct {
+	r io.Reader
+}
+
+func (b *clientBody) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	if err != nil && err != io.EOF {
+		err = &RequestError{Err: err}
+	}
+	return n, err
+}
+
+// doWithRetry sends the request, retrying retryable requests on network errors and
+// configured status codes. It returns the final response and the number of attempts made.
+func (c *HTTPClient) doWithRetry(ctx context.Context, req *http.Request, endpoint string, retryable bool, clientLogger *logger.Logger) (*http.Response, int, error) {
+	maxAttempts := 1
+	if retryable {
+		maxAttempts = c.retryPolicy.MaxAttempts
+	}
+	
+	// The transport would reject these before sending; they are the client's fault, not the backend's
+	if err := checkHeaderValues(req.Header); err != nil {
+		return nil, 0, err
+	}
+	
+	for attempt := 1; ; attempt++ {
+		attemptReq := req
+		if attempt > 1 {
+			attemptReq = req.Clone(ctx)
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, attempt - 1, err
+				}
+				attemptReq.Body = body
+			}
+		}
+		
+		target, err := c.selectUpstream(ctx, attemptReq, endpoint)
+		if err != nil {
+			return nil, attempt - 1, err
+		}
+		
+		ticket, err := c.breakerFor(target).Allow()
+		if err != nil && target.role == BackendPrimary {
+			// The primary's breaker is open, but the hot standby can still serve the attempt
+			target = attemptTarget{role: BackendSecondary}
+			if err = setTargetURL(ctx, attemptReq, c.failover.SecondaryURL(), endpoint); err == nil {
+				ticket, err = c.standby.Allow()
+			}
+		}
+		if err != nil {
+			if target.upstream != nil {
+				target.upstream.Done()
+			}
+			return nil, attempt - 1, err
+		}
+		
+		// Credentials are added per attempt because upstream selection replaces the URL
+		creds := c.credentialsFor(ctx)
+		creds.apply(attemptReq)
+		
+		resp, err := c.client.Do(attemptReq)
+		err = creds.redactError(err)
+		c.recordResult(ctx, ticket, target, resp, err)
+		if target.upstream != nil {
+			if resp != nil {
+				resp.Body = &trackedBody{ReadCloser: resp.Body, done: target.upstream.Done}
+			} else {
+				target.upstream.Done()
+			}
+		}
+		if resp != nil {
+			// Tell the client which backend served the response, by role rather than host
+			resp.Header.Set("X-Upstream", target.label())
+			setServedBy(ctx, target.label())
+		}
+		if attempt >= maxAttempts || ctx.Err() != nil {
+			return resp, attempt, err
+		}
+		
+		if err != nil {
+			if !IsNetworkError(err) || IsRequestError(err) {
+				return nil, attempt, err
+			}
+		} else if !c.retryPolicy.shouldRetryStatus(resp.StatusCode) {
+			return resp, attempt, nil
+		}
+		
+		delay := c.retryPolicy.retryDelay(attempt, resp)
+		if !fitsDeadline(ctx, delay) {
+			clientLogger.Warn("Not retrying backend request, delay exceeds request deadline", map[string]interface{}{
+				"attempt": attempt,
+				"delay":   delay.String(),
+			})
+			return resp, attempt, err
+		}
+		
+		fields := map[string]interface{}{
+			"attempt":      attempt,
+			"max_attempts": maxAttempts,
+			"delay":        delay.String(),
+			"target_url":   creds.redact(req.URL.String()),
+		}
+		if resp != nil {
+			fields["status_code"] = resp.StatusCode
+			// Drain so the connection can be reused
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+		}
+		clientLogger.Warn("Retrying backend request", fields)
+		
+		if !sleepContext(ctx, delay) {
+			return nil, attempt, ctx.Err()
+		}
+	}
+}
+
 // GetTokens fetches tokens from the backend API
 func (c *HTTPClient) GetTokens(ctx context.Context) (*models.TokenResponse, error) {
-	targetURL := c.backendURL + "/tokens"
+	targetURL := c.getBackendURL(ctx) + "/tokens"
 	
 	requestID := getRequestIDFromContext(ctx)
 	clientLogger := logger.ClientLogger.WithRequestID(requestID)
@@ -123,15 +474,24 @@ func (c *HTTPClient) GetTokens(ctx context.Context) (*models.TokenResponse, erro
 	// Set standard headers for API requests
 	req.Header.Set("Accept", "application/json")
 	req.Header.Set("User-Agent", "go-api-proxy/1.0")
+	req.Header.Set("Accept-Encoding", backendAcceptEncoding)
 	
 	start := time.Now()
-	resp, err := c.client.Do(req)
+	resp, attempts, err := c.doWithRetry(ctx, req, "/tokens", c.retryPolicy.MaxAttempts > 1, clientLogger)
 	duration := time.Since(start)
 	
 	if err != nil {
+		if IsCircuitOpenError(err) {
+			clientLogger.Warn("Tokens request rejected by circuit breaker", map[string]interface{}{
+				"target_url": targetURL,
+			})
+			return nil, err
+		}
+		
 		clientLogger.Error("Failed to fetch tokens from backend", err, map[string]interface{}{
 			"target_url": targetURL,
 			"duration":   duration.String(),
+			"attempts":   attempts,
 		})
 		return nil, &NetworkError{
 			Operation: "get_tokens",
@@ -155,6 +515,14 @@ func (c *HTTPClient) GetTokens(ctx context.Context) (*models.TokenResponse, erro
 		}
 	}
 	
+	// The token list is parsed, so a compressed body has to be decoded first
+	if err := DecompressResponse(resp); err != nil {
+		clientLogger.Error("Failed to decode tokens response body", err, map[string]interface{}{
+			"content_encoding": resp.Header.Get("Content-Encoding"),
+		})
+		return nil, err
+	}
+	
 	// Read response body
 	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
@@ -180,64 +548,73 @@ func (c *HTTPClient) GetTokens(ctx context.Context) (*models.TokenResponse, erro
 	return &tokenResponse, nil
 }
 
-// forwardHeaders copies relevant headers from the original request to the backend request
+// defaultForwardedHeaders are the client headers sent to the backend unless a
+// route's request header rules say otherwise
+var defaultForwardedHeaders = []string{
+	"Accept",
+	"Accept-Language",
+	"Cache-Control",
+	"Content-Type",
+	"User-Agent",
+	"Idempotency-Key",
+	"If-None-Match",
+	"If-Modified-Since",
+}
+
+// protectedRequestHeaders are managed by the proxy and the transport, and are never
+// copied from the client request
+var protectedRequestHeaders = []string{
+	"Accept-Encoding",
+	"Content-Length",
+	"Expect",
+	"Host",
+	"X-Forwarded-For",
+	"X-Real-Ip",
+}
+
+// forwardHeaders copies relevant headers from the original request to the backend request,
+// applying the route's request header rules
 func (c *HTTPClient) forwardHeaders(originalReq, backendReq *http.Request) {
-	// Headers to forward to backend
-	headersToForward := []string{
-		"Accept",
-		"Accept-Encoding",
-		"Accept-Language",
-		"Cache-Control",
-		"Content-Type",
-		"User-Agent",
-		"X-Forwarded-For",
-		"X-Real-IP",
-	}
-	
-	for _, header := range headersToForward {
-		if value := originalReq.Header.Get(header); value != "" {
-			backendReq.Header.Set(header, value)
+	policy := requestHeadersFor(originalReq.Context())
+	
+	for name, values := range originalReq.Header {
+		if IsHopByHopHeader(name) || containsHeader(protectedRequestHeaders, name) {
+			continue
 		}
+		if !policy.Passes(name, containsHeader(defaultForwardedHeaders, name)) {
+			continue
+		}
+		backendReq.Header[http.CanonicalHeaderKey(name)] = append([]string(nil), values...)
 	}
 	
-	// Add X-Forwarded-For header if not present
-	if originalReq.Header.Get("X-Forwarded-For") == "" {
-		if remoteAddr := getClientIP(originalReq); remoteAddr != "" {
-			backendReq.Header.Set("X-Forwarded-For", remoteAddr)
-		}
+	// Forwarding headers from untrusted clients are replaced, not passed on
+	if forwardedFor := ForwardedFor(originalReq); forwardedFor != "" {
+		backendReq.Header.Set("X-Forwarded-For", forwardedFor)
+	}
+	if clientIP := GetClientIP(originalReq); clientIP != "" {
+		backendReq.Header.Set("X-Real-IP", clientIP)
 	}
 	
 	// Set our own User-Agent if none provided
-	if originalReq.Header.Get("User-Agent") == "" {
+	if backendReq.Header.Get("User-Agent") == "" {
 		backendReq.Header.Set("User-Agent", "go-api-proxy/1.0")
 	}
-}
-
-// getClientIP extracts the client IP address from the request
-func getClientIP(req *http.Request) string {
-	// Check X-Forwarded-For header first
-	if xff := req.Header.Get("X-Forwarded-For"); xff != "" {
-		// Take the first IP in the chain
-		if ips := strings.Split(xff, ","); len(ips) > 0 {
-			return strings.TrimSpace(ips[0])
-		}
-	}
 	
-	// Check X-Real-IP header
-	if xri := req.Header.Get("X-Real-IP"); xri != "" {
-		return xri
-	}
+	policy.Apply(backendReq.Header, originalReq)
 	
-	// Fall back to RemoteAddr
-	if req.RemoteAddr != "" {
-		// Remove port if present
-		if idx := strings.LastIndex(req.RemoteAddr, ":"); idx != -1 {
-			return req.RemoteAddr[:idx]
+	// The client's Accept-Encoding is not forwarded: the backend may only send gzip or
+	// identity, and the proxy negotiates the encoding with the client itself
+	backendReq.Header.Set("Accept-Encoding", backendAcceptEncoding)
+}
+
+// containsHeader checks if the list contains the header name (case-insensitive)
+func containsHeader(names []string, name string) bool {
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return true
 		}
-		return req.RemoteAddr
 	}
-	
-	return ""
+	return false
 }
 
 // NetworkError represents a network-related error
@@ -266,6 +643,41 @@ func (e *APIError) Error() string {
 	return fmt.Sprintf("API error: %s (%d) from %s", e.Status, e.StatusCode, e.URL)
 }
 
+// RequestTooLargeError represents a request body that exceeded the configured size limit
+type RequestTooLargeError struct {
+	Limit int64
+}
+
+func (e *RequestTooLargeError) Error() string {
+	return fmt.Sprintf("request body exceeds limit of %d bytes", e.Limit)
+}
+
+// IsRequestTooLargeError checks if an error is a request size limit error
+func IsRequestTooLargeError(err error) bool {
+	var tooLargeErr *RequestTooLargeError
+	return errors.As(err, &tooLargeErr)
+}
+
+// RequestError represents a failure caused by the client's request rather than the backend,
+// such as a request body that could not be read. It never counts against the backend.
+type RequestError struct {
+	Err error
+}
+
+func (e *RequestError) Error() string {
+	return fmt.Sprintf("invalid client request: %v", e.Err)
+}
+
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+// IsRequestError checks if an error was caused by the client's request
+func IsRequestError(err error) bool {
+	var requestErr *RequestError
+	return errors.As(err, &requestErr)
+}
+
 // IsNetworkError checks if an error is a network error
 func IsNetworkError(err error) bool {
 	if err == nil {
@@ -321,6 +733,94 @@ func parseJSON(data []byte, v interface{}) error {
 	return nil
 }
 
+// WithTargetBackend returns a context that directs backend requests to the given base URL
+func WithTargetBackend(ctx context.Context, baseURL string) context.Context {
+	return context.WithValue(ctx, "target_backend", strings.TrimSuffix(baseURL, "/"))
+}
+
+// TargetBackend returns the per-route backend base URL carried by the context, if any
+func TargetBackend(ctx context.Context) (string, bool) {
+	baseURL, ok := ctx.Value("target_backend").(string)
+	return baseURL, ok && baseURL != ""
+}
+
+// hasTargetBackend reports whether the context carries a per-route backend override
+func hasTargetBackend(ctx context.Context) bool {
+	_, ok := TargetBackend(ctx)
+	return ok
+}
+
+// WithBackendRoot returns a context that sends backend requests relative to the backend's
+// root instead of its API URL, for routes serving non-API paths
+func WithBackendRoot(ctx context.Context) context.Context {
+	return context.WithValue(ctx, "backend_root", true)
+}
+
+// isBackendRoot reports whether backend requests are relative to the backend's root
+func isBackendRoot(ctx context.Context) bool {
+	root, _ := ctx.Value("backend_root").(bool)
+	return root
+}
+
+// getBackendURL returns the backend base URL for the request, honouring any per-route override
+func (c *HTTPClient) getBackendURL(ctx context.Context) string {
+	if baseURL, ok := ctx.Value("target_backend").(string); ok && baseURL != "" {
+		return baseURL
+	}
+	if isBackendRoot(ctx) {
+		return c.rootURL
+	}
+	return c.backendURL
+}
+
+// PickBackend chooses the backend base URL for a connection that does not go through
+// ProxyRequest, such as a WebSocket tunnel: a pool upstream, the active failover backend,
+// or the backend host. done must be called when the connection ends.
+func (c *HTTPClient) PickBackend() (baseURL string, done func()) {
+	switch {
+	case c.pool != nil:
+		upstream := c.pool.Next()
+		return upstream.URL, upstream.Done
+	case c.failover != nil:
+		_, baseURL = c.failover.Current()
+		return baseURL, func() {}
+	}
+	return c.rootURL, func() {}
+}
+
+// upstreamTracker receives the backend that served a request, for handlers that do not
+// forward backend response headers
+type upstreamTracker struct {
+	mu   sync.Mutex
+	host string // backend label, not a host name
+}
+
+// WithUpstreamTracking returns a context in which the client records the backend that served the request
+func WithUpstreamTracking(ctx context.Context) context.Context {
+	return context.WithValue(ctx, "upstream_tracker", &upstreamTracker{})
+}
+
+// ServedBy returns the label of the backend that served the last request made with the context,
+// or "" if the context is not tracked or no response was received
+func ServedBy(ctx context.Context) string {
+	tracker, ok := ctx.Value("upstream_tracker").(*upstreamTracker)
+	if !ok {
+		return ""
+	}
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	return tracker.host
+}
+
+// setServedBy records the backend label in the context's tracker, if any
+func setServedBy(ctx context.Context, label string) {
+	if tracker, ok := ctx.Value("upstream_tracker").(*upstreamTracker); ok {
+		tracker.mu.Lock()
+		tracker.host = label
+		tracker.mu.Unlock()
+	}
+}
+
 // getRequestIDFromContext extracts request ID from context
 func getRequestIDFromContext(ctx context.Context) string {
 	if requestID, ok := ctx.Value("request_id").(string); ok {
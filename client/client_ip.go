@@ -0,0 +1,230 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"go-api-proxy/config"
+)
+
+// IPResolver determines the client IP address of requests. Only the forwarding
+// header set by the trusted proxies is read, only when the connection comes from a
+// trusted proxy, and then only up to the first hop that is not itself a trusted
+// proxy, so clients cannot spoof their address by sending their own headers.
+type IPResolver struct {
+	trusted []netip.Prefix
+	header  string
+}
+
+// clientAddress is the resolved address of a request, kept in the request context
+type clientAddress struct {
+	ip    string
+	peer  string
+	chain []string // trusted hops before the peer, from the client to the peer
+}
+
+// NewIPResolver creates a resolver trusting the given proxies. Each entry is a CIDR
+// range or a single IP address. The header is the one the proxies set:
+// x-forwarded-for (the default when empty), forwarded or x-real-ip.
+func NewIPResolver(trustedProxies []string, header string) (*IPResolver, error) {
+	r := &IPResolver{header: strings.ToLower(header)}
+	switch r.header {
+	case "":
+		r.header = "x-forwarded-for"
+	case "x-forwarded-for", "forwarded", "x-real-ip":
+	default:
+		return nil, fmt.Errorf("unsupported trusted proxy header %q", header)
+	}
+	for _, entry := range trustedProxies {
+		prefix, err := config.ParseIPPrefix(entry)
+		if err != nil {
+			return nil, err
+		}
+		r.trusted = append(r.trusted, prefix)
+	}
+	return r, nil
+}
+
+// WithClientIP resolves the client IP address and stores it in the request context
+func (r *IPResolver) WithClientIP(req *http.Request) *http.Request {
+	ctx := context.WithValue(req.Context(), "client_ip", r.resolve(req))
+	return req.WithContext(ctx)
+}
+
+// resolve walks the configured forwarding header from the right, starting at the
+// connection's peer, and stops at the first address that is not a trusted proxy
+func (r *IPResolver) resolve(req *http.Request) *clientAddress {
+	peer, ok := parseRemoteAddr(req.RemoteAddr)
+	if !ok {
+		return &clientAddress{}
+	}
+	result := &clientAddress{ip: peer.String(), peer: peer.String()}
+	if !r.isTrusted(peer) {
+		return result
+	}
+
+	var hops []string
+	switch r.header {
+	case "forwarded":
+		hops = forwardedFor(req.Header.Values("Forwarded"))
+	case "x-real-ip":
+		if realIP := strings.TrimSpace(req.Header.Get("X-Real-IP")); realIP != "" {
+			hops = []string{realIP}
+		}
+	default:
+		hops = forwardedHops(req.Header.Values("X-Forwarded-For"))
+	}
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, ok := parseNodeAddr(hops[i])
+		if !ok {
+			// Obfuscated or malformed hops end the chain that can be traced
+			break
+		}
+		result.ip = addr.String()
+		result.chain = append([]string{result.ip}, result.chain...)
+		if !r.isTrusted(addr) {
+			break
+		}
+	}
+	return result
+}
+
+// isTrusted reports whether the address belongs to a trusted proxy
+func (r *IPResolver) isTrusted(addr netip.Addr) bool {
+	for _, prefix := range r.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetClientIP returns the client IP address resolved for the request. Requests that
+// did not pass an IPResolver fall back to the connection's peer address; forwarding
+// headers are never trusted on their own.
+func GetClientIP(req *http.Request) string {
+	if addr, ok := req.Context().Value("client_ip").(*clientAddress); ok {
+		return addr.ip
+	}
+	if peer, ok := parseRemoteAddr(req.RemoteAddr); ok {
+		return peer.String()
+	}
+	return ""
+}
+
+// ForwardedFor returns the X-Forwarded-For value to send upstream: the part of the
+// incoming chain that was trusted to resolve the client IP, followed by the
+// connection's peer. Hops a client prepended itself are not passed on.
+func ForwardedFor(req *http.Request) string {
+	addr, ok := req.Context().Value("client_ip").(*clientAddress)
+	if !ok {
+		if peer, ok := parseRemoteAddr(req.RemoteAddr); ok {
+			return peer.String()
+		}
+		return ""
+	}
+	if addr.peer == "" {
+		return ""
+	}
+	return strings.Join(append(append([]string(nil), addr.chain...), addr.peer), ", ")
+}
+
+// parseRemoteAddr parses the host of a connection address such as "192.0.2.1:1234"
+// or "[2001:db8::1]:1234", also accepting an address without a port
+func parseRemoteAddr(remoteAddr string) (netip.Addr, bool) {
+	host := remoteAddr
+	if h, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		host = h
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap().WithZone(""), true
+}
+
+// parseNodeAddr parses a hop from a forwarding header. Hops may be quoted, carry a
+// port and put IPv6 addresses in brackets, as in RFC 7239 node names.
+func parseNodeAddr(node string) (netip.Addr, bool) {
+	node = strings.Trim(strings.TrimSpace(node), `"`)
+	if node == "" {
+		return netip.Addr{}, false
+	}
+	if strings.HasPrefix(node, "[") {
+		end := strings.Index(node, "]")
+		if end == -1 {
+			return netip.Addr{}, false
+		}
+		node = node[1:end]
+	} else if strings.Count(node, ":") == 1 {
+		node = node[:strings.Index(node, ":")]
+	}
+	addr, err := netip.ParseAddr(node)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap().WithZone(""), true
+}
+
+// forwardedHops splits X-Forwarded-For header values into hops, left to right
+func forwardedHops(values []string) []string {
+	var hops []string
+	for _, value := range values {
+		for _, hop := range strings.Split(value, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+	return hops
+}
+
+// forwardedFor returns the "for" parameters of RFC 7239 Forwarded header values,
+// left to right. Elements without one are kept as empty hops so they end the chain.
+func forwardedFor(values []string) []string {
+	var hops []string
+	for _, value := range values {
+		for _, element := range splitOutsideQuotes(value, ',') {
+			if strings.TrimSpace(element) == "" {
+				continue
+			}
+			hop := ""
+			for _, pair := range splitOutsideQuotes(element, ';') {
+				name, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if ok && strings.EqualFold(name, "for") {
+					hop = val
+				}
+			}
+			hops = append(hops, hop)
+		}
+	}
+	return hops
+}
+
+// splitOutsideQuotes splits s at sep, ignoring separators inside quoted strings
+func splitOutsideQuotes(s string, sep byte) []string {
+	var parts []string
+	quoted := false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			quoted = !quoted
+		case '\\':
+			if quoted {
+				i++
+			}
+		case sep:
+			if !quoted {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
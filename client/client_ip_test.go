@@ -0,0 +1,167 @@
+package client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIPResolver(t *testing.T) {
+	trusted := []string{"10.0.0.0/8", "2001:db8:ffff::/48", "192.0.2.10"}
+
+	tests := []struct {
+		name        string
+		proxyHeader string
+		remoteAddr  string
+		headers     map[string]string
+		expectedIP  string
+	}{
+		{
+			name:       "untrusted peer ignores forwarding headers",
+			remoteAddr: "203.0.113.5:4000",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.1", "X-Real-IP": "198.51.100.2"},
+			expectedIP: "203.0.113.5",
+		},
+		{
+			name:       "trusted peer without headers",
+			remoteAddr: "10.1.2.3:4000",
+			expectedIP: "10.1.2.3",
+		},
+		{
+			name:       "stops at the first untrusted hop from the right",
+			remoteAddr: "10.1.2.3:4000",
+			headers:    map[string]string{"X-Forwarded-For": "6.6.6.6, 198.51.100.7, 10.0.0.2"},
+			expectedIP: "198.51.100.7",
+		},
+		{
+			name:       "all hops trusted returns the leftmost",
+			remoteAddr: "10.1.2.3:4000",
+			headers:    map[string]string{"X-Forwarded-For": "10.9.9.9, 10.0.0.2"},
+			expectedIP: "10.9.9.9",
+		},
+		{
+			name:       "malformed hop ends the chain",
+			remoteAddr: "10.1.2.3:4000",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.7, garbage, 10.0.0.2"},
+			expectedIP: "10.0.0.2",
+		},
+		{
+			name:        "X-Real-IP from a trusted peer",
+			proxyHeader: "x-real-ip",
+			remoteAddr:  "192.0.2.10:4000",
+			headers:     map[string]string{"X-Real-IP": "198.51.100.9"},
+			expectedIP:  "198.51.100.9",
+		},
+		{
+			name:       "X-Real-IP is ignored when proxies set X-Forwarded-For",
+			remoteAddr: "192.0.2.10:4000",
+			headers:    map[string]string{"X-Real-IP": "198.51.100.9"},
+			expectedIP: "192.0.2.10",
+		},
+		{
+			name:        "Forwarded header with IPv6 and ports",
+			proxyHeader: "forwarded",
+			remoteAddr:  "[2001:db8:ffff::1]:443",
+			headers:     map[string]string{"Forwarded": `for="[2001:db8:cafe::17]:4711";proto=https, for=10.0.0.2`},
+			expectedIP:  "2001:db8:cafe::17",
+		},
+		{
+			name:        "X-Forwarded-For is ignored when proxies set Forwarded",
+			proxyHeader: "forwarded",
+			remoteAddr:  "10.1.2.3:4000",
+			headers:     map[string]string{"Forwarded": `for=198.51.100.20;by=10.1.2.3`, "X-Forwarded-For": "198.51.100.21"},
+			expectedIP:  "198.51.100.20",
+		},
+		{
+			name:       "spoofed Forwarded is ignored when proxies set X-Forwarded-For",
+			remoteAddr: "10.1.2.3:4000",
+			headers:    map[string]string{"Forwarded": `for=6.6.6.6`, "X-Forwarded-For": "198.51.100.21"},
+			expectedIP: "198.51.100.21",
+		},
+		{
+			name:        "obfuscated Forwarded node",
+			proxyHeader: "forwarded",
+			remoteAddr:  "10.1.2.3:4000",
+			headers:     map[string]string{"Forwarded": `for=_hidden, for="10.0.0.5:80"`},
+			expectedIP:  "10.0.0.5",
+		},
+		{
+			name:       "IPv4-mapped IPv6 peer",
+			remoteAddr: "[::ffff:10.1.2.3]:4000",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.30"},
+			expectedIP: "198.51.100.30",
+		},
+		{
+			name:       "IPv6 peer with zone",
+			remoteAddr: "[fe80::1%eth0]:4000",
+			expectedIP: "fe80::1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver, err := NewIPResolver(trusted, tt.proxyHeader)
+			if err != nil {
+				t.Fatalf("NewIPResolver failed: %v", err)
+			}
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			if ip := GetClientIP(resolver.WithClientIP(req)); ip != tt.expectedIP {
+				t.Errorf("Expected IP %s, got %s", tt.expectedIP, ip)
+			}
+		})
+	}
+}
+
+func TestForwardedFor(t *testing.T) {
+	resolver, _ := NewIPResolver([]string{"10.0.0.0/8"}, "")
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "203.0.113.5:4000"
+	req.Header.Set("X-Forwarded-For", "1.2.3.4")
+	if got := ForwardedFor(resolver.WithClientIP(req)); got != "203.0.113.5" {
+		t.Errorf("Expected a spoofed chain to be replaced, got %q", got)
+	}
+
+	req.RemoteAddr = "10.0.0.2:4000"
+	if got := ForwardedFor(resolver.WithClientIP(req)); got != "1.2.3.4, 10.0.0.2" {
+		t.Errorf("Expected the trusted chain to be extended, got %q", got)
+	}
+
+	req.Header.Set("X-Forwarded-For", "6.6.6.6, 198.51.100.7, 10.0.0.3")
+	if got := ForwardedFor(resolver.WithClientIP(req)); got != "198.51.100.7, 10.0.0.3, 10.0.0.2" {
+		t.Errorf("Expected hops before the client to be dropped, got %q", got)
+	}
+}
+
+func TestForwardedFor_SpoofedForwardedHeader(t *testing.T) {
+	resolver, _ := NewIPResolver([]string{"10.0.0.0/8"}, "x-forwarded-for")
+
+	// A client sends its own Forwarded header through a proxy that only sets X-Forwarded-For
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.2:4000"
+	req.Header.Set("Forwarded", "for=10.0.0.9, for=10.0.0.8")
+	req.Header.Set("X-Forwarded-For", "198.51.100.40")
+	req = resolver.WithClientIP(req)
+
+	if ip := GetClientIP(req); ip != "198.51.100.40" {
+		t.Errorf("Expected the client IP from X-Forwarded-For, got %s", ip)
+	}
+	if got := ForwardedFor(req); got != "198.51.100.40, 10.0.0.2" {
+		t.Errorf("Expected the outbound chain to be built from X-Forwarded-For, got %q", got)
+	}
+}
+
+func TestNewIPResolver_Invalid(t *testing.T) {
+	for _, entry := range []string{"10.0.0.0/33", "not-an-ip", "10.0.0"} {
+		if _, err := NewIPResolver([]string{entry}, ""); err == nil {
+			t.Errorf("Expected an error for %q", entry)
+		}
+	}
+	if _, err := NewIPResolver(nil, "x-client-ip"); err == nil {
+		t.Error("Expected an error for an unsupported header")
+	}
+}
@@ -0,0 +1,179 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go-api-proxy/config"
+)
+
+func TestProxyRequest_StreamsBodyWithContentLength(t *testing.T) {
+	requestBody := strings.Repeat("a", 4096)
+	
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength != int64(len(requestBody)) {
+			t.Errorf("Expected Content-Length %d, got %d", len(requestBody), r.ContentLength)
+		}
+		if len(r.TransferEncoding) != 0 {
+			t.Errorf("Expected no transfer encoding, got %v", r.TransferEncoding)
+		}
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != requestBody {
+			t.Errorf("Expected body of %d bytes, got %d", len(requestBody), len(body))
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewHTTPClient(&config.Config{BackendHost: server.URL, Timeout: 5 * time.Second})
+
+	originalReq := httptest.NewRequest("POST", "/upload", strings.NewReader(requestBody))
+	resp, err := client.ProxyRequest(context.Background(), originalReq, "/upload")
+	if err != nil {
+		t.Fatalf("ProxyRequest failed: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestProxyRequest_StreamsChunkedBody(t *testing.T) {
+	requestBody := "chunked payload"
+	
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TransferEncoding) != 1 || r.TransferEncoding[0] != "chunked" {
+			t.Errorf("Expected chunked transfer encoding, got %v", r.TransferEncoding)
+		}
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != requestBody {
+			t.Errorf("Expected body %q, got %q", requestBody, string(body))
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewHTTPClient(&config.Config{BackendHost: server.URL, Timeout: 5 * time.Second})
+
+	originalReq := httptest.NewRequest("POST", "/upload", io.NopCloser(strings.NewReader(requestBody)))
+	originalReq.ContentLength = -1
+	resp, err := client.ProxyRequest(context.Background(), originalReq, "/upload")
+	if err != nil {
+		t.Fatalf("ProxyRequest failed: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestProxyRequest_BodyOverLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewHTTPClient(&config.Config{BackendHost: server.URL, Timeout: 5 * time.Second})
+
+	originalReq := httptest.NewRequest("POST", "/upload", nil)
+	originalReq.Body = http.MaxBytesReader(httptest.NewRecorder(), io.NopCloser(strings.NewReader(strings.Repeat("x", 100))), 10)
+	originalReq.ContentLength = -1
+
+	_, err := client.ProxyRequest(context.Background(), originalReq, "/upload")
+	if err == nil {
+		t.Fatal("Expected error for oversized body")
+	}
+	if !IsRequestTooLargeError(err) {
+		t.Errorf("Expected RequestTooLargeError, got %T: %v", err, err)
+	}
+}
+
+// failingBody returns some data and then fails, like an upload the client aborted
+type failingBody struct {
+	sent bool
+}
+
+func (b *failingBody) Read(p []byte) (int, error) {
+	if b.sent {
+		return 0, io.ErrUnexpectedEOF
+	}
+	b.sent = true
+	return copy(p, "partial"), nil
+}
+
+func TestProxyRequest_ClientBodyErrorsDoNotTripBreaker(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewHTTPClient(&config.Config{
+		BackendHost:         server.URL,
+		Timeout:             5 * time.Second,
+		CircuitFailureRatio: 0.5,
+		CircuitMinRequests:  2,
+		CircuitCooldown:     time.Minute,
+	})
+
+	for i := 0; i < 10; i++ {
+		oversized := httptest.NewRequest("POST", "/upload", nil)
+		oversized.Body = http.MaxBytesReader(httptest.NewRecorder(), io.NopCloser(strings.NewReader(strings.Repeat("x", 1000))), 10)
+		oversized.ContentLength = -1
+		if _, err := client.ProxyRequest(context.Background(), oversized, "/upload"); !IsRequestTooLargeError(err) {
+			t.Fatalf("Expected RequestTooLargeError, got %v", err)
+		}
+
+		aborted := httptest.NewRequest("POST", "/upload", nil)
+		aborted.Body = io.NopCloser(&failingBody{})
+		aborted.ContentLength = -1
+		if _, err := client.ProxyRequest(context.Background(), aborted, "/upload"); !IsRequestError(err) {
+			t.Fatalf("Expected RequestError, got %v", err)
+		}
+	}
+
+	status := client.CircuitBreakerStatus()
+	if status == nil || status.State != "closed" || status.Failures != 0 {
+		t.Errorf("Expected client body errors not to count against the backend, got %+v", status)
+	}
+
+	resp, err := client.ProxyRequest(context.Background(), httptest.NewRequest("GET", "/stats", nil), "/stats")
+	if err != nil {
+		t.Fatalf("Expected the backend to stay reachable, got %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestProxyRequest_InvalidHeaderValueDoesNotTripBreaker(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewHTTPClient(&config.Config{
+		BackendHost:         server.URL,
+		Timeout:             5 * time.Second,
+		CircuitFailureRatio: 0.5,
+		CircuitMinRequests:  2,
+		CircuitCooldown:     time.Minute,
+		RetryMaxAttempts:    3,
+	})
+
+	for i := 0; i < 10; i++ {
+		req := httptest.NewRequest("GET", "/stats", nil)
+		req.Header.Set("Accept-Language", "en\nX-Injected: 1")
+		if _, err := client.ProxyRequest(context.Background(), req, "/stats"); !IsRequestError(err) {
+			t.Fatalf("Expected RequestError, got %v", err)
+		}
+	}
+
+	if calls != 0 {
+		t.Errorf("Expected no request to reach the backend, got %d", calls)
+	}
+	if status := client.CircuitBreakerStatus(); status == nil || status.State != "closed" || status.Requests != 0 {
+		t.Errorf("Expected invalid requests not to count against the backend, got %+v", status)
+	}
+}
@@ -327,31 +327,32 @@ func TestGetClientIP(t *testing.T) {
 		expectedIP     string
 	}{
 		{
-			name: "X-Forwarded-For header",
+			name: "X-Forwarded-For header is not trusted without a resolver",
 			setupRequest: func() *http.Request {
 				req := httptest.NewRequest("GET", "/", nil)
 				req.Header.Set("X-Forwarded-For", "192.168.1.1, 10.0.0.1")
+				req.Header.Set("X-Real-IP", "192.168.1.2")
 				return req
 			},
-			expectedIP: "192.168.1.1",
+			expectedIP: "192.0.2.1",
 		},
 		{
-			name: "X-Real-IP header",
+			name: "RemoteAddr",
 			setupRequest: func() *http.Request {
 				req := httptest.NewRequest("GET", "/", nil)
-				req.Header.Set("X-Real-IP", "192.168.1.2")
+				req.RemoteAddr = "192.168.1.3:12345"
 				return req
 			},
-			expectedIP: "192.168.1.2",
+			expectedIP: "192.168.1.3",
 		},
 		{
-			name: "RemoteAddr",
+			name: "IPv6 RemoteAddr",
 			setupRequest: func() *http.Request {
 				req := httptest.NewRequest("GET", "/", nil)
-				req.RemoteAddr = "192.168.1.3:12345"
+				req.RemoteAddr = "[2001:db8::1]:12345"
 				return req
 			},
-			expectedIP: "192.168.1.3",
+			expectedIP: "2001:db8::1",
 		},
 		{
 			name: "No IP available",
@@ -367,7 +368,7 @@ func TestGetClientIP(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			req := tt.setupRequest()
-			ip := getClientIP(req)
+			ip := GetClientIP(req)
 			if ip != tt.expectedIP {
 				t.Errorf("Expected IP %s, got %s", tt.expectedIP, ip)
 			}
@@ -0,0 +1,152 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// redactedValue replaces credentials in logged URLs and errors
+const redactedValue = "REDACTED"
+
+// Credentials is an API key the proxy adds to backend requests, sent either as a
+// query parameter or as a header
+type Credentials struct {
+	key    string
+	param  string
+	header string
+}
+
+// LoadCredentials reads an API key from a secret file. The key is sent in the named
+// header, or in the query parameter param when header is empty. It returns nil when
+// no key file is configured.
+func LoadCredentials(keyFile, param, header string) (*Credentials, error) {
+	if keyFile == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read API key file %s: %w", keyFile, err)
+	}
+	key := strings.TrimSpace(string(data))
+	if key == "" {
+		return nil, fmt.Errorf("API key file %s is empty", keyFile)
+	}
+	if header == "" && param == "" {
+		return nil, fmt.Errorf("API key file %s needs a query parameter or header name", keyFile)
+	}
+
+	return &Credentials{
+		key:    key,
+		param:  param,
+		header: http.CanonicalHeaderKey(header),
+	}, nil
+}
+
+// apply adds the API key to a backend request, replacing any value already present
+func (c *Credentials) apply(req *http.Request) {
+	if c == nil {
+		return
+	}
+	if c.header != "" {
+		req.Header.Set(c.header, c.key)
+		return
+	}
+
+	rawQuery := RemoveQueryParam(req.URL.RawQuery, c.param)
+	if rawQuery != "" {
+		rawQuery += "&"
+	}
+	req.URL.RawQuery = rawQuery + url.QueryEscape(c.param) + "=" + url.QueryEscape(c.key)
+}
+
+// redactError removes the API key from the URL carried by a transport error
+func (c *Credentials) redactError(err error) error {
+	var urlErr *url.Error
+	if c != nil && errors.As(err, &urlErr) {
+		urlErr.URL = c.redact(urlErr.URL)
+	}
+	return err
+}
+
+// redact replaces the API key in a URL or error message
+func (c *Credentials) redact(s string) string {
+	if c == nil {
+		return s
+	}
+	s = strings.ReplaceAll(s, url.QueryEscape(c.key), redactedValue)
+	return strings.ReplaceAll(s, c.key, redactedValue)
+}
+
+// WithCredentials returns a context that sends the given credentials, instead of the
+// proxy-wide ones, with backend requests
+func WithCredentials(ctx context.Context, creds *Credentials) context.Context {
+	return context.WithValue(ctx, "backend_credentials", creds)
+}
+
+// credentialsFor returns the credentials for a backend request: the route's own, or
+// the proxy-wide credentials when the request goes to the configured backend. Routes
+// pointing at another backend get none so the key is never sent to a third party.
+func (c *HTTPClient) credentialsFor(ctx context.Context) *Credentials {
+	if creds, ok := ctx.Value("backend_credentials").(*Credentials); ok && creds != nil {
+		return creds
+	}
+	if baseURL, ok := TargetBackend(ctx); ok && baseURL != strings.TrimSuffix(c.config.BackendHost, "/") {
+		return nil
+	}
+	return c.credentials
+}
+
+// SetCredentials sets the proxy-wide credentials sent with backend requests
+func (c *HTTPClient) SetCredentials(creds *Credentials) {
+	c.credentials = creds
+}
+
+// StripClientCredentials returns the request without the API key query parameter and
+// header, so keys supplied by clients are neither forwarded nor logged. The request is
+// returned unchanged when it carries neither.
+func StripClientCredentials(r *http.Request, param, header string) *http.Request {
+	rawQuery := r.URL.RawQuery
+	if param != "" {
+		rawQuery = RemoveQueryParam(rawQuery, param)
+	}
+	hasHeader := header != "" && r.Header.Get(header) != ""
+	if rawQuery == r.URL.RawQuery && !hasHeader {
+		return r
+	}
+
+	stripped := r.Clone(r.Context())
+	stripped.Body = r.Body
+	stripped.URL.RawQuery = rawQuery
+	stripped.RequestURI = stripped.URL.RequestURI()
+	if hasHeader {
+		stripped.Header.Del(header)
+	}
+	return stripped
+}
+
+// RemoveQueryParam removes every occurrence of the named parameter from a raw query,
+// leaving the other parameters and their encoding untouched
+func RemoveQueryParam(rawQuery, name string) string {
+	if rawQuery == "" {
+		return rawQuery
+	}
+
+	parts := strings.Split(rawQuery, "&")
+	kept := parts[:0]
+	for _, part := range parts {
+		key, _, _ := strings.Cut(part, "=")
+		if decoded, err := url.QueryUnescape(key); err == nil {
+			key = decoded
+		}
+		if key != name {
+			kept = append(kept, part)
+		}
+	}
+	return strings.Join(kept, "&")
+}
@@ -0,0 +1,142 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go-api-proxy/config"
+)
+
+func writeKeyFile(t *testing.T, key string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "apikey")
+	if err := os.WriteFile(path, []byte(key), 0o600); err != nil {
+		t.Fatalf("Failed to write key file: %v", err)
+	}
+	return path
+}
+
+func TestLoadCredentials(t *testing.T) {
+	if creds, err := LoadCredentials("", "apikey", ""); creds != nil || err != nil {
+		t.Errorf("Expected nil without a key file, got %v, %v", creds, err)
+	}
+	if _, err := LoadCredentials(writeKeyFile(t, " \n"), "apikey", ""); err == nil {
+		t.Error("Expected an error for an empty key file")
+	}
+	if _, err := LoadCredentials(filepath.Join(t.TempDir(), "missing"), "apikey", ""); err == nil {
+		t.Error("Expected an error for a missing key file")
+	}
+
+	creds, err := LoadCredentials(writeKeyFile(t, "s3cret\n"), "apikey", "x-api-key")
+	if err != nil {
+		t.Fatalf("LoadCredentials failed: %v", err)
+	}
+	if creds.key != "s3cret" || creds.header != "X-Api-Key" {
+		t.Errorf("Expected trimmed key and canonical header, got %q and %q", creds.key, creds.header)
+	}
+}
+
+func TestProxyRequest_InjectsAPIKey(t *testing.T) {
+	var gotQuery string
+	var gotHeader string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotQuery = r.URL.RawQuery
+		gotHeader = r.Header.Get("X-Api-Key")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"items":[]}`))
+	}))
+	defer server.Close()
+
+	client := NewHTTPClient(&config.Config{BackendHost: server.URL, Timeout: 5 * time.Second})
+	creds, _ := LoadCredentials(writeKeyFile(t, "s3cret"), "apikey", "")
+	client.SetCredentials(creds)
+
+	req := httptest.NewRequest(http.MethodGet, "/blocks?type=block&apikey=client-key", nil)
+	resp, err := client.ProxyRequest(context.Background(), req, "/blocks?type=block&apikey=client-key")
+	if err != nil {
+		t.Fatalf("ProxyRequest failed: %v", err)
+	}
+	resp.Body.Close()
+	if gotQuery != "type=block&apikey=s3cret" {
+		t.Errorf("Expected the proxy's key to replace the client's, got query %q", gotQuery)
+	}
+
+	if _, err := client.GetTokens(context.Background()); err != nil {
+		t.Fatalf("GetTokens failed: %v", err)
+	}
+	if gotQuery != "apikey=s3cret" {
+		t.Errorf("Expected the key on the tokens request, got query %q", gotQuery)
+	}
+
+	// Routes to another backend do not get the proxy-wide key
+	ctx := WithTargetBackend(context.Background(), server.URL+"/other")
+	resp, err = client.ProxyRequest(ctx, httptest.NewRequest(http.MethodGet, "/", nil), "/")
+	if err != nil {
+		t.Fatalf("ProxyRequest failed: %v", err)
+	}
+	resp.Body.Close()
+	if gotQuery != "" {
+		t.Errorf("Expected no key for a third-party backend, got query %q", gotQuery)
+	}
+
+	// Route credentials take precedence and may use a header
+	routeCreds, _ := LoadCredentials(writeKeyFile(t, "route-key"), "apikey", "X-Api-Key")
+	ctx = WithCredentials(ctx, routeCreds)
+	resp, err = client.ProxyRequest(ctx, httptest.NewRequest(http.MethodGet, "/", nil), "/")
+	if err != nil {
+		t.Fatalf("ProxyRequest failed: %v", err)
+	}
+	resp.Body.Close()
+	if gotHeader != "route-key" || gotQuery != "" {
+		t.Errorf("Expected the route key in the header, got header %q and query %q", gotHeader, gotQuery)
+	}
+}
+
+func TestProxyRequest_RedactsAPIKeyFromErrors(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	backendURL := server.URL
+	server.Close()
+
+	client := NewHTTPClient(&config.Config{BackendHost: backendURL, Timeout: 5 * time.Second})
+	creds, _ := LoadCredentials(writeKeyFile(t, "s3cret/+key"), "apikey", "")
+	client.SetCredentials(creds)
+
+	_, err := client.ProxyRequest(context.Background(), httptest.NewRequest(http.MethodGet, "/blocks", nil), "/blocks")
+	if err == nil {
+		t.Fatal("Expected a network error")
+	}
+	if strings.Contains(err.Error(), "s3cret") {
+		t.Errorf("Expected the key to be redacted, got %v", err)
+	}
+}
+
+func TestStripClientCredentials(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/v2/blocks?apikey=abc&type=block&api%6Bey=def", nil)
+	req.Header.Set("X-Api-Key", "abc")
+
+	stripped := StripClientCredentials(req, "apikey", "X-Api-Key")
+	if stripped.URL.RawQuery != "type=block" {
+		t.Errorf("Expected the key parameters to be removed, got %q", stripped.URL.RawQuery)
+	}
+	if stripped.RequestURI != "/api/v2/blocks?type=block" {
+		t.Errorf("Expected the request URI to be updated, got %q", stripped.RequestURI)
+	}
+	if stripped.Header.Get("X-Api-Key") != "" {
+		t.Error("Expected the key header to be removed")
+	}
+	if req.URL.RawQuery == stripped.URL.RawQuery || req.Header.Get("X-Api-Key") == "" {
+		t.Error("Expected the original request to be left untouched")
+	}
+
+	plain := httptest.NewRequest(http.MethodGet, "/api/v2/blocks?type=block", nil)
+	if StripClientCredentials(plain, "apikey", "X-Api-Key") != plain {
+		t.Error("Expected a request without credentials to be returned as is")
+	}
+}
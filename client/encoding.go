@@ -0,0 +1,59 @@
+package client
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// backendAcceptEncoding is sent on every backend request. The proxy only ever asks
+// for gzip or an uncompressed body so that it can always decode what comes back.
+const backendAcceptEncoding = "gzip"
+
+// IsGzipEncoded reports whether the response body is gzip-compressed
+func IsGzipEncoded(header http.Header) bool {
+	return strings.EqualFold(strings.TrimSpace(header.Get("Content-Encoding")), "gzip")
+}
+
+// DecompressResponse replaces a gzip-compressed response body with its decoded
+// content and drops the headers describing the encoded form. Responses without a
+// Content-Encoding are left untouched; any other encoding is an error because the
+// proxy never asks the backend for one.
+func DecompressResponse(resp *http.Response) error {
+	encoding := strings.TrimSpace(resp.Header.Get("Content-Encoding"))
+	if encoding == "" || strings.EqualFold(encoding, "identity") {
+		return nil
+	}
+	if !strings.EqualFold(encoding, "gzip") {
+		return fmt.Errorf("unsupported content encoding %q", encoding)
+	}
+
+	reader, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to decode gzip response: %w", err)
+	}
+
+	resp.Body = &gzipBody{reader: reader, body: resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return nil
+}
+
+// gzipBody reads decoded content and closes the underlying response body
+type gzipBody struct {
+	reader *gzip.Reader
+	body   io.ReadCloser
+}
+
+func (b *gzipBody) Read(p []byte) (int, error) {
+	return b.reader.Read(p)
+}
+
+func (b *gzipBody) Close() error {
+	b.reader.Close()
+	return b.body.Close()
+}
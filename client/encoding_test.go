@@ -0,0 +1,92 @@
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go-api-proxy/config"
+)
+
+func gzipBytes(t *testing.T, s string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write([]byte(s))
+	gz.Close()
+	return buf.Bytes()
+}
+
+func TestDecompressResponse(t *testing.T) {
+	compressed := gzipBytes(t, `{"items":[]}`)
+	resp := &http.Response{
+		Header: http.Header{
+			"Content-Encoding": {"gzip"},
+			"Content-Length":   {"99"},
+		},
+		ContentLength: int64(len(compressed)),
+		Body:          io.NopCloser(bytes.NewReader(compressed)),
+	}
+
+	if err := DecompressResponse(resp); err != nil {
+		t.Fatalf("DecompressResponse failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != `{"items":[]}` {
+		t.Errorf("Unexpected decoded body %q", body)
+	}
+	if resp.Header.Get("Content-Encoding") != "" || resp.Header.Get("Content-Length") != "" || resp.ContentLength != -1 {
+		t.Errorf("Expected encoding headers to be dropped, got %v (length %d)", resp.Header, resp.ContentLength)
+	}
+
+	plain := &http.Response{Header: http.Header{}, Body: io.NopCloser(strings.NewReader("plain"))}
+	if err := DecompressResponse(plain); err != nil {
+		t.Errorf("Expected identity body to be left alone, got %v", err)
+	}
+
+	brotli := &http.Response{Header: http.Header{"Content-Encoding": {"br"}}, Body: io.NopCloser(strings.NewReader(""))}
+	if err := DecompressResponse(brotli); err == nil {
+		t.Error("Expected an error for an unsupported encoding")
+	}
+}
+
+func TestGetTokens_DecodesGzipBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept-Encoding") != "gzip" {
+			t.Errorf("Expected the proxy to ask for gzip, got %q", r.Header.Get("Accept-Encoding"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(gzipBytes(t, `{"items":[{"address":"0xabc","name":"Token"}]}`))
+	}))
+	defer server.Close()
+
+	client := NewHTTPClient(&config.Config{BackendHost: server.URL, Timeout: 5 * time.Second})
+	tokens, err := client.GetTokens(context.Background())
+	if err != nil {
+		t.Fatalf("GetTokens failed: %v", err)
+	}
+	if len(tokens.Items) != 1 || tokens.Items[0].Address != "0xabc" {
+		t.Errorf("Unexpected tokens %+v", tokens.Items)
+	}
+}
+
+func TestForwardHeaders_NegotiatesBackendEncoding(t *testing.T) {
+	client := NewHTTPClient(&config.Config{BackendHost: "https://example.com", Timeout: 5 * time.Second})
+
+	originalReq := httptest.NewRequest("GET", "/test", nil)
+	originalReq.Header.Set("Accept-Encoding", "br, zstd")
+	backendReq := httptest.NewRequest("GET", "https://example.com/test", nil)
+	client.forwardHeaders(originalReq, backendReq)
+
+	if got := backendReq.Header.Get("Accept-Encoding"); got != "gzip" {
+		t.Errorf("Expected backend Accept-Encoding gzip, got %q", got)
+	}
+}
@@ -0,0 +1,238 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"go-api-proxy/config"
+	"go-api-proxy/logger"
+)
+
+// Failover backend roles
+const (
+	BackendPrimary   = "primary"
+	BackendSecondary = "secondary"
+)
+
+// FailoverStatus is a point-in-time view of the failover state for health reporting.
+// Backends are identified by role, so the public health check never exposes their hosts.
+type FailoverStatus struct {
+	Active              string `json:"active"`
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+	ActiveSince         string `json:"active_since"`
+	PrimaryHealthySince string `json:"primary_healthy_since,omitempty"`
+}
+
+// Failover sends all traffic to the primary backend and switches to a hot-standby
+// secondary when the primary fails repeatedly. It switches back once the primary
+// has stayed healthy for the whole recovery window.
+type Failover struct {
+	primary          string
+	secondary        string
+	failureThreshold int
+	recoveryWindow   time.Duration
+	healthPath       string
+	healthInterval   time.Duration
+	probeClient      *http.Client
+	now              func() time.Time
+
+	mu                  sync.Mutex
+	active              string
+	activeSince         time.Time
+	consecutiveFailures int
+	primaryHealthySince time.Time
+
+	stopOnce sync.Once
+	stopCh   chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewFailover creates a failover controller. It returns nil when no secondary backend is configured.
+func NewFailover(cfg *config.Config, transport http.RoundTripper) *Failover {
+	if cfg.SecondaryBackendHost == "" {
+		return nil
+	}
+
+	f := &Failover{
+		primary:          strings.TrimSuffix(cfg.BackendHost, "/"),
+		secondary:        strings.TrimSuffix(cfg.SecondaryBackendHost, "/"),
+		failureThreshold: cfg.FailoverFailureThreshold,
+		recoveryWindow:   cfg.FailoverRecoveryWindow,
+		healthPath:       cfg.UpstreamHealthPath,
+		healthInterval:   cfg.UpstreamHealthInterval,
+		probeClient: &http.Client{
+			Timeout:   5 * time.Second,
+			Transport: transport,
+		},
+		now:    time.Now,
+		active: BackendPrimary,
+		stopCh: make(chan struct{}),
+	}
+
+	if f.failureThreshold < 1 {
+		f.failureThreshold = 1
+	}
+	f.activeSince = f.now()
+
+	return f
+}
+
+// Current returns the role and base URL of the backend that should serve the next request
+func (f *Failover) Current() (string, string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// Without active probes, try the primary again once the recovery window has passed
+	if f.active == BackendSecondary && f.healthInterval <= 0 && f.now().Sub(f.activeSince) >= f.recoveryWindow {
+		f.switchTo(BackendPrimary, "recovery_window_elapsed")
+	}
+
+	if f.active == BackendSecondary {
+		return BackendSecondary, f.secondary
+	}
+	return BackendPrimary, f.primary
+}
+
+// SecondaryURL returns the base URL of the secondary backend
+func (f *Failover) SecondaryURL() string {
+	return f.secondary
+}
+
+// RecordResult records the outcome of a request sent to the given backend role
+func (f *Failover) RecordResult(role string, success bool) {
+	if role != BackendPrimary {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.recordPrimary(success, "request_errors")
+}
+
+// recordPrimary tracks primary health and fails over or back as needed; callers must hold the lock
+func (f *Failover) recordPrimary(success bool, reason string) {
+	now := f.now()
+
+	if !success {
+		f.consecutiveFailures++
+		f.primaryHealthySince = time.Time{}
+		if f.active == BackendPrimary && f.consecutiveFailures >= f.failureThreshold {
+			f.switchTo(BackendSecondary, reason)
+		}
+		return
+	}
+
+	f.consecutiveFailures = 0
+	if f.primaryHealthySince.IsZero() {
+		f.primaryHealthySince = now
+	}
+	if f.active == BackendSecondary && now.Sub(f.primaryHealthySince) >= f.recoveryWindow {
+		f.switchTo(BackendPrimary, "primary_recovered")
+	}
+}
+
+// switchTo changes the active backend and logs the event; callers must hold the lock
+func (f *Failover) switchTo(role, reason string) {
+	if f.active == role {
+		return
+	}
+
+	event := "failover"
+	if role == BackendPrimary {
+		event = "failback"
+	}
+
+	logger.ClientLogger.Warn("Backend "+event, map[string]interface{}{
+		"event":                event,
+		"from":                 f.active,
+		"to":                   role,
+		"reason":               reason,
+		"primary":              f.primary,
+		"secondary":            f.secondary,
+		"consecutive_failures": f.consecutiveFailures,
+		"active_for":           f.now().Sub(f.activeSince).String(),
+	})
+
+	f.active = role
+	f.activeSince = f.now()
+	if role == BackendPrimary {
+		f.consecutiveFailures = 0
+	}
+}
+
+// Start launches periodic probes of the primary backend. It is a no-op when probing is disabled.
+func (f *Failover) Start() {
+	if f == nil || f.healthInterval <= 0 || f.healthPath == "" {
+		return
+	}
+
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+
+		ticker := time.NewTicker(f.healthInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				f.ProbePrimary(context.Background())
+			case <-f.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the primary probes and waits for them to finish
+func (f *Failover) Stop() {
+	if f == nil {
+		return
+	}
+	f.stopOnce.Do(func() {
+		close(f.stopCh)
+	})
+	f.wg.Wait()
+}
+
+// ProbePrimary runs one health probe against the primary backend
+func (f *Failover) ProbePrimary(ctx context.Context) {
+	healthy := false
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.primary+f.healthPath, nil)
+	if err == nil {
+		req.Header.Set("User-Agent", "go-api-proxy/1.0 (health-check)")
+		if resp, err := f.probeClient.Do(req); err == nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+			healthy = resp.StatusCode >= 200 && resp.StatusCode <= 299
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.recordPrimary(healthy, "health_probe")
+}
+
+// Status returns the current failover state, or nil when failover is not configured
+func (f *Failover) Status() *FailoverStatus {
+	if f == nil {
+		return nil
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	status := &FailoverStatus{
+		Active:              f.active,
+		ConsecutiveFailures: f.consecutiveFailures,
+		ActiveSince:         f.activeSince.UTC().Format(time.RFC3339),
+	}
+	if !f.primaryHealthySince.IsZero() {
+		status.PrimaryHealthySince = f.primaryHealthySince.UTC().Format(time.RFC3339)
+	}
+	return status
+}
@@ -0,0 +1,279 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go-api-proxy/config"
+)
+
+func TestNewFailover_DisabledWithoutSecondary(t *testing.T) {
+	if failover := NewFailover(&config.Config{BackendHost: "https://example.com"}, http.DefaultTransport); failover != nil {
+		t.Error("Expected no failover without a secondary backend")
+	}
+}
+
+func TestProxyRequest_FailsOverToSecondary(t *testing.T) {
+	var primaryCalls, secondaryCalls int32
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&primaryCalls, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer primary.Close()
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v2/stats" {
+			t.Errorf("Expected path /api/v2/stats, got %s", r.URL.Path)
+		}
+		atomic.AddInt32(&secondaryCalls, 1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer secondary.Close()
+
+	client := NewHTTPClient(&config.Config{
+		BackendHost:              primary.URL,
+		SecondaryBackendHost:     secondary.URL,
+		FailoverFailureThreshold: 2,
+		FailoverRecoveryWindow:   time.Minute,
+		Timeout:                  5 * time.Second,
+	})
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.ProxyRequest(context.Background(), httptest.NewRequest("GET", "/stats", nil), "/stats")
+		if err != nil {
+			t.Fatalf("ProxyRequest failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.Header.Get("X-Upstream") != BackendPrimary {
+			t.Errorf("Expected X-Upstream %s, got %s", BackendPrimary, resp.Header.Get("X-Upstream"))
+		}
+	}
+
+	resp, err := client.ProxyRequest(context.Background(), httptest.NewRequest("GET", "/stats", nil), "/stats")
+	if err != nil {
+		t.Fatalf("ProxyRequest failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200 from the secondary, got %d", resp.StatusCode)
+	}
+	if resp.Header.Get("X-Upstream") != BackendSecondary {
+		t.Errorf("Expected X-Upstream %s, got %s", BackendSecondary, resp.Header.Get("X-Upstream"))
+	}
+	if primaryCalls != 2 || secondaryCalls != 1 {
+		t.Errorf("Expected 2 primary and 1 secondary calls, got %d and %d", primaryCalls, secondaryCalls)
+	}
+	if status := client.FailoverStatus(); status == nil || status.Active != BackendSecondary {
+		t.Errorf("Expected secondary to be active, got %+v", status)
+	}
+}
+
+func TestProxyRequest_OpenPrimaryBreakerDoesNotBlockSecondary(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer primary.Close()
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer secondary.Close()
+
+	client := NewHTTPClient(&config.Config{
+		BackendHost:              primary.URL,
+		SecondaryBackendHost:     secondary.URL,
+		FailoverFailureThreshold: 2,
+		FailoverRecoveryWindow:   time.Minute,
+		CircuitFailureRatio:      0.5,
+		CircuitMinRequests:       2,
+		CircuitWindow:            time.Minute,
+		CircuitCooldown:          time.Minute,
+		Timeout:                  5 * time.Second,
+	})
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.ProxyRequest(context.Background(), httptest.NewRequest("GET", "/stats", nil), "/stats")
+		if err != nil {
+			t.Fatalf("ProxyRequest failed: %v", err)
+		}
+		resp.Body.Close()
+	}
+	if status := client.CircuitBreakerStatus(); status.State != CircuitOpen.String() {
+		t.Fatalf("Expected the primary breaker to be open, got %+v", status)
+	}
+
+	resp, err := client.ProxyRequest(context.Background(), httptest.NewRequest("GET", "/stats", nil), "/stats")
+	if err != nil {
+		t.Fatalf("Expected failover traffic to bypass the primary breaker, got %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("X-Upstream") != BackendSecondary {
+		t.Errorf("Expected a 200 from the secondary, got %d from %s", resp.StatusCode, resp.Header.Get("X-Upstream"))
+	}
+}
+
+func TestProxyRequest_OpenPrimaryBreakerSendsToSecondary(t *testing.T) {
+	var primaryCalls int32
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&primaryCalls, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer primary.Close()
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer secondary.Close()
+
+	// The breaker opens before failover has seen enough failures to switch
+	client := NewHTTPClient(&config.Config{
+		BackendHost:              primary.URL,
+		SecondaryBackendHost:     secondary.URL,
+		FailoverFailureThreshold: 10,
+		FailoverRecoveryWindow:   time.Minute,
+		CircuitFailureRatio:      0.5,
+		CircuitMinRequests:       2,
+		CircuitWindow:            time.Minute,
+		CircuitCooldown:          time.Minute,
+		Timeout:                  5 * time.Second,
+	})
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.ProxyRequest(context.Background(), httptest.NewRequest("GET", "/stats", nil), "/stats")
+		if err != nil {
+			t.Fatalf("ProxyRequest failed: %v", err)
+		}
+		resp.Body.Close()
+	}
+	if status := client.FailoverStatus(); status.Active != BackendPrimary {
+		t.Fatalf("Expected the primary to still be active, got %+v", status)
+	}
+
+	resp, err := client.ProxyRequest(context.Background(), httptest.NewRequest("GET", "/stats", nil), "/stats")
+	if err != nil {
+		t.Fatalf("Expected the secondary to serve requests rejected by the primary breaker, got %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("X-Upstream") != BackendSecondary {
+		t.Errorf("Expected a 200 from the secondary, got %d from %s", resp.StatusCode, resp.Header.Get("X-Upstream"))
+	}
+	if primaryCalls != 2 {
+		t.Errorf("Expected the open breaker to keep requests off the primary, got %d calls", primaryCalls)
+	}
+}
+
+func TestFailover_FailsBackAfterStableRecoveryWindow(t *testing.T) {
+	var healthy int32
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&healthy) == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer primary.Close()
+
+	failover := NewFailover(&config.Config{
+		BackendHost:              primary.URL,
+		SecondaryBackendHost:     "http://standby",
+		FailoverFailureThreshold: 1,
+		FailoverRecoveryWindow:   time.Minute,
+		UpstreamHealthPath:       "/api/v2/stats",
+		UpstreamHealthInterval:   time.Hour,
+	}, http.DefaultTransport)
+	now := time.Now()
+	failover.now = func() time.Time { return now }
+
+	failover.ProbePrimary(context.Background())
+	if role, _ := failover.Current(); role != BackendSecondary {
+		t.Fatalf("Expected failover after a failed probe, got %s", role)
+	}
+
+	atomic.StoreInt32(&healthy, 1)
+	failover.ProbePrimary(context.Background())
+	now = now.Add(30 * time.Second)
+	failover.ProbePrimary(context.Background())
+	if role, _ := failover.Current(); role != BackendSecondary {
+		t.Fatal("Expected to stay on the secondary before the recovery window has passed")
+	}
+
+	now = now.Add(31 * time.Second)
+	failover.ProbePrimary(context.Background())
+	if role, baseURL := failover.Current(); role != BackendPrimary || baseURL != primary.URL {
+		t.Errorf("Expected failback to the primary, got %s (%s)", role, baseURL)
+	}
+}
+
+func TestFailover_RetriesPrimaryWithoutProbes(t *testing.T) {
+	failover := NewFailover(&config.Config{
+		BackendHost:              "http://primary",
+		SecondaryBackendHost:     "http://standby/",
+		FailoverFailureThreshold: 1,
+		FailoverRecoveryWindow:   time.Minute,
+	}, http.DefaultTransport)
+	now := time.Now()
+	failover.now = func() time.Time { return now }
+
+	failover.RecordResult(BackendPrimary, false)
+	if role, baseURL := failover.Current(); role != BackendSecondary || baseURL != "http://standby" {
+		t.Fatalf("Expected failover to http://standby, got %s (%s)", role, baseURL)
+	}
+
+	// Errors from the secondary do not count against the primary
+	failover.RecordResult(BackendSecondary, false)
+
+	now = now.Add(time.Minute)
+	if role, _ := failover.Current(); role != BackendPrimary {
+		t.Errorf("Expected the primary to be retried after the recovery window, got %s", role)
+	}
+}
+
+func TestProxyRequest_RootRoutesFollowFailover(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer primary.Close()
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/robots.txt" {
+			t.Errorf("Expected path /robots.txt, got %s", r.URL.Path)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer secondary.Close()
+
+	client := NewHTTPClient(&config.Config{
+		BackendHost:              primary.URL,
+		SecondaryBackendHost:     secondary.URL,
+		FailoverFailureThreshold: 1,
+		FailoverRecoveryWindow:   time.Minute,
+		Timeout:                  5 * time.Second,
+	})
+	ctx := WithBackendRoot(context.Background())
+
+	resp, err := client.ProxyRequest(ctx, httptest.NewRequest("GET", "/robots.txt", nil), "/robots.txt")
+	if err != nil {
+		t.Fatalf("ProxyRequest failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.Header.Get("X-Upstream") != BackendPrimary {
+		t.Errorf("Expected the first request on the primary, got %s", resp.Header.Get("X-Upstream"))
+	}
+
+	resp, err = client.ProxyRequest(ctx, httptest.NewRequest("GET", "/robots.txt", nil), "/robots.txt")
+	if err != nil {
+		t.Fatalf("ProxyRequest failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("X-Upstream") != BackendSecondary {
+		t.Errorf("Expected a 200 from the secondary, got %d from %s", resp.StatusCode, resp.Header.Get("X-Upstream"))
+	}
+
+	// WebSocket tunnels pick their backend the same way
+	baseURL, done := client.PickBackend()
+	done()
+	if baseURL != secondary.URL {
+		t.Errorf("Expected WebSocket tunnels to use the secondary, got %s", baseURL)
+	}
+}
@@ -0,0 +1,242 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"go-api-proxy/config"
+)
+
+// hopByHopHeaders apply to a single connection and are never passed on
+var hopByHopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailers",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// headerTemplateVariables are the placeholders available in set and append values
+var headerTemplateVariables = map[string]func(req *http.Request, route string) string{
+	"request_id": func(req *http.Request, route string) string { return getRequestIDFromContext(req.Context()) },
+	"client_ip":  func(req *http.Request, route string) string { return GetClientIP(req) },
+	"route":      func(req *http.Request, route string) string { return route },
+	"method":     func(req *http.Request, route string) string { return req.Method },
+	"path":       func(req *http.Request, route string) string { return req.URL.Path },
+	"host":       func(req *http.Request, route string) string { return req.Host },
+}
+
+// HeaderPolicy is a route's compiled header rules. A nil policy keeps the defaults.
+type HeaderPolicy struct {
+	route  string
+	allow  []string
+	deny   []string
+	set    []headerValue
+	append []headerValue
+}
+
+// headerValue is a header with a templated value, split into literal text and
+// placeholders
+type headerValue struct {
+	name  string
+	parts []templatePart
+}
+
+// templatePart is either literal text or a placeholder variable
+type templatePart struct {
+	text     string
+	variable string
+}
+
+// NewHeaderPolicy compiles header rules for a route. It returns nil when the route
+// has no rules.
+func NewHeaderPolicy(route string, rules *config.HeaderRules) (*HeaderPolicy, error) {
+	if rules == nil {
+		return nil, nil
+	}
+
+	p := &HeaderPolicy{route: route}
+	for _, name := range rules.Allow {
+		p.allow = append(p.allow, strings.ToLower(name))
+	}
+	for _, name := range rules.Deny {
+		p.deny = append(p.deny, strings.ToLower(name))
+	}
+
+	var err error
+	if p.set, err = compileHeaderValues(rules.Set); err != nil {
+		return nil, err
+	}
+	if p.append, err = compileHeaderValues(rules.Append); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// compileHeaderValues parses the templates of set or append rules, in name order
+func compileHeaderValues(values map[string]string) ([]headerValue, error) {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	compiled := make([]headerValue, 0, len(names))
+	for _, name := range names {
+		parts, err := parseHeaderTemplate(values[name])
+		if err != nil {
+			return nil, fmt.Errorf("header %s: %w", name, err)
+		}
+		compiled = append(compiled, headerValue{name: http.CanonicalHeaderKey(name), parts: parts})
+	}
+	return compiled, nil
+}
+
+// parseHeaderTemplate splits a value such as "proxy-{request_id}" into its parts
+func parseHeaderTemplate(value string) ([]templatePart, error) {
+	var parts []templatePart
+	for value != "" {
+		start := strings.Index(value, "{")
+		if start == -1 {
+			if hasControlChars(value) {
+				return nil, fmt.Errorf("control character in %q", value)
+			}
+			parts = append(parts, templatePart{text: value})
+			break
+		}
+		end := strings.Index(value[start:], "}")
+		if end == -1 {
+			return nil, fmt.Errorf("unclosed placeholder in %q", value)
+		}
+		variable := value[start+1 : start+end]
+		if _, ok := headerTemplateVariables[variable]; !ok {
+			return nil, fmt.Errorf("unknown placeholder {%s}", variable)
+		}
+		if start > 0 {
+			if hasControlChars(value[:start]) {
+				return nil, fmt.Errorf("control character in %q", value)
+			}
+			parts = append(parts, templatePart{text: value[:start]})
+		}
+		parts = append(parts, templatePart{variable: variable})
+		value = value[start+end+1:]
+	}
+	return parts, nil
+}
+
+// render fills in the placeholders for a request. Values come from the client, such as
+// the decoded path, so control characters in them are percent-encoded to keep the
+// header valid.
+func (v headerValue) render(req *http.Request, route string) string {
+	var b strings.Builder
+	for _, part := range v.parts {
+		if part.variable != "" {
+			writeEscaped(&b, headerTemplateVariables[part.variable](req, route))
+			continue
+		}
+		b.WriteString(part.text)
+	}
+	return b.String()
+}
+
+// writeEscaped writes the value with control characters, including CR and LF,
+// percent-encoded
+func writeEscaped(b *strings.Builder, value string) {
+	for i := 0; i < len(value); i++ {
+		if c := value[i]; isControlChar(c) {
+			fmt.Fprintf(b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+}
+
+// hasControlChars reports whether the value contains a character that is not
+// allowed in a header value
+func hasControlChars(value string) bool {
+	for i := 0; i < len(value); i++ {
+		if isControlChar(value[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// isControlChar reports whether c is an ASCII control character other than tab
+func isControlChar(c byte) bool {
+	return (c < 0x20 && c != '\t') || c == 0x7f
+}
+
+// Passes reports whether a header passes the policy. Headers matching allow always
+// pass, headers matching deny never do, and others pass when they do by default.
+func (p *HeaderPolicy) Passes(name string, byDefault bool) bool {
+	if p == nil {
+		return byDefault
+	}
+	if matchesHeader(p.allow, name) {
+		return true
+	}
+	if matchesHeader(p.deny, name) {
+		return false
+	}
+	return byDefault
+}
+
+// Apply sets and appends the policy's header values, rendered for the request
+func (p *HeaderPolicy) Apply(header http.Header, req *http.Request) {
+	if p == nil {
+		return
+	}
+	for _, value := range p.set {
+		header.Set(value.name, value.render(req, p.route))
+	}
+	for _, value := range p.append {
+		header.Add(value.name, value.render(req, p.route))
+	}
+}
+
+// matchesHeader reports whether the header name matches any of the lower-cased
+// patterns, which may end in "*" to match a prefix
+func matchesHeader(patterns []string, name string) bool {
+	name = strings.ToLower(name)
+	for _, pattern := range patterns {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if pattern == name {
+			return true
+		}
+	}
+	return false
+}
+
+// IsHopByHopHeader reports whether a header only applies to a single connection
+func IsHopByHopHeader(name string) bool {
+	for _, hopHeader := range hopByHopHeaders {
+		if strings.EqualFold(name, hopHeader) {
+			return true
+		}
+	}
+	return false
+}
+
+// WithRequestHeaders returns a context that applies the route's request header
+// policy to backend requests
+func WithRequestHeaders(ctx context.Context, policy *HeaderPolicy) context.Context {
+	return context.WithValue(ctx, "request_headers", policy)
+}
+
+// requestHeadersFor returns the request header policy of the route, if any
+func requestHeadersFor(ctx context.Context) *HeaderPolicy {
+	if policy, ok := ctx.Value("request_headers").(*HeaderPolicy); ok {
+		return policy
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-api-proxy/config"
+)
+
+func TestHeaderPolicy_Passes(t *testing.T) {
+	policy, err := NewHeaderPolicy("api", &config.HeaderRules{
+		Allow: []string{"Authorization", "X-B3-*"},
+		Deny:  []string{"user-agent", "X-*"},
+	})
+	if err != nil {
+		t.Fatalf("NewHeaderPolicy failed: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		byDefault bool
+		expected  bool
+	}{
+		{"Authorization", false, true},
+		{"X-B3-Traceid", false, true},
+		{"User-Agent", true, false},
+		{"X-Custom", true, false},
+		{"Accept", true, true},
+		{"Cookie", false, false},
+	}
+
+	for _, tt := range tests {
+		if result := policy.Passes(tt.name, tt.byDefault); result != tt.expected {
+			t.Errorf("Passes(%q, %v) = %v, expected %v", tt.name, tt.byDefault, result, tt.expected)
+		}
+	}
+
+	var none *HeaderPolicy
+	if !none.Passes("Accept", true) || none.Passes("Cookie", false) {
+		t.Error("Expected a nil policy to keep the defaults")
+	}
+}
+
+func TestHeaderPolicy_Apply(t *testing.T) {
+	policy, err := NewHeaderPolicy("blocks", &config.HeaderRules{
+		Set:    map[string]string{"x-request-id": "{request_id}", "X-Proxy-Route": "{route} {method} {path}"},
+		Append: map[string]string{"Via": "1.1 go-api-proxy", "Forwarded": "for={client_ip};host={host}"},
+	})
+	if err != nil {
+		t.Fatalf("NewHeaderPolicy failed: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "http://api.example.com/api/v2/blocks", nil)
+	req.RemoteAddr = "203.0.113.5:4000"
+	req = req.WithContext(context.WithValue(req.Context(), "request_id", "req-123"))
+
+	header := http.Header{"Via": {"1.1 cdn"}, "X-Request-Id": {"spoofed"}}
+	policy.Apply(header, req)
+
+	if header.Get("X-Request-Id") != "req-123" {
+		t.Errorf("Expected X-Request-Id to be replaced, got %v", header["X-Request-Id"])
+	}
+	if header.Get("X-Proxy-Route") != "blocks GET /api/v2/blocks" {
+		t.Errorf("Unexpected X-Proxy-Route %q", header.Get("X-Proxy-Route"))
+	}
+	if via := header.Values("Via"); len(via) != 2 || via[1] != "1.1 go-api-proxy" {
+		t.Errorf("Expected Via to be appended, got %v", via)
+	}
+	if header.Get("Forwarded") != "for=203.0.113.5;host=api.example.com" {
+		t.Errorf("Unexpected Forwarded %q", header.Get("Forwarded"))
+	}
+}
+
+func TestHeaderPolicy_ApplyEscapesControlCharacters(t *testing.T) {
+	policy, err := NewHeaderPolicy("api", &config.HeaderRules{
+		Set: map[string]string{"X-Original-Path": "{path}"},
+	})
+	if err != nil {
+		t.Fatalf("NewHeaderPolicy failed: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v2/x%0Ay%0D%7Fz%09", nil)
+	header := http.Header{}
+	policy.Apply(header, req)
+
+	if got := header.Get("X-Original-Path"); got != "/api/v2/x%0Ay%0D%7Fz\t" {
+		t.Errorf("Expected control characters to be percent-encoded, got %q", got)
+	}
+}
+
+func TestNewHeaderPolicy_InvalidTemplate(t *testing.T) {
+	for _, value := range []string{"{unknown}", "prefix-{request_id", "{}", "line\nbreak", "{path}\r"} {
+		if _, err := NewHeaderPolicy("api", &config.HeaderRules{Set: map[string]string{"X-Test": value}}); err == nil {
+			t.Errorf("Expected an error for %q", value)
+		}
+	}
+	if policy, err := NewHeaderPolicy("api", nil); policy != nil || err != nil {
+		t.Errorf("Expected no policy without rules, got %v, %v", policy, err)
+	}
+}
+
+func TestForwardHeaders_WithPolicy(t *testing.T) {
+	client := NewHTTPClient(&config.Config{BackendHost: "https://example.com", Timeout: 5 * time.Second})
+	policy, err := NewHeaderPolicy("api", &config.HeaderRules{
+		Allow: []string{"Authorization", "Range", "Traceparent", "Tracestate", "X-Forwarded-For", "Accept-Encoding"},
+		Deny:  []string{"User-Agent"},
+		Set:   map[string]string{"X-Request-ID": "{request_id}"},
+	})
+	if err != nil {
+		t.Fatalf("NewHeaderPolicy failed: %v", err)
+	}
+
+	originalReq := httptest.NewRequest(http.MethodGet, "/test", nil)
+	originalReq.Header.Set("Authorization", "Bearer abc")
+	originalReq.Header.Set("Range", "bytes=0-99")
+	originalReq.Header.Set("Traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	originalReq.Header.Set("User-Agent", "test-agent")
+	originalReq.Header.Set("X-Forwarded-For", "6.6.6.6")
+	originalReq.Header.Set("Accept-Encoding", "br")
+	originalReq.Header.Set("Connection", "keep-alive")
+	originalReq.RemoteAddr = "192.168.1.1:12345"
+	ctx := context.WithValue(originalReq.Context(), "request_id", "req-1")
+	originalReq = originalReq.WithContext(WithRequestHeaders(ctx, policy))
+
+	backendReq := httptest.NewRequest(http.MethodGet, "https://example.com/test", nil)
+	client.forwardHeaders(originalReq, backendReq)
+
+	expected := map[string]string{
+		"Authorization":   "Bearer abc",
+		"Range":           "bytes=0-99",
+		"Traceparent":     "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+		"User-Agent":      "go-api-proxy/1.0",
+		"X-Forwarded-For": "192.168.1.1",
+		"X-Request-Id":    "req-1",
+		"Accept-Encoding": backendAcceptEncoding,
+		"Connection":      "",
+	}
+	for name, value := range expected {
+		if got := backendReq.Header.Get(name); got != value {
+			t.Errorf("Expected %s %q, got %q", name, value, got)
+		}
+	}
+}
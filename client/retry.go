@@ -0,0 +1,128 @@
+package client
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-api-proxy/config"
+)
+
+// RetryPolicy controls how failed backend requests are retried
+type RetryPolicy struct {
+	MaxAttempts      int
+	BaseDelay        time.Duration
+	MaxDelay         time.Duration
+	RetryStatusCodes map[int]bool
+}
+
+// NewRetryPolicy creates a retry policy from configuration. A zero attempt count disables retries.
+func NewRetryPolicy(cfg *config.Config) *RetryPolicy {
+	policy := &RetryPolicy{
+		MaxAttempts:      cfg.RetryMaxAttempts,
+		BaseDelay:        cfg.RetryBaseDelay,
+		MaxDelay:         cfg.RetryMaxDelay,
+		RetryStatusCodes: make(map[int]bool),
+	}
+
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+	if policy.MaxDelay < policy.BaseDelay {
+		policy.MaxDelay = policy.BaseDelay
+	}
+	for _, code := range cfg.RetryStatusCodes {
+		policy.RetryStatusCodes[code] = true
+	}
+
+	return policy
+}
+
+// IsIdempotent reports whether a request may be retried: GET and HEAD always,
+// other methods only when the client supplied an Idempotency-Key
+func IsIdempotent(req *http.Request) bool {
+	if req.Method == http.MethodGet || req.Method == http.MethodHead {
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != ""
+}
+
+// shouldRetryStatus reports whether a response status code is retryable
+func (p *RetryPolicy) shouldRetryStatus(statusCode int) bool {
+	return p.RetryStatusCodes[statusCode]
+}
+
+// backoff returns the delay before the given retry (1-based) using exponential backoff with full jitter
+func (p *RetryPolicy) backoff(retry int) time.Duration {
+	if p.BaseDelay <= 0 {
+		return 0
+	}
+
+	delay := p.MaxDelay
+	if shift := retry - 1; shift < 32 {
+		if exp := p.BaseDelay << uint(shift); exp > 0 && exp < p.MaxDelay {
+			delay = exp
+		}
+	}
+
+	return time.Duration(rand.Int64N(int64(delay) + 1))
+}
+
+// retryDelay returns the delay before the next attempt, honouring a Retry-After header when present
+func (p *RetryPolicy) retryDelay(retry int, resp *http.Response) time.Duration {
+	delay := p.backoff(retry)
+	if resp == nil {
+		return delay
+	}
+
+	if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok && retryAfter > delay {
+		return retryAfter
+	}
+	return delay
+}
+
+// parseRetryAfter parses a Retry-After header given either as seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		if delay := date.Sub(now); delay > 0 {
+			return delay, true
+		}
+		return 0, true
+	}
+
+	return 0, false
+}
+
+// fitsDeadline reports whether waiting for the delay still leaves time before the context deadline
+func fitsDeadline(ctx context.Context, delay time.Duration) bool {
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= delay {
+		return false
+	}
+	return true
+}
+
+// sleepContext waits for the delay, returning false if the context ends first
+func sleepContext(ctx context.Context, delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,222 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go-api-proxy/config"
+)
+
+func newRetryTestClient(backendURL string, attempts int) *HTTPClient {
+	return NewHTTPClient(&config.Config{
+		BackendHost:      backendURL,
+		Timeout:          5 * time.Second,
+		RetryMaxAttempts: attempts,
+		RetryBaseDelay:   time.Millisecond,
+		RetryMaxDelay:    5 * time.Millisecond,
+		RetryStatusCodes: []int{502, 503},
+	})
+}
+
+func TestProxyRequest_RetriesRetryableStatus(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	client := newRetryTestClient(server.URL, 3)
+
+	resp, err := client.ProxyRequest(context.Background(), httptest.NewRequest("GET", "/stats", nil), "/stats")
+	if err != nil {
+		t.Fatalf("ProxyRequest failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200 after retries, got %d", resp.StatusCode)
+	}
+	if calls != 3 {
+		t.Errorf("Expected 3 attempts, got %d", calls)
+	}
+}
+
+func TestProxyRequest_ReturnsLastResponseWhenAttemptsExhausted(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	client := newRetryTestClient(server.URL, 2)
+
+	resp, err := client.ProxyRequest(context.Background(), httptest.NewRequest("GET", "/stats", nil), "/stats")
+	if err != nil {
+		t.Fatalf("ProxyRequest failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("Expected final status 502, got %d", resp.StatusCode)
+	}
+	if calls != 2 {
+		t.Errorf("Expected 2 attempts, got %d", calls)
+	}
+}
+
+func TestProxyRequest_DoesNotRetryNonIdempotent(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := newRetryTestClient(server.URL, 3)
+
+	resp, err := client.ProxyRequest(context.Background(), httptest.NewRequest("POST", "/verify", strings.NewReader("{}")), "/verify")
+	if err != nil {
+		t.Fatalf("ProxyRequest failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if calls != 1 {
+		t.Errorf("Expected a single attempt for POST without idempotency key, got %d", calls)
+	}
+}
+
+func TestProxyRequest_RetriesWithIdempotencyKey(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != `{"a":1}` {
+			t.Errorf("Expected body to be replayed, got %q", string(body))
+		}
+		if r.Header.Get("Idempotency-Key") != "abc" {
+			t.Errorf("Expected Idempotency-Key to be forwarded")
+		}
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	client := newRetryTestClient(server.URL, 3)
+
+	req := httptest.NewRequest("POST", "/verify", strings.NewReader(`{"a":1}`))
+	req.Header.Set("Idempotency-Key", "abc")
+	resp, err := client.ProxyRequest(context.Background(), req, "/verify")
+	if err != nil {
+		t.Fatalf("ProxyRequest failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated || calls != 2 {
+		t.Errorf("Expected success on 2nd attempt, got status %d after %d attempts", resp.StatusCode, calls)
+	}
+}
+
+func TestProxyRequest_RetriesNetworkErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	backendURL := server.URL
+	server.Close()
+
+	client := newRetryTestClient(backendURL, 3)
+
+	start := time.Now()
+	_, err := client.ProxyRequest(context.Background(), httptest.NewRequest("GET", "/stats", nil), "/stats")
+	if err == nil {
+		t.Fatal("Expected error for unreachable backend")
+	}
+	if !IsNetworkError(err) {
+		t.Errorf("Expected network error, got %v", err)
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Errorf("Retries took too long: %v", time.Since(start))
+	}
+}
+
+func TestProxyRequest_RetryAfterBeyondDeadline(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Retry-After", "10")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := newRetryTestClient(server.URL, 3)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	resp, err := client.ProxyRequest(ctx, httptest.NewRequest("GET", "/stats", nil), "/stats")
+	if err != nil {
+		t.Fatalf("ProxyRequest failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected the 503 to be returned, got %d", resp.StatusCode)
+	}
+	if calls != 1 {
+		t.Errorf("Expected no retry when Retry-After exceeds the deadline, got %d attempts", calls)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value    string
+		expected time.Duration
+		ok       bool
+	}{
+		{"", 0, false},
+		{"5", 5 * time.Second, true},
+		{"-1", 0, false},
+		{"Mon, 01 Jan 2024 12:00:30 GMT", 30 * time.Second, true},
+		{"Mon, 01 Jan 2024 11:00:00 GMT", 0, true},
+		{"soon", 0, false},
+	}
+
+	for _, tt := range tests {
+		delay, ok := parseRetryAfter(tt.value, now)
+		if ok != tt.ok || delay != tt.expected {
+			t.Errorf("parseRetryAfter(%q) = (%v, %v), expected (%v, %v)", tt.value, delay, ok, tt.expected, tt.ok)
+		}
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := NewRetryPolicy(&config.Config{
+		RetryMaxAttempts: 5,
+		RetryBaseDelay:   100 * time.Millisecond,
+		RetryMaxDelay:    time.Second,
+	})
+
+	for retry := 1; retry <= 10; retry++ {
+		delay := policy.backoff(retry)
+		if delay < 0 || delay > time.Second {
+			t.Errorf("backoff(%d) = %v, expected within [0, 1s]", retry, delay)
+		}
+	}
+
+	if NewRetryPolicy(&config.Config{}).MaxAttempts != 1 {
+		t.Error("Expected zero configuration to disable retries")
+	}
+}
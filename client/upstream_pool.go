@@ -0,0 +1,357 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go-api-proxy/config"
+	"go-api-proxy/logger"
+)
+
+// Load balancing strategies
+const (
+	BalancerRoundRobin       = "round-robin"
+	BalancerLeastOutstanding = "least-outstanding"
+)
+
+// Upstream is a single backend instance in the pool
+type Upstream struct {
+	Name   string // alias reported to clients instead of the host
+	URL    string
+	APIURL string
+
+	outstanding int64
+
+	mu                   sync.Mutex
+	healthy              bool
+	consecutiveFailures  int
+	consecutiveSuccesses int
+	ejectedAt            time.Time
+	lastProbe            time.Time
+	lastError            string
+	totalRequests        int64
+	totalFailures        int64
+}
+
+// UpstreamStatus is a point-in-time view of an upstream for health reporting.
+// Upstreams are identified by name, so the public health check never exposes backend hosts.
+type UpstreamStatus struct {
+	Name                string `json:"name"`
+	Healthy             bool   `json:"healthy"`
+	Outstanding         int64  `json:"outstanding"`
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+	TotalRequests       int64  `json:"total_requests"`
+	TotalFailures       int64  `json:"total_failures"`
+	LastProbe           string `json:"last_probe,omitempty"`
+	LastError           string `json:"last_error,omitempty"`
+}
+
+// UpstreamPool balances requests across backend instances, ejecting instances
+// that fail repeatedly and reintroducing them once they recover
+type UpstreamPool struct {
+	upstreams        []*Upstream
+	balancer         string
+	healthPath       string
+	healthInterval   time.Duration
+	ejectFailures    int
+	recoverSuccesses int
+	ejectDuration    time.Duration
+	probeClient      *http.Client
+	next             uint64
+	now              func() time.Time
+
+	stopOnce sync.Once
+	stopCh   chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewUpstreamPool creates an upstream pool for the configured backend hosts. It returns
+// nil when BackendHosts is not set, in which case all traffic goes to BackendHost.
+func NewUpstreamPool(cfg *config.Config, transport http.RoundTripper) *UpstreamPool {
+	if len(cfg.BackendHosts) == 0 {
+		return nil
+	}
+
+	pool := &UpstreamPool{
+		balancer:         cfg.UpstreamBalancer,
+		healthPath:       cfg.UpstreamHealthPath,
+		healthInterval:   cfg.UpstreamHealthInterval,
+		ejectFailures:    cfg.UpstreamEjectFailures,
+		recoverSuccesses: cfg.UpstreamRecoverSuccesses,
+		ejectDuration:    cfg.UpstreamEjectDuration,
+		probeClient: &http.Client{
+			Timeout:   5 * time.Second,
+			Transport: transport,
+		},
+		now:    time.Now,
+		stopCh: make(chan struct{}),
+	}
+
+	if pool.balancer == "" {
+		pool.balancer = BalancerRoundRobin
+	}
+	if pool.ejectFailures < 1 {
+		pool.ejectFailures = 3
+	}
+	if pool.recoverSuccesses < 1 {
+		pool.recoverSuccesses = 1
+	}
+
+	for i, host := range cfg.BackendHosts {
+		host = strings.TrimSuffix(host, "/")
+		pool.upstreams = append(pool.upstreams, &Upstream{
+			Name:    fmt.Sprintf("upstream-%d", i+1),
+			URL:     host,
+			APIURL:  host + "/api/v2",
+			healthy: true,
+		})
+	}
+
+	return pool
+}
+
+// Next selects an upstream for a request and marks it as having one more outstanding request.
+// Callers must call Done on the returned upstream when the request finishes.
+func (p *UpstreamPool) Next() *Upstream {
+	candidates := p.available()
+	if len(candidates) == 0 {
+		// Every upstream is ejected: fail open rather than refuse all traffic
+		candidates = p.upstreams
+	}
+
+	var selected *Upstream
+	switch p.balancer {
+	case BalancerLeastOutstanding:
+		start := int(atomic.AddUint64(&p.next, 1)-1) % len(candidates)
+		for i := range candidates {
+			u := candidates[(start+i)%len(candidates)]
+			if selected == nil || atomic.LoadInt64(&u.outstanding) < atomic.LoadInt64(&selected.outstanding) {
+				selected = u
+			}
+		}
+	default:
+		selected = candidates[int(atomic.AddUint64(&p.next, 1)-1)%len(candidates)]
+	}
+
+	atomic.AddInt64(&selected.outstanding, 1)
+	return selected
+}
+
+// Done marks an outstanding request on the upstream as finished
+func (u *Upstream) Done() {
+	atomic.AddInt64(&u.outstanding, -1)
+}
+
+// available returns the upstreams currently eligible for traffic
+func (p *UpstreamPool) available() []*Upstream {
+	now := p.now()
+	candidates := make([]*Upstream, 0, len(p.upstreams))
+	for _, u := range p.upstreams {
+		u.mu.Lock()
+		eligible := u.healthy
+		// Without active probes, ejected upstreams get another chance after the ejection period
+		if !eligible && p.healthInterval <= 0 && p.ejectDuration > 0 && now.Sub(u.ejectedAt) >= p.ejectDuration {
+			eligible = true
+		}
+		u.mu.Unlock()
+
+		if eligible {
+			candidates = append(candidates, u)
+		}
+	}
+	return candidates
+}
+
+// RecordResult records the outcome of a proxied request for passive health checking
+func (p *UpstreamPool) RecordResult(u *Upstream, success bool, errMsg string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.totalRequests++
+	if success {
+		u.consecutiveFailures = 0
+		if !u.healthy && p.healthInterval <= 0 {
+			p.setHealthy(u, true, "passive_recovery")
+		}
+		return
+	}
+
+	u.totalFailures++
+	u.consecutiveFailures++
+	u.lastError = errMsg
+	if u.healthy && u.consecutiveFailures >= p.ejectFailures {
+		p.setHealthy(u, false, "passive_ejection")
+	}
+}
+
+// setHealthy changes the health of an upstream; callers must hold the upstream lock
+func (p *UpstreamPool) setHealthy(u *Upstream, healthy bool, reason string) {
+	if u.healthy == healthy {
+		return
+	}
+
+	u.healthy = healthy
+	u.consecutiveSuccesses = 0
+	if !healthy {
+		u.ejectedAt = p.now()
+		logger.ClientLogger.Warn("Upstream ejected", map[string]interface{}{
+			"upstream":             u.URL,
+			"reason":               reason,
+			"consecutive_failures": u.consecutiveFailures,
+			"last_error":           u.lastError,
+		})
+		return
+	}
+
+	u.consecutiveFailures = 0
+	logger.ClientLogger.Info("Upstream reintroduced", map[string]interface{}{
+		"upstream": u.URL,
+		"reason":   reason,
+	})
+}
+
+// Start launches periodic active health probes. It is a no-op when probing is disabled.
+func (p *UpstreamPool) Start() {
+	if p == nil || p.healthInterval <= 0 || p.healthPath == "" {
+		return
+	}
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+
+		ticker := time.NewTicker(p.healthInterval)
+		defer ticker.Stop()
+
+		p.ProbeAll(context.Background())
+		for {
+			select {
+			case <-ticker.C:
+				p.ProbeAll(context.Background())
+			case <-p.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the health probes and waits for them to finish
+func (p *UpstreamPool) Stop() {
+	if p == nil {
+		return
+	}
+	p.stopOnce.Do(func() {
+		close(p.stopCh)
+	})
+	p.wg.Wait()
+}
+
+// ProbeAll runs one active health probe against every upstream
+func (p *UpstreamPool) ProbeAll(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, u := range p.upstreams {
+		wg.Add(1)
+		go func(u *Upstream) {
+			defer wg.Done()
+			p.probe(ctx, u)
+		}(u)
+	}
+	wg.Wait()
+}
+
+// probe checks a single upstream's health endpoint
+func (p *UpstreamPool) probe(ctx context.Context, u *Upstream) {
+	errMsg := ""
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.URL+p.healthPath, nil)
+	if err == nil {
+		req.Header.Set("User-Agent", "go-api-proxy/1.0 (health-check)")
+		var resp *http.Response
+		resp, err = p.probeClient.Do(req)
+		if err == nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+			if resp.StatusCode < 200 || resp.StatusCode > 299 {
+				errMsg = "health probe returned " + resp.Status
+			}
+		}
+	}
+	if err != nil {
+		errMsg = err.Error()
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.lastProbe = p.now()
+	if errMsg == "" {
+		u.consecutiveFailures = 0
+		u.consecutiveSuccesses++
+		if !u.healthy && u.consecutiveSuccesses >= p.recoverSuccesses {
+			p.setHealthy(u, true, "active_probe")
+		}
+		return
+	}
+
+	u.consecutiveSuccesses = 0
+	u.consecutiveFailures++
+	u.lastError = errMsg
+	if u.healthy && u.consecutiveFailures >= p.ejectFailures {
+		p.setHealthy(u, false, "active_probe")
+	}
+}
+
+// Status returns the state of every upstream in the pool
+func (p *UpstreamPool) Status() []UpstreamStatus {
+	if p == nil {
+		return nil
+	}
+	statuses := make([]UpstreamStatus, 0, len(p.upstreams))
+	for _, u := range p.upstreams {
+		u.mu.Lock()
+		status := UpstreamStatus{
+			Name:                u.Name,
+			Healthy:             u.healthy,
+			Outstanding:         atomic.LoadInt64(&u.outstanding),
+			ConsecutiveFailures: u.consecutiveFailures,
+			TotalRequests:       u.totalRequests,
+			TotalFailures:       u.totalFailures,
+			LastError:           u.redact(u.lastError),
+		}
+		if !u.lastProbe.IsZero() {
+			status.LastProbe = u.lastProbe.UTC().Format(time.RFC3339)
+		}
+		u.mu.Unlock()
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// redact replaces the upstream's URL and host in a message with its name
+func (u *Upstream) redact(message string) string {
+	message = strings.ReplaceAll(message, u.URL, u.Name)
+	if parsed, err := url.Parse(u.URL); err == nil && parsed.Host != "" {
+		message = strings.ReplaceAll(message, parsed.Host, u.Name)
+		message = strings.ReplaceAll(message, parsed.Hostname(), u.Name)
+	}
+	return message
+}
+
+// trackedBody calls done exactly once when the response body is closed
+type trackedBody struct {
+	io.ReadCloser
+	once sync.Once
+	done func()
+}
+
+func (b *trackedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.done)
+	return err
+}
@@ -0,0 +1,257 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go-api-proxy/config"
+)
+
+func newTestPool(hosts []string, balancer string) *UpstreamPool {
+	return NewUpstreamPool(&config.Config{
+		BackendHosts:             hosts,
+		UpstreamBalancer:         balancer,
+		UpstreamHealthPath:       "/api/v2/stats",
+		UpstreamEjectFailures:    2,
+		UpstreamRecoverSuccesses: 1,
+	}, http.DefaultTransport)
+}
+
+func TestNewUpstreamPool_DisabledWithoutHosts(t *testing.T) {
+	if pool := NewUpstreamPool(&config.Config{BackendHost: "https://example.com"}, http.DefaultTransport); pool != nil {
+		t.Error("Expected no pool without BackendHosts")
+	}
+}
+
+func TestUpstreamPool_RoundRobin(t *testing.T) {
+	pool := newTestPool([]string{"http://a", "http://b/"}, BalancerRoundRobin)
+
+	seen := map[string]int{}
+	for i := 0; i < 4; i++ {
+		u := pool.Next()
+		seen[u.URL]++
+		u.Done()
+	}
+
+	if seen["http://a"] != 2 || seen["http://b"] != 2 {
+		t.Errorf("Expected even distribution, got %v", seen)
+	}
+	if pool.upstreams[1].APIURL != "http://b/api/v2" {
+		t.Errorf("Expected API URL http://b/api/v2, got %s", pool.upstreams[1].APIURL)
+	}
+}
+
+func TestUpstreamPool_LeastOutstanding(t *testing.T) {
+	pool := newTestPool([]string{"http://a", "http://b"}, BalancerLeastOutstanding)
+
+	busy := pool.Next()
+	for i := 0; i < 3; i++ {
+		u := pool.Next()
+		if u == busy {
+			t.Fatalf("Expected the idle upstream to be selected, got busy %s", u.URL)
+		}
+		u.Done()
+	}
+	busy.Done()
+}
+
+func TestUpstreamPool_PassiveEjectionAndProbeRecovery(t *testing.T) {
+	var healthy int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&healthy) == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	pool := newTestPool([]string{server.URL, "http://other"}, BalancerRoundRobin)
+	pool.healthInterval = time.Hour
+	target := pool.upstreams[0]
+
+	pool.RecordResult(target, false, "backend returned 502")
+	pool.RecordResult(target, false, "backend returned 502")
+
+	if status := pool.Status()[0]; status.Healthy {
+		t.Fatal("Expected upstream to be ejected after consecutive failures")
+	}
+	for i := 0; i < 4; i++ {
+		u := pool.Next()
+		if u == target {
+			t.Fatal("Expected ejected upstream to receive no traffic")
+		}
+		u.Done()
+	}
+
+	// A failing probe keeps it out, a passing probe brings it back
+	pool.probe(context.Background(), target)
+	if pool.Status()[0].Healthy {
+		t.Fatal("Expected upstream to stay ejected while probes fail")
+	}
+
+	atomic.StoreInt32(&healthy, 1)
+	pool.probe(context.Background(), target)
+
+	status := pool.Status()[0]
+	if !status.Healthy {
+		t.Fatal("Expected upstream to be reintroduced after a successful probe")
+	}
+	if status.LastProbe == "" {
+		t.Error("Expected last probe time to be reported")
+	}
+}
+
+func TestUpstreamPool_SuccessfulProbeResetsFailures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	pool := newTestPool([]string{server.URL}, BalancerRoundRobin)
+	target := pool.upstreams[0]
+
+	pool.RecordResult(target, false, "backend returned 502")
+	pool.probe(context.Background(), target)
+	if status := pool.Status()[0]; status.ConsecutiveFailures != 0 {
+		t.Fatalf("Expected a successful probe to reset failures, got %d", status.ConsecutiveFailures)
+	}
+
+	// Failures on either side of the probe are not consecutive
+	pool.RecordResult(target, false, "backend returned 502")
+	if !pool.Status()[0].Healthy {
+		t.Error("Expected the upstream to stay in the pool")
+	}
+}
+
+func TestUpstreamPool_EjectionExpiresWithoutProbes(t *testing.T) {
+	pool := newTestPool([]string{"http://a", "http://b"}, BalancerRoundRobin)
+	pool.ejectDuration = 30 * time.Second
+	now := time.Now()
+	pool.now = func() time.Time { return now }
+
+	pool.RecordResult(pool.upstreams[0], false, "error")
+	pool.RecordResult(pool.upstreams[0], false, "error")
+	if len(pool.available()) != 1 {
+		t.Fatalf("Expected 1 available upstream, got %d", len(pool.available()))
+	}
+
+	now = now.Add(31 * time.Second)
+	if len(pool.available()) != 2 {
+		t.Errorf("Expected ejected upstream to be retried after the ejection period")
+	}
+}
+
+func TestUpstreamPool_AllEjectedFailsOpen(t *testing.T) {
+	pool := newTestPool([]string{"http://a"}, BalancerRoundRobin)
+	pool.RecordResult(pool.upstreams[0], false, "error")
+	pool.RecordResult(pool.upstreams[0], false, "error")
+
+	u := pool.Next()
+	if u == nil || u.URL != "http://a" {
+		t.Errorf("Expected fallback to the ejected upstream, got %v", u)
+	}
+	u.Done()
+}
+
+func TestUpstreamPool_StatusDoesNotExposeHosts(t *testing.T) {
+	pool := newTestPool([]string{"http://10.0.0.5:8080"}, BalancerRoundRobin)
+	pool.RecordResult(pool.upstreams[0], false, "network error during GET to http://10.0.0.5:8080/api/v2/stats: dial tcp 10.0.0.5:8080: connection refused")
+
+	status := pool.Status()[0]
+	if status.Name != "upstream-1" {
+		t.Errorf("Expected upstream-1, got %s", status.Name)
+	}
+	if strings.Contains(status.LastError, "10.0.0.5") {
+		t.Errorf("Expected the host to be redacted from the last error, got %q", status.LastError)
+	}
+}
+
+func TestProxyRequest_BalancesAcrossUpstreams(t *testing.T) {
+	var callsA, callsB int32
+	serverA := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v2/blocks" {
+			t.Errorf("Expected path /api/v2/blocks, got %s", r.URL.Path)
+		}
+		atomic.AddInt32(&callsA, 1)
+	}))
+	defer serverA.Close()
+	serverB := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&callsB, 1)
+	}))
+	defer serverB.Close()
+
+	client := NewHTTPClient(&config.Config{
+		BackendHost:  serverA.URL,
+		BackendHosts: []string{serverA.URL, serverB.URL},
+		Timeout:      5 * time.Second,
+	})
+
+	for i := 0; i < 4; i++ {
+		resp, err := client.ProxyRequest(context.Background(), httptest.NewRequest("GET", "/blocks", nil), "/blocks")
+		if err != nil {
+			t.Fatalf("ProxyRequest failed: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	if callsA != 2 || callsB != 2 {
+		t.Errorf("Expected 2 requests per upstream, got %d and %d", callsA, callsB)
+	}
+
+	for _, status := range client.UpstreamStatus() {
+		if status.Outstanding != 0 {
+			t.Errorf("Expected no outstanding requests after bodies were closed, got %d for %s", status.Outstanding, status.Name)
+		}
+		if status.TotalRequests != 2 {
+			t.Errorf("Expected 2 total requests for %s, got %d", status.Name, status.TotalRequests)
+		}
+	}
+}
+
+func TestProxyRequest_RootRoutesUseThePool(t *testing.T) {
+	var calls int32
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/robots.txt" {
+			t.Errorf("Expected path /robots.txt without the API prefix, got %s", r.URL.Path)
+		}
+		atomic.AddInt32(&calls, 1)
+	})
+	serverA := httptest.NewServer(handler)
+	defer serverA.Close()
+	serverB := httptest.NewServer(handler)
+	defer serverB.Close()
+
+	client := NewHTTPClient(&config.Config{
+		BackendHost:  "http://backend-host.invalid",
+		BackendHosts: []string{serverA.URL, serverB.URL},
+		Timeout:      5 * time.Second,
+	})
+
+	ctx := WithBackendRoot(context.Background())
+	for i := 0; i < 2; i++ {
+		resp, err := client.ProxyRequest(ctx, httptest.NewRequest("GET", "/robots.txt", nil), "/robots.txt")
+		if err != nil {
+			t.Fatalf("ProxyRequest failed: %v", err)
+		}
+		resp.Body.Close()
+	}
+	if calls != 2 {
+		t.Errorf("Expected both requests to reach the pool, got %d", calls)
+	}
+
+	seen := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		baseURL, done := client.PickBackend()
+		seen[baseURL] = true
+		done()
+	}
+	if !seen[serverA.URL] || !seen[serverB.URL] {
+		t.Errorf("Expected tunnels to be balanced across the pool, got %v", seen)
+	}
+}
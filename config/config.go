@@ -2,12 +2,15 @@ package config
 
 import (
 	"fmt"
+	"net/url"
 	"os"
 	"strconv"
 	"strings"
 	"time"
 
+	"go-api-proxy/jwtauth"
 	"go-api-proxy/logger"
+	"go-api-proxy/tlsutil"
 )
 
 // Config holds all application configuration settings
@@ -16,6 +19,95 @@ type Config struct {
 	Port          string
 	WhitelistFile string
 	Timeout       time.Duration
+	RoutesFile    string
+	MaxBodyBytes  int64
+
+	RetryMaxAttempts int
+	RetryBaseDelay   time.Duration
+	RetryMaxDelay    time.Duration
+	RetryStatusCodes []int
+
+	CircuitFailureRatio     float64
+	CircuitMinRequests      int
+	CircuitWindow           time.Duration
+	CircuitCooldown         time.Duration
+	CircuitHalfOpenRequests int
+
+	BackendHosts             []string
+	UpstreamBalancer         string
+	UpstreamHealthPath       string
+	UpstreamHealthInterval   time.Duration
+	UpstreamEjectFailures    int
+	UpstreamRecoverSuccesses int
+	UpstreamEjectDuration    time.Duration
+
+	SecondaryBackendHost     string
+	FailoverFailureThreshold int
+	FailoverRecoveryWindow   time.Duration
+
+	CacheMaxBytes      int64
+	CacheMaxEntryBytes int64
+	CacheDir           string
+	CacheDiskMaxBytes  int64
+
+	CompressionEnabled  bool
+	CompressionMinBytes int
+
+	WebSocketAllowedOrigins []string
+	WebSocketIdleTimeout    time.Duration
+	WebSocketMaxConnections int
+	WebSocketMaxFrameBytes  int64
+	WebSocketTokenFilter    string
+
+	TLSCertFile       string
+	TLSKeyFile        string
+	TLSMinVersion     string
+	TLSCipherPolicy   string
+	TLSReloadInterval time.Duration
+	HTTPRedirectPort  string
+
+	BackendTLSCertFile   string
+	BackendTLSKeyFile    string
+	BackendTLSCAFile     string
+	BackendTLSServerName string
+	BackendTLSPinSHA256  []string
+
+	UpstreamAPIKeyFile   string
+	UpstreamAPIKeyParam  string
+	UpstreamAPIKeyHeader string
+
+	RateLimitRPS         float64
+	RateLimitBurst       int
+	RateLimitKey         string
+	RateLimitIdleTimeout time.Duration
+
+	APIKeysFile      string
+	APIKeysUsageFile string
+	APIKeysRequired  bool
+	APIKeyHeader     string
+	APIKeyParam      string
+
+	JWTJWKSFile       string
+	JWTIssuer         string
+	JWTAudience       string
+	JWTRolesClaim     string
+	JWTAdminRoles     []string
+	JWTLeeway         time.Duration
+	JWTReloadInterval time.Duration
+
+	TrustedProxies     []string
+	TrustedProxyHeader string
+
+	IPFilterFile           string
+	IPFilterReloadInterval time.Duration
+
+	EndpointPolicyFile string
+
+	RequestValidation bool
+
+	PublicOrigin string
+
+	AdminToken string
 }
 
 // Load creates a new Config instance with values from environment variables and defaults
@@ -26,7 +118,96 @@ func Load() (*Config, error) {
 		BackendHost:   getEnvWithDefault("BACKEND_HOST", "https://exp.co2e.cc"),
 		Port:          getEnvWithDefault("PORT", "80"),
 		WhitelistFile: getEnvWithDefault("WHITELIST_FILE", "whitelist.json"),
-		Timeout:       getTimeoutFromEnv("HTTP_TIMEOUT", 30*time.Second),
+		Timeout:       getSecondsFromEnv("HTTP_TIMEOUT", 30*time.Second, 1),
+		RoutesFile:    os.Getenv("ROUTES_FILE"),
+		MaxBodyBytes:  getInt64FromEnv("MAX_BODY_BYTES", 10<<20),
+
+		RetryMaxAttempts: int(getInt64FromEnv("RETRY_MAX_ATTEMPTS", 3)),
+		RetryBaseDelay:   getMillisecondsFromEnv("RETRY_BASE_DELAY_MS", 100*time.Millisecond),
+		RetryMaxDelay:    getMillisecondsFromEnv("RETRY_MAX_DELAY_MS", 2*time.Second),
+		RetryStatusCodes: getIntListFromEnv("RETRY_STATUS_CODES", []int{502, 503, 504}),
+
+		CircuitFailureRatio:     getFloatFromEnv("CIRCUIT_FAILURE_RATIO", 0.5),
+		CircuitMinRequests:      int(getInt64FromEnv("CIRCUIT_MIN_REQUESTS", 10)),
+		CircuitWindow:           getSecondsFromEnv("CIRCUIT_WINDOW_SECONDS", 60*time.Second, 1),
+		CircuitCooldown:         getSecondsFromEnv("CIRCUIT_COOLDOWN_SECONDS", 30*time.Second, 1),
+		CircuitHalfOpenRequests: int(getInt64FromEnv("CIRCUIT_HALF_OPEN_REQUESTS", 1)),
+
+		BackendHosts:             getStringListFromEnv("BACKEND_HOSTS"),
+		UpstreamBalancer:         getEnvWithDefault("UPSTREAM_BALANCER", "round-robin"),
+		UpstreamHealthPath:       getEnvWithDefault("UPSTREAM_HEALTH_PATH", "/api/v2/stats"),
+		UpstreamHealthInterval:   getSecondsFromEnv("UPSTREAM_HEALTH_INTERVAL_SECONDS", 10*time.Second, 0),
+		UpstreamEjectFailures:    int(getInt64FromEnv("UPSTREAM_EJECT_FAILURES", 3)),
+		UpstreamRecoverSuccesses: int(getInt64FromEnv("UPSTREAM_RECOVER_SUCCESSES", 2)),
+		UpstreamEjectDuration:    getSecondsFromEnv("UPSTREAM_EJECT_SECONDS", 30*time.Second, 0),
+
+		SecondaryBackendHost:     os.Getenv("SECONDARY_BACKEND_HOST"),
+		FailoverFailureThreshold: int(getInt64FromEnv("FAILOVER_FAILURE_THRESHOLD", 3)),
+		FailoverRecoveryWindow:   getSecondsFromEnv("FAILOVER_RECOVERY_SECONDS", 60*time.Second, 0),
+
+		CacheMaxBytes:      getInt64FromEnv("CACHE_MAX_BYTES", 64<<20),
+		CacheMaxEntryBytes: getInt64FromEnv("CACHE_MAX_ENTRY_BYTES", 1<<20),
+		CacheDir:           os.Getenv("CACHE_DIR"),
+		CacheDiskMaxBytes:  getInt64FromEnv("CACHE_DISK_MAX_BYTES", 1<<30),
+
+		CompressionEnabled:  getBoolFromEnv("COMPRESSION_ENABLED", true),
+		CompressionMinBytes: int(getInt64FromEnv("COMPRESSION_MIN_BYTES", 1024)),
+
+		WebSocketAllowedOrigins: getStringListFromEnv("WEBSOCKET_ALLOWED_ORIGINS"),
+		WebSocketIdleTimeout:    getSecondsFromEnv("WEBSOCKET_IDLE_TIMEOUT_SECONDS", 120*time.Second, 0),
+		WebSocketMaxConnections: int(getInt64FromEnv("WEBSOCKET_MAX_CONNECTIONS", 1000)),
+		WebSocketMaxFrameBytes:  getInt64FromEnv("WEBSOCKET_MAX_FRAME_BYTES", 4<<20),
+		WebSocketTokenFilter:    getEnvWithDefault("WEBSOCKET_TOKEN_FILTER", "drop"),
+
+		TLSCertFile:       os.Getenv("TLS_CERT_FILE"),
+		TLSKeyFile:        os.Getenv("TLS_KEY_FILE"),
+		TLSMinVersion:     getEnvWithDefault("TLS_MIN_VERSION", "1.2"),
+		TLSCipherPolicy:   getEnvWithDefault("TLS_CIPHER_POLICY", "intermediate"),
+		TLSReloadInterval: getSecondsFromEnv("TLS_RELOAD_INTERVAL_SECONDS", 30*time.Second, 0),
+		HTTPRedirectPort:  os.Getenv("HTTP_REDIRECT_PORT"),
+
+		BackendTLSCertFile:   os.Getenv("BACKEND_TLS_CERT_FILE"),
+		BackendTLSKeyFile:    os.Getenv("BACKEND_TLS_KEY_FILE"),
+		BackendTLSCAFile:     os.Getenv("BACKEND_TLS_CA_FILE"),
+		BackendTLSServerName: os.Getenv("BACKEND_TLS_SERVER_NAME"),
+		BackendTLSPinSHA256:  getStringListFromEnv("BACKEND_TLS_PIN_SHA256"),
+
+		UpstreamAPIKeyFile:   os.Getenv("UPSTREAM_API_KEY_FILE"),
+		UpstreamAPIKeyParam:  getEnvWithDefault("UPSTREAM_API_KEY_PARAM", "apikey"),
+		UpstreamAPIKeyHeader: os.Getenv("UPSTREAM_API_KEY_HEADER"),
+
+		RateLimitRPS:         getFloatFromEnv("RATE_LIMIT_RPS", 0),
+		RateLimitBurst:       int(getInt64FromEnv("RATE_LIMIT_BURST", 0)),
+		RateLimitKey:         getEnvWithDefault("RATE_LIMIT_KEY", "ip"),
+		RateLimitIdleTimeout: getSecondsFromEnv("RATE_LIMIT_IDLE_SECONDS", 10*time.Minute, 0),
+
+		APIKeysFile:      os.Getenv("API_KEYS_FILE"),
+		APIKeysUsageFile: os.Getenv("API_KEYS_USAGE_FILE"),
+		APIKeysRequired:  getBoolFromEnv("API_KEYS_REQUIRED", false),
+		APIKeyHeader:     getEnvWithDefault("API_KEY_HEADER", "X-API-Key"),
+		APIKeyParam:      getEnvWithDefault("API_KEY_PARAM", "apikey"),
+
+		JWTJWKSFile:       os.Getenv("JWT_JWKS_FILE"),
+		JWTIssuer:         os.Getenv("JWT_ISSUER"),
+		JWTAudience:       os.Getenv("JWT_AUDIENCE"),
+		JWTRolesClaim:     getEnvWithDefault("JWT_ROLES_CLAIM", "roles"),
+		JWTAdminRoles:     getStringListFromEnv("JWT_ADMIN_ROLES"),
+		JWTLeeway:         getSecondsFromEnv("JWT_LEEWAY_SECONDS", time.Minute, 0),
+		JWTReloadInterval: getSecondsFromEnv("JWT_JWKS_RELOAD_SECONDS", 30*time.Second, 0),
+
+		TrustedProxies:     getStringListFromEnv("TRUSTED_PROXIES"),
+		TrustedProxyHeader: strings.ToLower(getEnvWithDefault("TRUSTED_PROXY_HEADER", "x-forwarded-for")),
+
+		IPFilterFile:           os.Getenv("IP_FILTER_FILE"),
+		IPFilterReloadInterval: getSecondsFromEnv("IP_FILTER_RELOAD_SECONDS", 10*time.Second, 0),
+
+		EndpointPolicyFile: os.Getenv("ENDPOINT_POLICY_FILE"),
+
+		RequestValidation: getBoolFromEnv("REQUEST_VALIDATION", true),
+
+		PublicOrigin: os.Getenv("PUBLIC_ORIGIN"),
+
+		AdminToken: os.Getenv("ADMIN_TOKEN"),
 	}
 
 	logger.ConfigLogger.Debug("Configuration loaded", map[string]interface{}{
@@ -34,6 +215,33 @@ func Load() (*Config, error) {
 		"port":           config.Port,
 		"whitelist_file": config.WhitelistFile,
 		"timeout":        config.Timeout.String(),
+		"routes_file":    config.RoutesFile,
+		"max_body_bytes": config.MaxBodyBytes,
+		"retry_attempts": config.RetryMaxAttempts,
+		"retry_statuses": config.RetryStatusCodes,
+		"circuit_ratio":  config.CircuitFailureRatio,
+		"backend_hosts":  config.GetBackendHosts(),
+		"balancer":       config.UpstreamBalancer,
+		"secondary_host": config.SecondaryBackendHost,
+		"cache_bytes":    config.CacheMaxBytes,
+		"cache_dir":      config.CacheDir,
+		"compression":    config.CompressionEnabled,
+		"ws_max_conns":   config.WebSocketMaxConnections,
+		"tls":            config.TLSEnabled(),
+		"redirect_port":  config.HTTPRedirectPort,
+		"backend_mtls":   config.BackendTLSCertFile != "",
+		"backend_ca":     config.BackendTLSCAFile,
+		"upstream_key":   config.UpstreamAPIKeyFile != "",
+		"rate_limit_rps": config.RateLimitRPS,
+		"api_keys_file":  config.APIKeysFile,
+		"jwt_jwks_file":  config.JWTJWKSFile,
+		"trusted_cidrs":  config.TrustedProxies,
+		"proxy_header":   config.TrustedProxyHeader,
+		"ip_filter_file": config.IPFilterFile,
+		"policy_file":    config.EndpointPolicyFile,
+		"validation":     config.RequestValidation,
+		"public_origin":  config.PublicOrigin,
+		"admin_api":      config.AdminToken != "",
 	})
 
 	if err := config.Validate(); err != nil {
@@ -64,6 +272,10 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("port must be a valid number between 1 and 65535")
 	}
 
+	if err := c.validateTLS(); err != nil {
+		return err
+	}
+
 	if c.WhitelistFile == "" {
 		return fmt.Errorf("whitelist file path cannot be empty")
 	}
@@ -72,14 +284,235 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("timeout must be greater than 0")
 	}
 
+	if c.MaxBodyBytes < 0 {
+		return fmt.Errorf("max body bytes cannot be negative")
+	}
+
+	if c.CacheMaxBytes < 0 || c.CacheMaxEntryBytes < 0 || c.CacheDiskMaxBytes < 0 {
+		return fmt.Errorf("cache sizes cannot be negative")
+	}
+
+	if c.CompressionMinBytes < 0 {
+		return fmt.Errorf("compression min bytes cannot be negative")
+	}
+
+	if c.WebSocketMaxConnections < 0 || c.WebSocketMaxFrameBytes < 0 {
+		return fmt.Errorf("websocket limits cannot be negative")
+	}
+
+	if c.RetryMaxAttempts < 0 {
+		return fmt.Errorf("retry max attempts cannot be negative")
+	}
+
+	for _, host := range c.BackendHosts {
+		if !strings.HasPrefix(host, "http://") && !strings.HasPrefix(host, "https://") {
+			return fmt.Errorf("backend host %q must start with http:// or https://", host)
+		}
+	}
+
+	if c.SecondaryBackendHost != "" {
+		if !strings.HasPrefix(c.SecondaryBackendHost, "http://") && !strings.HasPrefix(c.SecondaryBackendHost, "https://") {
+			return fmt.Errorf("secondary backend host must start with http:// or https://")
+		}
+		if len(c.BackendHosts) > 0 {
+			return fmt.Errorf("secondary backend host cannot be combined with backend hosts")
+		}
+	}
+
+	if c.PublicOrigin != "" {
+		parsed, err := url.Parse(c.PublicOrigin)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" || strings.Trim(parsed.Path, "/") != "" {
+			return fmt.Errorf("public origin must be an http:// or https:// URL without a path")
+		}
+	}
+
+	if c.UpstreamAPIKeyFile != "" && c.UpstreamAPIKeyParam == "" && c.UpstreamAPIKeyHeader == "" {
+		return fmt.Errorf("upstream API key requires a query parameter or header name")
+	}
+
+	if c.RateLimitRPS < 0 || c.RateLimitBurst < 0 {
+		return fmt.Errorf("rate limit cannot be negative")
+	}
+
+	switch c.RateLimitKey {
+	case "", "ip", "api-key":
+	default:
+		return fmt.Errorf("rate limit key must be ip or api-key")
+	}
+	if c.RateLimitKey == "api-key" && c.APIKeysFile == "" {
+		return fmt.Errorf("rate limit key api-key requires an API keys file")
+	}
+
+	if c.APIKeysFile != "" && c.APIKeyHeader == "" && c.APIKeyParam == "" {
+		return fmt.Errorf("API keys require a header or query parameter name")
+	}
+	if c.APIKeysRequired && c.APIKeysFile == "" {
+		return fmt.Errorf("API keys required but no API keys file is set")
+	}
+
+	if len(c.JWTAdminRoles) > 0 && c.JWTJWKSFile == "" {
+		return fmt.Errorf("JWT admin roles require a JWKS file")
+	}
+
+	for _, proxy := range c.TrustedProxies {
+		if _, err := ParseIPPrefix(proxy); err != nil {
+			return fmt.Errorf("invalid trusted proxy %q: must be an IP address or CIDR range", proxy)
+		}
+	}
+	switch c.TrustedProxyHeader {
+	case "", "x-forwarded-for", "forwarded", "x-real-ip":
+	default:
+		return fmt.Errorf("trusted proxy header must be x-forwarded-for, forwarded or x-real-ip")
+	}
+
+	if c.FailoverFailureThreshold < 0 {
+		return fmt.Errorf("failover failure threshold cannot be negative")
+	}
+
+	switch c.WebSocketTokenFilter {
+	case "", "off", "drop", "annotate":
+	default:
+		return fmt.Errorf("websocket token filter must be off, drop or annotate")
+	}
+
+	switch c.UpstreamBalancer {
+	case "", "round-robin", "least-outstanding":
+	default:
+		return fmt.Errorf("upstream balancer must be round-robin or least-outstanding")
+	}
+
+	if c.CircuitFailureRatio < 0 || c.CircuitFailureRatio > 1 {
+		return fmt.Errorf("circuit failure ratio must be between 0 and 1")
+	}
+
+	for _, code := range c.RetryStatusCodes {
+		if code < 100 || code > 599 {
+			return fmt.Errorf("retry status code %d is not a valid HTTP status", code)
+		}
+	}
+
 	return nil
 }
 
+// validateTLS checks the listener and backend TLS settings and the redirect listener
+func (c *Config) validateTLS() error {
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		return fmt.Errorf("TLS cert file and key file must be set together")
+	}
+
+	if c.TLSEnabled() {
+		switch c.TLSMinVersion {
+		case "", "1.2", "1.3":
+		default:
+			return fmt.Errorf("TLS min version must be 1.2 or 1.3")
+		}
+
+		switch c.TLSCipherPolicy {
+		case "", "modern", "intermediate", "default":
+		default:
+			return fmt.Errorf("TLS cipher policy must be modern, intermediate or default")
+		}
+	}
+
+	if (c.BackendTLSCertFile == "") != (c.BackendTLSKeyFile == "") {
+		return fmt.Errorf("backend TLS cert file and key file must be set together")
+	}
+
+	for _, pin := range c.BackendTLSPinSHA256 {
+		if _, err := tlsutil.ParseFingerprint(pin); err != nil {
+			return fmt.Errorf("backend TLS pin: %w", err)
+		}
+	}
+
+	if c.HTTPRedirectPort != "" {
+		if !c.TLSEnabled() {
+			return fmt.Errorf("HTTP redirect port requires TLS")
+		}
+		if port, err := strconv.Atoi(c.HTTPRedirectPort); err != nil || port < 1 || port > 65535 {
+			return fmt.Errorf("HTTP redirect port must be a valid number between 1 and 65535")
+		}
+		if c.HTTPRedirectPort == c.Port {
+			return fmt.Errorf("HTTP redirect port must differ from port")
+		}
+	}
+
+	return nil
+}
+
+// TLSEnabled reports whether the proxy terminates TLS itself
+func (c *Config) TLSEnabled() bool {
+	return c.TLSCertFile != "" && c.TLSKeyFile != ""
+}
+
+// BackendTLSOptions returns the TLS settings for backend connections
+func (c *Config) BackendTLSOptions() tlsutil.ClientOptions {
+	return tlsutil.ClientOptions{
+		CertFile:       c.BackendTLSCertFile,
+		KeyFile:        c.BackendTLSKeyFile,
+		CAFile:         c.BackendTLSCAFile,
+		ServerName:     c.BackendTLSServerName,
+		PinSHA256:      c.BackendTLSPinSHA256,
+		Hosts:          c.backendHostNames(),
+		ReloadInterval: c.TLSReloadInterval,
+	}
+}
+
+// backendHostNames returns the host names of the configured backends: the backend
+// hosts and the secondary backend, but not the backends of individual routes
+func (c *Config) backendHostNames() []string {
+	hosts := append([]string{c.BackendHost}, c.BackendHosts...)
+	if c.SecondaryBackendHost != "" {
+		hosts = append(hosts, c.SecondaryBackendHost)
+	}
+
+	var names []string
+	for _, host := range hosts {
+		if parsed, err := url.Parse(host); err == nil && parsed.Hostname() != "" {
+			names = append(names, parsed.Hostname())
+		}
+	}
+	return names
+}
+
+// JWTOptions returns the JWT validation options
+func (c *Config) JWTOptions() jwtauth.Options {
+	return jwtauth.Options{
+		JWKSFile:       c.JWTJWKSFile,
+		Issuer:         c.JWTIssuer,
+		Audience:       c.JWTAudience,
+		Leeway:         c.JWTLeeway,
+		ReloadInterval: c.JWTReloadInterval,
+	}
+}
+
 // GetBackendAPIURL returns the full backend API URL
 func (c *Config) GetBackendAPIURL() string {
 	return strings.TrimSuffix(c.BackendHost, "/") + "/api/v2"
 }
 
+// GetBackendHosts returns the backend instances to balance across, defaulting to BackendHost
+func (c *Config) GetBackendHosts() []string {
+	if len(c.BackendHosts) > 0 {
+		return c.BackendHosts
+	}
+	return []string{c.BackendHost}
+}
+
+// GetBackendOrigins returns every backend the proxy forwards to: the backend hosts,
+// the secondary backend and the backends of the given routes
+func (c *Config) GetBackendOrigins(routes []Route) []string {
+	origins := append([]string{}, c.GetBackendHosts()...)
+	if c.SecondaryBackendHost != "" {
+		origins = append(origins, c.SecondaryBackendHost)
+	}
+	for _, route := range routes {
+		if strings.HasPrefix(route.Backend, "http://") || strings.HasPrefix(route.Backend, "https://") {
+			origins = append(origins, route.Backend)
+		}
+	}
+	return origins
+}
+
 // getEnvWithDefault returns the environment variable value or the default if not set
 func getEnvWithDefault(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
@@ -88,12 +521,86 @@ func getEnvWithDefault(key, defaultValue string) string {
 	return defaultValue
 }
 
-// getTimeoutFromEnv parses timeout from environment variable or returns default
-func getTimeoutFromEnv(key string, defaultValue time.Duration) time.Duration {
+// getInt64FromEnv parses a non-negative integer from environment variable or returns default
+func getInt64FromEnv(key string, defaultValue int64) int64 {
+	if value := os.Getenv(key); value != "" {
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil && n >= 0 {
+			return n
+		}
+	}
+	return defaultValue
+}
+
+// getMillisecondsFromEnv parses a duration in milliseconds from environment variable or returns default
+func getMillisecondsFromEnv(key string, defaultValue time.Duration) time.Duration {
 	if value := os.Getenv(key); value != "" {
-		if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		if ms, err := strconv.Atoi(value); err == nil && ms >= 0 {
+			return time.Duration(ms) * time.Millisecond
+		}
+	}
+	return defaultValue
+}
+
+// getIntListFromEnv parses a comma-separated list of integers from environment variable or returns default
+func getIntListFromEnv(key string, defaultValue []int) []int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	var result []int
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return defaultValue
+		}
+		result = append(result, n)
+	}
+	return result
+}
+
+// getFloatFromEnv parses a non-negative float from environment variable or returns default
+func getFloatFromEnv(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if f, err := strconv.ParseFloat(value, 64); err == nil && f >= 0 {
+			return f
+		}
+	}
+	return defaultValue
+}
+
+// getSecondsFromEnv parses a number of seconds of at least minSeconds from environment
+// variable or returns default
+func getSecondsFromEnv(key string, defaultValue time.Duration, minSeconds int) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil && seconds >= minSeconds {
 			return time.Duration(seconds) * time.Second
 		}
 	}
 	return defaultValue
-}
\ No newline at end of file
+}
+
+// getStringListFromEnv parses a comma-separated list of strings from environment variable
+func getStringListFromEnv(key string) []string {
+	var result []string
+	for _, part := range strings.Split(os.Getenv(key), ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			result = append(result, part)
+		}
+	}
+	return result
+}
+
+// getBoolFromEnv parses a boolean from environment variable or returns default
+func getBoolFromEnv(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.ParseBool(value); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
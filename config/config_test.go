@@ -2,6 +2,7 @@ package config
 
 import (
 	"os"
+	"strings"
 	"testing"
 	"time"
 )
@@ -167,6 +168,141 @@ func TestValidate(t *testing.T) {
 			expectError: true,
 			errorMsg:    "timeout must be greater than 0",
 		},
+		{
+			name: "secondary backend with backend hosts",
+			config: Config{
+				BackendHost:          "https://api.example.com",
+				Port:                 "8080",
+				WhitelistFile:        "whitelist.json",
+				Timeout:              30 * time.Second,
+				BackendHosts:         []string{"https://a.example.com", "https://b.example.com"},
+				SecondaryBackendHost: "https://standby.example.com",
+			},
+			expectError: true,
+			errorMsg:    "secondary backend host cannot be combined with backend hosts",
+		},
+		{
+			name: "TLS cert without key",
+			config: Config{
+				BackendHost:   "https://api.example.com",
+				Port:          "443",
+				WhitelistFile: "whitelist.json",
+				Timeout:       30 * time.Second,
+				TLSCertFile:   "/etc/proxy/tls.crt",
+			},
+			expectError: true,
+			errorMsg:    "TLS cert file and key file must be set together",
+		},
+		{
+			name: "redirect port without TLS",
+			config: Config{
+				BackendHost:      "https://api.example.com",
+				Port:             "8080",
+				WhitelistFile:    "whitelist.json",
+				Timeout:          30 * time.Second,
+				HTTPRedirectPort: "80",
+			},
+			expectError: true,
+			errorMsg:    "HTTP redirect port requires TLS",
+		},
+		{
+			name: "invalid backend TLS pin",
+			config: Config{
+				BackendHost:         "https://api.example.com",
+				Port:                "8080",
+				WhitelistFile:       "whitelist.json",
+				Timeout:             30 * time.Second,
+				BackendTLSPinSHA256: []string{"abcd"},
+			},
+			expectError: true,
+			errorMsg:    `backend TLS pin: invalid SHA-256 fingerprint "abcd"`,
+		},
+		{
+			name: "invalid rate limit key",
+			config: Config{
+				BackendHost:   "https://api.example.com",
+				Port:          "8080",
+				WhitelistFile: "whitelist.json",
+				Timeout:       30 * time.Second,
+				RateLimitRPS:  5,
+				RateLimitKey:  "cookie",
+			},
+			expectError: true,
+			errorMsg:    "rate limit key must be ip or api-key",
+		},
+		{
+			name: "rate limit by API key without API keys",
+			config: Config{
+				BackendHost:   "https://api.example.com",
+				Port:          "8080",
+				WhitelistFile: "whitelist.json",
+				Timeout:       30 * time.Second,
+				RateLimitRPS:  5,
+				RateLimitKey:  "api-key",
+			},
+			expectError: true,
+			errorMsg:    "rate limit key api-key requires an API keys file",
+		},
+		{
+			name: "API keys required without keys file",
+			config: Config{
+				BackendHost:     "https://api.example.com",
+				Port:            "8080",
+				WhitelistFile:   "whitelist.json",
+				Timeout:         30 * time.Second,
+				APIKeysRequired: true,
+			},
+			expectError: true,
+			errorMsg:    "API keys required but no API keys file is set",
+		},
+		{
+			name: "JWT admin roles without JWKS file",
+			config: Config{
+				BackendHost:   "https://api.example.com",
+				Port:          "8080",
+				WhitelistFile: "whitelist.json",
+				Timeout:       30 * time.Second,
+				JWTAdminRoles: []string{"proxy-admin"},
+			},
+			expectError: true,
+			errorMsg:    "JWT admin roles require a JWKS file",
+		},
+		{
+			name: "invalid trusted proxy",
+			config: Config{
+				BackendHost:    "https://api.example.com",
+				Port:           "8080",
+				WhitelistFile:  "whitelist.json",
+				Timeout:        30 * time.Second,
+				TrustedProxies: []string{"10.0.0.0/33"},
+			},
+			expectError: true,
+			errorMsg:    `invalid trusted proxy "10.0.0.0/33": must be an IP address or CIDR range`,
+		},
+		{
+			name: "invalid trusted proxy header",
+			config: Config{
+				BackendHost:        "https://api.example.com",
+				Port:               "8080",
+				WhitelistFile:      "whitelist.json",
+				Timeout:            30 * time.Second,
+				TrustedProxyHeader: "x-client-ip",
+			},
+			expectError: true,
+			errorMsg:    "trusted proxy header must be x-forwarded-for, forwarded or x-real-ip",
+		},
+		{
+			name: "public origin with path",
+			config: Config{
+				BackendHost:   "https://api.example.com",
+				Port:          "8080",
+				WhitelistFile: "whitelist.json",
+				Timeout:       30 * time.Second,
+				PublicOrigin:  "https://explorer.example.com/api",
+			},
+			expectError: true,
+			errorMsg:    "public origin must be an http:// or https:// URL without a path",
+		},
 	}
 
 	for _, tt := range tests {
@@ -237,12 +373,12 @@ func TestGetEnvWithDefault(t *testing.T) {
 	})
 }
 
-func TestGetTimeoutFromEnv(t *testing.T) {
+func TestGetSecondsFromEnv(t *testing.T) {
 	t.Run("returns parsed timeout from environment", func(t *testing.T) {
 		os.Setenv("TEST_TIMEOUT", "45")
 		defer os.Unsetenv("TEST_TIMEOUT")
 
-		result := getTimeoutFromEnv("TEST_TIMEOUT", 30*time.Second)
+		result := getSecondsFromEnv("TEST_TIMEOUT", 30*time.Second, 1)
 		expected := 45 * time.Second
 		if result != expected {
 			t.Errorf("expected %v, got %v", expected, result)
@@ -253,7 +389,7 @@ func TestGetTimeoutFromEnv(t *testing.T) {
 		os.Setenv("TEST_TIMEOUT", "invalid")
 		defer os.Unsetenv("TEST_TIMEOUT")
 
-		result := getTimeoutFromEnv("TEST_TIMEOUT", 30*time.Second)
+		result := getSecondsFromEnv("TEST_TIMEOUT", 30*time.Second, 1)
 		expected := 30 * time.Second
 		if result != expected {
 			t.Errorf("expected %v, got %v", expected, result)
@@ -263,12 +399,24 @@ func TestGetTimeoutFromEnv(t *testing.T) {
 	t.Run("returns default when environment variable not set", func(t *testing.T) {
 		os.Unsetenv("TEST_TIMEOUT")
 
-		result := getTimeoutFromEnv("TEST_TIMEOUT", 30*time.Second)
+		result := getSecondsFromEnv("TEST_TIMEOUT", 30*time.Second, 1)
 		expected := 30 * time.Second
 		if result != expected {
 			t.Errorf("expected %v, got %v", expected, result)
 		}
 	})
+
+	t.Run("applies the minimum", func(t *testing.T) {
+		os.Setenv("TEST_TIMEOUT", "0")
+		defer os.Unsetenv("TEST_TIMEOUT")
+
+		if result := getSecondsFromEnv("TEST_TIMEOUT", 30*time.Second, 1); result != 30*time.Second {
+			t.Errorf("expected 0 to be rejected below the minimum, got %v", result)
+		}
+		if result := getSecondsFromEnv("TEST_TIMEOUT", 30*time.Second, 0); result != 0 {
+			t.Errorf("expected 0 to be accepted with a zero minimum, got %v", result)
+		}
+	})
 }
 
 // clearEnvVars clears all environment variables used in tests
@@ -277,4 +425,17 @@ func clearEnvVars() {
 	os.Unsetenv("PORT")
 	os.Unsetenv("WHITELIST_FILE")
 	os.Unsetenv("HTTP_TIMEOUT")
-}
\ No newline at end of file
+}
+func TestBackendTLSOptions_Hosts(t *testing.T) {
+	cfg := &Config{
+		BackendHost:          "https://backend.example",
+		BackendHosts:         []string{"https://a.example:8443", "http://10.0.0.5"},
+		SecondaryBackendHost: "https://standby.example/",
+	}
+
+	hosts := cfg.BackendTLSOptions().Hosts
+	expected := []string{"backend.example", "a.example", "10.0.0.5", "standby.example"}
+	if strings.Join(hosts, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected hosts %v, got %v", expected, hosts)
+	}
+}
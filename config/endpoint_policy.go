@@ -0,0 +1,82 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"go-api-proxy/logger"
+)
+
+// EndpointRule allows requests to paths matching a pattern with the listed methods.
+// Patterns use the same syntax as route patterns and are matched against the path
+// the client requested. Without methods, only GET and HEAD are allowed.
+type EndpointRule struct {
+	Name    string   `json:"name,omitempty"`
+	Pattern string   `json:"pattern"`
+	Methods []string `json:"methods,omitempty"`
+}
+
+// EndpointPolicy is the on-disk format of the passthrough endpoint policy file
+type EndpointPolicy struct {
+	Endpoints []EndpointRule `json:"endpoints"`
+}
+
+// AllowedMethods returns the methods the rule allows, upper-cased
+func (r EndpointRule) AllowedMethods() []string {
+	if len(r.Methods) == 0 {
+		return []string{http.MethodGet, http.MethodHead}
+	}
+	methods := make([]string, 0, len(r.Methods))
+	for _, method := range r.Methods {
+		methods = append(methods, strings.ToUpper(method))
+	}
+	return methods
+}
+
+// LoadEndpointPolicy reads the passthrough endpoint allowlist from a JSON file
+func LoadEndpointPolicy(filename string) ([]EndpointRule, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read endpoint policy file %s: %w", filename, err)
+	}
+
+	var policy EndpointPolicy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("failed to parse endpoint policy file %s: %w", filename, err)
+	}
+
+	if len(policy.Endpoints) == 0 {
+		return nil, fmt.Errorf("endpoint policy file %s contains no endpoints", filename)
+	}
+
+	for i, rule := range policy.Endpoints {
+		if err := rule.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid endpoint at index %d: %w", i, err)
+		}
+	}
+
+	logger.ConfigLogger.Info("Loaded endpoint policy", map[string]interface{}{
+		"filename":       filename,
+		"endpoint_count": len(policy.Endpoints),
+	})
+
+	return policy.Endpoints, nil
+}
+
+// Validate checks if the endpoint rule is valid
+func (r EndpointRule) Validate() error {
+	if err := validatePattern(r.Pattern); err != nil {
+		return fmt.Errorf("endpoint %q: %w", r.Pattern, err)
+	}
+
+	for _, method := range r.Methods {
+		if method == "" || strings.ContainsAny(method, " \t,") {
+			return fmt.Errorf("endpoint %q: invalid method %q", r.Pattern, method)
+		}
+	}
+
+	return nil
+}
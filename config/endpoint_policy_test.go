@@ -0,0 +1,63 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadEndpointPolicy(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("loads valid policy file", func(t *testing.T) {
+		path := filepath.Join(dir, "endpoints.json")
+		content := `{"endpoints": [
+			{"pattern": "/api/v2/*"},
+			{"name": "eth-rpc", "pattern": "/api/eth-rpc", "methods": ["post"]}
+		]}`
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write endpoint policy file: %v", err)
+		}
+
+		endpoints, err := LoadEndpointPolicy(path)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if len(endpoints) != 2 {
+			t.Fatalf("expected 2 endpoints, got %d", len(endpoints))
+		}
+
+		if methods := endpoints[0].AllowedMethods(); len(methods) != 2 || methods[0] != "GET" || methods[1] != "HEAD" {
+			t.Errorf("expected GET and HEAD by default, got %v", methods)
+		}
+
+		if methods := endpoints[1].AllowedMethods(); len(methods) != 1 || methods[0] != "POST" {
+			t.Errorf("expected methods [POST], got %v", methods)
+		}
+	})
+
+	t.Run("rejects invalid policies", func(t *testing.T) {
+		for name, content := range map[string]string{
+			"no endpoints":     `{"endpoints": []}`,
+			"relative pattern": `{"endpoints": [{"pattern": "api"}]}`,
+			"inner wildcard":   `{"endpoints": [{"pattern": "/api/*/x"}]}`,
+			"bad method":       `{"endpoints": [{"pattern": "/api", "methods": ["GET, POST"]}]}`,
+		} {
+			path := filepath.Join(dir, "invalid.json")
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatalf("failed to write endpoint policy file: %v", err)
+			}
+
+			if _, err := LoadEndpointPolicy(path); err == nil {
+				t.Errorf("%s: expected an error", name)
+			}
+		}
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		if _, err := LoadEndpointPolicy(filepath.Join(dir, "missing.json")); err == nil {
+			t.Error("expected error for missing file")
+		}
+	})
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"os"
+	"strings"
+
+	"go-api-proxy/logger"
+)
+
+// IPFilterRule restricts the client addresses that may use paths matching a pattern.
+// Patterns use the same syntax as route patterns. A client in Deny is always blocked;
+// when Allow is set, clients outside it are blocked as well.
+type IPFilterRule struct {
+	Name    string   `json:"name"`
+	Pattern string   `json:"pattern"`
+	Allow   []string `json:"allow,omitempty"`
+	Deny    []string `json:"deny,omitempty"`
+}
+
+// IPFilterTable is the on-disk format of the IP filter file
+type IPFilterTable struct {
+	Rules []IPFilterRule `json:"rules"`
+}
+
+// LoadIPFilterRules reads the IP filter rules from a JSON file
+func LoadIPFilterRules(filename string) ([]IPFilterRule, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read IP filter file %s: %w", filename, err)
+	}
+
+	var table IPFilterTable
+	if err := json.Unmarshal(data, &table); err != nil {
+		return nil, fmt.Errorf("failed to parse IP filter file %s: %w", filename, err)
+	}
+
+	for i, rule := range table.Rules {
+		if err := rule.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid IP filter rule at index %d: %w", i, err)
+		}
+	}
+
+	logger.ConfigLogger.Info("Loaded IP filter rules", map[string]interface{}{
+		"filename":   filename,
+		"rule_count": len(table.Rules),
+	})
+
+	return table.Rules, nil
+}
+
+// Validate checks if the rule is valid
+func (r IPFilterRule) Validate() error {
+	if err := validatePattern(r.Pattern); err != nil {
+		return fmt.Errorf("rule %q: %w", r.Name, err)
+	}
+
+	if len(r.Allow) == 0 && len(r.Deny) == 0 {
+		return fmt.Errorf("rule %q: needs an allow or deny list", r.Name)
+	}
+
+	for _, entry := range append(append([]string{}, r.Allow...), r.Deny...) {
+		if _, err := ParseIPPrefix(entry); err != nil {
+			return fmt.Errorf("rule %q: %w", r.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// ParseIPPrefix parses a CIDR range, or a single IP address as a range of one
+func ParseIPPrefix(entry string) (netip.Prefix, error) {
+	entry = strings.TrimSpace(entry)
+	if strings.Contains(entry, "/") {
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid CIDR %q", entry)
+		}
+		return prefix.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(entry)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid IP address %q", entry)
+	}
+	addr = addr.Unmap().WithZone("")
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
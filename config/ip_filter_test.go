@@ -0,0 +1,85 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadIPFilterRules(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("loads valid rules file", func(t *testing.T) {
+		path := filepath.Join(dir, "ip-filter.json")
+		content := `{"rules": [
+			{"name": "admin", "pattern": "/admin/*", "allow": [" 10.0.0.0/8", "192.0.2.10 "]},
+			{"name": "abuse", "pattern": "/*", "deny": ["2001:db8:bad::/48"]}
+		]}`
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write IP filter file: %v", err)
+		}
+
+		rules, err := LoadIPFilterRules(path)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if len(rules) != 2 {
+			t.Fatalf("expected 2 rules, got %d", len(rules))
+		}
+
+		if rules[0].Name != "admin" || len(rules[0].Allow) != 2 {
+			t.Errorf("expected admin rule with 2 allowed entries, got %+v", rules[0])
+		}
+	})
+
+	t.Run("rejects invalid rules", func(t *testing.T) {
+		for name, content := range map[string]string{
+			"bad CIDR":         `{"rules": [{"name": "x", "pattern": "/x", "allow": ["10.0.0.0/33"]}]}`,
+			"bad address":      `{"rules": [{"name": "x", "pattern": "/x", "deny": ["10.0.0"]}]}`,
+			"no lists":         `{"rules": [{"name": "x", "pattern": "/x"}]}`,
+			"relative pattern": `{"rules": [{"name": "x", "pattern": "x", "deny": ["10.0.0.1"]}]}`,
+			"inner wildcard":   `{"rules": [{"name": "x", "pattern": "/x/*/y", "deny": ["10.0.0.1"]}]}`,
+			"malformed JSON":   `{"rules": [`,
+		} {
+			path := filepath.Join(dir, "invalid.json")
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatalf("failed to write IP filter file: %v", err)
+			}
+
+			if _, err := LoadIPFilterRules(path); err == nil {
+				t.Errorf("%s: expected an error", name)
+			}
+		}
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		if _, err := LoadIPFilterRules(filepath.Join(dir, "missing.json")); err == nil {
+			t.Error("expected error for missing file")
+		}
+	})
+}
+
+func TestParseIPPrefix(t *testing.T) {
+	tests := []struct {
+		entry    string
+		expected string
+	}{
+		{" 10.1.2.3/8 ", "10.0.0.0/8"},
+		{"192.0.2.10", "192.0.2.10/32"},
+		{"::ffff:192.0.2.10", "192.0.2.10/32"},
+		{"2001:db8::1", "2001:db8::1/128"},
+	}
+	for _, tt := range tests {
+		prefix, err := ParseIPPrefix(tt.entry)
+		if err != nil || prefix.String() != tt.expected {
+			t.Errorf("ParseIPPrefix(%q) = %v, %v, expected %s", tt.entry, prefix, err, tt.expected)
+		}
+	}
+
+	for _, entry := range []string{"", "10.0.0.0/33", "example.com"} {
+		if _, err := ParseIPPrefix(entry); err == nil {
+			t.Errorf("ParseIPPrefix(%q): expected an error", entry)
+		}
+	}
+}
@@ -0,0 +1,292 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"go-api-proxy/logger"
+)
+
+// Route handler types
+const (
+	HandlerPassthrough = "passthrough"
+	HandlerTokenFilter = "token-filter"
+	HandlerStatic      = "static"
+	HandlerWebSocket   = "websocket"
+)
+
+// Route describes how requests matching a path pattern are handled.
+//
+// Patterns are matched segment by segment: "{name}" matches a single segment
+// and captures it as a parameter, and a trailing "*" matches any remainder.
+// Routes are evaluated in order and the first match wins.
+type Route struct {
+	Name         string          `json:"name"`
+	Pattern      string          `json:"pattern"`
+	Handler      string          `json:"handler"`
+	Backend      string          `json:"backend,omitempty"`
+	BackendRoot  bool            `json:"backend_root,omitempty"`
+	StripPrefix  string          `json:"strip_prefix,omitempty"`
+	AddPrefix    string          `json:"add_prefix,omitempty"`
+	Methods      []string        `json:"methods,omitempty"`
+	MaxBodyBytes int64           `json:"max_body_bytes,omitempty"`
+	CacheTTL     int             `json:"cache_ttl_seconds,omitempty"`
+	Static       *StaticResponse `json:"static,omitempty"`
+	Credentials  *Credentials    `json:"credentials,omitempty"`
+	RateLimit    *RateLimit      `json:"rate_limit,omitempty"`
+	Roles        []string        `json:"roles,omitempty"`
+	RewriteURLs  bool            `json:"rewrite_urls,omitempty"`
+
+	RequestHeaders  *HeaderRules `json:"request_headers,omitempty"`
+	ResponseHeaders *HeaderRules `json:"response_headers,omitempty"`
+}
+
+// HeaderRules adjust which headers pass between client and backend. A header matching
+// Allow always passes and one matching Deny never does; others keep the default
+// behavior. Names may end in "*" to match a prefix. Set replaces and Append adds
+// values, which may use {request_id}, {client_ip}, {route}, {method}, {path} and {host}.
+type HeaderRules struct {
+	Allow  []string          `json:"allow,omitempty"`
+	Deny   []string          `json:"deny,omitempty"`
+	Set    map[string]string `json:"set,omitempty"`
+	Append map[string]string `json:"append,omitempty"`
+}
+
+// Validate checks the header names used by the rules
+func (h *HeaderRules) Validate() error {
+	for _, name := range append(append([]string{}, h.Allow...), h.Deny...) {
+		if !validHeaderName(strings.TrimSuffix(name, "*")) && name != "*" {
+			return fmt.Errorf("invalid header name %q", name)
+		}
+	}
+	for _, values := range []map[string]string{h.Set, h.Append} {
+		for name := range values {
+			if !validHeaderName(name) {
+				return fmt.Errorf("invalid header name %q", name)
+			}
+		}
+	}
+	return nil
+}
+
+// validHeaderName reports whether name is a non-empty HTTP token without wildcards
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.IndexByte("!#$%&'+-.^_`|~", c) != -1) {
+			return false
+		}
+	}
+	return true
+}
+
+// RateLimit is a token bucket limit applied per client. A rate of zero disables
+// limiting; a zero burst defaults to one second's worth of requests.
+type RateLimit struct {
+	RequestsPerSecond float64 `json:"requests_per_second"`
+	Burst             int     `json:"burst,omitempty"`
+}
+
+// Credentials is the backend API key sent with a route's requests instead of the
+// proxy-wide UPSTREAM_API_KEY_FILE. The key is read from a secret file and sent in
+// the header, or in the query parameter when no header is set.
+type Credentials struct {
+	KeyFile    string `json:"key_file"`
+	QueryParam string `json:"query_param,omitempty"`
+	Header     string `json:"header,omitempty"`
+}
+
+// Param returns the query parameter for the key, defaulting to "apikey"
+func (c *Credentials) Param() string {
+	if c.QueryParam == "" {
+		return "apikey"
+	}
+	return c.QueryParam
+}
+
+// StaticResponse is the fixed response served by static routes
+type StaticResponse struct {
+	StatusCode  int    `json:"status_code"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        string `json:"body"`
+}
+
+// RouteTable is the on-disk format of the routes file
+type RouteTable struct {
+	Routes []Route `json:"routes"`
+}
+
+// DefaultRoutes returns the built-in route table used when no routes file is configured.
+// Backend paths are relative to the backend API URL unless a route sets its own backend
+// or is relative to the backend root. The built-in routes never pin a backend, so the
+// client picks a pool upstream or the active failover backend for them.
+func DefaultRoutes() []Route {
+	return []Route{
+		{
+			Name:    "tokens",
+			Pattern: "/api/v2/tokens",
+			Handler: HandlerTokenFilter,
+			Methods: []string{http.MethodGet, http.MethodHead},
+		},
+		{
+			Name:        "block",
+			Pattern:     "/api/v2/blocks/{id}",
+			Handler:     HandlerPassthrough,
+			StripPrefix: "/api/v2",
+			CacheTTL:    60,
+		},
+		{
+			Name:        "transaction",
+			Pattern:     "/api/v2/transactions/{hash}",
+			Handler:     HandlerPassthrough,
+			StripPrefix: "/api/v2",
+			CacheTTL:    60,
+		},
+		{
+			Name:        "api-v2",
+			Pattern:     "/api/v2/*",
+			Handler:     HandlerPassthrough,
+			StripPrefix: "/api/v2",
+		},
+		{
+			Name:    "websocket",
+			Pattern: "/socket/v2/websocket",
+			Handler: HandlerWebSocket,
+			Methods: []string{http.MethodGet},
+		},
+		{
+			Name:        "default",
+			Pattern:     "/*",
+			Handler:     HandlerPassthrough,
+			BackendRoot: true,
+		},
+	}
+}
+
+// LoadRoutes reads a route table from a JSON file
+func LoadRoutes(filename string) ([]Route, error) {
+	logger.ConfigLogger.Debug("Loading route table from file", map[string]interface{}{
+		"filename": filename,
+	})
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read routes file %s: %w", filename, err)
+	}
+
+	var table RouteTable
+	if err := json.Unmarshal(data, &table); err != nil {
+		return nil, fmt.Errorf("failed to parse routes file %s: %w", filename, err)
+	}
+
+	if len(table.Routes) == 0 {
+		return nil, fmt.Errorf("routes file %s contains no routes", filename)
+	}
+
+	for i, route := range table.Routes {
+		if err := route.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid route at index %d: %w", i, err)
+		}
+	}
+
+	logger.ConfigLogger.Info("Loaded route table", map[string]interface{}{
+		"filename":    filename,
+		"route_count": len(table.Routes),
+	})
+
+	return table.Routes, nil
+}
+
+// validatePattern checks the pattern syntax shared by routes, IP filter rules and
+// endpoint rules: an absolute path with an optional trailing wildcard
+func validatePattern(pattern string) error {
+	if !strings.HasPrefix(pattern, "/") {
+		return fmt.Errorf("pattern must start with /")
+	}
+
+	if idx := strings.Index(pattern, "*"); idx != -1 && idx != len(pattern)-1 {
+		return fmt.Errorf("wildcard is only allowed at the end of the pattern")
+	}
+
+	return nil
+}
+
+// Validate checks if the route definition is valid
+func (r Route) Validate() error {
+	if err := validatePattern(r.Pattern); err != nil {
+		return fmt.Errorf("route %q: %w", r.Name, err)
+	}
+
+	switch r.Handler {
+	case HandlerPassthrough, HandlerTokenFilter, HandlerWebSocket:
+	case HandlerStatic:
+		if r.Static == nil {
+			return fmt.Errorf("route %q: static handler requires a static response", r.Name)
+		}
+	default:
+		return fmt.Errorf("route %q: unknown handler type %q", r.Name, r.Handler)
+	}
+
+	if r.MaxBodyBytes < 0 {
+		return fmt.Errorf("route %q: max body bytes cannot be negative", r.Name)
+	}
+
+	if r.CacheTTL < 0 {
+		return fmt.Errorf("route %q: cache ttl cannot be negative", r.Name)
+	}
+
+	if r.Backend != "" && !strings.HasPrefix(r.Backend, "http://") && !strings.HasPrefix(r.Backend, "https://") {
+		return fmt.Errorf("route %q: backend must start with http:// or https://", r.Name)
+	}
+	if r.Backend != "" && r.BackendRoot {
+		return fmt.Errorf("route %q: backend_root cannot be combined with backend", r.Name)
+	}
+
+	if r.RateLimit != nil && (r.RateLimit.RequestsPerSecond < 0 || r.RateLimit.Burst < 0) {
+		return fmt.Errorf("route %q: rate limit cannot be negative", r.Name)
+	}
+
+	if r.Credentials != nil && r.Credentials.KeyFile == "" {
+		return fmt.Errorf("route %q: credentials require a key file", r.Name)
+	}
+
+	if r.RequestHeaders != nil {
+		if err := r.RequestHeaders.Validate(); err != nil {
+			return fmt.Errorf("route %q: request headers: %w", r.Name, err)
+		}
+	}
+
+	if r.ResponseHeaders != nil {
+		if err := r.ResponseHeaders.Validate(); err != nil {
+			return fmt.Errorf("route %q: response headers: %w", r.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// GetRoutes returns the configured route table, falling back to the defaults.
+// Routes without their own body limit inherit MaxBodyBytes.
+func (c *Config) GetRoutes() ([]Route, error) {
+	routes := DefaultRoutes()
+	if c.RoutesFile != "" {
+		loaded, err := LoadRoutes(c.RoutesFile)
+		if err != nil {
+			return nil, err
+		}
+		routes = loaded
+	}
+
+	for i := range routes {
+		if routes[i].MaxBodyBytes == 0 {
+			routes[i].MaxBodyBytes = c.MaxBodyBytes
+		}
+	}
+	return routes, nil
+}
@@ -0,0 +1,128 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGetRoutes_Defaults(t *testing.T) {
+	cfg := &Config{BackendHost: "https://example.com/"}
+
+	routes, err := cfg.GetRoutes()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(routes) != 6 {
+		t.Fatalf("expected 6 default routes, got %d", len(routes))
+	}
+
+	if routes[0].Handler != HandlerTokenFilter || routes[0].Pattern != "/api/v2/tokens" {
+		t.Errorf("expected first route to be the token filter, got %+v", routes[0])
+	}
+
+	if routes[1].CacheTTL <= 0 || routes[2].CacheTTL <= 0 {
+		t.Errorf("expected block and transaction routes to be cached, got %+v and %+v", routes[1], routes[2])
+	}
+
+	if routes[3].StripPrefix != "/api/v2" || routes[3].CacheTTL != 0 {
+		t.Errorf("expected uncached api-v2 route to strip /api/v2, got %+v", routes[3])
+	}
+
+	if routes[4].Handler != HandlerWebSocket || routes[4].Pattern != "/socket/v2/websocket" || routes[4].Backend != "" {
+		t.Errorf("expected websocket route without a pinned backend, got %+v", routes[4])
+	}
+
+	if routes[5].Backend != "" || !routes[5].BackendRoot {
+		t.Errorf("expected default route relative to the backend root without a pinned backend, got %+v", routes[5])
+	}
+}
+
+func TestLoadRoutes(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("loads valid routes file", func(t *testing.T) {
+		path := filepath.Join(dir, "routes.json")
+		content := `{"routes": [
+			{"name": "health", "pattern": "/ping", "handler": "static", "static": {"status_code": 200, "body": "pong"}},
+			{"name": "api", "pattern": "/api/v2/*", "handler": "passthrough", "strip_prefix": "/api/v2", "methods": ["GET"]}
+		]}`
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write routes file: %v", err)
+		}
+
+		routes, err := LoadRoutes(path)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if len(routes) != 2 {
+			t.Fatalf("expected 2 routes, got %d", len(routes))
+		}
+
+		if routes[0].Static == nil || routes[0].Static.Body != "pong" {
+			t.Errorf("expected static body 'pong', got %+v", routes[0].Static)
+		}
+
+		if len(routes[1].Methods) != 1 || routes[1].Methods[0] != "GET" {
+			t.Errorf("expected methods [GET], got %v", routes[1].Methods)
+		}
+	})
+
+	t.Run("rejects invalid route", func(t *testing.T) {
+		path := filepath.Join(dir, "invalid.json")
+		content := `{"routes": [{"name": "bad", "pattern": "/x", "handler": "passthrough", "backend": "ftp://nope"}]}`
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write routes file: %v", err)
+		}
+
+		if _, err := LoadRoutes(path); err == nil {
+			t.Error("expected error for invalid backend")
+		}
+	})
+
+	t.Run("rejects empty table", func(t *testing.T) {
+		path := filepath.Join(dir, "empty.json")
+		if err := os.WriteFile(path, []byte(`{"routes": []}`), 0644); err != nil {
+			t.Fatalf("failed to write routes file: %v", err)
+		}
+
+		if _, err := LoadRoutes(path); err == nil {
+			t.Error("expected error for empty route table")
+		}
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		if _, err := LoadRoutes(filepath.Join(dir, "missing.json")); err == nil {
+			t.Error("expected error for missing file")
+		}
+	})
+}
+
+func TestHeaderRulesValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules HeaderRules
+		valid bool
+	}{
+		{"names and prefixes", HeaderRules{Allow: []string{"Authorization", "X-B3-*"}, Deny: []string{"*"}}, true},
+		{"templated values", HeaderRules{Set: map[string]string{"X-Request-ID": "{request_id}"}}, true},
+		{"space in name", HeaderRules{Allow: []string{"X Custom"}}, false},
+		{"inner wildcard", HeaderRules{Deny: []string{"X-*-Id"}}, false},
+		{"wildcard in set", HeaderRules{Set: map[string]string{"X-*": "value"}}, false},
+		{"empty append name", HeaderRules{Append: map[string]string{"": "value"}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.rules.Validate()
+			if tt.valid && err != nil {
+				t.Errorf("expected no error, got %v", err)
+			}
+			if !tt.valid && err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
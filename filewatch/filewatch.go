@@ -0,0 +1,107 @@
+// Package filewatch polls files that configure the proxy and reloads them when they
+// change, so certificates, keys and rules can be replaced without a restart.
+package filewatch
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go-api-proxy/logger"
+)
+
+// Version identifies the contents of a file by size and modification time
+type Version struct {
+	size    int64
+	modTime time.Time
+}
+
+// Stat returns the version of a file
+func Stat(path string) (Version, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Version{}, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return Version{size: info.Size(), modTime: info.ModTime()}, nil
+}
+
+// Source is something loaded from files that is reloaded when they change
+type Source struct {
+	Changed func() bool  // reports whether the files differ from the loaded version
+	Reload  func() error // loads the files, keeping the current version on failure
+	Logger  *logger.Logger
+	Failure string                 // warning logged when a reload fails
+	Fields  map[string]interface{} // logged with the warning, along with the error
+}
+
+// Watcher polls its sources and reloads the ones that changed. A source that fails
+// to load, such as a certificate written before its key, is retried on the next tick.
+type Watcher struct {
+	interval time.Duration
+	sources  []Source
+
+	stopOnce sync.Once
+	stop     chan struct{}
+}
+
+// New creates a watcher checking the sources every interval once Start is called
+func New(interval time.Duration, sources ...Source) *Watcher {
+	return &Watcher{
+		interval: interval,
+		sources:  sources,
+		stop:     make(chan struct{}),
+	}
+}
+
+// Start watches the sources. It does nothing when the interval is not positive.
+func (w *Watcher) Start() {
+	if w == nil || w.interval <= 0 {
+		return
+	}
+	go w.watch()
+}
+
+// Stop stops watching the sources
+func (w *Watcher) Stop() {
+	if w == nil {
+		return
+	}
+	w.stopOnce.Do(func() {
+		close(w.stop)
+	})
+}
+
+// watch polls the sources until Stop is called
+func (w *Watcher) watch() {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			for _, source := range w.sources {
+				if source.Changed() {
+					reload(source)
+				}
+			}
+		}
+	}
+}
+
+// reload reloads a changed source and logs a warning when it fails
+func reload(source Source) {
+	err := source.Reload()
+	if err == nil {
+		return
+	}
+	fields := map[string]interface{}{
+		"error": err.Error(),
+	}
+	for name, value := range source.Fields {
+		fields[name] = value
+	}
+	source.Logger.Warn(source.Failure, fields)
+}
@@ -0,0 +1,146 @@
+package filewatch
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"go-api-proxy/logger"
+)
+
+// fileSource is a source that loads a file and records what it loaded
+type fileSource struct {
+	path string
+	fail bool
+
+	mu      sync.Mutex
+	loads   int
+	version Version
+}
+
+func (s *fileSource) changed() bool {
+	version, err := Stat(s.path)
+	if err != nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return version != s.version
+}
+
+func (s *fileSource) reload() error {
+	version, err := Stat(s.path)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loads++
+	if s.fail {
+		return errors.New("broken file")
+	}
+	s.version = version
+	return nil
+}
+
+func (s *fileSource) loadCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.loads
+}
+
+func newFileSource(t *testing.T, fail bool) *fileSource {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{}`), 0o600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	source := &fileSource{path: path}
+	if err := source.reload(); err != nil {
+		t.Fatalf("Initial load failed: %v", err)
+	}
+	source.fail = fail
+	return source
+}
+
+func (s *fileSource) touch(t *testing.T) {
+	t.Helper()
+
+	if err := os.WriteFile(s.path, []byte(`{"changed": true}`), 0o600); err != nil {
+		t.Fatalf("Failed to update file: %v", err)
+	}
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(s.path, future, future)
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the watcher")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestWatcher_ReloadsChangedSources(t *testing.T) {
+	changed := newFileSource(t, false)
+	unchanged := newFileSource(t, false)
+	watcher := New(10*time.Millisecond,
+		Source{Changed: changed.changed, Reload: changed.reload, Logger: logger.MainLogger, Failure: "reload failed"},
+		Source{Changed: unchanged.changed, Reload: unchanged.reload, Logger: logger.MainLogger, Failure: "reload failed"},
+	)
+	watcher.Start()
+	defer watcher.Stop()
+
+	changed.touch(t)
+	waitFor(t, func() bool { return changed.loadCount() == 2 })
+
+	// The reload recorded the new version, so the file is not loaded again
+	time.Sleep(50 * time.Millisecond)
+	if loads := changed.loadCount(); loads != 2 {
+		t.Errorf("Expected one reload of the changed file, got %d", loads-1)
+	}
+	if loads := unchanged.loadCount(); loads != 1 {
+		t.Errorf("Expected the unchanged file not to be reloaded, got %d reloads", loads-1)
+	}
+}
+
+func TestWatcher_RetriesFailedReloads(t *testing.T) {
+	source := newFileSource(t, true)
+	watcher := New(10*time.Millisecond, Source{
+		Changed: source.changed,
+		Reload:  source.reload,
+		Logger:  logger.MainLogger,
+		Failure: "reload failed",
+		Fields:  map[string]interface{}{"file": source.path},
+	})
+	watcher.Start()
+	defer watcher.Stop()
+
+	source.touch(t)
+	waitFor(t, func() bool { return source.loadCount() >= 3 })
+}
+
+func TestWatcher_DisabledAndNil(t *testing.T) {
+	source := newFileSource(t, false)
+	watcher := New(0, Source{Changed: source.changed, Reload: source.reload, Logger: logger.MainLogger})
+	watcher.Start()
+	source.touch(t)
+	time.Sleep(30 * time.Millisecond)
+	if loads := source.loadCount(); loads != 1 {
+		t.Errorf("Expected no reloads without an interval, got %d", loads-1)
+	}
+	watcher.Stop()
+	watcher.Stop()
+
+	var none *Watcher
+	none.Start()
+	none.Stop()
+}
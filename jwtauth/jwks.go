@@ -0,0 +1,214 @@
+// Package jwtauth validates JSON Web Tokens issued by an identity provider against a
+// locally configured JSON Web Key Set that is reloaded when the file changes.
+package jwtauth
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+
+	"go-api-proxy/filewatch"
+	"go-api-proxy/logger"
+)
+
+// jsonWebKey is a key as it appears in a JWKS file (RFC 7517)
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+// verificationKey is a parsed key together with the algorithm it verifies
+type verificationKey struct {
+	kid    string
+	alg    string
+	secret []byte
+	rsa    *rsa.PublicKey
+	ecdsa  *ecdsa.PublicKey
+}
+
+// KeySet holds the keys from a JWKS file and reloads them when the file changes.
+// Tokens are always verified against the latest set that loaded successfully.
+type KeySet struct {
+	file    string
+	watcher *filewatch.Watcher
+
+	mu      sync.RWMutex
+	keys    []verificationKey
+	version filewatch.Version
+}
+
+// NewKeySet loads the JWKS file. The file is checked for changes every interval once
+// Start is called.
+func NewKeySet(file string, interval time.Duration) (*KeySet, error) {
+	s := &KeySet{
+		file: file,
+	}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	s.watcher = filewatch.New(interval, filewatch.Source{
+		Changed: s.Changed,
+		Reload:  s.Reload,
+		Logger:  logger.AuthLogger,
+		Failure: "Failed to reload JWKS, keeping the current keys",
+		Fields:  map[string]interface{}{"file": s.file},
+	})
+	return s, nil
+}
+
+// Reload reads the JWKS file, keeping the current keys if it cannot be loaded
+func (s *KeySet) Reload() error {
+	version, err := filewatch.Stat(s.file)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(s.file)
+	if err != nil {
+		return fmt.Errorf("failed to read JWKS file %s: %w", s.file, err)
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return fmt.Errorf("failed to parse JWKS file %s: %w", s.file, err)
+	}
+
+	keys := make([]verificationKey, 0, len(set.Keys))
+	for i, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := parseKey(jwk)
+		if err != nil {
+			return fmt.Errorf("JWKS file %s: key %d (%q): %w", s.file, i, jwk.Kid, err)
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("JWKS file %s contains no signing keys", s.file)
+	}
+
+	s.mu.Lock()
+	s.keys = keys
+	s.version = version
+	s.mu.Unlock()
+
+	logger.AuthLogger.Info("Loaded JWKS", map[string]interface{}{
+		"file":      s.file,
+		"key_count": len(keys),
+	})
+	return nil
+}
+
+// Changed reports whether the file differs from the loaded version
+func (s *KeySet) Changed() bool {
+	version, err := filewatch.Stat(s.file)
+	if err != nil {
+		return false
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return version != s.version
+}
+
+// Start watches the file and reloads the keys when it changes
+func (s *KeySet) Start() {
+	s.watcher.Start()
+}
+
+// Stop stops watching the file
+func (s *KeySet) Stop() {
+	s.watcher.Stop()
+}
+
+// candidates returns the keys that may have signed a token with the given algorithm
+// and key ID. Keys only verify the algorithm family of their type, so an RSA public
+// key can never be used as an HMAC secret.
+func (s *KeySet) candidates(alg, kid string) []verificationKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []verificationKey
+	for _, key := range s.keys {
+		if key.alg != alg || (kid != "" && key.kid != kid) {
+			continue
+		}
+		result = append(result, key)
+	}
+	return result
+}
+
+// parseKey converts a JWK into a verification key for HS256, RS256 or ES256
+func parseKey(jwk jsonWebKey) (verificationKey, error) {
+	key := verificationKey{kid: jwk.Kid}
+
+	switch jwk.Kty {
+	case "oct":
+		secret, err := base64.RawURLEncoding.DecodeString(jwk.K)
+		if err != nil || len(secret) == 0 {
+			return key, fmt.Errorf("invalid symmetric key")
+		}
+		key.alg, key.secret = "HS256", secret
+	case "RSA":
+		n, errN := decodeBigInt(jwk.N)
+		e, errE := decodeBigInt(jwk.E)
+		if errN != nil || errE != nil || !e.IsInt64() {
+			return key, fmt.Errorf("invalid RSA key")
+		}
+		if n.BitLen() < 2048 {
+			return key, fmt.Errorf("RSA key must be at least 2048 bits")
+		}
+		key.alg, key.rsa = "RS256", &rsa.PublicKey{N: n, E: int(e.Int64())}
+	case "EC":
+		if jwk.Crv != "P-256" {
+			return key, fmt.Errorf("unsupported curve %q", jwk.Crv)
+		}
+		x, errX := decodeBigInt(jwk.X)
+		y, errY := decodeBigInt(jwk.Y)
+		if errX != nil || errY != nil || x.BitLen() > 256 || y.BitLen() > 256 {
+			return key, fmt.Errorf("invalid EC key")
+		}
+		point := make([]byte, 65)
+		point[0] = 4
+		x.FillBytes(point[1:33])
+		y.FillBytes(point[33:])
+		if _, err := ecdh.P256().NewPublicKey(point); err != nil {
+			return key, fmt.Errorf("EC key is not on the P-256 curve")
+		}
+		key.alg, key.ecdsa = "ES256", &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+	default:
+		return key, fmt.Errorf("unsupported key type %q", jwk.Kty)
+	}
+
+	if jwk.Alg != "" && jwk.Alg != key.alg {
+		return key, fmt.Errorf("algorithm %q does not match key type %q", jwk.Alg, jwk.Kty)
+	}
+	return key, nil
+}
+
+// decodeBigInt decodes a base64url encoded unsigned big-endian integer
+func decodeBigInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(data) == 0 {
+		return nil, fmt.Errorf("invalid integer")
+	}
+	return new(big.Int).SetBytes(data), nil
+}
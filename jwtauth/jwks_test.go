@@ -0,0 +1,74 @@
+package jwtauth
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestNewKeySet_InvalidFiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"invalid JSON", `{"keys": [`},
+		{"no keys", `{"keys": []}`},
+		{"short RSA key", `{"keys": [{"kty": "RSA", "n": "AQAB", "e": "AQAB"}]}`},
+		{"unsupported curve", `{"keys": [{"kty": "EC", "crv": "P-384", "x": "AQAB", "y": "AQAB"}]}`},
+		{"point off the curve", `{"keys": [{"kty": "EC", "crv": "P-256", "x": "AQAB", "y": "AQAB"}]}`},
+		{"mismatched alg", `{"keys": [{"kty": "oct", "alg": "RS256", "k": "c2VjcmV0"}]}`},
+		{"unknown key type", `{"keys": [{"kty": "OKP", "crv": "Ed25519", "x": "AQAB"}]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewKeySet(writeJWKS(t, tt.content), 0); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+
+	if _, err := NewKeySet(writeJWKS(t, "")+".missing", 0); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
+
+func TestKeySet_Reload(t *testing.T) {
+	oldKeys, newKeys := newTestKeys(t), newTestKeys(t)
+	verifier := newTestVerifier(t, oldKeys, Options{ReloadInterval: 10 * time.Millisecond})
+	verifier.Start()
+	defer verifier.Stop()
+
+	oldToken := oldKeys.sign(t, "ES256", "ec", validClaims())
+	newToken := newKeys.sign(t, "ES256", "ec", validClaims())
+	if _, err := verifier.Verify(oldToken); err != nil {
+		t.Fatalf("Expected the old key to verify, got %v", err)
+	}
+
+	// A broken file keeps the current keys
+	if err := os.WriteFile(verifier.keys.file, []byte(`{"keys": [`), 0o600); err != nil {
+		t.Fatalf("Failed to write JWKS: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if _, err := verifier.Verify(oldToken); err != nil {
+		t.Fatalf("Expected the old key to survive a broken file, got %v", err)
+	}
+
+	if err := os.WriteFile(verifier.keys.file, []byte(newKeys.jwks()), 0o600); err != nil {
+		t.Fatalf("Failed to write JWKS: %v", err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, err := verifier.Verify(newToken); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the new key to be loaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, err := verifier.Verify(oldToken); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected the rotated-out key to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,273 @@
+package jwtauth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// Errors returned when a token is rejected
+var (
+	ErrMalformedToken       = errors.New("malformed token")
+	ErrUnsupportedAlgorithm = errors.New("unsupported signing algorithm")
+	ErrUnknownKey           = errors.New("no key matches the token")
+	ErrInvalidSignature     = errors.New("invalid token signature")
+	ErrMissingExpiry        = errors.New("token has no expiry")
+	ErrExpired              = errors.New("token is expired")
+	ErrNotYetValid          = errors.New("token is not valid yet")
+	ErrInvalidIssuer        = errors.New("token issuer is not accepted")
+	ErrInvalidAudience      = errors.New("token audience is not accepted")
+)
+
+// Options configures token validation
+type Options struct {
+	JWKSFile       string        // JWKS file with the keys tokens may be signed with
+	Issuer         string        // required iss claim, if set
+	Audience       string        // audience that must be in the aud claim, if set
+	Leeway         time.Duration // clock skew tolerated for exp and nbf
+	ReloadInterval time.Duration // how often the JWKS file is checked for changes
+}
+
+// Verifier validates the signature and the registered claims of tokens
+type Verifier struct {
+	keys     *KeySet
+	issuer   string
+	audience string
+	leeway   time.Duration
+	now      func() time.Time
+}
+
+// Claims are the claims of a validated token
+type Claims struct {
+	Subject   string
+	Issuer    string
+	Audience  []string
+	ExpiresAt time.Time
+	raw       map[string]interface{}
+}
+
+// New loads the JWKS file. It returns nil when no JWKS file is configured.
+func New(opts Options) (*Verifier, error) {
+	if opts.JWKSFile == "" {
+		return nil, nil
+	}
+
+	keys, err := NewKeySet(opts.JWKSFile, opts.ReloadInterval)
+	if err != nil {
+		return nil, err
+	}
+	return &Verifier{
+		keys:     keys,
+		issuer:   opts.Issuer,
+		audience: opts.Audience,
+		leeway:   opts.Leeway,
+		now:      time.Now,
+	}, nil
+}
+
+// Start watches the JWKS file for changes
+func (v *Verifier) Start() {
+	if v == nil {
+		return
+	}
+	v.keys.Start()
+}
+
+// Stop stops watching the JWKS file
+func (v *Verifier) Stop() {
+	if v == nil {
+		return
+	}
+	v.keys.Stop()
+}
+
+// Verify checks the token's signature against the key set, then its exp, nbf, iss
+// and aud claims. Only HS256, RS256 and ES256 are accepted.
+func (v *Verifier) Verify(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformedToken
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, ErrMalformedToken
+	}
+	switch header.Alg {
+	case "HS256", "RS256", "ES256":
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedAlgorithm, header.Alg)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformedToken
+	}
+
+	keys := v.keys.candidates(header.Alg, header.Kid)
+	if len(keys) == 0 {
+		return nil, ErrUnknownKey
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	verified := false
+	for _, key := range keys {
+		if verifySignature(key, parts[0]+"."+parts[1], digest[:], signature) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, ErrInvalidSignature
+	}
+
+	var raw map[string]interface{}
+	if err := decodeSegment(parts[1], &raw); err != nil {
+		return nil, ErrMalformedToken
+	}
+	return v.validateClaims(raw)
+}
+
+// validateClaims checks the registered claims of a token with a valid signature
+func (v *Verifier) validateClaims(raw map[string]interface{}) (*Claims, error) {
+	now := v.now()
+	claims := &Claims{raw: raw}
+	claims.Subject, _ = raw["sub"].(string)
+	claims.Issuer, _ = raw["iss"].(string)
+
+	exp, ok := numericDate(raw["exp"])
+	if !ok {
+		return nil, ErrMissingExpiry
+	}
+	claims.ExpiresAt = exp
+	if !now.Before(exp.Add(v.leeway)) {
+		return nil, ErrExpired
+	}
+	if _, present := raw["nbf"]; present {
+		nbf, ok := numericDate(raw["nbf"])
+		if !ok {
+			return nil, ErrMalformedToken
+		}
+		if now.Add(v.leeway).Before(nbf) {
+			return nil, ErrNotYetValid
+		}
+	}
+
+	if v.issuer != "" && claims.Issuer != v.issuer {
+		return nil, ErrInvalidIssuer
+	}
+
+	switch aud := raw["aud"].(type) {
+	case string:
+		claims.Audience = []string{aud}
+	case []interface{}:
+		for _, value := range aud {
+			if s, ok := value.(string); ok {
+				claims.Audience = append(claims.Audience, s)
+			}
+		}
+	}
+	if v.audience != "" && !containsString(claims.Audience, v.audience) {
+		return nil, ErrInvalidAudience
+	}
+
+	return claims, nil
+}
+
+// Roles returns the roles in a claim. The claim may be a dotted path into nested
+// objects, such as "realm_access.roles", and hold an array or a space separated string.
+func (c *Claims) Roles(claim string) []string {
+	var value interface{} = c.raw
+	for _, name := range strings.Split(claim, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[name]
+	}
+
+	switch roles := value.(type) {
+	case string:
+		return strings.Fields(roles)
+	case []interface{}:
+		result := make([]string, 0, len(roles))
+		for _, role := range roles {
+			if s, ok := role.(string); ok {
+				result = append(result, s)
+			}
+		}
+		return result
+	}
+	return nil
+}
+
+// HasAnyRole reports whether the claim holds at least one of the roles
+func (c *Claims) HasAnyRole(claim string, roles []string) bool {
+	for _, role := range c.Roles(claim) {
+		if containsString(roles, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// verifySignature checks a signature with one key
+func verifySignature(key verificationKey, signingInput string, digest, signature []byte) bool {
+	switch key.alg {
+	case "HS256":
+		mac := hmac.New(sha256.New, key.secret)
+		mac.Write([]byte(signingInput))
+		return hmac.Equal(mac.Sum(nil), signature)
+	case "RS256":
+		return rsa.VerifyPKCS1v15(key.rsa, crypto.SHA256, digest, signature) == nil
+	case "ES256":
+		if len(signature) != 64 {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		return ecdsa.Verify(key.ecdsa, digest, r, s)
+	}
+	return false
+}
+
+// decodeSegment decodes a base64url encoded JSON segment of a token
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// numericDate converts a NumericDate claim (seconds since the epoch) to a time
+func numericDate(value interface{}) (time.Time, bool) {
+	seconds, ok := value.(float64)
+	if !ok || math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+		return time.Time{}, false
+	}
+	whole, frac := math.Modf(seconds)
+	return time.Unix(int64(whole), int64(frac*1e9)), true
+}
+
+// containsString checks if the list contains the value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
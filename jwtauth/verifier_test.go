@@ -0,0 +1,231 @@
+package jwtauth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testNow = time.Unix(1700000000, 0)
+
+// testKeys are one key of each supported type with their JWKS entries
+type testKeys struct {
+	secret []byte
+	rsa    *rsa.PrivateKey
+	ecdsa  *ecdsa.PrivateKey
+}
+
+func newTestKeys(t *testing.T) *testKeys {
+	t.Helper()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate EC key: %v", err)
+	}
+	return &testKeys{secret: []byte("0123456789abcdef0123456789abcdef"), rsa: rsaKey, ecdsa: ecKey}
+}
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// jwks returns the JWKS document for the keys
+func (k *testKeys) jwks() string {
+	ecX, ecY := make([]byte, 32), make([]byte, 32)
+	k.ecdsa.X.FillBytes(ecX)
+	k.ecdsa.Y.FillBytes(ecY)
+
+	doc, _ := json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{
+			{"kty": "oct", "kid": "hmac", "k": b64(k.secret)},
+			{"kty": "RSA", "kid": "rsa", "alg": "RS256", "n": b64(k.rsa.N.Bytes()), "e": b64(big.NewInt(int64(k.rsa.E)).Bytes())},
+			{"kty": "EC", "kid": "ec", "crv": "P-256", "x": b64(ecX), "y": b64(ecY)},
+			{"kty": "RSA", "kid": "enc", "use": "enc", "n": "AQAB", "e": "AQAB"},
+		},
+	})
+	return string(doc)
+}
+
+// sign creates a token with the given algorithm and claims
+func (k *testKeys) sign(t *testing.T, alg, kid string, claims map[string]interface{}) string {
+	t.Helper()
+
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	input := b64(header) + "." + b64(payload)
+	digest := sha256.Sum256([]byte(input))
+
+	var signature []byte
+	switch alg {
+	case "HS256":
+		mac := hmac.New(sha256.New, k.secret)
+		mac.Write([]byte(input))
+		signature = mac.Sum(nil)
+	case "RS256":
+		var err error
+		if signature, err = rsa.SignPKCS1v15(rand.Reader, k.rsa, crypto.SHA256, digest[:]); err != nil {
+			t.Fatalf("Failed to sign: %v", err)
+		}
+	case "ES256":
+		r, s, err := ecdsa.Sign(rand.Reader, k.ecdsa, digest[:])
+		if err != nil {
+			t.Fatalf("Failed to sign: %v", err)
+		}
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	}
+	return input + "." + b64(signature)
+}
+
+func writeJWKS(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write JWKS: %v", err)
+	}
+	return path
+}
+
+func newTestVerifier(t *testing.T, keys *testKeys, opts Options) *Verifier {
+	t.Helper()
+
+	opts.JWKSFile = writeJWKS(t, keys.jwks())
+	verifier, err := New(opts)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	verifier.now = func() time.Time { return testNow }
+	return verifier
+}
+
+func validClaims() map[string]interface{} {
+	return map[string]interface{}{
+		"sub": "alice",
+		"iss": "https://idp.example.com",
+		"aud": []string{"dashboards", "other"},
+		"exp": testNow.Add(time.Hour).Unix(),
+		"nbf": testNow.Add(-time.Minute).Unix(),
+	}
+}
+
+func TestVerify_Algorithms(t *testing.T) {
+	keys := newTestKeys(t)
+	verifier := newTestVerifier(t, keys, Options{Issuer: "https://idp.example.com", Audience: "dashboards"})
+
+	for _, tc := range []struct{ alg, kid string }{{"HS256", "hmac"}, {"RS256", "rsa"}, {"ES256", "ec"}, {"RS256", ""}} {
+		claims, err := verifier.Verify(keys.sign(t, tc.alg, tc.kid, validClaims()))
+		if err != nil {
+			t.Errorf("%s with kid %q: %v", tc.alg, tc.kid, err)
+			continue
+		}
+		if claims.Subject != "alice" || len(claims.Audience) != 2 {
+			t.Errorf("%s: unexpected claims %+v", tc.alg, claims)
+		}
+	}
+}
+
+func TestVerify_Rejections(t *testing.T) {
+	keys := newTestKeys(t)
+	verifier := newTestVerifier(t, keys, Options{Issuer: "https://idp.example.com", Audience: "dashboards", Leeway: 30 * time.Second})
+
+	with := func(name string, value interface{}) map[string]interface{} {
+		claims := validClaims()
+		if value == nil {
+			delete(claims, name)
+		} else {
+			claims[name] = value
+		}
+		return claims
+	}
+	tampered := keys.sign(t, "HS256", "hmac", validClaims())
+	tampered = tampered[:len(tampered)-4] + "AAAA"
+
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"expired", keys.sign(t, "HS256", "hmac", with("exp", testNow.Add(-time.Minute).Unix())), ErrExpired},
+		{"no expiry", keys.sign(t, "HS256", "hmac", with("exp", nil)), ErrMissingExpiry},
+		{"not yet valid", keys.sign(t, "HS256", "hmac", with("nbf", testNow.Add(time.Minute).Unix())), ErrNotYetValid},
+		{"wrong issuer", keys.sign(t, "HS256", "hmac", with("iss", "https://evil.example.com")), ErrInvalidIssuer},
+		{"wrong audience", keys.sign(t, "HS256", "hmac", with("aud", "billing")), ErrInvalidAudience},
+		{"tampered signature", tampered, ErrInvalidSignature},
+		{"unknown kid", keys.sign(t, "RS256", "rotated-out", validClaims()), ErrUnknownKey},
+		{"algorithm none", b64([]byte(`{"alg":"none"}`)) + "." + b64([]byte(`{"sub":"alice"}`)) + ".", ErrUnsupportedAlgorithm},
+		{"not a JWT", "opaque-token", ErrMalformedToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := verifier.Verify(tt.token); !errors.Is(err, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+
+	// Within the leeway an expired token is still accepted
+	if _, err := verifier.Verify(keys.sign(t, "HS256", "hmac", with("exp", testNow.Add(-10*time.Second).Unix()))); err != nil {
+		t.Errorf("Expected the leeway to apply, got %v", err)
+	}
+}
+
+func TestVerify_RejectsAlgorithmConfusion(t *testing.T) {
+	keys := newTestKeys(t)
+	verifier := newTestVerifier(t, keys, Options{})
+
+	// An HS256 token signed with the RSA public key as the secret must not verify
+	confused := &testKeys{secret: keys.rsa.N.Bytes()}
+	if _, err := verifier.Verify(confused.sign(t, "HS256", "rsa", validClaims())); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Expected ErrUnknownKey, got %v", err)
+	}
+}
+
+func TestClaims_Roles(t *testing.T) {
+	claims := &Claims{raw: map[string]interface{}{
+		"roles":        []interface{}{"viewer", "admin"},
+		"scope":        "read:tokens write:cache",
+		"realm_access": map[string]interface{}{"roles": []interface{}{"token-auditor"}},
+	}}
+
+	if got := strings.Join(claims.Roles("roles"), ","); got != "viewer,admin" {
+		t.Errorf("Expected array roles, got %q", got)
+	}
+	if got := strings.Join(claims.Roles("scope"), ","); got != "read:tokens,write:cache" {
+		t.Errorf("Expected space separated roles, got %q", got)
+	}
+	if !claims.HasAnyRole("realm_access.roles", []string{"token-auditor"}) {
+		t.Error("Expected nested roles to be found")
+	}
+	if claims.HasAnyRole("roles", []string{"operator"}) || claims.Roles("missing.path") != nil {
+		t.Error("Expected no match for absent roles")
+	}
+}
+
+func TestNew_Disabled(t *testing.T) {
+	verifier, err := New(Options{})
+	if verifier != nil || err != nil {
+		t.Errorf("Expected nil without a JWKS file, got %v, %v", verifier, err)
+	}
+	verifier.Start()
+	verifier.Stop()
+}
@@ -184,4 +184,7 @@ var (
 	ClientLogger     = NewLogger("client")
 	MiddlewareLogger = NewLogger("middleware")
 	ModelsLogger     = NewLogger("models")
+	CacheLogger      = NewLogger("cache")
+	TLSLogger        = NewLogger("tls")
+	AuthLogger       = NewLogger("auth")
 )
\ No newline at end of file
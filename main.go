@@ -2,19 +2,25 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
-	"strings"
 	"syscall"
 	"time"
 
+	"go-api-proxy/apikeys"
+	"go-api-proxy/cache"
 	"go-api-proxy/client"
 	"go-api-proxy/config"
+	"go-api-proxy/jwtauth"
 	"go-api-proxy/logger"
 	"go-api-proxy/middleware"
 	"go-api-proxy/models"
+	"go-api-proxy/tlsutil"
+	"go-api-proxy/validation"
 )
 
 // ProxyServer holds the main server components
@@ -24,13 +30,36 @@ type ProxyServer struct {
 	whitelist         *models.TokenWhitelist
 	tokenHandler      *middleware.TokenFilterHandler
 	standardHandler   *middleware.StandardProxyHandler
+	router            *middleware.Router
+	dispatcher        http.Handler
+	cacheHandler      *middleware.CacheHandler
+	endpointPolicy    *middleware.EndpointPolicyHandler
+	diskCache         *cache.Disk
+	websocketProxy    *middleware.WebSocketProxy
+	certReloader      *tlsutil.CertReloader
+	backendTLS        *tlsutil.ClientTLS
+	apiKeys           *apikeys.Store
+	jwtVerifier       *jwtauth.Verifier
+	ipFilter          *middleware.IPFilterHandler
 	server            *http.Server
+	redirectServer    *http.Server
 }
 
 // NewProxyServer creates a new proxy server instance
 func NewProxyServer(cfg *config.Config) (*ProxyServer, error) {
-	// Initialize HTTP client
-	httpClient := client.NewHTTPClient(cfg)
+	// Initialize HTTP client, with mutual TLS or a private CA when configured
+	backendTLS, err := tlsutil.NewClientTLS(cfg.BackendTLSOptions())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load backend TLS configuration: %w", err)
+	}
+	httpClient := client.NewHTTPClientWithTLS(cfg, backendTLS)
+	
+	// API key added to backend requests, read from a secret file
+	credentials, err := client.LoadCredentials(cfg.UpstreamAPIKeyFile, cfg.UpstreamAPIKeyParam, cfg.UpstreamAPIKeyHeader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load upstream API key: %w", err)
+	}
+	httpClient.SetCredentials(credentials)
 	
 	// Initialize whitelist
 	whitelist := models.NewTokenWhitelist()
@@ -44,15 +73,155 @@ func NewProxyServer(cfg *config.Config) (*ProxyServer, error) {
 	// Create handlers
 	tokenHandler := middleware.NewTokenFilterHandler(httpClient, whitelist)
 	standardHandler := middleware.NewStandardProxyHandler(httpClient)
+	websocketProxy := middleware.NewWebSocketProxy(cfg, middleware.NewTokenEventFilter(whitelist, cfg.WebSocketTokenFilter), backendTLS)
+	websocketProxy.SetBackendPicker(httpClient)
+	
+	// Build the route table
+	routes, err := cfg.GetRoutes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load route table: %w", err)
+	}
+	// Terminate TLS with a certificate that follows rotated files
+	var certReloader *tlsutil.CertReloader
+	var tlsConfig *tls.Config
+	if cfg.TLSEnabled() {
+		certReloader, err = tlsutil.NewCertReloader(cfg.TLSCertFile, cfg.TLSKeyFile, cfg.TLSReloadInterval)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+		}
+		tlsConfig, err = tlsutil.ServerConfig(certReloader, cfg.TLSMinVersion, cfg.TLSCipherPolicy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TLS configuration: %w", err)
+		}
+	}
+	
+	// Backend URLs in responses of routes with rewrite_urls are replaced with the public origin
+	urlRewriter, err := middleware.NewURLRewriter(cfg.PublicOrigin, cfg.GetBackendOrigins(routes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure URL rewriting: %w", err)
+	}
+	for _, route := range routes {
+		if route.RewriteURLs && urlRewriter == nil {
+			return nil, fmt.Errorf("route %q rewrites URLs but PUBLIC_ORIGIN is not set", route.Name)
+		}
+	}
+	standardHandler.SetURLRewriter(urlRewriter)
+	
+	// API keys issued to consumers, with their plans and usage quotas
+	var apiKeys *apikeys.Store
+	if cfg.APIKeysFile != "" {
+		apiKeys, err = apikeys.Open(cfg.APIKeysFile, cfg.APIKeysUsageFile, 10*time.Second)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load API keys: %w", err)
+		}
+	}
+	
+	// Quotas are charged just before a route handler serves the request, after every check
+	chargeQuota := func(next http.Handler) http.Handler {
+		if apiKeys == nil {
+			return next
+		}
+		return middleware.NewQuotaHandler(next, apiKeys)
+	}
+	
+	// Cache passthrough responses for routes with a cache TTL, optionally persisted to disk
+	var passthroughHandler http.Handler = standardHandler
+	var cacheHandler *middleware.CacheHandler
+	var diskCache *cache.Disk
+	if cfg.CacheMaxBytes > 0 {
+		memoryCache := cache.NewLRU(cfg.CacheMaxBytes)
+		var store cache.Store = memoryCache
+		if cfg.CacheDir != "" {
+			diskCache, err = cache.OpenDisk(cfg.CacheDir, cfg.CacheDiskMaxBytes, 10*time.Second)
+			if err != nil {
+				return nil, fmt.Errorf("failed to open disk cache: %w", err)
+			}
+			store = cache.NewTiered(memoryCache, diskCache)
+		}
+		cacheHandler = middleware.NewCacheHandler(standardHandler, store, cfg.CacheMaxEntryBytes)
+		passthroughHandler = cacheHandler
+	}
+	
+	// Only endpoints on the allowlist are passed through to the backend, and only they are charged
+	passthroughHandler = chargeQuota(passthroughHandler)
+	var endpointPolicy *middleware.EndpointPolicyHandler
+	if cfg.EndpointPolicyFile != "" {
+		endpoints, err := config.LoadEndpointPolicy(cfg.EndpointPolicyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load endpoint policy: %w", err)
+		}
+		endpointPolicy = middleware.NewEndpointPolicyHandler(passthroughHandler, endpoints)
+		passthroughHandler = endpointPolicy
+	}
+	
+	router, err := middleware.NewRouter(routes, map[string]http.Handler{
+		config.HandlerPassthrough: passthroughHandler,
+		config.HandlerTokenFilter: chargeQuota(tokenHandler),
+		config.HandlerWebSocket:   chargeQuota(websocketProxy),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to build router: %w", err)
+	}
+	
+	// Bearer JWTs from the identity provider, required on routes with roles
+	jwtVerifier, err := jwtauth.New(cfg.JWTOptions())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load JWKS: %w", err)
+	}
+	if jwtVerifier == nil {
+		for _, route := range routes {
+			if len(route.Roles) > 0 {
+				return nil, fmt.Errorf("route %q requires roles but JWT_JWKS_FILE is not set", route.Name)
+			}
+		}
+	}
+	
+	// Malformed Blockscout parameters are rejected before they reach a backend
+	var routed http.Handler = router
+	if cfg.RequestValidation {
+		routed = middleware.NewValidationHandler(router, validation.BlockscoutV2Rules)
+	}
+	
+	// Requests pass the per-client rate limits before they are validated and routed
+	var dispatcher http.Handler = routed
+	if rateLimiter := middleware.NewRateLimitHandler(routed, router, routes, cfg); rateLimiter != nil {
+		dispatcher = rateLimiter
+	}
+	
+	// Client addresses are taken from forwarding headers only behind trusted proxies
+	ipResolver, err := client.NewIPResolver(cfg.TrustedProxies, cfg.TrustedProxyHeader)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trusted proxies: %w", err)
+	}
 	
-	// Create HTTP server
+	// Create HTTP server, with the IP filter in front of every endpoint including admin
 	mux := http.NewServeMux()
+	var handler http.Handler = mux
+	ipFilter, err := middleware.NewIPFilterHandler(mux, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load IP filter rules: %w", err)
+	}
+	if ipFilter != nil {
+		handler = ipFilter
+	}
 	server := &http.Server{
 		Addr:         ":" + cfg.Port,
-		Handler:      mux,
+		Handler:      middleware.NewClientIPHandler(handler, ipResolver),
 		ReadTimeout:  30 * time.Second,
 		WriteTimeout: 30 * time.Second,
 		IdleTimeout:  60 * time.Second,
+		TLSConfig:    tlsConfig,
+	}
+	
+	// Optional plain HTTP listener that redirects to the TLS listener
+	var redirectServer *http.Server
+	if cfg.HTTPRedirectPort != "" {
+		redirectServer = &http.Server{
+			Addr:         ":" + cfg.HTTPRedirectPort,
+			Handler:      middleware.NewHTTPSRedirectHandler(cfg.Port),
+			ReadTimeout:  10 * time.Second,
+			WriteTimeout: 10 * time.Second,
+		}
 	}
 	
 	proxyServer := &ProxyServer{
@@ -61,7 +230,19 @@ func NewProxyServer(cfg *config.Config) (*ProxyServer, error) {
 		whitelist:       whitelist,
 		tokenHandler:    tokenHandler,
 		standardHandler: standardHandler,
+		router:          router,
+		dispatcher:      dispatcher,
+		cacheHandler:    cacheHandler,
+		endpointPolicy:  endpointPolicy,
+		diskCache:       diskCache,
+		websocketProxy:  websocketProxy,
+		certReloader:    certReloader,
+		backendTLS:      backendTLS,
+		apiKeys:         apiKeys,
+		jwtVerifier:     jwtVerifier,
+		ipFilter:        ipFilter,
 		server:          server,
+		redirectServer:  redirectServer,
 	}
 	
 	// Setup routes
@@ -76,9 +257,35 @@ func (ps *ProxyServer) setupRoutes(mux *http.ServeMux) {
 	healthHandler := middleware.NewCORSHandler(http.HandlerFunc(ps.healthCheckHandler))
 	mux.Handle("/health", healthHandler)
 	
-	// Main routing handler (with CORS)
-	routeHandler := middleware.NewCORSHandler(http.HandlerFunc(ps.routeHandler))
-	mux.Handle("/", routeHandler)
+	// Admin APIs, only available when an admin token or JWT admin roles are configured
+	if adminAuth := middleware.NewAdminAuth(ps.config, ps.jwtVerifier); adminAuth.Enabled() {
+		mux.Handle("/admin/cache/", ps.withCompression(middleware.NewCacheAdminHandler(ps.cacheHandler, ps.router, ps.tokenHandler, adminAuth)))
+		if ps.apiKeys != nil {
+			keysAdmin := middleware.NewAPIKeyAdminHandler(ps.apiKeys, adminAuth)
+			mux.Handle("/admin/keys", keysAdmin)
+			mux.Handle("/admin/keys/", keysAdmin)
+		}
+	}
+	
+	// Main routing handler (with CORS and response compression). Consumer API keys and
+	// bearer JWTs are checked before the request is logged, so keys never appear in the logs.
+	var handler http.Handler = http.HandlerFunc(ps.routeHandler)
+	if ps.jwtVerifier != nil {
+		handler = middleware.NewJWTHandler(handler, ps.jwtVerifier, ps.router, ps.config)
+	}
+	if ps.apiKeys != nil {
+		handler = middleware.NewAPIKeyHandler(handler, ps.apiKeys, ps.router, ps.config)
+	}
+	routeHandler := middleware.NewCORSHandler(handler)
+	mux.Handle("/", ps.withCompression(routeHandler))
+}
+
+// withCompression wraps a handler with gzip response compression when it is enabled
+func (ps *ProxyServer) withCompression(handler http.Handler) http.Handler {
+	if !ps.config.CompressionEnabled {
+		return handler
+	}
+	return middleware.NewCompressionHandler(handler, ps.config.CompressionMinBytes)
 }
 
 // routeHandler implements the main request routing logic
@@ -87,12 +294,16 @@ func (ps *ProxyServer) routeHandler(w http.ResponseWriter, r *http.Request) {
 	requestID := generateRequestID()
 	requestLogger := logger.MainLogger.WithRequestID(requestID)
 	
+	// API keys supplied by clients are never forwarded to the backend or logged
+	r = client.StripClientCredentials(r, ps.config.UpstreamAPIKeyParam, ps.config.UpstreamAPIKeyHeader)
+	
 	// Log incoming request
 	requestLogger.Info("Incoming request", map[string]interface{}{
 		"method":      r.Method,
 		"path":        r.URL.Path,
 		"query":       r.URL.RawQuery,
 		"remote_addr": r.RemoteAddr,
+		"client_ip":   client.GetClientIP(r),
 		"user_agent":  r.Header.Get("User-Agent"),
 	})
 	
@@ -100,34 +311,63 @@ func (ps *ProxyServer) routeHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := context.WithValue(r.Context(), "request_id", requestID)
 	r = r.WithContext(ctx)
 	
-	// Check if this is a tokens endpoint request
-	if ps.isTokensEndpoint(r.URL.Path) {
-		requestLogger.Debug("Routing to token filter handler")
-		ps.tokenHandler.ServeHTTP(w, r)
-		return
-	}
-	
-	// Handle all other requests with standard proxy
-	requestLogger.Debug("Routing to standard proxy handler")
-	ps.standardHandler.ServeHTTP(w, r)
+	// Dispatch through the rate limiter and the route table
+	ps.dispatcher.ServeHTTP(w, r)
 }
 
-// isTokensEndpoint checks if the request path is for the tokens endpoint
-func (ps *ProxyServer) isTokensEndpoint(path string) bool {
-	// Normalize path by removing trailing slashes
-	normalizedPath := strings.TrimSuffix(path, "/")
-	
-	// Check for exact match or with query parameters
-	return normalizedPath == "/api/v2/tokens" || strings.HasPrefix(path, "/api/v2/tokens?")
+// HealthResponse is the body returned by the health check endpoint
+type HealthResponse struct {
+	Status         string                          `json:"status"`
+	Service        string                          `json:"service"`
+	CircuitBreaker *client.CircuitBreakerStatus    `json:"circuit_breaker,omitempty"`
+	Upstreams      []client.UpstreamStatus         `json:"upstreams,omitempty"`
+	Failover       *client.FailoverStatus          `json:"failover,omitempty"`
+	EndpointPolicy *middleware.EndpointPolicyStats `json:"endpoint_policy,omitempty"`
 }
 
 // healthCheckHandler provides a health check endpoint
 func (ps *ProxyServer) healthCheckHandler(w http.ResponseWriter, r *http.Request) {
 	logger.MainLogger.Debug("Health check request received")
 	
+	health := HealthResponse{
+		Status:         "healthy",
+		Service:        "go-api-proxy",
+		CircuitBreaker: ps.httpClient.CircuitBreakerStatus(),
+		Upstreams:      ps.httpClient.UpstreamStatus(),
+		Failover:       ps.httpClient.FailoverStatus(),
+	}
+	if ps.endpointPolicy != nil {
+		stats := ps.endpointPolicy.Stats()
+		health.EndpointPolicy = &stats
+	}
+	
+	// The proxy itself is up, but report degraded while the backend circuit is not
+	// closed, no upstream instance is healthy or traffic has failed over to the secondary
+	if health.CircuitBreaker != nil && health.CircuitBreaker.State != client.CircuitClosed.String() {
+		health.Status = "degraded"
+	}
+	if len(health.Upstreams) > 0 && !anyUpstreamHealthy(health.Upstreams) {
+		health.Status = "degraded"
+	}
+	if health.Failover != nil && health.Failover.Active != client.BackendPrimary {
+		health.Status = "degraded"
+	}
+	
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(`{"status":"healthy","service":"go-api-proxy"}`))
+	if err := json.NewEncoder(w).Encode(health); err != nil {
+		logger.MainLogger.Error("Failed to encode health response", err)
+	}
+}
+
+// anyUpstreamHealthy reports whether at least one upstream is healthy
+func anyUpstreamHealthy(upstreams []client.UpstreamStatus) bool {
+	for _, upstream := range upstreams {
+		if upstream.Healthy {
+			return true
+		}
+	}
+	return false
 }
 
 // Start starts the HTTP server
@@ -138,15 +378,62 @@ func (ps *ProxyServer) Start() error {
 		"whitelist_file":   ps.config.WhitelistFile,
 		"whitelist_count":  ps.whitelist.Size(),
 		"timeout":          ps.config.Timeout.String(),
+		"tls":              ps.certReloader != nil,
 	})
 	
-	return ps.server.ListenAndServe()
+	ps.httpClient.StartHealthChecks()
+	ps.backendTLS.Start()
+	ps.jwtVerifier.Start()
+	ps.ipFilter.Start()
+	
+	if ps.certReloader == nil {
+		return ps.server.ListenAndServe()
+	}
+	
+	ps.certReloader.Start()
+	if ps.redirectServer != nil {
+		go func() {
+			logger.MainLogger.Info("Starting HTTP to HTTPS redirect listener", map[string]interface{}{
+				"port": ps.config.HTTPRedirectPort,
+			})
+			if err := ps.redirectServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logger.MainLogger.Error("HTTPS redirect listener failed", err)
+			}
+		}()
+	}
+	return ps.server.ListenAndServeTLS("", "")
 }
 
 // Shutdown gracefully shuts down the server
 func (ps *ProxyServer) Shutdown(ctx context.Context) error {
 	logger.MainLogger.Info("Shutting down server...")
-	return ps.server.Shutdown(ctx)
+	ps.httpClient.StopHealthChecks()
+	
+	// Hijacked WebSocket connections are not tracked by the HTTP server, so close them first
+	if wsErr := ps.websocketProxy.Shutdown(ctx); wsErr != nil {
+		logger.MainLogger.Error("WebSocket connections did not close in time", wsErr)
+	}
+	err := ps.server.Shutdown(ctx)
+	if ps.redirectServer != nil {
+		ps.redirectServer.Shutdown(ctx)
+	}
+	if ps.certReloader != nil {
+		ps.certReloader.Stop()
+	}
+	ps.backendTLS.Stop()
+	ps.jwtVerifier.Stop()
+	ps.ipFilter.Stop()
+	if ps.apiKeys != nil {
+		if closeErr := ps.apiKeys.Close(); closeErr != nil {
+			logger.MainLogger.Error("Failed to flush API key usage", closeErr)
+		}
+	}
+	if ps.diskCache != nil {
+		if closeErr := ps.diskCache.Close(); closeErr != nil {
+			logger.MainLogger.Error("Failed to flush disk cache", closeErr)
+		}
+	}
+	return err
 }
 
 func main() {
@@ -6,6 +6,7 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"strings"
 	"testing"
@@ -157,6 +158,40 @@ func TestProxyServer_HealthCheck(t *testing.T) {
 	}
 }
 
+func TestProxyServer_HealthCheckCircuitBreaker(t *testing.T) {
+	cfg := &config.Config{
+		BackendHost:         "https://example.com",
+		Port:                "8080",
+		WhitelistFile:       "nonexistent.json",
+		Timeout:             30 * time.Second,
+		CircuitFailureRatio: 0.5,
+		CircuitCooldown:     30 * time.Second,
+	}
+	
+	server, err := NewProxyServer(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create proxy server: %v", err)
+	}
+	
+	req := httptest.NewRequest("GET", "/health", nil)
+	w := httptest.NewRecorder()
+	
+	server.healthCheckHandler(w, req)
+	
+	var health HealthResponse
+	if err := json.NewDecoder(w.Body).Decode(&health); err != nil {
+		t.Fatalf("Failed to decode health response: %v", err)
+	}
+	
+	if health.Status != "healthy" {
+		t.Errorf("Expected status healthy, got %s", health.Status)
+	}
+	
+	if health.CircuitBreaker == nil || health.CircuitBreaker.State != "closed" {
+		t.Errorf("Expected closed circuit breaker in health response, got %+v", health.CircuitBreaker)
+	}
+}
+
 func TestProxyServer_TokensEndpointFiltering(t *testing.T) {
 	// Start mock backend server
 	mockServer := mockBackendServer()
@@ -303,7 +338,7 @@ func TestProxyServer_StandardProxyEndpoint(t *testing.T) {
 	}
 }
 
-func TestProxyServer_IsTokensEndpoint(t *testing.T) {
+func TestProxyServer_RouteTable(t *testing.T) {
 	cfg := &config.Config{
 		BackendHost:   "https://example.com",
 		Port:          "8080",
@@ -332,9 +367,51 @@ func TestProxyServer_IsTokensEndpoint(t *testing.T) {
 	}
 	
 	for _, test := range tests {
-		result := server.isTokensEndpoint(test.path)
+		u, err := url.Parse(test.path)
+		if err != nil {
+			t.Fatalf("Failed to parse path %q: %v", test.path, err)
+		}
+		match, ok := server.router.Match(u.Path)
+		result := ok && match.Route.Handler == config.HandlerTokenFilter
 		if result != test.expected {
-			t.Errorf("isTokensEndpoint(%q) = %v, expected %v", test.path, result, test.expected)
+			t.Errorf("token filter route for %q = %v, expected %v", test.path, result, test.expected)
+		}
+	}
+}
+
+func TestProxyServer_StandardProxyStripsAPIPrefix(t *testing.T) {
+	var backendPath string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		backendPath = r.URL.Path
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+	
+	cfg := &config.Config{
+		BackendHost:   mockServer.URL,
+		Port:          "8080",
+		WhitelistFile: "nonexistent.json",
+		Timeout:       30 * time.Second,
+	}
+	
+	server, err := NewProxyServer(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create proxy server: %v", err)
+	}
+	
+	tests := map[string]string{
+		"/api/v2/blocks/123": "/api/v2/blocks/123",
+		"/api?module=stats":  "/api",
+	}
+	
+	for requestPath, expectedPath := range tests {
+		req := httptest.NewRequest("GET", requestPath, nil)
+		w := httptest.NewRecorder()
+		
+		server.routeHandler(w, req)
+		
+		if backendPath != expectedPath {
+			t.Errorf("Request %s reached backend path %s, expected %s", requestPath, backendPath, expectedPath)
 		}
 	}
 }
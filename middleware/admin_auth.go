@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"go-api-proxy/config"
+	"go-api-proxy/jwtauth"
+)
+
+// AdminAuth authorizes requests to the admin APIs. The bearer token must be either
+// the static admin token or a JWT carrying one of the admin roles.
+type AdminAuth struct {
+	token      string
+	verifier   *jwtauth.Verifier
+	rolesClaim string
+	roles      []string
+}
+
+// NewAdminAuth creates the admin authorization from ADMIN_TOKEN and JWT_ADMIN_ROLES.
+// The verifier may be nil when JWT authentication is disabled.
+func NewAdminAuth(cfg *config.Config, verifier *jwtauth.Verifier) *AdminAuth {
+	return &AdminAuth{
+		token:      cfg.AdminToken,
+		verifier:   verifier,
+		rolesClaim: cfg.JWTRolesClaim,
+		roles:      cfg.JWTAdminRoles,
+	}
+}
+
+// Enabled reports whether any way to authorize admin requests is configured
+func (a *AdminAuth) Enabled() bool {
+	return a.token != "" || (a.verifier != nil && len(a.roles) > 0)
+}
+
+// Authorized checks the bearer token: the admin token is compared in constant time,
+// anything else is verified as a JWT with an admin role
+func (a *AdminAuth) Authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return false
+	}
+	if a.token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) == 1 {
+		return true
+	}
+	if a.verifier == nil || len(a.roles) == 0 {
+		return false
+	}
+	claims, err := a.verifier.Verify(token)
+	return err == nil && claims.HasAnyRole(a.rolesClaim, a.roles)
+}
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-api-proxy/config"
+)
+
+func TestAdminAuth(t *testing.T) {
+	verifier := newTestJWTVerifier(t)
+	auth := NewAdminAuth(&config.Config{AdminToken: "secret", JWTRolesClaim: "roles", JWTAdminRoles: []string{"proxy-admin"}}, verifier)
+
+	tests := []struct {
+		name          string
+		authorization string
+		want          bool
+	}{
+		{"admin token", "Bearer secret", true},
+		{"wrong admin token", "Bearer wrong", false},
+		{"no header", "", false},
+		{"basic auth", "Basic c2VjcmV0", false},
+		{"JWT with admin role", "Bearer " + signTestJWT([]string{"proxy-admin"}, time.Hour), true},
+		{"JWT without admin role", "Bearer " + signTestJWT([]string{"viewer"}, time.Hour), false},
+		{"expired JWT with admin role", "Bearer " + signTestJWT([]string{"proxy-admin"}, -time.Hour), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/admin/cache/stats", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			if got := auth.Authorized(req); got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	if NewAdminAuth(&config.Config{JWTAdminRoles: []string{"proxy-admin"}}, nil).Enabled() {
+		t.Error("Expected admin roles without a verifier to leave the admin API disabled")
+	}
+	if !NewAdminAuth(&config.Config{JWTAdminRoles: []string{"proxy-admin"}}, verifier).Enabled() {
+		t.Error("Expected admin roles with a verifier to enable the admin API")
+	}
+}
@@ -0,0 +1,175 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"go-api-proxy/apikeys"
+	"go-api-proxy/logger"
+)
+
+// APIKeyAdminHandler serves the /admin/keys API for issuing and managing API keys
+type APIKeyAdminHandler struct {
+	store *apikeys.Store
+	auth  *AdminAuth
+}
+
+// APIKeyListResponse is the body returned when listing keys
+type APIKeyListResponse struct {
+	Count int               `json:"count"`
+	Keys  []apikeys.KeyInfo `json:"keys"`
+}
+
+// APIKeyCreateRequest is the body of a request to issue a key
+type APIKeyCreateRequest struct {
+	ID    string `json:"id,omitempty"`
+	Owner string `json:"owner,omitempty"`
+	Plan  string `json:"plan"`
+}
+
+// APIKeyCreateResponse is the body returned after issuing a key. The key itself is
+// only ever returned here.
+type APIKeyCreateResponse struct {
+	Key string `json:"key"`
+	apikeys.KeyInfo
+}
+
+// APIKeyUpdateRequest is the body of a request to change a key
+type APIKeyUpdateRequest struct {
+	Plan     *string `json:"plan,omitempty"`
+	Disabled *bool   `json:"disabled,omitempty"`
+}
+
+// NewAPIKeyAdminHandler creates the API key admin API. Requests must be authorized
+// by the admin auth.
+func NewAPIKeyAdminHandler(store *apikeys.Store, auth *AdminAuth) *APIKeyAdminHandler {
+	return &APIKeyAdminHandler{
+		store: store,
+		auth:  auth,
+	}
+}
+
+// ServeHTTP implements the http.Handler interface for the API key admin API
+func (h *APIKeyAdminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	requestID := getRequestIDFromContext(r.Context())
+	adminLogger := logger.AuthLogger.WithRequestID(requestID)
+
+	if !h.auth.Authorized(r) {
+		adminLogger.Warn("Rejected API key admin request", map[string]interface{}{
+			"path":        r.URL.Path,
+			"remote_addr": r.RemoteAddr,
+		})
+		w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+		writeJSONError(w, http.StatusUnauthorized, "Unauthorized", "A valid admin token is required")
+		return
+	}
+
+	path := strings.TrimSuffix(r.URL.Path, "/")
+	switch {
+	case path == "/admin/keys":
+		switch r.Method {
+		case http.MethodGet:
+			keys := h.store.List()
+			writeAdminJSON(w, http.StatusOK, APIKeyListResponse{Count: len(keys), Keys: keys})
+		case http.MethodPost:
+			h.create(w, r, adminLogger)
+		default:
+			methodNotAllowed(w, r, http.MethodGet, http.MethodPost)
+		}
+	case path == "/admin/keys/plans":
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, r, http.MethodGet)
+			return
+		}
+		writeAdminJSON(w, http.StatusOK, map[string]interface{}{"plans": h.store.Plans()})
+	case strings.HasPrefix(path, "/admin/keys/") && !strings.Contains(strings.TrimPrefix(path, "/admin/keys/"), "/"):
+		id := strings.TrimPrefix(path, "/admin/keys/")
+		switch r.Method {
+		case http.MethodGet:
+			info, ok := h.store.Get(id)
+			if !ok {
+				writeJSONError(w, http.StatusNotFound, "Not found", "Unknown API key "+id)
+				return
+			}
+			writeAdminJSON(w, http.StatusOK, info)
+		case http.MethodPatch:
+			h.update(w, r, id, adminLogger)
+		case http.MethodDelete:
+			h.delete(w, id, adminLogger)
+		default:
+			methodNotAllowed(w, r, http.MethodGet, http.MethodPatch, http.MethodDelete)
+		}
+	default:
+		writeJSONError(w, http.StatusNotFound, "Not found", "Unknown admin endpoint "+r.URL.Path)
+	}
+}
+
+// create issues a key and returns it once
+func (h *APIKeyAdminHandler) create(w http.ResponseWriter, r *http.Request, adminLogger *logger.Logger) {
+	var body APIKeyCreateRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024)).Decode(&body); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Bad request", "Invalid JSON body: "+err.Error())
+		return
+	}
+	if body.Plan == "" {
+		writeJSONError(w, http.StatusBadRequest, "Bad request", "plan is required")
+		return
+	}
+
+	rawKey, info, err := h.store.Create(body.ID, body.Owner, body.Plan)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Bad request", err.Error())
+		return
+	}
+
+	adminLogger.Info("Issued API key", map[string]interface{}{
+		"key_id": info.ID,
+		"owner":  info.Owner,
+		"plan":   info.Plan,
+	})
+	writeAdminJSON(w, http.StatusCreated, APIKeyCreateResponse{Key: rawKey, KeyInfo: info})
+}
+
+// update changes a key's plan or disables it
+func (h *APIKeyAdminHandler) update(w http.ResponseWriter, r *http.Request, id string, adminLogger *logger.Logger) {
+	var body APIKeyUpdateRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024)).Decode(&body); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Bad request", "Invalid JSON body: "+err.Error())
+		return
+	}
+
+	info, err := h.store.Update(id, body.Plan, body.Disabled)
+	switch {
+	case errors.Is(err, apikeys.ErrUnknownKey):
+		writeJSONError(w, http.StatusNotFound, "Not found", "Unknown API key "+id)
+		return
+	case err != nil:
+		writeJSONError(w, http.StatusBadRequest, "Bad request", err.Error())
+		return
+	}
+
+	adminLogger.Info("Updated API key", map[string]interface{}{
+		"key_id":   info.ID,
+		"plan":     info.Plan,
+		"disabled": info.Disabled,
+	})
+	writeAdminJSON(w, http.StatusOK, info)
+}
+
+// delete revokes a key
+func (h *APIKeyAdminHandler) delete(w http.ResponseWriter, id string, adminLogger *logger.Logger) {
+	deleted, err := h.store.Delete(id)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Internal server error", err.Error())
+		return
+	}
+	if !deleted {
+		writeJSONError(w, http.StatusNotFound, "Not found", "Unknown API key "+id)
+		return
+	}
+
+	adminLogger.Info("Revoked API key", map[string]interface{}{"key_id": id})
+	writeAdminJSON(w, http.StatusOK, map[string]interface{}{"deleted": id})
+}
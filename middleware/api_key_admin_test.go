@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-api-proxy/config"
+)
+
+func keyAdminRequest(handler http.Handler, method, path, body, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+func TestAPIKeyAdminHandler(t *testing.T) {
+	store := newTestKeyStore(t)
+	handler := NewAPIKeyAdminHandler(store, NewAdminAuth(&config.Config{AdminToken: "admin-secret"}, nil))
+
+	if w := keyAdminRequest(handler, http.MethodGet, "/admin/keys", "", "wrong"); w.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected 401 with a wrong token, got %d", w.Code)
+	}
+
+	w := keyAdminRequest(handler, http.MethodGet, "/admin/keys", "", "admin-secret")
+	var list APIKeyListResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil || list.Count != 3 {
+		t.Fatalf("Expected 3 keys, got %s", w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), "hash") {
+		t.Error("Expected key hashes to stay out of the listing")
+	}
+
+	if w := keyAdminRequest(handler, http.MethodPost, "/admin/keys", `{"owner": "Carol", "plan": "gold"}`, "admin-secret"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown plan, got %d", w.Code)
+	}
+
+	w = keyAdminRequest(handler, http.MethodPost, "/admin/keys", `{"id": "carol", "owner": "Carol", "plan": "free"}`, "admin-secret")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var created APIKeyCreateResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil || created.Key == "" || created.ID != "carol" {
+		t.Fatalf("Expected the new key in the response, got %s", w.Body.String())
+	}
+	if _, _, err := store.Authenticate(created.Key); err != nil {
+		t.Errorf("Expected the issued key to authenticate, got %v", err)
+	}
+
+	w = keyAdminRequest(handler, http.MethodPatch, "/admin/keys/carol", `{"plan": "pro", "disabled": true}`, "admin-secret")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"disabled":true`) || !strings.Contains(w.Body.String(), `"plan":"pro"`) {
+		t.Errorf("Expected the key to be updated, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if w := keyAdminRequest(handler, http.MethodDelete, "/admin/keys/carol", "", "admin-secret"); w.Code != http.StatusOK {
+		t.Errorf("Expected 200 on delete, got %d", w.Code)
+	}
+	if w := keyAdminRequest(handler, http.MethodGet, "/admin/keys/carol", "", "admin-secret"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a deleted key, got %d", w.Code)
+	}
+
+	w = keyAdminRequest(handler, http.MethodGet, "/admin/keys/plans", "", "admin-secret")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"name":"free"`) {
+		t.Errorf("Expected the plans, got %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,172 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-api-proxy/apikeys"
+	"go-api-proxy/client"
+	"go-api-proxy/config"
+	"go-api-proxy/logger"
+)
+
+// APIConsumer is the authenticated API key of a request and its plan
+type APIConsumer struct {
+	Key  *apikeys.Key
+	Plan *apikeys.Plan
+}
+
+// APIKeyHandler authenticates consumers by API key, sent in a header or a query
+// parameter, and enforces their plan's allowed routes. Keys with no quota left are
+// rejected here, but requests are only charged by QuotaHandler once every other
+// check has passed. The key is removed from the request so it is neither logged nor
+// forwarded to the backend.
+type APIKeyHandler struct {
+	next     http.Handler
+	store    *apikeys.Store
+	router   *Router
+	header   string
+	param    string
+	required bool
+}
+
+// NewAPIKeyHandler creates the API key handler. Without API_KEYS_REQUIRED, requests
+// without a key pass as anonymous; a key that is sent must always be valid.
+func NewAPIKeyHandler(next http.Handler, store *apikeys.Store, router *Router, cfg *config.Config) *APIKeyHandler {
+	return &APIKeyHandler{
+		next:     next,
+		store:    store,
+		router:   router,
+		header:   cfg.APIKeyHeader,
+		param:    cfg.APIKeyParam,
+		required: cfg.APIKeysRequired,
+	}
+}
+
+// ServeHTTP implements the http.Handler interface for API key authentication
+func (h *APIKeyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rawKey := h.extractKey(r)
+	r = client.StripClientCredentials(r, h.param, h.header)
+
+	fields := map[string]interface{}{
+		"path":        r.URL.Path,
+		"remote_addr": r.RemoteAddr,
+	}
+
+	if rawKey == "" {
+		if h.required {
+			logger.AuthLogger.Warn("Rejected request without API key", fields)
+			w.Header().Set("WWW-Authenticate", `ApiKey realm="api"`)
+			writeJSONError(w, http.StatusUnauthorized, "Unauthorized", "An API key is required")
+			return
+		}
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	key, plan, err := h.store.Authenticate(rawKey)
+	switch {
+	case errors.Is(err, apikeys.ErrDisabledKey):
+		fields["key_id"] = key.ID
+		logger.AuthLogger.Warn("Rejected disabled API key", fields)
+		writeJSONError(w, http.StatusForbidden, "Forbidden", "The API key is disabled")
+		return
+	case err != nil:
+		logger.AuthLogger.Warn("Rejected unknown API key", fields)
+		w.Header().Set("WWW-Authenticate", `ApiKey realm="api"`)
+		writeJSONError(w, http.StatusUnauthorized, "Unauthorized", "The API key is not valid")
+		return
+	}
+
+	fields["key_id"] = key.ID
+	fields["plan"] = plan.Name
+	if match, ok := h.router.Match(r.URL.Path); ok && !plan.AllowsRoute(match.Route.Name) {
+		fields["route"] = match.Route.Name
+		logger.AuthLogger.Warn("Rejected API key for route outside its plan", fields)
+		writeJSONError(w, http.StatusForbidden, "Forbidden", "The API key's plan does not include "+r.URL.Path)
+		return
+	}
+
+	if quota := h.store.Check(key, plan); !quota.Allowed {
+		writeQuotaExceeded(w, quota, fields)
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), "api_consumer", &APIConsumer{Key: key, Plan: plan})
+	h.next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// QuotaHandler charges the authenticated API key's quota for a request that is about
+// to be served. It wraps the route handlers, so requests rejected by the rate
+// limiter, IP filter, validation or endpoint policy do not use up quota.
+type QuotaHandler struct {
+	next  http.Handler
+	store *apikeys.Store
+}
+
+// NewQuotaHandler creates a quota handler in front of a route handler
+func NewQuotaHandler(next http.Handler, store *apikeys.Store) *QuotaHandler {
+	return &QuotaHandler{
+		next:  next,
+		store: store,
+	}
+}
+
+// ServeHTTP implements the http.Handler interface for quota accounting
+func (h *QuotaHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	consumer := GetAPIConsumerFromContext(r.Context())
+	if consumer == nil {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	// Concurrent requests may have used up the quota since APIKeyHandler checked it
+	quota := h.store.Consume(consumer.Key, consumer.Plan)
+	if !quota.Allowed {
+		writeQuotaExceeded(w, quota, map[string]interface{}{
+			"path":   r.URL.Path,
+			"key_id": consumer.Key.ID,
+			"plan":   consumer.Plan.Name,
+		})
+		return
+	}
+	if quota.Remaining >= 0 {
+		w.Header().Set("X-Quota-Remaining", strconv.FormatInt(quota.Remaining, 10))
+	}
+	h.next.ServeHTTP(w, r)
+}
+
+// writeQuotaExceeded rejects a request from a key whose quota is used up
+func writeQuotaExceeded(w http.ResponseWriter, quota apikeys.QuotaResult, fields map[string]interface{}) {
+	retryAfter := int(math.Ceil(time.Until(quota.ResetAt).Seconds()))
+	fields["retry_after"] = retryAfter
+	logger.AuthLogger.Warn("Rejected API key over quota", fields)
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	w.Header().Set("X-Quota-Remaining", "0")
+	writeJSONError(w, http.StatusTooManyRequests, "Quota exceeded", "The API key's request quota is used up until "+quota.ResetAt.Format(time.RFC3339))
+}
+
+// extractKey returns the API key from the header, or from the query parameter
+func (h *APIKeyHandler) extractKey(r *http.Request) string {
+	if h.header != "" {
+		if key := r.Header.Get(h.header); key != "" {
+			return key
+		}
+	}
+	if h.param != "" {
+		return r.URL.Query().Get(h.param)
+	}
+	return ""
+}
+
+// GetAPIConsumerFromContext returns the authenticated API key of the request, if any
+func GetAPIConsumerFromContext(ctx context.Context) *APIConsumer {
+	if consumer, ok := ctx.Value("api_consumer").(*APIConsumer); ok {
+		return consumer
+	}
+	return nil
+}
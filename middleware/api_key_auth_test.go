@@ -0,0 +1,201 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"go-api-proxy/apikeys"
+	"go-api-proxy/config"
+	"go-api-proxy/models"
+)
+
+func newTestKeyStore(t *testing.T) *apikeys.Store {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "keys.json")
+	content := `{
+  "plans": [
+    {"name": "free", "requests_per_second": 1, "daily_quota": 3, "allowed_routes": ["blocks"]},
+    {"name": "pro"}
+  ],
+  "keys": [
+    {"id": "alice", "plan": "free", "key": "alice-secret"},
+    {"id": "bob", "plan": "pro", "key": "bob-secret"},
+    {"id": "eve", "plan": "pro", "key": "eve-secret", "disabled": true}
+  ]
+}`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write keys file: %v", err)
+	}
+	store, err := apikeys.Open(path, "", 0)
+	if err != nil {
+		t.Fatalf("Failed to open key store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+var apiKeyTestRoutes = []config.Route{
+	{Name: "blocks", Pattern: "/api/v2/blocks", Handler: config.HandlerPassthrough},
+	{Name: "tokens", Pattern: "/api/v2/tokens", Handler: config.HandlerTokenFilter},
+}
+
+func TestAPIKeyHandler(t *testing.T) {
+	router, _, _ := newTestRouter(t, apiKeyTestRoutes)
+	handler := NewAPIKeyHandler(router, newTestKeyStore(t), router, &config.Config{
+		APIKeyHeader:    "X-API-Key",
+		APIKeyParam:     "apikey",
+		APIKeysRequired: true,
+	})
+
+	tests := []struct {
+		name       string
+		path       string
+		key        string
+		wantStatus int
+		wantError  string
+	}{
+		{"missing key", "/api/v2/blocks", "", http.StatusUnauthorized, "Unauthorized"},
+		{"unknown key", "/api/v2/blocks", "mallory", http.StatusUnauthorized, "Unauthorized"},
+		{"disabled key", "/api/v2/blocks", "eve-secret", http.StatusForbidden, "Forbidden"},
+		{"route outside plan", "/api/v2/tokens", "alice-secret", http.StatusForbidden, "Forbidden"},
+		{"valid key", "/api/v2/blocks", "alice-secret", http.StatusOK, ""},
+		{"plan without route list", "/api/v2/tokens", "bob-secret", http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.key != "" {
+				req.Header.Set("X-API-Key", tt.key)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if tt.wantError != "" {
+				var body models.ErrorResponse
+				if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.Error != tt.wantError {
+					t.Errorf("Expected error %q, got %q", tt.wantError, w.Body.String())
+				}
+			}
+		})
+	}
+}
+
+// newQuotaTestHandler chains the API key handler, a router and quota accounting in
+// front of the route handlers, as the server does
+func newQuotaTestHandler(t *testing.T, routes []config.Route) (http.Handler, *recordingHandler) {
+	t.Helper()
+
+	store := newTestKeyStore(t)
+	passthrough := &recordingHandler{name: "passthrough"}
+	router, err := NewRouter(routes, map[string]http.Handler{
+		config.HandlerPassthrough: NewQuotaHandler(passthrough, store),
+		config.HandlerTokenFilter: NewQuotaHandler(&recordingHandler{name: "token-filter"}, store),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create router: %v", err)
+	}
+	return NewAPIKeyHandler(router, store, router, &config.Config{APIKeyParam: "apikey"}), passthrough
+}
+
+func TestAPIKeyHandler_QueryKeyAndQuota(t *testing.T) {
+	handler, passthrough := newQuotaTestHandler(t, apiKeyTestRoutes)
+
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v2/blocks?apikey=alice-secret&page=2", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Request %d: expected 200, got %d", i, w.Code)
+		}
+		if w.Header().Get("X-Quota-Remaining") != strconv.Itoa(2-i) {
+			t.Errorf("Request %d: unexpected X-Quota-Remaining %q", i, w.Header().Get("X-Quota-Remaining"))
+		}
+	}
+	if passthrough.request.URL.RawQuery != "page=2" {
+		t.Errorf("Expected the key to be removed from the forwarded query, got %q", passthrough.request.URL.RawQuery)
+	}
+	consumer := GetAPIConsumerFromContext(passthrough.request.Context())
+	if consumer == nil || consumer.Key.ID != "alice" || consumer.Plan.Name != "free" {
+		t.Errorf("Expected the consumer in the request context, got %+v", consumer)
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v2/blocks?apikey=alice-secret", nil))
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected 429 with Retry-After once the quota is used up, got %d", w.Code)
+	}
+
+	// Without API_KEYS_REQUIRED, anonymous requests pass
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v2/tokens", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected an anonymous request to pass, got %d", w.Code)
+	}
+}
+
+func TestQuotaHandler_RejectedRequestsAreNotCharged(t *testing.T) {
+	handler, _ := newQuotaTestHandler(t, []config.Route{
+		{Name: "blocks", Pattern: "/api/v2/blocks", Handler: config.HandlerPassthrough, Methods: []string{http.MethodGet}},
+	})
+
+	for i := 0; i < 5; i++ {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v2/blocks?apikey=alice-secret", nil))
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Fatalf("Expected 405 for a method outside the route, got %d", w.Code)
+		}
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v2/unknown?apikey=alice-secret", nil))
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("Expected 404 for an unrouted path, got %d", w.Code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v2/blocks?apikey=alice-secret", nil))
+	if w.Code != http.StatusOK || w.Header().Get("X-Quota-Remaining") != "2" {
+		t.Errorf("Expected rejected requests to leave the quota untouched, got %d with X-Quota-Remaining %q", w.Code, w.Header().Get("X-Quota-Remaining"))
+	}
+}
+
+func TestAPIKeyHandler_PlanRateLimit(t *testing.T) {
+	router, _, _ := newTestRouter(t, apiKeyTestRoutes)
+	cfg := &config.Config{APIKeysFile: "keys.json", APIKeyHeader: "X-API-Key"}
+	limiter := NewRateLimitHandler(router, router, apiKeyTestRoutes, cfg)
+	if limiter == nil {
+		t.Fatal("Expected API keys to enable the rate limiter")
+	}
+	handler := NewAPIKeyHandler(limiter, newTestKeyStore(t), router, cfg)
+
+	request := func(key, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v2/blocks", nil)
+		req.Header.Set("X-API-Key", key)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := request("alice-secret", "192.0.2.1:1234"); w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "1" {
+		t.Fatalf("Expected the plan's limit, got %d with limit %q", w.Code, w.Header().Get("RateLimit-Limit"))
+	}
+	// The bucket belongs to the key, not the address
+	if w := request("alice-secret", "192.0.2.2:1234"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected the key's bucket to be empty from another address, got %d", w.Code)
+	}
+	// Plans without a rate fall back to the default limit, which is off here
+	for i := 0; i < 5; i++ {
+		if w := request("bob-secret", "192.0.2.1:1234"); w.Code != http.StatusOK {
+			t.Fatalf("Expected the pro plan to be unlimited, got %d", w.Code)
+		}
+	}
+}
@@ -0,0 +1,442 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go-api-proxy/cache"
+	"go-api-proxy/config"
+	"go-api-proxy/logger"
+)
+
+// CacheHandler serves GET requests on routes with a cache TTL from a shared response
+// cache. It honours upstream Cache-Control and Vary, revalidates stale entries with
+// conditional requests and answers client If-None-Match requests with 304.
+type CacheHandler struct {
+	next          http.Handler
+	store         cache.Store
+	maxEntryBytes int64
+	now           func() time.Time
+
+	hits          int64
+	misses        int64
+	revalidations int64
+}
+
+// CacheStats reports cache effectiveness and the usage of each cache tier
+type CacheStats struct {
+	Hits          int64             `json:"hits"`
+	Misses        int64             `json:"misses"`
+	Revalidations int64             `json:"revalidations"`
+	HitRatio      float64           `json:"hit_ratio"`
+	Tiers         []cache.TierStats `json:"tiers"`
+}
+
+// NewCacheHandler creates a caching handler in front of next. Responses larger than
+// maxEntryBytes are passed through without being cached.
+func NewCacheHandler(next http.Handler, store cache.Store, maxEntryBytes int64) *CacheHandler {
+	if maxEntryBytes <= 0 {
+		maxEntryBytes = 1 << 20
+	}
+	return &CacheHandler{
+		next:          next,
+		store:         store,
+		maxEntryBytes: maxEntryBytes,
+		now:           time.Now,
+	}
+}
+
+// ServeHTTP implements the http.Handler interface for the response cache
+func (h *CacheHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	match := GetRouteMatchFromContext(r.Context())
+	if match == nil || match.Route.CacheTTL <= 0 || !isCacheableRequest(r) {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	requestDirectives := cache.ParseCacheControl(r.Header)
+	if _, noStore := requestDirectives["no-store"]; noStore {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	requestID := getRequestIDFromContext(r.Context())
+	cacheLogger := logger.MiddlewareLogger.WithRequestID(requestID)
+
+	requestURI := match.Path
+	if r.URL.RawQuery != "" {
+		requestURI += "?" + r.URL.RawQuery
+	}
+	baseKey := cache.Key(http.MethodGet, requestURI)
+	ttl := time.Duration(match.Route.CacheTTL) * time.Second
+
+	entry := h.lookup(baseKey, r.Header)
+	_, noCache := requestDirectives["no-cache"]
+	if entry != nil && entry.Fresh(h.now()) && !noCache {
+		cacheLogger.Debug("Serving response from cache", map[string]interface{}{
+			"key": entry.Key,
+			"age": entry.Age(h.now()).String(),
+		})
+		atomic.AddInt64(&h.hits, 1)
+		h.serveEntry(w, r, entry, "HIT")
+		return
+	}
+
+	if r.Method == http.MethodHead {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	var stale *cache.Entry
+	if entry != nil && entry.HasValidator() {
+		stale = entry
+	}
+	h.fetch(w, r, baseKey, ttl, stale, cacheLogger)
+}
+
+// lookup returns the cached response for the request, following the variant index if the response varies
+func (h *CacheHandler) lookup(baseKey string, header http.Header) *cache.Entry {
+	entry, ok := h.store.Get(baseKey)
+	if !ok {
+		return nil
+	}
+	if entry.IsVariantIndex() {
+		entry, ok = h.store.Get(cache.VariantKey(baseKey, entry.Vary, header))
+		if !ok {
+			return nil
+		}
+	}
+	return entry
+}
+
+// fetch forwards the request to the backend, revalidating the stale entry if there is one,
+// and stores the response when it is cacheable
+func (h *CacheHandler) fetch(w http.ResponseWriter, r *http.Request, baseKey string, ttl time.Duration, stale *cache.Entry, cacheLogger *logger.Logger) {
+	// Fetch the full response so it can be cached; client conditionals are answered from the cache
+	backendReq := r.Clone(r.Context())
+	backendReq.Header.Del("If-None-Match")
+	backendReq.Header.Del("If-Modified-Since")
+	if stale != nil {
+		if etag := stale.ETag(); etag != "" {
+			backendReq.Header.Set("If-None-Match", etag)
+		}
+		if lastModified := stale.Header.Get("Last-Modified"); lastModified != "" {
+			backendReq.Header.Set("If-Modified-Since", lastModified)
+		}
+	}
+
+	// Hold the response back when it may need to be answered from the cache instead
+	hold := stale != nil || r.Header.Get("If-None-Match") != ""
+	rec := newCacheRecorder(w, h.maxEntryBytes, hold)
+	h.next.ServeHTTP(rec, backendReq)
+	if !rec.wroteHeader {
+		rec.WriteHeader(http.StatusOK)
+	}
+
+	now := h.now()
+	if rec.held && rec.status == http.StatusNotModified && stale != nil {
+		refreshed := *stale
+		refreshed.Header = stale.Header.Clone()
+		for name, values := range rec.header {
+			if name != "Content-Length" {
+				refreshed.Header[name] = values
+			}
+		}
+		refreshed.StoredAt = now
+		if freshFor, ok := freshnessLifetime(refreshed.Header, ttl); ok {
+			refreshed.ExpiresAt = now.Add(freshFor)
+		}
+		h.store.Set(&refreshed)
+
+		cacheLogger.Debug("Revalidated cached response", map[string]interface{}{
+			"key": refreshed.Key,
+		})
+		atomic.AddInt64(&h.revalidations, 1)
+		h.serveEntry(w, r, &refreshed, "REVALIDATED")
+		return
+	}
+	atomic.AddInt64(&h.misses, 1)
+
+	entry := h.newEntry(r, baseKey, ttl, rec, now)
+	if entry != nil {
+		h.store.Set(entry)
+		cacheLogger.Debug("Stored response in cache", map[string]interface{}{
+			"key":        entry.Key,
+			"size":       entry.Size(),
+			"expires_at": entry.ExpiresAt.UTC().Format(time.RFC3339),
+		})
+	}
+
+	if !rec.held {
+		return
+	}
+	if entry != nil {
+		h.serveEntry(w, r, entry, "MISS")
+		return
+	}
+	rec.flush()
+}
+
+// newEntry builds a cache entry from the recorded response, storing the variant index
+// when the response varies. It returns nil when the response must not be cached.
+func (h *CacheHandler) newEntry(r *http.Request, baseKey string, ttl time.Duration, rec *cacheRecorder, now time.Time) *cache.Entry {
+	if rec.status != http.StatusOK || rec.overflow || rec.header.Get("Set-Cookie") != "" {
+		return nil
+	}
+
+	freshFor, ok := freshnessLifetime(rec.header, ttl)
+	if !ok {
+		return nil
+	}
+
+	entry := &cache.Entry{
+		Key:        baseKey,
+		StatusCode: rec.status,
+		Header:     rec.header.Clone(),
+		Body:       bytes.Clone(rec.body.Bytes()),
+		StoredAt:   now,
+		ExpiresAt:  now.Add(freshFor),
+	}
+	if freshFor == 0 && !entry.HasValidator() {
+		return nil
+	}
+
+	vary := cache.ParseVary(rec.header)
+	if rec.header.Get("Content-Encoding") != "" && !containsString(vary, "Accept-Encoding") {
+		vary = append(vary, "Accept-Encoding")
+	}
+	if containsString(vary, "*") {
+		return nil
+	}
+	if len(vary) > 0 {
+		h.store.Set(&cache.Entry{
+			Key:       baseKey,
+			Vary:      vary,
+			StoredAt:  now,
+			ExpiresAt: entry.ExpiresAt,
+		})
+		entry.Key = cache.VariantKey(baseKey, vary, r.Header)
+	}
+	return entry
+}
+
+// serveEntry writes a cached response, answering matching If-None-Match requests with 304
+func (h *CacheHandler) serveEntry(w http.ResponseWriter, r *http.Request, entry *cache.Entry, cacheStatus string) {
+	for name, values := range entry.Header {
+		w.Header()[name] = values
+	}
+	w.Header().Set("Age", strconv.Itoa(int(entry.Age(h.now())/time.Second)))
+	w.Header().Set("X-Cache", cacheStatus)
+
+	if cache.MatchETag(r.Header.Get("If-None-Match"), entry.ETag()) {
+		w.Header().Del("Content-Length")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.WriteHeader(entry.StatusCode)
+	if r.Method != http.MethodHead {
+		w.Write(entry.Body)
+	}
+}
+
+// Stats returns hit/miss counters and the usage of each cache tier
+func (h *CacheHandler) Stats() CacheStats {
+	stats := CacheStats{
+		Hits:          atomic.LoadInt64(&h.hits),
+		Misses:        atomic.LoadInt64(&h.misses),
+		Revalidations: atomic.LoadInt64(&h.revalidations),
+		Tiers:         h.store.Stats(),
+	}
+	if total := stats.Hits + stats.Misses + stats.Revalidations; total > 0 {
+		stats.HitRatio = float64(stats.Hits+stats.Revalidations) / float64(total)
+	}
+	return stats
+}
+
+// List returns the cached entries whose key starts with prefix. A prefix
+// starting with "/" is treated as a request path.
+func (h *CacheHandler) List(prefix string) []cache.EntryInfo {
+	return h.store.List(normalizeCacheKey(prefix))
+}
+
+// Purge removes the entry for a cache key or request path, including all of its
+// variants. It returns the number of entries removed.
+func (h *CacheHandler) Purge(key string) int {
+	key = normalizeCacheKey(key)
+	purged := 0
+	if h.store.Delete(key) {
+		purged++
+	}
+	for _, info := range h.store.List(key + "\n") {
+		if h.store.Delete(info.Key) {
+			purged++
+		}
+	}
+	return purged
+}
+
+// PurgePattern removes every entry whose request path matches a route pattern
+// such as "/api/v2/blocks/{id}" or "/api/v2/blocks/*"
+func (h *CacheHandler) PurgePattern(pattern string) (int, error) {
+	route := config.Route{Name: "purge", Pattern: pattern, Handler: config.HandlerPassthrough}
+	if err := route.Validate(); err != nil {
+		return 0, err
+	}
+	compiled := compileRoute(route)
+
+	purged := 0
+	for _, info := range h.store.List("") {
+		if _, ok := compiled.match(splitPath(normalizePath(cacheKeyPath(info.Key)))); ok && h.store.Delete(info.Key) {
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// PurgeAll removes every cached entry
+func (h *CacheHandler) PurgeAll() int {
+	purged := 0
+	for _, info := range h.store.List("") {
+		if h.store.Delete(info.Key) {
+			purged++
+		}
+	}
+	return purged
+}
+
+// normalizeCacheKey turns a request path into the cache key for a GET of that path
+func normalizeCacheKey(key string) string {
+	if strings.HasPrefix(key, "/") {
+		return cache.Key(http.MethodGet, key)
+	}
+	return key
+}
+
+// cacheKeyPath extracts the request path from a cache key
+func cacheKeyPath(key string) string {
+	_, path, _ := strings.Cut(key, " ")
+	path, _, _ = strings.Cut(path, "\n")
+	path, _, _ = strings.Cut(path, "?")
+	return path
+}
+
+// freshnessLifetime returns how long a response may be served from the cache: the route
+// TTL, shortened by any upstream max-age. It reports false for responses that must not be stored.
+func freshnessLifetime(header http.Header, ttl time.Duration) (time.Duration, bool) {
+	directives := cache.ParseCacheControl(header)
+	if _, ok := directives["no-store"]; ok {
+		return 0, false
+	}
+	if _, ok := directives["private"]; ok {
+		return 0, false
+	}
+	if _, ok := directives["no-cache"]; ok {
+		return 0, true
+	}
+	if maxAge, ok := cache.MaxAge(directives); ok && maxAge < ttl {
+		return maxAge, true
+	}
+	return ttl, true
+}
+
+// isCacheableRequest reports whether a request may be served from the shared cache
+func isCacheableRequest(r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	return r.Header.Get("Authorization") == ""
+}
+
+// containsString checks if the list contains the value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheRecorder captures a response for caching. It either passes the response through to
+// the client as it is written, or holds it back so the cache can decide what to send.
+// A held response that grows beyond the entry limit is flushed and passed through.
+type cacheRecorder struct {
+	w           http.ResponseWriter
+	header      http.Header
+	body        bytes.Buffer
+	limit       int64
+	status      int
+	wroteHeader bool
+	held        bool
+	overflow    bool
+}
+
+func newCacheRecorder(w http.ResponseWriter, limit int64, hold bool) *cacheRecorder {
+	return &cacheRecorder{
+		w:      w,
+		header: make(http.Header),
+		limit:  limit,
+		held:   hold,
+	}
+}
+
+func (rec *cacheRecorder) Header() http.Header {
+	return rec.header
+}
+
+func (rec *cacheRecorder) WriteHeader(status int) {
+	if rec.wroteHeader {
+		return
+	}
+	rec.wroteHeader = true
+	rec.status = status
+	if !rec.held {
+		rec.writeHeaderThrough()
+	}
+}
+
+func (rec *cacheRecorder) Write(p []byte) (int, error) {
+	if !rec.wroteHeader {
+		rec.WriteHeader(http.StatusOK)
+	}
+
+	if !rec.overflow {
+		if int64(rec.body.Len()+len(p)) > rec.limit {
+			rec.overflow = true
+			if rec.held {
+				rec.flush()
+			}
+			rec.body.Reset()
+		} else {
+			rec.body.Write(p)
+			if rec.held {
+				return len(p), nil
+			}
+		}
+	}
+	return rec.w.Write(p)
+}
+
+// flush sends a held response to the client and switches to pass-through
+func (rec *cacheRecorder) flush() {
+	if !rec.held {
+		return
+	}
+	rec.held = false
+	rec.writeHeaderThrough()
+	rec.w.Write(rec.body.Bytes())
+}
+
+// writeHeaderThrough copies the recorded headers and status to the client
+func (rec *cacheRecorder) writeHeaderThrough() {
+	for name, values := range rec.header {
+		rec.w.Header()[name] = values
+	}
+	rec.w.Header().Set("X-Cache", "MISS")
+	rec.w.WriteHeader(rec.status)
+}
@@ -0,0 +1,198 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go-api-proxy/cache"
+	"go-api-proxy/config"
+	"go-api-proxy/logger"
+)
+
+// CacheAdminHandler serves the /admin/cache API for inspecting and purging cached responses
+type CacheAdminHandler struct {
+	cache  *CacheHandler
+	router *Router
+	tokens *TokenFilterHandler
+	auth   *AdminAuth
+}
+
+// CacheEntriesResponse is the body returned when listing cache entries
+type CacheEntriesResponse struct {
+	Prefix  string            `json:"prefix"`
+	Count   int               `json:"count"`
+	Entries []cache.EntryInfo `json:"entries"`
+}
+
+// CachePurgeResponse is the body returned after a purge
+type CachePurgeResponse struct {
+	Purged                int  `json:"purged"`
+	TokenCacheInvalidated bool `json:"token_cache_invalidated"`
+}
+
+// NewCacheAdminHandler creates the cache admin API. Requests must be authorized by
+// the admin auth. The response cache may be nil when caching is disabled.
+func NewCacheAdminHandler(responseCache *CacheHandler, router *Router, tokens *TokenFilterHandler, auth *AdminAuth) *CacheAdminHandler {
+	return &CacheAdminHandler{
+		cache:  responseCache,
+		router: router,
+		tokens: tokens,
+		auth:   auth,
+	}
+}
+
+// ServeHTTP implements the http.Handler interface for the cache admin API
+func (h *CacheAdminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	requestID := getRequestIDFromContext(r.Context())
+	adminLogger := logger.MiddlewareLogger.WithRequestID(requestID)
+
+	if !h.auth.Authorized(r) {
+		adminLogger.Warn("Rejected cache admin request", map[string]interface{}{
+			"path":        r.URL.Path,
+			"remote_addr": r.RemoteAddr,
+		})
+		w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+		writeJSONError(w, http.StatusUnauthorized, "Unauthorized", "A valid admin token is required")
+		return
+	}
+
+	switch strings.TrimSuffix(r.URL.Path, "/") {
+	case "/admin/cache/stats":
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, r, http.MethodGet)
+			return
+		}
+		writeAdminJSON(w, http.StatusOK, h.stats())
+	case "/admin/cache/entries":
+		switch r.Method {
+		case http.MethodGet:
+			h.list(w, r)
+		case http.MethodDelete:
+			h.purge(w, r, adminLogger)
+		default:
+			methodNotAllowed(w, r, http.MethodGet, http.MethodDelete)
+		}
+	default:
+		writeJSONError(w, http.StatusNotFound, "Not found", "Unknown admin endpoint "+r.URL.Path)
+	}
+}
+
+// stats returns the response cache statistics, or empty statistics when caching is disabled
+func (h *CacheAdminHandler) stats() CacheStats {
+	if h.cache == nil {
+		return CacheStats{Tiers: []cache.TierStats{}}
+	}
+	return h.cache.Stats()
+}
+
+// list writes the entries matching the prefix query parameter
+func (h *CacheAdminHandler) list(w http.ResponseWriter, r *http.Request) {
+	prefix := r.URL.Query().Get("prefix")
+	entries := []cache.EntryInfo{}
+	if h.cache != nil {
+		if listed := h.cache.List(prefix); listed != nil {
+			entries = listed
+		}
+	}
+
+	if limit, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && limit >= 0 && limit < len(entries) {
+		entries = entries[:limit]
+	}
+
+	writeAdminJSON(w, http.StatusOK, CacheEntriesResponse{
+		Prefix:  prefix,
+		Count:   len(entries),
+		Entries: entries,
+	})
+}
+
+// purge removes one key, every key matching a route pattern, or everything
+func (h *CacheAdminHandler) purge(w http.ResponseWriter, r *http.Request, adminLogger *logger.Logger) {
+	query := r.URL.Query()
+	key, pattern := query.Get("key"), query.Get("pattern")
+	all, _ := strconv.ParseBool(query.Get("all"))
+
+	var response CachePurgeResponse
+	switch {
+	case key != "":
+		if h.cache != nil {
+			response.Purged = h.cache.Purge(key)
+		}
+		if h.isTokenPath(cacheKeyPath(normalizeCacheKey(key))) {
+			response.TokenCacheInvalidated = h.tokens.InvalidateCache()
+		}
+	case pattern != "":
+		if h.cache != nil {
+			purged, err := h.cache.PurgePattern(pattern)
+			if err != nil {
+				writeJSONError(w, http.StatusBadRequest, "Bad request", err.Error())
+				return
+			}
+			response.Purged = purged
+		}
+		if h.patternCoversTokens(pattern) {
+			response.TokenCacheInvalidated = h.tokens.InvalidateCache()
+		}
+	case all:
+		if h.cache != nil {
+			response.Purged = h.cache.PurgeAll()
+		}
+		if h.tokens != nil {
+			response.TokenCacheInvalidated = h.tokens.InvalidateCache()
+		}
+	default:
+		writeJSONError(w, http.StatusBadRequest, "Bad request", "One of key, pattern or all=true is required")
+		return
+	}
+
+	adminLogger.Info("Purged cache entries", map[string]interface{}{
+		"key":                     key,
+		"pattern":                 pattern,
+		"all":                     all,
+		"purged":                  response.Purged,
+		"token_cache_invalidated": response.TokenCacheInvalidated,
+	})
+	writeAdminJSON(w, http.StatusOK, response)
+}
+
+// isTokenPath reports whether the path is served by the token filter
+func (h *CacheAdminHandler) isTokenPath(path string) bool {
+	if h.tokens == nil || h.router == nil {
+		return false
+	}
+	match, ok := h.router.Match(path)
+	return ok && match.Route.Handler == config.HandlerTokenFilter
+}
+
+// patternCoversTokens reports whether a purge pattern matches any token filter route
+func (h *CacheAdminHandler) patternCoversTokens(pattern string) bool {
+	if h.tokens == nil || h.router == nil {
+		return false
+	}
+	compiled := compileRoute(config.Route{Pattern: pattern})
+	for _, route := range h.router.routes {
+		if route.route.Handler != config.HandlerTokenFilter {
+			continue
+		}
+		if _, ok := compiled.match(splitPath(normalizePath(route.route.Pattern))); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// methodNotAllowed writes a 405 response listing the allowed methods
+func methodNotAllowed(w http.ResponseWriter, r *http.Request, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeJSONError(w, http.StatusMethodNotAllowed, "Method not allowed", r.Method+" is not allowed for "+r.URL.Path)
+}
+
+// writeAdminJSON writes a JSON response that must not be cached
+func writeAdminJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,129 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-api-proxy/cache"
+	"go-api-proxy/config"
+	"go-api-proxy/models"
+)
+
+func newCacheAdminTest(t *testing.T) (*CacheAdminHandler, *Router, *TokenFilterHandler) {
+	t.Helper()
+
+	backend := &countingBackend{handle: func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Vary", "Accept-Language")
+		w.Write([]byte("ok"))
+	}}
+	tokens := NewTokenFilterHandler(&mockHTTPClient{tokenResponse: &models.TokenResponse{}}, models.NewTokenWhitelist())
+	cacheHandler := NewCacheHandler(backend, cache.NewLRU(1<<20), 1024)
+
+	router, err := NewRouter([]config.Route{
+		{Name: "tokens", Pattern: "/api/v2/tokens", Handler: config.HandlerTokenFilter, CacheTTL: 30},
+		{Name: "block", Pattern: "/api/v2/blocks/{id}", Handler: config.HandlerPassthrough, CacheTTL: 60},
+		{Name: "transaction", Pattern: "/api/v2/transactions/{hash}", Handler: config.HandlerPassthrough, CacheTTL: 60},
+	}, map[string]http.Handler{
+		config.HandlerPassthrough: cacheHandler,
+		config.HandlerTokenFilter: tokens,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create router: %v", err)
+	}
+
+	for _, path := range []string{"/api/v2/blocks/1", "/api/v2/blocks/2", "/api/v2/transactions/0xabc", "/api/v2/tokens"} {
+		serveCacheTest(router, "GET", path, http.Header{"Accept-Language": {"en"}})
+	}
+	serveCacheTest(router, "GET", "/api/v2/blocks/1", http.Header{"Accept-Language": {"de"}})
+	serveCacheTest(router, "GET", "/api/v2/blocks/1", http.Header{"Accept-Language": {"de"}})
+
+	return NewCacheAdminHandler(cacheHandler, router, tokens, NewAdminAuth(&config.Config{AdminToken: "secret"}, nil)), router, tokens
+}
+
+func serveAdmin(handler http.Handler, method, target, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+func TestCacheAdminHandler_RequiresToken(t *testing.T) {
+	admin, _, _ := newCacheAdminTest(t)
+
+	for _, token := range []string{"", "wrong"} {
+		if w := serveAdmin(admin, "GET", "/admin/cache/stats", token); w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected 401 with token %q, got %d", token, w.Code)
+		}
+	}
+	if w := serveAdmin(admin, "GET", "/admin/cache/stats", "secret"); w.Code != http.StatusOK {
+		t.Errorf("Expected 200 with the admin token, got %d", w.Code)
+	}
+}
+
+func TestCacheAdminHandler_StatsAndList(t *testing.T) {
+	admin, _, _ := newCacheAdminTest(t)
+
+	var stats CacheStats
+	json.NewDecoder(serveAdmin(admin, "GET", "/admin/cache/stats", "secret").Body).Decode(&stats)
+	if stats.Hits != 1 || stats.Misses != 4 {
+		t.Errorf("Expected 1 hit and 4 misses, got %+v", stats)
+	}
+	if len(stats.Tiers) != 1 || stats.Tiers[0].Tier != "memory" || stats.Tiers[0].Entries == 0 {
+		t.Errorf("Expected memory tier usage, got %+v", stats.Tiers)
+	}
+
+	var listed CacheEntriesResponse
+	json.NewDecoder(serveAdmin(admin, "GET", "/admin/cache/entries?prefix=/api/v2/blocks/", "secret").Body).Decode(&listed)
+	// Two variant index entries and three variants
+	if listed.Count != 5 {
+		t.Errorf("Expected 5 block entries, got %d: %+v", listed.Count, listed.Entries)
+	}
+}
+
+func TestCacheAdminHandler_Purge(t *testing.T) {
+	admin, _, tokens := newCacheAdminTest(t)
+
+	purge := func(query string) CachePurgeResponse {
+		t.Helper()
+		w := serveAdmin(admin, "DELETE", "/admin/cache/entries?"+query, "secret")
+		if w.Code != http.StatusOK {
+			t.Fatalf("Purge %q returned %d: %s", query, w.Code, w.Body.String())
+		}
+		var response CachePurgeResponse
+		json.NewDecoder(w.Body).Decode(&response)
+		return response
+	}
+
+	if response := purge("key=/api/v2/blocks/1"); response.Purged != 3 || response.TokenCacheInvalidated {
+		t.Errorf("Expected key purge to remove the index and both variants, got %+v", response)
+	}
+
+	if response := purge("pattern=/api/v2/transactions/{hash}"); response.Purged != 2 {
+		t.Errorf("Expected pattern purge to remove the transaction entries, got %+v", response)
+	}
+
+	if response := purge("key=/api/v2/tokens"); !response.TokenCacheInvalidated {
+		t.Errorf("Expected purging the token list to invalidate the filtered token cache, got %+v", response)
+	}
+
+	tokens.storeTokens("tokens|", &filteredTokens{response: &models.TokenResponse{}})
+	if response := purge("pattern=/api/v2/*"); response.Purged != 2 || !response.TokenCacheInvalidated {
+		t.Errorf("Expected wildcard purge to remove remaining entries and the token cache, got %+v", response)
+	}
+
+	if response := purge("all=true"); response.Purged != 0 {
+		t.Errorf("Expected nothing left to purge, got %+v", response)
+	}
+
+	if w := serveAdmin(admin, "DELETE", "/admin/cache/entries", "secret"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without purge parameters, got %d", w.Code)
+	}
+	if w := serveAdmin(admin, "DELETE", "/admin/cache/entries?pattern=blocks", "secret"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid pattern, got %d", w.Code)
+	}
+}
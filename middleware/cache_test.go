@@ -0,0 +1,194 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-api-proxy/cache"
+	"go-api-proxy/config"
+)
+
+// countingBackend serves a fixed response and counts the requests it receives
+type countingBackend struct {
+	calls      int
+	lastHeader http.Header
+	handle     func(w http.ResponseWriter, r *http.Request)
+}
+
+func (b *countingBackend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.calls++
+	b.lastHeader = r.Header.Clone()
+	b.handle(w, r)
+}
+
+func newCacheTestRouter(t *testing.T, backend http.Handler, ttl int) (*Router, *CacheHandler) {
+	t.Helper()
+
+	cacheHandler := NewCacheHandler(backend, cache.NewLRU(1<<20), 1024)
+	router, err := NewRouter([]config.Route{
+		{Name: "block", Pattern: "/api/v2/blocks/{id}", Handler: config.HandlerPassthrough, StripPrefix: "/api/v2", CacheTTL: ttl},
+		{Name: "api", Pattern: "/api/v2/*", Handler: config.HandlerPassthrough, StripPrefix: "/api/v2"},
+	}, map[string]http.Handler{
+		config.HandlerPassthrough: cacheHandler,
+		config.HandlerTokenFilter: backend,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create router: %v", err)
+	}
+	return router, cacheHandler
+}
+
+func serveCacheTest(router http.Handler, method, path string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestCacheHandler_HitAndMiss(t *testing.T) {
+	backend := &countingBackend{handle: func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"height":1}`))
+	}}
+	router, _ := newCacheTestRouter(t, backend, 60)
+
+	first := serveCacheTest(router, "GET", "/api/v2/blocks/1", nil)
+	second := serveCacheTest(router, "GET", "/api/v2/blocks/1", nil)
+
+	if backend.calls != 1 {
+		t.Errorf("Expected 1 backend call, got %d", backend.calls)
+	}
+	if first.Header().Get("X-Cache") != "MISS" || second.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("Expected MISS then HIT, got %s then %s", first.Header().Get("X-Cache"), second.Header().Get("X-Cache"))
+	}
+	if second.Body.String() != `{"height":1}` || second.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Unexpected cached response: %q %v", second.Body.String(), second.Header())
+	}
+
+	// Different query strings and uncached routes go to the backend
+	serveCacheTest(router, "GET", "/api/v2/blocks/1?type=full", nil)
+	serveCacheTest(router, "GET", "/api/v2/stats", nil)
+	serveCacheTest(router, "GET", "/api/v2/stats", nil)
+	if backend.calls != 4 {
+		t.Errorf("Expected 4 backend calls, got %d", backend.calls)
+	}
+}
+
+func TestCacheHandler_RespectsUpstreamCacheControl(t *testing.T) {
+	tests := []struct {
+		cacheControl  string
+		expectedCalls int
+	}{
+		{"no-store", 2},
+		{"private, max-age=60", 2},
+		{"max-age=0", 2},
+		{"public, max-age=30", 1},
+	}
+
+	for _, tt := range tests {
+		backend := &countingBackend{handle: func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cache-Control", tt.cacheControl)
+			w.Write([]byte("ok"))
+		}}
+		router, _ := newCacheTestRouter(t, backend, 60)
+
+		serveCacheTest(router, "GET", "/api/v2/blocks/1", nil)
+		serveCacheTest(router, "GET", "/api/v2/blocks/1", nil)
+		if backend.calls != tt.expectedCalls {
+			t.Errorf("Cache-Control %q: expected %d backend calls, got %d", tt.cacheControl, tt.expectedCalls, backend.calls)
+		}
+	}
+}
+
+func TestCacheHandler_ConditionalRequests(t *testing.T) {
+	backend := &countingBackend{handle: func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte("block"))
+	}}
+	router, cacheHandler := newCacheTestRouter(t, backend, 60)
+	now := time.Now()
+	cacheHandler.now = func() time.Time { return now }
+
+	// A client conditional on a miss is answered from the freshly cached response
+	first := serveCacheTest(router, "GET", "/api/v2/blocks/1", http.Header{"If-None-Match": {`"v1"`}})
+	if first.Code != http.StatusNotModified {
+		t.Errorf("Expected 304 for matching If-None-Match, got %d", first.Code)
+	}
+	if backend.lastHeader.Get("If-None-Match") != "" {
+		t.Error("Expected client conditional not to be forwarded on a miss")
+	}
+
+	// Once stale, the entry is revalidated with the backend
+	now = now.Add(61 * time.Second)
+	second := serveCacheTest(router, "GET", "/api/v2/blocks/1", nil)
+	if second.Code != http.StatusOK || second.Body.String() != "block" {
+		t.Errorf("Expected revalidated 200 with cached body, got %d %q", second.Code, second.Body.String())
+	}
+	if second.Header().Get("X-Cache") != "REVALIDATED" {
+		t.Errorf("Expected X-Cache REVALIDATED, got %s", second.Header().Get("X-Cache"))
+	}
+	if backend.lastHeader.Get("If-None-Match") != `"v1"` {
+		t.Errorf("Expected revalidation with If-None-Match, got %q", backend.lastHeader.Get("If-None-Match"))
+	}
+
+	third := serveCacheTest(router, "GET", "/api/v2/blocks/1", nil)
+	if third.Header().Get("X-Cache") != "HIT" || backend.calls != 2 {
+		t.Errorf("Expected refreshed entry to be served from cache, got %s after %d calls", third.Header().Get("X-Cache"), backend.calls)
+	}
+}
+
+func TestCacheHandler_Vary(t *testing.T) {
+	backend := &countingBackend{handle: func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Vary", "Accept-Language")
+		w.Write([]byte(r.Header.Get("Accept-Language")))
+	}}
+	router, _ := newCacheTestRouter(t, backend, 60)
+
+	english := http.Header{"Accept-Language": {"en"}}
+	german := http.Header{"Accept-Language": {"de"}}
+
+	serveCacheTest(router, "GET", "/api/v2/blocks/1", english)
+	serveCacheTest(router, "GET", "/api/v2/blocks/1", german)
+	en := serveCacheTest(router, "GET", "/api/v2/blocks/1", english)
+	de := serveCacheTest(router, "GET", "/api/v2/blocks/1", german)
+
+	if backend.calls != 2 {
+		t.Errorf("Expected one backend call per variant, got %d", backend.calls)
+	}
+	if en.Body.String() != "en" || de.Body.String() != "de" {
+		t.Errorf("Expected per-variant bodies, got %q and %q", en.Body.String(), de.Body.String())
+	}
+}
+
+func TestCacheHandler_SkipsLargeAndErrorResponses(t *testing.T) {
+	status := http.StatusOK
+	body := make([]byte, 2048)
+	backend := &countingBackend{handle: func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write(body)
+	}}
+	router, _ := newCacheTestRouter(t, backend, 60)
+
+	first := serveCacheTest(router, "GET", "/api/v2/blocks/1", nil)
+	serveCacheTest(router, "GET", "/api/v2/blocks/1", nil)
+	if first.Body.Len() != len(body) || backend.calls != 2 {
+		t.Errorf("Expected oversized response to be passed through uncached, got %d bytes after %d calls", first.Body.Len(), backend.calls)
+	}
+
+	status = http.StatusBadGateway
+	body = []byte("error")
+	serveCacheTest(router, "GET", "/api/v2/blocks/2", nil)
+	serveCacheTest(router, "GET", "/api/v2/blocks/2", nil)
+	if backend.calls != 4 {
+		t.Errorf("Expected error responses not to be cached, got %d calls", backend.calls)
+	}
+}
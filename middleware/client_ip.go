@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"net/http"
+
+	"go-api-proxy/client"
+)
+
+// ClientIPHandler resolves the client IP address once per request, honoring
+// forwarding headers only from trusted proxies, and stores it in the request context
+// where client.GetClientIP finds it
+type ClientIPHandler struct {
+	next     http.Handler
+	resolver *client.IPResolver
+}
+
+// NewClientIPHandler creates the client IP handler
+func NewClientIPHandler(next http.Handler, resolver *client.IPResolver) *ClientIPHandler {
+	return &ClientIPHandler{
+		next:     next,
+		resolver: resolver,
+	}
+}
+
+// ServeHTTP implements the http.Handler interface for client IP resolution
+func (h *ClientIPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.next.ServeHTTP(w, h.resolver.WithClientIP(r))
+}
@@ -0,0 +1,276 @@
+package middleware
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// compressibleTypes lists the content types worth compressing
+var compressibleTypes = []string{
+	"application/json",
+	"application/javascript",
+	"application/xml",
+	"image/svg+xml",
+	"text/",
+}
+
+var gzipWriterPool = sync.Pool{
+	New: func() interface{} {
+		return gzip.NewWriter(nil)
+	},
+}
+
+// CompressionHandler gzips responses for clients that accept it once the body
+// reaches a minimum size. Responses that already carry a Content-Encoding, such as
+// gzip bodies passed through from the backend, are left as they are.
+type CompressionHandler struct {
+	next     http.Handler
+	minBytes int
+}
+
+// NewCompressionHandler creates a compression middleware that only compresses
+// bodies of at least minBytes
+func NewCompressionHandler(next http.Handler, minBytes int) *CompressionHandler {
+	return &CompressionHandler{
+		next:     next,
+		minBytes: minBytes,
+	}
+}
+
+// ServeHTTP implements the http.Handler interface for response compression
+func (h *CompressionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodHead || isUpgradeRequest(r) {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	cw := &compressResponseWriter{
+		ResponseWriter: w,
+		minBytes:       h.minBytes,
+		acceptsGzip:    AcceptsGzip(r),
+	}
+	defer cw.Close()
+	h.next.ServeHTTP(cw, r)
+}
+
+// AcceptsGzip reports whether the request's Accept-Encoding allows a gzip response
+func AcceptsGzip(r *http.Request) bool {
+	gzipQ, wildcardQ := -1.0, -1.0
+	for _, value := range r.Header.Values("Accept-Encoding") {
+		for _, part := range strings.Split(value, ",") {
+			coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+			q := 1.0
+			if name, value, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.EqualFold(strings.TrimSpace(name), "q") {
+				if parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+					q = parsed
+				}
+			}
+			switch strings.ToLower(strings.TrimSpace(coding)) {
+			case "gzip", "x-gzip":
+				gzipQ = q
+			case "*":
+				wildcardQ = q
+			}
+		}
+	}
+	if gzipQ >= 0 {
+		return gzipQ > 0
+	}
+	return wildcardQ > 0
+}
+
+// addVary appends a header name to Vary unless it is already listed
+func addVary(header http.Header, name string) {
+	for _, value := range header.Values("Vary") {
+		for _, existing := range strings.Split(value, ",") {
+			existing = strings.TrimSpace(existing)
+			if existing == "*" || strings.EqualFold(existing, name) {
+				return
+			}
+		}
+	}
+	header.Add("Vary", name)
+}
+
+// isUpgradeRequest reports whether the request asks to switch protocols
+func isUpgradeRequest(r *http.Request) bool {
+	for _, value := range r.Header.Values("Connection") {
+		for _, token := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isCompressible reports whether a response with these headers and status may be gzipped
+func isCompressible(status int, header http.Header) bool {
+	if status < http.StatusOK || status == http.StatusNoContent || status == http.StatusNotModified || status == http.StatusPartialContent {
+		return false
+	}
+	if header.Get("Content-Encoding") != "" || header.Get("Content-Range") != "" {
+		return false
+	}
+	if strings.Contains(strings.ToLower(header.Get("Cache-Control")), "no-transform") {
+		return false
+	}
+
+	contentType := strings.ToLower(header.Get("Content-Type"))
+	for _, prefix := range compressibleTypes {
+		if strings.HasPrefix(contentType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// compressResponseWriter buffers the start of a compressible response until it
+// knows whether the body reaches the size threshold, then either gzips the rest
+// or writes the buffered bytes unchanged with an accurate Content-Length
+type compressResponseWriter struct {
+	http.ResponseWriter
+	minBytes    int
+	acceptsGzip bool
+
+	status      int
+	wroteHeader bool
+	decided     bool
+	buf         []byte
+	gz          *gzip.Writer
+}
+
+// WriteHeader records the status and decides immediately for responses that are never compressed
+func (w *compressResponseWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	if status >= 100 && status < 200 && status != http.StatusSwitchingProtocols {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	w.wroteHeader = true
+	w.status = status
+
+	if !isCompressible(status, w.Header()) {
+		w.passThrough()
+		return
+	}
+
+	// The representation depends on Accept-Encoding whether or not this one is compressed
+	addVary(w.Header(), "Accept-Encoding")
+	if !w.acceptsGzip {
+		w.passThrough()
+	}
+}
+
+// Write buffers the body until the threshold is reached
+func (w *compressResponseWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", http.DetectContentType(p))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.decided {
+		if w.gz != nil {
+			return w.gz.Write(p)
+		}
+		return w.ResponseWriter.Write(p)
+	}
+
+	w.buf = append(w.buf, p...)
+	if len(w.buf) >= w.minBytes {
+		if err := w.startGzip(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush compresses whatever has been buffered so streamed responses are not held back
+func (w *compressResponseWriter) Flush() {
+	if w.wroteHeader && !w.decided {
+		w.startGzip()
+	}
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack exposes the underlying connection when no body has been written yet
+func (w *compressResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok || w.wroteHeader {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	w.decided = true
+	return hijacker.Hijack()
+}
+
+// Unwrap returns the underlying response writer for http.ResponseController
+func (w *compressResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Close writes out a body that stayed below the threshold, or finishes the gzip stream
+func (w *compressResponseWriter) Close() error {
+	if !w.wroteHeader {
+		return nil
+	}
+	if !w.decided {
+		w.Header().Set("Content-Length", strconv.Itoa(len(w.buf)))
+		w.passThrough()
+		return nil
+	}
+	if w.gz == nil {
+		return nil
+	}
+
+	err := w.gz.Close()
+	w.gz.Reset(nil)
+	gzipWriterPool.Put(w.gz)
+	w.gz = nil
+	return err
+}
+
+// passThrough writes the headers and any buffered bytes without compression
+func (w *compressResponseWriter) passThrough() {
+	w.decided = true
+	w.ResponseWriter.WriteHeader(w.status)
+	if len(w.buf) > 0 {
+		w.ResponseWriter.Write(w.buf)
+		w.buf = nil
+	}
+}
+
+// startGzip switches the response to gzip and compresses the buffered bytes
+func (w *compressResponseWriter) startGzip() error {
+	w.decided = true
+	header := w.Header()
+	header.Set("Content-Encoding", "gzip")
+	header.Del("Content-Length")
+	if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		// The compressed bytes differ from the identity representation
+		header.Set("ETag", "W/"+etag)
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+
+	w.gz = gzipWriterPool.Get().(*gzip.Writer)
+	w.gz.Reset(w.ResponseWriter)
+	if len(w.buf) > 0 {
+		_, err := w.gz.Write(w.buf)
+		w.buf = nil
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func gunzip(t *testing.T, body []byte) string {
+	t.Helper()
+
+	reader, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("Expected a gzip body: %v", err)
+	}
+	decoded, _ := io.ReadAll(reader)
+	return string(decoded)
+}
+
+func serveCompressed(handler http.Handler, acceptEncoding string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/api/v2/blocks", nil)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+func jsonHandler(body string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.Write([]byte(body))
+	})
+}
+
+func TestCompressionHandler_CompressesLargeBodies(t *testing.T) {
+	body := `{"items":[` + strings.Repeat(`{"height":1},`, 200) + `{}]}`
+	w := serveCompressed(NewCompressionHandler(jsonHandler(body), 1024), "br, gzip")
+
+	if w.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Expected gzip response, got headers %v", w.Header())
+	}
+	if w.Header().Get("Content-Length") != "" {
+		t.Error("Expected Content-Length of the identity body to be removed")
+	}
+	if w.Header().Get("Vary") != "Accept-Encoding" {
+		t.Errorf("Expected Vary: Accept-Encoding, got %q", w.Header().Get("Vary"))
+	}
+	if gunzip(t, w.Body.Bytes()) != body {
+		t.Error("Expected decoded body to match the original")
+	}
+}
+
+func TestCompressionHandler_SkipsSmallBodies(t *testing.T) {
+	w := serveCompressed(NewCompressionHandler(jsonHandler(`{"ok":true}`), 1024), "gzip")
+
+	if w.Header().Get("Content-Encoding") != "" {
+		t.Error("Expected small body to be sent uncompressed")
+	}
+	if w.Header().Get("Content-Length") != "11" || w.Body.String() != `{"ok":true}` {
+		t.Errorf("Expected exact body and Content-Length, got %q (%s)", w.Body.String(), w.Header().Get("Content-Length"))
+	}
+	if w.Header().Get("Vary") != "Accept-Encoding" {
+		t.Error("Expected Vary: Accept-Encoding on compressible responses")
+	}
+}
+
+func TestCompressionHandler_RespectsAcceptEncoding(t *testing.T) {
+	body := strings.Repeat("a", 2048)
+	handler := NewCompressionHandler(jsonHandler(body), 1024)
+
+	for _, accept := range []string{"", "identity", "gzip;q=0", "br, *;q=0"} {
+		w := serveCompressed(handler, accept)
+		if w.Header().Get("Content-Encoding") != "" || w.Body.String() != body {
+			t.Errorf("Expected identity response for Accept-Encoding %q", accept)
+		}
+	}
+	if w := serveCompressed(handler, "*"); w.Header().Get("Content-Encoding") != "gzip" {
+		t.Error("Expected wildcard to allow gzip")
+	}
+}
+
+func TestCompressionHandler_LeavesEncodedAndUncompressibleResponses(t *testing.T) {
+	encoded := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write([]byte(strings.Repeat("x", 2048)))
+	})
+	if w := serveCompressed(NewCompressionHandler(encoded, 0), "gzip"); w.Body.Len() != 2048 {
+		t.Error("Expected an already encoded body to pass through untouched")
+	}
+
+	image := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(make([]byte, 2048))
+	})
+	if w := serveCompressed(NewCompressionHandler(image, 0), "gzip"); w.Header().Get("Content-Encoding") != "" || w.Header().Get("Vary") != "" {
+		t.Error("Expected binary content to be left alone")
+	}
+
+	notModified := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotModified)
+	})
+	if w := serveCompressed(NewCompressionHandler(notModified, 0), "gzip"); w.Code != http.StatusNotModified || w.Header().Get("Content-Encoding") != "" {
+		t.Error("Expected 304 responses to be left alone")
+	}
+}
+
+func TestCompressionHandler_StreamsOnFlush(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("first "))
+		w.(http.Flusher).Flush()
+		w.Write([]byte("second"))
+	})
+	w := serveCompressed(NewCompressionHandler(handler, 1024), "gzip")
+
+	if !w.Flushed || w.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatal("Expected flushed response to be compressed")
+	}
+	if gunzip(t, w.Body.Bytes()) != "first second" {
+		t.Error("Expected streamed body to decode completely")
+	}
+}
@@ -103,6 +103,13 @@ func (c *CORSHandler) setCORSHeaders(w http.ResponseWriter, r *http.Request) {
 		"Cache-Control",
 		"Etag",
 		"Last-Modified",
+		"X-Upstream",
+		"X-Cache",
+		"Age",
+		"Retry-After",
+		"RateLimit-Limit",
+		"RateLimit-Remaining",
+		"RateLimit-Reset",
 	}
 	w.Header().Set("Access-Control-Expose-Headers", strings.Join(exposedHeaders, ", "))
 	
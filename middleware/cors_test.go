@@ -120,14 +120,18 @@ func TestCORSHandler_ServeHTTP(t *testing.T) {
 }
 
 func TestCORSHandler_NewCORSHandler(t *testing.T) {
-	nextHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	nextCalled := false
+	nextHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nextCalled = true
+	})
 	corsHandler := NewCORSHandler(nextHandler)
 
 	if corsHandler == nil {
-		t.Error("NewCORSHandler should not return nil")
+		t.Fatal("NewCORSHandler should not return nil")
 	}
 
-	if corsHandler.next != nextHandler {
+	corsHandler.next.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if !nextCalled {
 		t.Error("NewCORSHandler should set the next handler correctly")
 	}
 }
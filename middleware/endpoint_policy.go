@@ -0,0 +1,117 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"go-api-proxy/config"
+	"go-api-proxy/logger"
+)
+
+// EndpointPolicyHandler only lets passthrough requests through to the backend when
+// their path and method are on the endpoint allowlist. Paths that are not listed get
+// a 404 and listed paths with other methods a 405, both answered by the proxy.
+type EndpointPolicyHandler struct {
+	next      http.Handler
+	endpoints []*compiledEndpoint
+
+	notFound         int64
+	methodNotAllowed int64
+}
+
+// compiledEndpoint is an allowlist entry with its pattern split into segments
+type compiledEndpoint struct {
+	rule    config.EndpointRule
+	route   *compiledRoute
+	methods []string
+}
+
+// EndpointPolicyStats counts the requests blocked by the endpoint policy
+type EndpointPolicyStats struct {
+	Endpoints        int   `json:"endpoints"`
+	NotFound         int64 `json:"blocked_not_found"`
+	MethodNotAllowed int64 `json:"blocked_method_not_allowed"`
+}
+
+// NewEndpointPolicyHandler creates a policy handler allowing the given endpoints
+func NewEndpointPolicyHandler(next http.Handler, endpoints []config.EndpointRule) *EndpointPolicyHandler {
+	h := &EndpointPolicyHandler{next: next}
+	for _, rule := range endpoints {
+		h.endpoints = append(h.endpoints, &compiledEndpoint{
+			rule:    rule,
+			route:   compileRoute(config.Route{Name: rule.Name, Pattern: rule.Pattern}),
+			methods: rule.AllowedMethods(),
+		})
+	}
+	return h
+}
+
+// ServeHTTP implements the http.Handler interface for the endpoint policy
+func (h *EndpointPolicyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	requestID := getRequestIDFromContext(r.Context())
+	policyLogger := logger.MiddlewareLogger.WithRequestID(requestID)
+
+	// Match the path the client requested, before any route prefix rewriting
+	path := r.URL.Path
+	if match := GetRouteMatchFromContext(r.Context()); match != nil {
+		path = match.Path
+	}
+
+	matched, allowed := h.check(path, r.Method)
+	if !matched {
+		atomic.AddInt64(&h.notFound, 1)
+		policyLogger.Warn("Blocked request to endpoint outside the allowlist", map[string]interface{}{
+			"method": r.Method,
+			"path":   path,
+		})
+		writeJSONError(w, http.StatusNotFound, "Not found", "No endpoint matches "+path)
+		return
+	}
+	if !containsMethod(allowed, r.Method) {
+		atomic.AddInt64(&h.methodNotAllowed, 1)
+		policyLogger.Warn("Blocked request with method outside the allowlist", map[string]interface{}{
+			"method":  r.Method,
+			"path":    path,
+			"allowed": allowed,
+		})
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method not allowed", r.Method+" is not allowed for "+path)
+		return
+	}
+
+	h.next.ServeHTTP(w, r)
+}
+
+// check reports whether any endpoint matches the path and the methods allowed for it.
+// When several endpoints match, their methods are combined.
+func (h *EndpointPolicyHandler) check(path, method string) (bool, []string) {
+	segments := splitPath(normalizePath(path))
+
+	matched := false
+	var allowed []string
+	for _, endpoint := range h.endpoints {
+		if _, ok := endpoint.route.match(segments); !ok {
+			continue
+		}
+		matched = true
+		if containsMethod(endpoint.methods, method) {
+			return true, endpoint.methods
+		}
+		for _, m := range endpoint.methods {
+			if !containsMethod(allowed, m) {
+				allowed = append(allowed, m)
+			}
+		}
+	}
+	return matched, allowed
+}
+
+// Stats returns the number of requests blocked by the policy
+func (h *EndpointPolicyHandler) Stats() EndpointPolicyStats {
+	return EndpointPolicyStats{
+		Endpoints:        len(h.endpoints),
+		NotFound:         atomic.LoadInt64(&h.notFound),
+		MethodNotAllowed: atomic.LoadInt64(&h.methodNotAllowed),
+	}
+}
@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-api-proxy/config"
+	"go-api-proxy/models"
+)
+
+func TestEndpointPolicyHandler(t *testing.T) {
+	backend := &recordingHandler{name: "passthrough"}
+	policy := NewEndpointPolicyHandler(backend, []config.EndpointRule{
+		{Pattern: "/api/v2/*"},
+		{Pattern: "/api/v2/smart-contracts/{hash}/query-read-method", Methods: []string{"post"}},
+		{Pattern: "/api/eth-rpc", Methods: []string{"POST"}},
+	})
+	router, err := NewRouter([]config.Route{
+		{Name: "api", Pattern: "/api/v2/*", Handler: config.HandlerPassthrough, StripPrefix: "/api/v2"},
+		{Name: "default", Pattern: "/*", Handler: config.HandlerPassthrough},
+	}, map[string]http.Handler{config.HandlerPassthrough: policy})
+	if err != nil {
+		t.Fatalf("Failed to create router: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		method        string
+		path          string
+		expected      int
+		expectedAllow string
+	}{
+		{"GET on allowed prefix", http.MethodGet, "/api/v2/blocks/1", http.StatusOK, ""},
+		{"HEAD allowed by default", http.MethodHead, "/api/v2/blocks", http.StatusOK, ""},
+		{"POST on read-only prefix", http.MethodPost, "/api/v2/blocks", http.StatusMethodNotAllowed, "GET, HEAD"},
+		{"POST on allowed endpoint", http.MethodPost, "/api/v2/smart-contracts/0xabc/query-read-method", http.StatusOK, ""},
+		{"GET on endpoint matching two rules", http.MethodGet, "/api/v2/smart-contracts/0xabc/query-read-method", http.StatusOK, ""},
+		{"DELETE on endpoint matching two rules", http.MethodDelete, "/api/v2/smart-contracts/0xabc/query-read-method", http.StatusMethodNotAllowed, "GET, HEAD, POST"},
+		{"account API", http.MethodGet, "/api/account/v2/user/info", http.StatusNotFound, ""},
+		{"admin path", http.MethodPost, "/admin/api/v1/tokens", http.StatusNotFound, ""},
+		{"JSON-RPC", http.MethodPost, "/api/eth-rpc", http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend.request = nil
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expected {
+				t.Fatalf("Expected status %d, got %d", tt.expected, w.Code)
+			}
+			if tt.expected == http.StatusOK {
+				if backend.request == nil {
+					t.Fatal("Expected the request to reach the backend")
+				}
+				return
+			}
+			if backend.request != nil {
+				t.Error("Expected the request to be blocked before the backend")
+			}
+			if allow := w.Header().Get("Allow"); allow != tt.expectedAllow {
+				t.Errorf("Expected Allow %q, got %q", tt.expectedAllow, allow)
+			}
+			var body models.ErrorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.Message == "" {
+				t.Errorf("Expected a JSON error, got %q", w.Body.String())
+			}
+		})
+	}
+
+	stats := policy.Stats()
+	if stats.Endpoints != 3 || stats.NotFound != 2 || stats.MethodNotAllowed != 2 {
+		t.Errorf("Unexpected policy stats %+v", stats)
+	}
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"net/http"
+
+	"go-api-proxy/client"
+)
+
+// headerRulesWriter adds a route's set and append response headers just before the
+// response is written, so they also apply to cached and locally generated responses
+type headerRulesWriter struct {
+	http.ResponseWriter
+	policy  *client.HeaderPolicy
+	req     *http.Request
+	applied bool
+}
+
+// apply adds the route's headers once
+func (w *headerRulesWriter) apply() {
+	if w.applied {
+		return
+	}
+	w.applied = true
+	w.policy.Apply(w.Header(), w.req)
+}
+
+// WriteHeader adds the route's headers before the final status is written
+func (w *headerRulesWriter) WriteHeader(status int) {
+	if status >= 200 || status == http.StatusSwitchingProtocols {
+		w.apply()
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write adds the route's headers when the body is written without a status
+func (w *headerRulesWriter) Write(p []byte) (int, error) {
+	w.apply()
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush passes flushes through to the underlying writer, which commits the headers
+func (w *headerRulesWriter) Flush() {
+	w.apply()
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap returns the underlying response writer for http.ResponseController
+func (w *headerRulesWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
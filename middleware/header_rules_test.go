@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-api-proxy/config"
+)
+
+func TestRouter_ResponseHeaderRules(t *testing.T) {
+	backend := &MockProxyClient{}
+	router, err := NewRouter([]config.Route{
+		{
+			Name:    "api",
+			Pattern: "/api/v2/*",
+			Handler: config.HandlerPassthrough,
+			ResponseHeaders: &config.HeaderRules{
+				Allow:  []string{"Set-Cookie"},
+				Deny:   []string{"X-Debug-*"},
+				Set:    map[string]string{"X-Request-ID": "{request_id}"},
+				Append: map[string]string{"Cache-Control": "no-transform"},
+			},
+		},
+		{
+			Name:            "ping",
+			Pattern:         "/ping",
+			Handler:         config.HandlerStatic,
+			Static:          &config.StaticResponse{Body: "pong"},
+			ResponseHeaders: &config.HeaderRules{Set: map[string]string{"X-Route": "{route}"}},
+		},
+		{Name: "default", Pattern: "/*", Handler: config.HandlerPassthrough},
+	}, map[string]http.Handler{config.HandlerPassthrough: NewStandardProxyHandler(backend)})
+	if err != nil {
+		t.Fatalf("Failed to create router: %v", err)
+	}
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		backend.response = &http.Response{
+			StatusCode: http.StatusOK,
+			Header: http.Header{
+				"Content-Type":  {"application/json"},
+				"Cache-Control": {"max-age=5"},
+				"Server":        {"Cowboy"},
+				"Set-Cookie":    {"session=abc"},
+				"X-Powered-By":  {"Phoenix"},
+				"X-Debug-Node":  {"node-3"},
+			},
+			Body: io.NopCloser(strings.NewReader(`{}`)),
+		}
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req = req.WithContext(context.WithValue(req.Context(), "request_id", "req-42"))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve("/api/v2/blocks")
+	if w.Header().Get("Server") != "" || w.Header().Get("X-Powered-By") != "" || w.Header().Get("X-Debug-Node") != "" {
+		t.Errorf("Expected backend details to be stripped, got %v", w.Header())
+	}
+	if w.Header().Get("Set-Cookie") != "session=abc" {
+		t.Error("Expected the allowed Set-Cookie header to pass")
+	}
+	if w.Header().Get("X-Request-Id") != "req-42" {
+		t.Errorf("Expected templated X-Request-ID, got %q", w.Header().Get("X-Request-Id"))
+	}
+	if values := w.Header().Values("Cache-Control"); len(values) != 2 || values[1] != "no-transform" {
+		t.Errorf("Expected Cache-Control to be appended, got %v", values)
+	}
+
+	w = serve("/other")
+	if w.Header().Get("Server") != "" || w.Header().Get("Set-Cookie") != "" {
+		t.Errorf("Expected Server and Set-Cookie to be stripped by default, got %v", w.Header())
+	}
+	if w.Header().Get("X-Debug-Node") != "node-3" || w.Header().Get("X-Request-Id") != "" {
+		t.Errorf("Expected routes without rules to keep other headers, got %v", w.Header())
+	}
+
+	w = serve("/ping")
+	if w.Body.String() != "pong" || w.Header().Get("X-Route") != "ping" {
+		t.Errorf("Expected static routes to get their headers, got %v", w.Header())
+	}
+}
+
+func TestNewRouter_InvalidHeaderTemplate(t *testing.T) {
+	_, err := NewRouter([]config.Route{{
+		Name:           "api",
+		Pattern:        "/*",
+		Handler:        config.HandlerPassthrough,
+		RequestHeaders: &config.HeaderRules{Set: map[string]string{"X-Test": "{secret}"}},
+	}}, map[string]http.Handler{config.HandlerPassthrough: &recordingHandler{}})
+	if err == nil || !strings.Contains(err.Error(), "unknown placeholder {secret}") {
+		t.Errorf("Expected an unknown placeholder error, got %v", err)
+	}
+}
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+
+	"go-api-proxy/logger"
+)
+
+// HTTPSRedirectHandler redirects plain HTTP requests to the same URL on the TLS listener
+type HTTPSRedirectHandler struct {
+	httpsPort string
+}
+
+// NewHTTPSRedirectHandler creates a redirect handler for a TLS listener on httpsPort
+func NewHTTPSRedirectHandler(httpsPort string) *HTTPSRedirectHandler {
+	return &HTTPSRedirectHandler{
+		httpsPort: httpsPort,
+	}
+}
+
+// ServeHTTP implements the http.Handler interface for the HTTPS redirect
+func (h *HTTPSRedirectHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	host := r.Host
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+	if host == "" {
+		http.Error(w, "Bad Request: missing Host header", http.StatusBadRequest)
+		return
+	}
+	if h.httpsPort != "" && h.httpsPort != "443" {
+		host = net.JoinHostPort(host, h.httpsPort)
+	}
+	target := "https://" + host + r.URL.RequestURI()
+
+	logger.MiddlewareLogger.Debug("Redirecting to HTTPS", map[string]interface{}{
+		"method": r.Method,
+		"target": target,
+	})
+
+	// 301 is only safe for GET and HEAD; 308 makes clients repeat the method and body
+	status := http.StatusPermanentRedirect
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		status = http.StatusMovedPermanently
+	}
+	http.Redirect(w, r, target, status)
+}
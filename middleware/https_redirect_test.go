@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPSRedirectHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		port     string
+		method   string
+		host     string
+		target   string
+		status   int
+		location string
+	}{
+		{"standard port", "443", "GET", "explorer.example.com", "/api/v2/tokens?limit=5", http.StatusMovedPermanently, "https://explorer.example.com/api/v2/tokens?limit=5"},
+		{"custom port replaces plain port", "8443", "GET", "explorer.example.com:8080", "/health", http.StatusMovedPermanently, "https://explorer.example.com:8443/health"},
+		{"non-GET keeps the method", "443", "POST", "explorer.example.com", "/api/v2/graphql", http.StatusPermanentRedirect, "https://explorer.example.com/api/v2/graphql"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, nil)
+			req.Host = tt.host
+			w := httptest.NewRecorder()
+			NewHTTPSRedirectHandler(tt.port).ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Errorf("Expected status %d, got %d", tt.status, w.Code)
+			}
+			if location := w.Header().Get("Location"); location != tt.location {
+				t.Errorf("Expected Location %q, got %q", tt.location, location)
+			}
+		})
+	}
+}
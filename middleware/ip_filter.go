@@ -0,0 +1,180 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/netip"
+	"sync"
+
+	"go-api-proxy/client"
+	"go-api-proxy/config"
+	"go-api-proxy/filewatch"
+	"go-api-proxy/logger"
+)
+
+// ipFilterRule is a rule with its pattern and address ranges parsed
+type ipFilterRule struct {
+	rule     config.IPFilterRule
+	compiled *compiledRoute
+	allow    []netip.Prefix
+	deny     []netip.Prefix
+}
+
+// IPFilterHandler blocks clients by IP address according to rules for path patterns.
+// Every rule matching the path applies, in file order. The rules file is reloaded
+// when it changes; a file that fails to load keeps the current rules.
+type IPFilterHandler struct {
+	next    http.Handler
+	file    string
+	watcher *filewatch.Watcher
+
+	mu      sync.RWMutex
+	rules   []ipFilterRule
+	version filewatch.Version
+}
+
+// NewIPFilterHandler loads the IP filter file. It returns nil when no file is configured.
+func NewIPFilterHandler(next http.Handler, cfg *config.Config) (*IPFilterHandler, error) {
+	if cfg.IPFilterFile == "" {
+		return nil, nil
+	}
+
+	h := &IPFilterHandler{
+		next: next,
+		file: cfg.IPFilterFile,
+	}
+	if err := h.Reload(); err != nil {
+		return nil, err
+	}
+	h.watcher = filewatch.New(cfg.IPFilterReloadInterval, filewatch.Source{
+		Changed: h.changed,
+		Reload:  h.Reload,
+		Logger:  logger.MiddlewareLogger,
+		Failure: "Failed to reload IP filter rules, keeping the current rules",
+		Fields:  map[string]interface{}{"file": h.file},
+	})
+	return h, nil
+}
+
+// ServeHTTP implements the http.Handler interface for IP filtering
+func (h *IPFilterHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	clientIP := client.GetClientIP(r)
+	if rule, reason, blocked := h.check(r.URL.Path, clientIP); blocked {
+		logger.MiddlewareLogger.Warn("Blocked request by IP filter", map[string]interface{}{
+			"client_ip": clientIP,
+			"path":      r.URL.Path,
+			"method":    r.Method,
+			"rule":      rule.Name,
+			"pattern":   rule.Pattern,
+			"reason":    reason,
+		})
+		writeJSONError(w, http.StatusForbidden, "Forbidden", "Access to "+r.URL.Path+" is not allowed from "+clientIP)
+		return
+	}
+	h.next.ServeHTTP(w, r)
+}
+
+// check returns the first rule that blocks the client from the path and why
+func (h *IPFilterHandler) check(path, clientIP string) (config.IPFilterRule, string, bool) {
+	addr, err := netip.ParseAddr(clientIP)
+	valid := err == nil
+	segments := splitPath(normalizePath(path))
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for _, rule := range h.rules {
+		if _, ok := rule.compiled.match(segments); !ok {
+			continue
+		}
+		if !valid {
+			// An unknown address cannot be shown to be allowed or not denied
+			return rule.rule, "unknown client address", true
+		}
+		for _, prefix := range rule.deny {
+			if prefix.Contains(addr) {
+				return rule.rule, "deny " + prefix.String(), true
+			}
+		}
+		if len(rule.allow) > 0 && !containsAddr(rule.allow, addr) {
+			return rule.rule, "not in allow list", true
+		}
+	}
+	return config.IPFilterRule{}, "", false
+}
+
+// Reload reads and compiles the rules file, keeping the current rules on failure
+func (h *IPFilterHandler) Reload() error {
+	version, err := filewatch.Stat(h.file)
+	if err != nil {
+		return err
+	}
+	loaded, err := config.LoadIPFilterRules(h.file)
+	if err != nil {
+		return err
+	}
+
+	rules := make([]ipFilterRule, 0, len(loaded))
+	for _, rule := range loaded {
+		compiled := ipFilterRule{rule: rule, compiled: compileRoute(config.Route{Name: rule.Name, Pattern: rule.Pattern})}
+		for _, entry := range rule.Allow {
+			prefix, err := config.ParseIPPrefix(entry)
+			if err != nil {
+				return fmt.Errorf("IP filter rule %q: %w", rule.Name, err)
+			}
+			compiled.allow = append(compiled.allow, prefix)
+		}
+		for _, entry := range rule.Deny {
+			prefix, err := config.ParseIPPrefix(entry)
+			if err != nil {
+				return fmt.Errorf("IP filter rule %q: %w", rule.Name, err)
+			}
+			compiled.deny = append(compiled.deny, prefix)
+		}
+		rules = append(rules, compiled)
+	}
+
+	h.mu.Lock()
+	h.rules = rules
+	h.version = version
+	h.mu.Unlock()
+	return nil
+}
+
+// Start watches the rules file and reloads it when it changes
+func (h *IPFilterHandler) Start() {
+	if h == nil {
+		return
+	}
+	h.watcher.Start()
+}
+
+// Stop stops watching the rules file
+func (h *IPFilterHandler) Stop() {
+	if h == nil {
+		return
+	}
+	h.watcher.Stop()
+}
+
+// changed reports whether the rules file differs from the loaded version
+func (h *IPFilterHandler) changed() bool {
+	version, err := filewatch.Stat(h.file)
+	if err != nil {
+		return false
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return version != h.version
+}
+
+// containsAddr reports whether any of the ranges contains the address
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,129 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go-api-proxy/config"
+	"go-api-proxy/models"
+)
+
+const testIPFilterRules = `{"rules": [
+	{"name": "admin", "pattern": "/admin/*", "allow": ["10.0.0.0/8", "2001:db8:1::/48"]},
+	{"name": "exports", "pattern": "/api/v2/addresses/{hash}/csv", "allow": ["10.0.0.0/8"], "deny": ["10.6.6.0/24"]},
+	{"name": "abuse", "pattern": "/*", "deny": ["203.0.113.0/24"]}
+]}`
+
+func newTestIPFilter(t *testing.T, rules string) (*IPFilterHandler, string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "ip-filter.json")
+	if err := os.WriteFile(path, []byte(rules), 0644); err != nil {
+		t.Fatalf("Failed to write IP filter file: %v", err)
+	}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	filter, err := NewIPFilterHandler(next, &config.Config{IPFilterFile: path})
+	if err != nil {
+		t.Fatalf("NewIPFilterHandler failed: %v", err)
+	}
+	return filter, path
+}
+
+func TestIPFilterHandler(t *testing.T) {
+	filter, _ := newTestIPFilter(t, testIPFilterRules)
+
+	tests := []struct {
+		name       string
+		path       string
+		remoteAddr string
+		expected   int
+	}{
+		{"admin from office", "/admin/cache", "10.1.2.3:4000", http.StatusOK},
+		{"admin from VPN over IPv6", "/admin/keys", "[2001:db8:1::5]:4000", http.StatusOK},
+		{"admin from outside", "/admin/cache", "198.51.100.1:4000", http.StatusForbidden},
+		{"export from office", "/api/v2/addresses/0xabc/csv", "10.1.2.3:4000", http.StatusOK},
+		{"export from denied range", "/api/v2/addresses/0xabc/csv", "10.6.6.6:4000", http.StatusForbidden},
+		{"export from outside", "/api/v2/addresses/0xabc/csv/", "198.51.100.1:4000", http.StatusForbidden},
+		{"public path from outside", "/api/v2/blocks", "198.51.100.1:4000", http.StatusOK},
+		{"public path from denied range", "/api/v2/blocks", "203.0.113.9:4000", http.StatusForbidden},
+		{"unknown client address", "/admin/cache", "garbage", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.RemoteAddr = tt.remoteAddr
+			w := httptest.NewRecorder()
+			filter.ServeHTTP(w, req)
+
+			if w.Code != tt.expected {
+				t.Fatalf("Expected status %d, got %d", tt.expected, w.Code)
+			}
+			if tt.expected == http.StatusForbidden {
+				var body models.ErrorResponse
+				if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.Error != "Forbidden" {
+					t.Errorf("Expected a JSON Forbidden error, got %q", w.Body.String())
+				}
+			}
+		})
+	}
+}
+
+func TestIPFilterHandler_Reload(t *testing.T) {
+	filter, path := newTestIPFilter(t, testIPFilterRules)
+
+	serve := func() int {
+		req := httptest.NewRequest(http.MethodGet, "/admin/cache", nil)
+		req.RemoteAddr = "198.51.100.1:4000"
+		w := httptest.NewRecorder()
+		filter.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := serve(); code != http.StatusForbidden {
+		t.Fatalf("Expected 403 before reload, got %d", code)
+	}
+
+	updated := `{"rules": [{"name": "admin", "pattern": "/admin/*", "allow": ["198.51.100.0/24"]}]}`
+	if err := os.WriteFile(path, []byte(updated), 0644); err != nil {
+		t.Fatalf("Failed to update IP filter file: %v", err)
+	}
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(path, future, future)
+	if !filter.changed() {
+		t.Fatal("Expected the rules file to be reported as changed")
+	}
+	if err := filter.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if code := serve(); code != http.StatusOK {
+		t.Fatalf("Expected 200 after reload, got %d", code)
+	}
+
+	// A broken file keeps the rules that were loaded last
+	if err := os.WriteFile(path, []byte(`{"rules": [{"name": "admin"}]}`), 0644); err != nil {
+		t.Fatalf("Failed to update IP filter file: %v", err)
+	}
+	if err := filter.Reload(); err == nil {
+		t.Fatal("Expected reload of an invalid file to fail")
+	}
+	if code := serve(); code != http.StatusOK {
+		t.Errorf("Expected the previous rules to stay in place, got %d", code)
+	}
+}
+
+func TestNewIPFilterHandler_Disabled(t *testing.T) {
+	filter, err := NewIPFilterHandler(http.NotFoundHandler(), &config.Config{})
+	if err != nil || filter != nil {
+		t.Fatalf("Expected no filter without a rules file, got %v, %v", filter, err)
+	}
+	filter.Start()
+	filter.Stop()
+}
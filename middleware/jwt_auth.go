@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"go-api-proxy/config"
+	"go-api-proxy/jwtauth"
+	"go-api-proxy/logger"
+)
+
+// JWTHandler authenticates bearer JWTs and restricts routes with roles to tokens
+// carrying one of those roles. Routes without roles stay public; a token sent to
+// them must still be valid.
+type JWTHandler struct {
+	next       http.Handler
+	verifier   *jwtauth.Verifier
+	router     *Router
+	rolesClaim string
+}
+
+// NewJWTHandler creates the JWT handler
+func NewJWTHandler(next http.Handler, verifier *jwtauth.Verifier, router *Router, cfg *config.Config) *JWTHandler {
+	return &JWTHandler{
+		next:       next,
+		verifier:   verifier,
+		router:     router,
+		rolesClaim: cfg.JWTRolesClaim,
+	}
+}
+
+// ServeHTTP implements the http.Handler interface for JWT authentication
+func (h *JWTHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var required []string
+	var routeName string
+	if match, ok := h.router.Match(r.URL.Path); ok {
+		required = match.Route.Roles
+		routeName = match.Route.Name
+	}
+
+	fields := map[string]interface{}{
+		"path":        r.URL.Path,
+		"route":       routeName,
+		"remote_addr": r.RemoteAddr,
+	}
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		if len(required) > 0 {
+			logger.AuthLogger.Warn("Rejected request without bearer token", fields)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+			writeJSONError(w, http.StatusUnauthorized, "Unauthorized", "A bearer token is required for "+r.URL.Path)
+			return
+		}
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	claims, err := h.verifier.Verify(token)
+	if err != nil {
+		fields["error"] = err.Error()
+		logger.AuthLogger.Warn("Rejected invalid bearer token", fields)
+		w.Header().Set("WWW-Authenticate", `Bearer realm="api", error="invalid_token"`)
+		writeJSONError(w, http.StatusUnauthorized, "Unauthorized", "The bearer token is not valid: "+err.Error())
+		return
+	}
+
+	fields["subject"] = claims.Subject
+	if len(required) > 0 && !claims.HasAnyRole(h.rolesClaim, required) {
+		fields["required_roles"] = required
+		logger.AuthLogger.Warn("Rejected bearer token without a required role", fields)
+		w.Header().Set("WWW-Authenticate", `Bearer realm="api", error="insufficient_scope"`)
+		writeJSONError(w, http.StatusForbidden, "Forbidden", "The bearer token lacks a role required for "+r.URL.Path)
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), "jwt_claims", claims)
+	h.next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// GetJWTClaimsFromContext returns the validated JWT claims of the request, if any
+func GetJWTClaimsFromContext(ctx context.Context) *jwtauth.Claims {
+	if claims, ok := ctx.Value("jwt_claims").(*jwtauth.Claims); ok {
+		return claims
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go-api-proxy/config"
+	"go-api-proxy/jwtauth"
+)
+
+var testJWTSecret = []byte("0123456789abcdef0123456789abcdef")
+
+func newTestJWTVerifier(t *testing.T) *jwtauth.Verifier {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	jwks := `{"keys": [{"kty": "oct", "kid": "test", "k": "` + base64.RawURLEncoding.EncodeToString(testJWTSecret) + `"}]}`
+	if err := os.WriteFile(path, []byte(jwks), 0o600); err != nil {
+		t.Fatalf("Failed to write JWKS: %v", err)
+	}
+	verifier, err := jwtauth.New(jwtauth.Options{JWKSFile: path, Audience: "dashboards"})
+	if err != nil {
+		t.Fatalf("Failed to create verifier: %v", err)
+	}
+	return verifier
+}
+
+// signTestJWT creates an HS256 token for the dashboards audience with the given roles
+func signTestJWT(roles []string, expiresIn time.Duration) string {
+	encode := func(v interface{}) string {
+		data, _ := json.Marshal(v)
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	input := encode(map[string]string{"alg": "HS256", "kid": "test"}) + "." + encode(map[string]interface{}{
+		"sub":   "dashboard-user",
+		"aud":   "dashboards",
+		"exp":   time.Now().Add(expiresIn).Unix(),
+		"roles": roles,
+	})
+	mac := hmac.New(sha256.New, testJWTSecret)
+	mac.Write([]byte(input))
+	return input + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestJWTHandler(t *testing.T) {
+	routes := []config.Route{
+		{Name: "unfiltered-tokens", Pattern: "/internal/tokens", Handler: config.HandlerPassthrough, Roles: []string{"token-auditor", "admin"}},
+		{Name: "api", Pattern: "/api/v2/*", Handler: config.HandlerPassthrough},
+	}
+	router, passthrough, _ := newTestRouter(t, routes)
+	handler := NewJWTHandler(router, newTestJWTVerifier(t), router, &config.Config{JWTRolesClaim: "roles"})
+
+	tests := []struct {
+		name       string
+		path       string
+		token      string
+		wantStatus int
+	}{
+		{"public route without token", "/api/v2/blocks", "", http.StatusOK},
+		{"public route with invalid token", "/api/v2/blocks", "not-a-jwt", http.StatusUnauthorized},
+		{"protected route without token", "/internal/tokens", "", http.StatusUnauthorized},
+		{"protected route with expired token", "/internal/tokens", signTestJWT([]string{"admin"}, -time.Hour), http.StatusUnauthorized},
+		{"protected route without role", "/internal/tokens", signTestJWT([]string{"viewer"}, time.Hour), http.StatusForbidden},
+		{"protected route with role", "/internal/tokens", signTestJWT([]string{"viewer", "token-auditor"}, time.Hour), http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+			if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("Expected a WWW-Authenticate challenge")
+			}
+		})
+	}
+
+	claims := GetJWTClaimsFromContext(passthrough.request.Context())
+	if claims == nil || claims.Subject != "dashboard-user" {
+		t.Errorf("Expected the claims in the request context, got %+v", claims)
+	}
+}
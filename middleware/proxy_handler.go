@@ -1,9 +1,13 @@
 package middleware
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"go-api-proxy/client"
@@ -18,6 +22,7 @@ type ProxyClientInterface interface {
 // StandardProxyHandler handles requests for non-token endpoints by forwarding them to the backend
 type StandardProxyHandler struct {
 	httpClient ProxyClientInterface
+	rewriter   *URLRewriter
 }
 
 // NewStandardProxyHandler creates a new standard proxy handler
@@ -27,6 +32,11 @@ func NewStandardProxyHandler(httpClient ProxyClientInterface) *StandardProxyHand
 	}
 }
 
+// SetURLRewriter sets the rewriter applied to responses of routes with rewrite_urls
+func (h *StandardProxyHandler) SetURLRewriter(rewriter *URLRewriter) {
+	h.rewriter = rewriter
+}
+
 // ServeHTTP implements the http.Handler interface for standard proxy functionality
 func (h *StandardProxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// Create context with timeout
@@ -58,14 +68,55 @@ func (h *StandardProxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 	}
 	defer resp.Body.Close()
 	
-	// Copy response headers from backend to client
-	h.copyHeaders(resp.Header, w.Header())
+	// The backend may answer with gzip; pass it through to clients that accept it
+	// and decode it for everyone else. Either way the body depends on Accept-Encoding.
+	// Bodies that have their URLs rewritten are always decoded.
+	rewrite := h.rewritesURLs(r)
+	encoded := client.IsGzipEncoded(resp.Header)
+	if encoded && (rewrite || !AcceptsGzip(r)) {
+		if err := client.DecompressResponse(resp); err != nil {
+			middlewareLogger.Error("Failed to decode backend response", err, map[string]interface{}{
+				"endpoint": endpoint,
+			})
+			http.Error(w, "Bad Gateway: Invalid backend response encoding", http.StatusBadGateway)
+			return
+		}
+	}
+	
+	// Copy response headers from backend to client, keeping those the route's rules let through
+	var responseHeaders *client.HeaderPolicy
+	if match := GetRouteMatchFromContext(r.Context()); match != nil {
+		responseHeaders = match.responseHeaders
+	}
+	h.copyHeaders(resp.Header, w.Header(), responseHeaders)
+	if encoded && !rewrite {
+		addVary(w.Header(), "Accept-Encoding")
+	}
+	
+	// Replace backend origins with the public origin in headers and JSON bodies
+	var body io.Reader = resp.Body
+	if rewrite {
+		h.rewriter.RewriteHeaders(w.Header())
+		if isJSONContentType(resp.Header.Get("Content-Type")) {
+			data, err := io.ReadAll(resp.Body)
+			if err != nil {
+				middlewareLogger.Error("Failed to read backend response", err, map[string]interface{}{
+					"endpoint": endpoint,
+				})
+				http.Error(w, "Bad Gateway: Backend API unreachable", http.StatusBadGateway)
+				return
+			}
+			data = h.rewriter.RewriteJSON(data)
+			w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+			body = bytes.NewReader(data)
+		}
+	}
 	
 	// Set the status code
 	w.WriteHeader(resp.StatusCode)
 	
 	// Copy response body from backend to client
-	if _, err := io.Copy(w, resp.Body); err != nil {
+	if _, err := io.Copy(w, body); err != nil {
 		middlewareLogger.Error("Error copying response body", err, map[string]interface{}{
 			"endpoint":    endpoint,
 			"status_code": resp.StatusCode,
@@ -80,8 +131,31 @@ func (h *StandardProxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 	})
 }
 
-// copyHeaders copies headers from source to destination
-func (h *StandardProxyHandler) copyHeaders(src, dst http.Header) {
+// rewritesURLs reports whether the matched route asks for backend URLs to be rewritten
+func (h *StandardProxyHandler) rewritesURLs(r *http.Request) bool {
+	if h.rewriter == nil {
+		return false
+	}
+	match := GetRouteMatchFromContext(r.Context())
+	return match != nil && match.Route.RewriteURLs
+}
+
+// isJSONContentType reports whether a Content-Type denotes a JSON document
+func isJSONContentType(contentType string) bool {
+	mediaType := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// strippedResponseHeaders reveal details of the backend and are not passed to
+// clients unless a route's response header rules allow them
+var strippedResponseHeaders = []string{
+	"Server",
+	"Set-Cookie",
+	"X-Powered-By",
+}
+
+// copyHeaders copies headers from source to destination, applying the response header policy
+func (h *StandardProxyHandler) copyHeaders(src, dst http.Header, policy *client.HeaderPolicy) {
 	for key, values := range src {
 		// Skip hop-by-hop headers that shouldn't be forwarded
 		if h.isHopByHopHeader(key) {
@@ -93,6 +167,11 @@ func (h *StandardProxyHandler) copyHeaders(src, dst http.Header) {
 			continue
 		}
 		
+		// The body's framing must always match the body that is sent
+		if key != "Content-Encoding" && key != "Content-Length" && !policy.Passes(key, !containsString(strippedResponseHeaders, key)) {
+			continue
+		}
+		
 		for _, value := range values {
 			dst.Add(key, value)
 		}
@@ -101,23 +180,7 @@ func (h *StandardProxyHandler) copyHeaders(src, dst http.Header) {
 
 // isHopByHopHeader checks if a header is a hop-by-hop header that shouldn't be forwarded
 func (h *StandardProxyHandler) isHopByHopHeader(header string) bool {
-	hopByHopHeaders := []string{
-		"Connection",
-		"Keep-Alive",
-		"Proxy-Authenticate",
-		"Proxy-Authorization",
-		"Te",
-		"Trailers",
-		"Transfer-Encoding",
-		"Upgrade",
-	}
-	
-	for _, hopHeader := range hopByHopHeaders {
-		if header == hopHeader {
-			return true
-		}
-	}
-	return false
+	return client.IsHopByHopHeader(header)
 }
 
 // isCORSHeader checks if a header is a CORS header that should be handled by our CORS middleware
@@ -151,6 +214,37 @@ func (h *StandardProxyHandler) handleError(w http.ResponseWriter, r *http.Reques
 		"path":   r.URL.Path,
 	})
 	
+	// Check if the request body exceeded the route's size limit
+	var tooLargeErr *client.RequestTooLargeError
+	if errors.As(err, &tooLargeErr) {
+		middlewareLogger.Warn("Request body too large", map[string]interface{}{
+			"error_type": "request_too_large",
+			"limit":      tooLargeErr.Limit,
+		})
+		writeRequestTooLarge(w, tooLargeErr.Limit)
+		return
+	}
+	
+	// Check if the client's own request could not be sent, e.g. an aborted upload
+	if client.IsRequestError(err) {
+		middlewareLogger.Warn("Invalid client request", map[string]interface{}{
+			"error_type": "request_error",
+		})
+		writeJSONError(w, http.StatusBadRequest, "Bad request", "The request could not be forwarded")
+		return
+	}
+	
+	// Check if the backend circuit breaker rejected the request
+	var circuitErr *client.CircuitOpenError
+	if errors.As(err, &circuitErr) {
+		middlewareLogger.Warn("Backend circuit breaker open", map[string]interface{}{
+			"error_type":  "circuit_open",
+			"retry_after": circuitErr.RetryAfterSeconds(),
+		})
+		writeCircuitOpen(w, circuitErr)
+		return
+	}
+	
 	// Check if it's a network error (backend unreachable)
 	if client.IsNetworkError(err) {
 		middlewareLogger.Warn("Backend API unreachable", map[string]interface{}{
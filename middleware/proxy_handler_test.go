@@ -1,15 +1,20 @@
 package middleware
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"go-api-proxy/client"
+	"go-api-proxy/config"
 )
 
 // MockProxyClient implements ProxyClientInterface for testing
@@ -193,7 +198,7 @@ func TestStandardProxyHandler_CopyHeaders(t *testing.T) {
 	dst := http.Header{}
 	
 	// Copy headers
-	handler.copyHeaders(src, dst)
+	handler.copyHeaders(src, dst, nil)
 	
 	// Verify regular headers are copied
 	if dst.Get("Content-Type") != "application/json" {
@@ -316,4 +321,117 @@ func TestStandardProxyHandler_ServeHTTP_DifferentStatusCodes(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+func TestStandardProxyHandler_ServeHTTP_CircuitOpen(t *testing.T) {
+	mockClient := &MockProxyClient{err: &client.CircuitOpenError{Backend: "backend", RetryAfter: 2500 * time.Millisecond}}
+	handler := NewStandardProxyHandler(mockClient)
+	
+	req := httptest.NewRequest("GET", "/api/v2/blocks", nil)
+	w := httptest.NewRecorder()
+	
+	handler.ServeHTTP(w, req)
+	
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d, got %d", http.StatusServiceUnavailable, w.Code)
+	}
+	
+	if w.Header().Get("Retry-After") != "3" {
+		t.Errorf("Expected Retry-After 3, got %q", w.Header().Get("Retry-After"))
+	}
+	
+	if w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Expected JSON error response, got %s", w.Header().Get("Content-Type"))
+	}
+}
+
+func TestStandardProxyHandler_NegotiatesGzip(t *testing.T) {
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write([]byte(`{"height":1}`))
+	gz.Close()
+
+	serve := func(acceptEncoding string) *httptest.ResponseRecorder {
+		mockClient := &MockProxyClient{response: &http.Response{
+			StatusCode: http.StatusOK,
+			Header: http.Header{
+				"Content-Type":     {"application/json"},
+				"Content-Encoding": {"gzip"},
+				"Content-Length":   {strconv.Itoa(compressed.Len())},
+			},
+			Body: io.NopCloser(bytes.NewReader(compressed.Bytes())),
+		}}
+		req := httptest.NewRequest("GET", "/api/v2/blocks/1", nil)
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+		w := httptest.NewRecorder()
+		NewStandardProxyHandler(mockClient).ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve("gzip, deflate")
+	if w.Header().Get("Content-Encoding") != "gzip" || w.Header().Get("Content-Length") != strconv.Itoa(compressed.Len()) {
+		t.Errorf("Expected gzip body to pass through, got headers %v", w.Header())
+	}
+	if !bytes.Equal(w.Body.Bytes(), compressed.Bytes()) || w.Header().Get("Vary") != "Accept-Encoding" {
+		t.Error("Expected unchanged gzip body with Vary: Accept-Encoding")
+	}
+
+	w = serve("identity")
+	if w.Header().Get("Content-Encoding") != "" || w.Header().Get("Content-Length") != "" {
+		t.Errorf("Expected encoding headers to be dropped, got %v", w.Header())
+	}
+	if w.Body.String() != `{"height":1}` || w.Header().Get("Vary") != "Accept-Encoding" {
+		t.Errorf("Expected decoded body with Vary: Accept-Encoding, got %q", w.Body.String())
+	}
+}
+
+func TestStandardProxyHandler_RewritesURLs(t *testing.T) {
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write([]byte(`{"icon_url":"https://exp.internal/icons/a.png"}`))
+	gz.Close()
+
+	rewriter, err := NewURLRewriter("https://api.example.com", []string{"https://exp.internal"})
+	if err != nil {
+		t.Fatalf("NewURLRewriter failed: %v", err)
+	}
+
+	serve := func(rewriteURLs bool) *httptest.ResponseRecorder {
+		mockClient := &MockProxyClient{response: &http.Response{
+			StatusCode: http.StatusFound,
+			Header: http.Header{
+				"Content-Type":     {"application/json; charset=utf-8"},
+				"Content-Encoding": {"gzip"},
+				"Content-Length":   {strconv.Itoa(compressed.Len())},
+				"Location":         {"https://exp.internal/api/v2/blocks/1"},
+			},
+			Body: io.NopCloser(bytes.NewReader(compressed.Bytes())),
+		}}
+		handler := NewStandardProxyHandler(mockClient)
+		handler.SetURLRewriter(rewriter)
+
+		req := httptest.NewRequest("GET", "/api/v2/blocks/latest", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		match := &RouteMatch{Route: &config.Route{Name: "api", RewriteURLs: rewriteURLs}}
+		req = req.WithContext(context.WithValue(req.Context(), "route_match", match))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve(true)
+	expected := `{"icon_url":"https://api.example.com/icons/a.png"}`
+	if w.Body.String() != expected {
+		t.Errorf("Expected rewritten body %s, got %q", expected, w.Body.String())
+	}
+	if w.Header().Get("Location") != "https://api.example.com/api/v2/blocks/1" {
+		t.Errorf("Expected rewritten Location, got %q", w.Header().Get("Location"))
+	}
+	if w.Header().Get("Content-Encoding") != "" || w.Header().Get("Content-Length") != strconv.Itoa(len(expected)) {
+		t.Errorf("Expected a decoded body with its new length, got headers %v", w.Header())
+	}
+
+	w = serve(false)
+	if !bytes.Equal(w.Body.Bytes(), compressed.Bytes()) || w.Header().Get("Location") != "https://exp.internal/api/v2/blocks/1" {
+		t.Error("Expected routes without rewrite_urls to be passed through unchanged")
+	}
+}
@@ -0,0 +1,195 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go-api-proxy/client"
+	"go-api-proxy/config"
+	"go-api-proxy/logger"
+)
+
+// Rate limit client keys
+const (
+	RateLimitKeyIP     = "ip"
+	RateLimitKeyAPIKey = "api-key"
+)
+
+// tokenBucket holds the tokens left for one client
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimitDecision is the outcome of taking a token from a bucket
+type rateLimitDecision struct {
+	allowed    bool
+	limit      int
+	remaining  int
+	reset      int // seconds until the bucket is full again
+	retryAfter int // seconds until the next token, when not allowed
+}
+
+// RateLimitHandler limits each client to a token bucket per route. Clients are
+// identified by IP address, or by their authenticated API key when configured. Routes with
+// their own rate_limit get separate buckets; all other routes share the default limit.
+type RateLimitHandler struct {
+	next         http.Handler
+	router       *Router
+	defaultLimit config.RateLimit
+	keyMode      string
+	idleTimeout  time.Duration
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewRateLimitHandler creates a rate limiting handler in front of the router. It
+// returns nil when neither a default limit nor any route limit is configured, and API
+// keys, whose plans may carry limits, are not enabled.
+func NewRateLimitHandler(next http.Handler, router *Router, routes []config.Route, cfg *config.Config) *RateLimitHandler {
+	enabled := cfg.RateLimitRPS > 0 || cfg.APIKeysFile != ""
+	for _, route := range routes {
+		if route.RateLimit != nil && route.RateLimit.RequestsPerSecond > 0 {
+			enabled = true
+		}
+	}
+	if !enabled {
+		return nil
+	}
+
+	idleTimeout := cfg.RateLimitIdleTimeout
+	if idleTimeout <= 0 {
+		idleTimeout = 10 * time.Minute
+	}
+
+	return &RateLimitHandler{
+		next:         next,
+		router:       router,
+		defaultLimit: config.RateLimit{RequestsPerSecond: cfg.RateLimitRPS, Burst: cfg.RateLimitBurst},
+		keyMode:      cfg.RateLimitKey,
+		idleTimeout:  idleTimeout,
+		buckets:      make(map[string]*tokenBucket),
+		now:          time.Now,
+	}
+}
+
+// ServeHTTP implements the http.Handler interface for rate limiting
+func (h *RateLimitHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	limit, scope := h.limitFor(r)
+	if limit.RequestsPerSecond <= 0 {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	clientKey, clientIP := h.clientKey(r, scope)
+	decision := h.take(scope+"|"+clientKey, limit)
+
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(decision.limit))
+	w.Header().Set("RateLimit-Remaining", strconv.Itoa(decision.remaining))
+	w.Header().Set("RateLimit-Reset", strconv.Itoa(decision.reset))
+
+	if !decision.allowed {
+		requestID := getRequestIDFromContext(r.Context())
+		logger.MiddlewareLogger.WithRequestID(requestID).Warn("Rate limit exceeded", map[string]interface{}{
+			"client_ip":   clientIP,
+			"scope":       scope,
+			"path":        r.URL.Path,
+			"retry_after": decision.retryAfter,
+		})
+		w.Header().Set("Retry-After", strconv.Itoa(decision.retryAfter))
+		writeJSONError(w, http.StatusTooManyRequests, "Too many requests",
+			fmt.Sprintf("Rate limit of %g requests per second exceeded, retry in %d seconds", limit.RequestsPerSecond, decision.retryAfter))
+		return
+	}
+
+	h.next.ServeHTTP(w, r)
+}
+
+// limitFor returns the limit for the request's route and the scope its buckets are kept in.
+// The plan of an authenticated API key takes precedence when it sets a rate.
+func (h *RateLimitHandler) limitFor(r *http.Request) (config.RateLimit, string) {
+	if consumer := GetAPIConsumerFromContext(r.Context()); consumer != nil && consumer.Plan.RequestsPerSecond > 0 {
+		return config.RateLimit{RequestsPerSecond: consumer.Plan.RequestsPerSecond, Burst: consumer.Plan.Burst}, "plan:" + consumer.Plan.Name
+	}
+	if match, ok := h.router.Match(r.URL.Path); ok && match.Route.RateLimit != nil {
+		return *match.Route.RateLimit, "route:" + match.Route.Name
+	}
+	return h.defaultLimit, "default"
+}
+
+// clientKey identifies the client by IP address. Authenticated API keys get their own
+// bucket in api-key mode, and whenever their plan sets the limit. Unauthenticated key
+// headers are never used, so clients cannot get a fresh bucket by sending a new value
+// on every request. It also returns the IP address for logging.
+func (h *RateLimitHandler) clientKey(r *http.Request, scope string) (string, string) {
+	clientIP := client.GetClientIP(r)
+	if consumer := GetAPIConsumerFromContext(r.Context()); consumer != nil {
+		if h.keyMode == RateLimitKeyAPIKey || strings.HasPrefix(scope, "plan:") {
+			return "consumer:" + consumer.Key.ID, clientIP
+		}
+	}
+	return "ip:" + clientIP, clientIP
+}
+
+// take refills the bucket for key and tries to take one token from it
+func (h *RateLimitHandler) take(key string, limit config.RateLimit) rateLimitDecision {
+	burst := float64(limit.Burst)
+	if burst <= 0 {
+		burst = math.Max(1, math.Ceil(limit.RequestsPerSecond))
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := h.now()
+	h.sweep(now)
+
+	bucket, ok := h.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: burst, last: now}
+		h.buckets[key] = bucket
+	}
+	bucket.tokens = math.Min(burst, bucket.tokens+now.Sub(bucket.last).Seconds()*limit.RequestsPerSecond)
+	bucket.last = now
+
+	decision := rateLimitDecision{limit: int(burst)}
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		decision.allowed = true
+	} else {
+		decision.retryAfter = int(math.Ceil((1 - bucket.tokens) / limit.RequestsPerSecond))
+	}
+	decision.remaining = int(bucket.tokens)
+	decision.reset = int(math.Ceil((burst - bucket.tokens) / limit.RequestsPerSecond))
+	return decision
+}
+
+// sweep drops buckets that have been idle for the idle timeout so memory stays bounded
+// by the number of recently active clients. It runs at most twice per idle timeout.
+func (h *RateLimitHandler) sweep(now time.Time) {
+	if now.Sub(h.lastSweep) < h.idleTimeout/2 {
+		return
+	}
+	h.lastSweep = now
+
+	for key, bucket := range h.buckets {
+		if now.Sub(bucket.last) >= h.idleTimeout {
+			delete(h.buckets, key)
+		}
+	}
+}
+
+// BucketCount returns the number of clients currently tracked
+func (h *RateLimitHandler) BucketCount() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.buckets)
+}
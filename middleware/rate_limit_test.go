@@ -0,0 +1,172 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-api-proxy/apikeys"
+	"go-api-proxy/config"
+	"go-api-proxy/models"
+)
+
+func newTestRateLimiter(t *testing.T, routes []config.Route, cfg *config.Config) (*RateLimitHandler, *time.Time) {
+	t.Helper()
+
+	router, _, _ := newTestRouter(t, routes)
+	limiter := NewRateLimitHandler(router, router, routes, cfg)
+	if limiter == nil {
+		t.Fatal("Expected a rate limiter")
+	}
+	now := time.Unix(1700000000, 0)
+	limiter.now = func() time.Time { return now }
+	return limiter, &now
+}
+
+func rateLimitedRequest(handler http.Handler, path, remoteAddr string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.RemoteAddr = remoteAddr
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimitHandler_LimitsPerClient(t *testing.T) {
+	routes := []config.Route{{Name: "api", Pattern: "/api/v2/*", Handler: config.HandlerPassthrough}}
+	limiter, now := newTestRateLimiter(t, routes, &config.Config{RateLimitRPS: 1, RateLimitBurst: 2})
+
+	for i := 0; i < 2; i++ {
+		w := rateLimitedRequest(limiter, "/api/v2/blocks", "192.0.2.1:1234", nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Request %d: expected 200, got %d", i, w.Code)
+		}
+		if w.Header().Get("RateLimit-Limit") != "2" {
+			t.Errorf("Expected RateLimit-Limit 2, got %q", w.Header().Get("RateLimit-Limit"))
+		}
+	}
+
+	w := rateLimitedRequest(limiter, "/api/v2/blocks", "192.0.2.1:1234", nil)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected 429 over the limit, got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") != "1" || w.Header().Get("RateLimit-Remaining") != "0" || w.Header().Get("RateLimit-Reset") != "2" {
+		t.Errorf("Unexpected rate limit headers %v", w.Header())
+	}
+	var body models.ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.Error != "Too many requests" {
+		t.Errorf("Expected a JSON error body, got %q", w.Body.String())
+	}
+
+	if w := rateLimitedRequest(limiter, "/api/v2/blocks", "192.0.2.2:1234", nil); w.Code != http.StatusOK {
+		t.Errorf("Expected another client to have its own bucket, got %d", w.Code)
+	}
+
+	*now = now.Add(time.Second)
+	if w := rateLimitedRequest(limiter, "/api/v2/blocks", "192.0.2.1:1234", nil); w.Code != http.StatusOK {
+		t.Errorf("Expected a token to be refilled after one second, got %d", w.Code)
+	}
+}
+
+func TestRateLimitHandler_RouteLimits(t *testing.T) {
+	routes := []config.Route{
+		{Name: "tokens", Pattern: "/api/v2/tokens", Handler: config.HandlerTokenFilter, RateLimit: &config.RateLimit{RequestsPerSecond: 0.5, Burst: 1}},
+		{Name: "stats", Pattern: "/api/v2/stats", Handler: config.HandlerPassthrough, RateLimit: &config.RateLimit{}},
+		{Name: "api", Pattern: "/api/v2/*", Handler: config.HandlerPassthrough},
+	}
+	limiter, _ := newTestRateLimiter(t, routes, &config.Config{RateLimitRPS: 10})
+
+	if w := rateLimitedRequest(limiter, "/api/v2/tokens", "192.0.2.1:1234", nil); w.Code != http.StatusOK {
+		t.Fatalf("Expected the first tokens request to pass, got %d", w.Code)
+	}
+	w := rateLimitedRequest(limiter, "/api/v2/tokens", "192.0.2.1:1234", nil)
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "2" {
+		t.Errorf("Expected the route limit to apply, got %d with Retry-After %q", w.Code, w.Header().Get("Retry-After"))
+	}
+
+	// The route bucket is separate from the default one
+	if w := rateLimitedRequest(limiter, "/api/v2/blocks", "192.0.2.1:1234", nil); w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "10" {
+		t.Errorf("Expected the default limit for other routes, got %d with limit %q", w.Code, w.Header().Get("RateLimit-Limit"))
+	}
+
+	// A zero rate on a route disables limiting for it
+	for i := 0; i < 20; i++ {
+		if w := rateLimitedRequest(limiter, "/api/v2/stats", "192.0.2.1:1234", nil); w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "" {
+			t.Fatalf("Expected an unlimited route, got %d", w.Code)
+		}
+	}
+}
+
+func TestRateLimitHandler_KeysByAPIKey(t *testing.T) {
+	routes := []config.Route{{Name: "api", Pattern: "/*", Handler: config.HandlerPassthrough}}
+	limiter, _ := newTestRateLimiter(t, routes, &config.Config{
+		RateLimitRPS: 1,
+		RateLimitKey: RateLimitKeyAPIKey,
+		APIKeysFile:  "keys.json",
+	})
+
+	// Stand-in for APIKeyHandler: only known keys are authenticated
+	consumers := map[string]*APIConsumer{
+		"partner-one": {Key: &apikeys.Key{ID: "one"}, Plan: &apikeys.Plan{Name: "free"}},
+		"partner-two": {Key: &apikeys.Key{ID: "two"}, Plan: &apikeys.Plan{Name: "free"}},
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if consumer, ok := consumers[r.Header.Get("X-API-Key")]; ok {
+			r = r.WithContext(context.WithValue(r.Context(), "api_consumer", consumer))
+		}
+		limiter.ServeHTTP(w, r)
+	})
+
+	first := http.Header{"X-Api-Key": {"partner-one"}}
+	second := http.Header{"X-Api-Key": {"partner-two"}}
+	if w := rateLimitedRequest(handler, "/", "192.0.2.1:1234", first); w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	if w := rateLimitedRequest(handler, "/", "192.0.2.1:1234", first); w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected the key's bucket to be empty, got %d", w.Code)
+	}
+	if w := rateLimitedRequest(handler, "/", "192.0.2.1:1234", second); w.Code != http.StatusOK {
+		t.Errorf("Expected another key from the same IP to pass, got %d", w.Code)
+	}
+	if w := rateLimitedRequest(handler, "/", "192.0.2.1:1234", nil); w.Code != http.StatusOK {
+		t.Errorf("Expected requests without a key to be limited by IP, got %d", w.Code)
+	}
+
+	// Unauthenticated key values must not get fresh buckets
+	for i, key := range []string{"random-1", "random-2"} {
+		w := rateLimitedRequest(handler, "/", "192.0.2.1:1234", http.Header{"X-Api-Key": {key}})
+		if w.Code != http.StatusTooManyRequests {
+			t.Errorf("Expected unknown key %d to share the IP's bucket, got %d", i, w.Code)
+		}
+	}
+}
+
+func TestRateLimitHandler_DropsIdleBuckets(t *testing.T) {
+	routes := []config.Route{{Name: "api", Pattern: "/*", Handler: config.HandlerPassthrough}}
+	limiter, now := newTestRateLimiter(t, routes, &config.Config{RateLimitRPS: 5, RateLimitIdleTimeout: time.Minute})
+
+	rateLimitedRequest(limiter, "/", "192.0.2.1:1234", nil)
+	rateLimitedRequest(limiter, "/", "192.0.2.2:1234", nil)
+	if limiter.BucketCount() != 2 {
+		t.Fatalf("Expected 2 buckets, got %d", limiter.BucketCount())
+	}
+
+	*now = now.Add(2 * time.Minute)
+	rateLimitedRequest(limiter, "/", "192.0.2.3:1234", nil)
+	if limiter.BucketCount() != 1 {
+		t.Errorf("Expected idle buckets to be dropped, got %d", limiter.BucketCount())
+	}
+}
+
+func TestNewRateLimitHandler_Disabled(t *testing.T) {
+	routes := []config.Route{{Name: "api", Pattern: "/*", Handler: config.HandlerPassthrough}}
+	router, _, _ := newTestRouter(t, routes)
+	if NewRateLimitHandler(router, router, routes, &config.Config{}) != nil {
+		t.Error("Expected no rate limiter without limits")
+	}
+}
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"net/http"
+
+	"go-api-proxy/config"
+	"go-api-proxy/logger"
+	"go-api-proxy/validation"
+)
+
+// ValidationHandler rejects requests with malformed path or query parameters with a
+// 400 before they are routed, so they never cost a backend round trip
+type ValidationHandler struct {
+	next  http.Handler
+	rules []*compiledValidation
+}
+
+// compiledValidation is a validation rule with its pattern split into segments
+type compiledValidation struct {
+	rule  validation.Rule
+	route *compiledRoute
+}
+
+// NewValidationHandler creates a handler checking requests against the rules. The
+// first rule matching the request path applies.
+func NewValidationHandler(next http.Handler, rules []validation.Rule) *ValidationHandler {
+	h := &ValidationHandler{next: next}
+	for _, rule := range rules {
+		h.rules = append(h.rules, &compiledValidation{
+			rule:  rule,
+			route: compileRoute(config.Route{Pattern: rule.Pattern}),
+		})
+	}
+	return h
+}
+
+// ServeHTTP implements the http.Handler interface for request validation
+func (h *ValidationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := h.validate(r); err != nil {
+		requestID := getRequestIDFromContext(r.Context())
+		logger.MiddlewareLogger.WithRequestID(requestID).Info("Rejected request with invalid parameters", map[string]interface{}{
+			"method": r.Method,
+			"path":   r.URL.Path,
+			"error":  err.Error(),
+		})
+		writeJSONError(w, http.StatusBadRequest, "Bad request", err.Error())
+		return
+	}
+	h.next.ServeHTTP(w, r)
+}
+
+// validate checks the request against the first rule matching its path
+func (h *ValidationHandler) validate(r *http.Request) error {
+	segments := splitPath(normalizePath(r.URL.Path))
+	for _, compiled := range h.rules {
+		if params, ok := compiled.route.match(segments); ok {
+			return compiled.rule.Validate(params, r.URL.Query())
+		}
+	}
+	return nil
+}
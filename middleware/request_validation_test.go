@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-api-proxy/models"
+	"go-api-proxy/validation"
+)
+
+func TestValidationHandler(t *testing.T) {
+	backend := &recordingHandler{name: "passthrough"}
+	handler := NewValidationHandler(backend, validation.BlockscoutV2Rules)
+
+	address := "0x" + strings.Repeat("ab", 20)
+	hash := "0x" + strings.Repeat("cd", 32)
+
+	tests := []struct {
+		name     string
+		path     string
+		expected int
+		message  string
+	}{
+		{"valid address", "/api/v2/addresses/" + address, http.StatusOK, ""},
+		{"valid address subpath", "/api/v2/addresses/" + address + "/transactions?block_number=10&index=2", http.StatusOK, ""},
+		{"invalid address", "/api/v2/addresses/notanaddress", http.StatusBadRequest, `invalid address "notanaddress": must be 0x followed by 40 hex characters`},
+		{"invalid address subpath", "/api/v2/addresses/0x12/token-transfers", http.StatusBadRequest, `invalid address "0x12": must be 0x followed by 40 hex characters`},
+		{"valid transaction", "/api/v2/transactions/" + hash, http.StatusOK, ""},
+		{"short transaction hash", "/api/v2/transactions/0x123", http.StatusBadRequest, `invalid hash "0x123": must be 0x followed by 64 hex characters`},
+		{"fixed transaction path", "/api/v2/transactions/watchlist", http.StatusOK, ""},
+		{"zkEVM batch transactions", "/api/v2/transactions/zkevm-batch/1234", http.StatusOK, ""},
+		{"invalid zkEVM batch", "/api/v2/transactions/zkevm-batch/latest", http.StatusBadRequest, `invalid batch "latest": must be a non-negative decimal integer`},
+		{"execution node transactions", "/api/v2/transactions/execution-node/" + address, http.StatusOK, ""},
+		{"Arbitrum batch blocks", "/api/v2/blocks/arbitrum-batch/77", http.StatusOK, ""},
+		{"Optimism batch blocks", "/api/v2/blocks/optimism-batch/77?block_number=10", http.StatusOK, ""},
+		{"address counters", "/api/v2/addresses/counters", http.StatusOK, ""},
+		{"block by number", "/api/v2/blocks/123/transactions", http.StatusOK, ""},
+		{"block by hash", "/api/v2/blocks/" + hash, http.StatusOK, ""},
+		{"invalid block", "/api/v2/blocks/latest", http.StatusBadRequest, `invalid block "latest": must be a block number or a 0x-prefixed 32-byte hash`},
+		{"token instance", "/api/v2/tokens/" + address + "/instances/42", http.StatusOK, ""},
+		{"invalid smart contract", "/api/v2/smart-contracts/0xzz", http.StatusBadRequest, `invalid address "0xzz": must be 0x followed by 40 hex characters`},
+		{"invalid pagination on list", "/api/v2/blocks?block_number=abc", http.StatusBadRequest, `invalid block_number "abc": must be a non-negative decimal integer`},
+		{"list without parameters", "/api/v2/transactions", http.StatusOK, ""},
+		{"path outside the API", "/health/0x12", http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend.request = nil
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tt.expected {
+				t.Fatalf("Expected status %d, got %d", tt.expected, w.Code)
+			}
+			if tt.expected == http.StatusOK {
+				return
+			}
+			if backend.request != nil {
+				t.Error("Expected the request to be rejected before the backend")
+			}
+			var body models.ErrorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("Expected a JSON error, got %q", w.Body.String())
+			}
+			if body.Error != "Bad request" || body.Message != tt.message {
+				t.Errorf("Unexpected error body %+v", body)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"go-api-proxy/client"
+	"go-api-proxy/logger"
+	"go-api-proxy/models"
+)
+
+// writeJSONError writes a JSON error response using models.ErrorResponse
+func writeJSONError(w http.ResponseWriter, statusCode int, message, detail string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(w).Encode(models.NewErrorResponse(message, detail)); err != nil {
+		logger.MiddlewareLogger.Error("Error encoding error response", err, map[string]interface{}{
+			"status_code": statusCode,
+			"message":     message,
+		})
+	}
+}
+
+// writeRequestTooLarge writes a 413 JSON error for a request body over the limit
+func writeRequestTooLarge(w http.ResponseWriter, limit int64) {
+	w.Header().Set("Connection", "close")
+	writeJSONError(w, http.StatusRequestEntityTooLarge, "Request entity too large",
+		fmt.Sprintf("Request body exceeds the limit of %d bytes", limit))
+}
+
+// writeCircuitOpen writes a fast 503 JSON error with Retry-After while the backend circuit is open
+func writeCircuitOpen(w http.ResponseWriter, circuitErr *client.CircuitOpenError) {
+	w.Header().Set("Retry-After", strconv.Itoa(circuitErr.RetryAfterSeconds()))
+	writeJSONError(w, http.StatusServiceUnavailable, "Service unavailable", "Backend API is temporarily unavailable")
+}
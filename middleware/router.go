@@ -0,0 +1,274 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"go-api-proxy/client"
+	"go-api-proxy/config"
+	"go-api-proxy/logger"
+)
+
+// RouteMatch is the result of matching a request path against the route table
+type RouteMatch struct {
+	Route  *config.Route
+	Params map[string]string
+	Path   string // request path before any prefix rewriting
+
+	credentials     *client.Credentials
+	requestHeaders  *client.HeaderPolicy
+	responseHeaders *client.HeaderPolicy
+}
+
+// compiledRoute is a route with its pattern split into segments for matching
+type compiledRoute struct {
+	route           config.Route
+	segments        []string
+	wildcard        bool
+	credentials     *client.Credentials
+	requestHeaders  *client.HeaderPolicy
+	responseHeaders *client.HeaderPolicy
+}
+
+// Router dispatches requests to handlers according to a declarative route table
+type Router struct {
+	routes   []*compiledRoute
+	handlers map[string]http.Handler
+}
+
+// NewRouter creates a router for the given routes. The handlers map provides the
+// implementation for each non-static handler type used by the table.
+func NewRouter(routes []config.Route, handlers map[string]http.Handler) (*Router, error) {
+	router := &Router{
+		handlers: handlers,
+	}
+
+	for _, route := range routes {
+		if err := route.Validate(); err != nil {
+			return nil, err
+		}
+		if route.Handler != config.HandlerStatic && handlers[route.Handler] == nil {
+			return nil, fmt.Errorf("route %q: no handler registered for type %q", route.Name, route.Handler)
+		}
+		compiled := compileRoute(route)
+		if creds := route.Credentials; creds != nil {
+			loaded, err := client.LoadCredentials(creds.KeyFile, creds.Param(), creds.Header)
+			if err != nil {
+				return nil, fmt.Errorf("route %q: %w", route.Name, err)
+			}
+			compiled.credentials = loaded
+		}
+		requestHeaders, err := client.NewHeaderPolicy(route.Name, route.RequestHeaders)
+		if err != nil {
+			return nil, fmt.Errorf("route %q: request headers: %w", route.Name, err)
+		}
+		responseHeaders, err := client.NewHeaderPolicy(route.Name, route.ResponseHeaders)
+		if err != nil {
+			return nil, fmt.Errorf("route %q: response headers: %w", route.Name, err)
+		}
+		compiled.requestHeaders, compiled.responseHeaders = requestHeaders, responseHeaders
+		router.routes = append(router.routes, compiled)
+	}
+
+	return router, nil
+}
+
+// compileRoute splits a route pattern into matchable segments
+func compileRoute(route config.Route) *compiledRoute {
+	pattern := normalizePath(route.Pattern)
+	compiled := &compiledRoute{route: route}
+
+	if strings.HasSuffix(pattern, "*") {
+		compiled.wildcard = true
+		pattern = normalizePath(strings.TrimSuffix(pattern, "*"))
+	}
+	compiled.segments = splitPath(pattern)
+
+	return compiled
+}
+
+// Match finds the first route matching the given path
+func (rt *Router) Match(path string) (*RouteMatch, bool) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, false
+	}
+
+	segments := splitPath(normalizePath(path))
+	for _, compiled := range rt.routes {
+		if params, ok := compiled.match(segments); ok {
+			route := compiled.route
+			return &RouteMatch{
+				Route:           &route,
+				Params:          params,
+				Path:            path,
+				credentials:     compiled.credentials,
+				requestHeaders:  compiled.requestHeaders,
+				responseHeaders: compiled.responseHeaders,
+			}, true
+		}
+	}
+	return nil, false
+}
+
+// match checks the path segments against the route pattern
+func (cr *compiledRoute) match(segments []string) (map[string]string, bool) {
+	if len(segments) < len(cr.segments) || (!cr.wildcard && len(segments) != len(cr.segments)) {
+		return nil, false
+	}
+
+	params := make(map[string]string)
+	for i, patternSegment := range cr.segments {
+		if strings.HasPrefix(patternSegment, "{") && strings.HasSuffix(patternSegment, "}") {
+			params[patternSegment[1:len(patternSegment)-1]] = segments[i]
+			continue
+		}
+		if patternSegment != segments[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// ServeHTTP implements the http.Handler interface for the router
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	requestID := getRequestIDFromContext(r.Context())
+	routerLogger := logger.MiddlewareLogger.WithRequestID(requestID)
+
+	match, ok := rt.Match(r.URL.Path)
+	if !ok {
+		routerLogger.Warn("No route matched request", map[string]interface{}{
+			"method": r.Method,
+			"path":   r.URL.Path,
+		})
+		writeJSONError(w, http.StatusNotFound, "Not found", "No route matches "+r.URL.Path)
+		return
+	}
+
+	route := match.Route
+	if methods := route.Methods; len(methods) > 0 && !containsMethod(methods, r.Method) {
+		routerLogger.Warn("Method not allowed for route", map[string]interface{}{
+			"method": r.Method,
+			"path":   r.URL.Path,
+			"route":  route.Name,
+		})
+		w.Header().Set("Allow", strings.Join(methods, ", "))
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method not allowed", r.Method+" is not allowed for "+r.URL.Path)
+		return
+	}
+
+	routerLogger.Debug("Matched route", map[string]interface{}{
+		"route":   route.Name,
+		"handler": route.Handler,
+		"params":  match.Params,
+	})
+
+	if limit := route.MaxBodyBytes; limit > 0 && r.Body != nil {
+		if r.ContentLength > limit {
+			routerLogger.Warn("Request body exceeds route limit", map[string]interface{}{
+				"route":          route.Name,
+				"content_length": r.ContentLength,
+				"limit":          limit,
+			})
+			writeRequestTooLarge(w, limit)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+	}
+
+	ctx := context.WithValue(r.Context(), "route_match", match)
+	if route.Backend != "" {
+		ctx = client.WithTargetBackend(ctx, route.Backend)
+	} else if route.BackendRoot {
+		ctx = client.WithBackendRoot(ctx)
+	}
+	if match.credentials != nil {
+		ctx = client.WithCredentials(ctx, match.credentials)
+	}
+	if match.requestHeaders != nil {
+		ctx = client.WithRequestHeaders(ctx, match.requestHeaders)
+	}
+	r = r.WithContext(ctx)
+
+	if match.responseHeaders != nil && route.Handler != config.HandlerWebSocket {
+		w = &headerRulesWriter{ResponseWriter: w, policy: match.responseHeaders, req: r}
+	}
+
+	if route.Handler == config.HandlerStatic {
+		rt.serveStatic(w, route.Static)
+		return
+	}
+
+	rt.handlers[route.Handler].ServeHTTP(w, rewritePath(r, route))
+}
+
+// serveStatic writes the fixed response of a static route
+func (rt *Router) serveStatic(w http.ResponseWriter, static *config.StaticResponse) {
+	statusCode := static.StatusCode
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+	if static.ContentType != "" {
+		w.Header().Set("Content-Type", static.ContentType)
+	}
+	w.WriteHeader(statusCode)
+	w.Write([]byte(static.Body))
+}
+
+// rewritePath applies the route's strip and add prefix rules to a copy of the request
+func rewritePath(r *http.Request, route *config.Route) *http.Request {
+	if route.StripPrefix == "" && route.AddPrefix == "" {
+		return r
+	}
+
+	path := r.URL.Path
+	if route.StripPrefix != "" {
+		path = strings.TrimPrefix(path, strings.TrimSuffix(route.StripPrefix, "/"))
+	}
+	path = strings.TrimSuffix(route.AddPrefix, "/") + path
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	rewritten := r.Clone(r.Context())
+	rewritten.URL = &url.URL{Path: path, RawQuery: r.URL.RawQuery}
+	rewritten.Body = r.Body
+	return rewritten
+}
+
+// GetRouteMatchFromContext returns the route matched for the request, if any
+func GetRouteMatchFromContext(ctx context.Context) *RouteMatch {
+	if match, ok := ctx.Value("route_match").(*RouteMatch); ok {
+		return match
+	}
+	return nil
+}
+
+// containsMethod checks if the method list contains the method (case-insensitive)
+func containsMethod(methods []string, method string) bool {
+	for _, m := range methods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizePath removes trailing slashes from a path, keeping the root path intact
+func normalizePath(path string) string {
+	if path == "/" {
+		return path
+	}
+	return strings.TrimSuffix(path, "/")
+}
+
+// splitPath splits a normalised path into its segments
+func splitPath(path string) []string {
+	trimmed := strings.Trim(path, "/")
+	if trimmed == "" {
+		return nil
+	}
+	return strings.Split(trimmed, "/")
+}
@@ -0,0 +1,286 @@
+package middleware
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go-api-proxy/client"
+	"go-api-proxy/config"
+	"go-api-proxy/models"
+)
+
+// recordingHandler records the last request it served
+type recordingHandler struct {
+	name    string
+	request *http.Request
+}
+
+func (h *recordingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.request = r
+	w.Header().Set("X-Handler", h.name)
+	w.WriteHeader(http.StatusOK)
+}
+
+func newTestRouter(t *testing.T, routes []config.Route) (*Router, *recordingHandler, *recordingHandler) {
+	t.Helper()
+
+	passthrough := &recordingHandler{name: "passthrough"}
+	tokenFilter := &recordingHandler{name: "token-filter"}
+
+	router, err := NewRouter(routes, map[string]http.Handler{
+		config.HandlerPassthrough: passthrough,
+		config.HandlerTokenFilter: tokenFilter,
+		config.HandlerWebSocket:   &recordingHandler{name: "websocket"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create router: %v", err)
+	}
+	return router, passthrough, tokenFilter
+}
+
+func TestRouter_Match(t *testing.T) {
+	router, _, _ := newTestRouter(t, []config.Route{
+		{Name: "tokens", Pattern: "/api/v2/tokens", Handler: config.HandlerTokenFilter},
+		{Name: "block", Pattern: "/api/v2/blocks/{number}", Handler: config.HandlerPassthrough},
+		{Name: "api", Pattern: "/api/v2/*", Handler: config.HandlerPassthrough},
+	})
+
+	tests := []struct {
+		path          string
+		expectedRoute string
+		expectedParam string
+	}{
+		{"/api/v2/tokens", "tokens", ""},
+		{"/api/v2/tokens/", "tokens", ""},
+		{"/api/v2/blocks/42", "block", "42"},
+		{"/api/v2/blocks/42/transactions", "api", ""},
+		{"/api/v2", "api", ""},
+		{"/other", "", ""},
+		{"", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			match, ok := router.Match(tt.path)
+			if tt.expectedRoute == "" {
+				if ok {
+					t.Errorf("Expected no match, got route %s", match.Route.Name)
+				}
+				return
+			}
+			if !ok {
+				t.Fatalf("Expected route %s, got no match", tt.expectedRoute)
+			}
+			if match.Route.Name != tt.expectedRoute {
+				t.Errorf("Expected route %s, got %s", tt.expectedRoute, match.Route.Name)
+			}
+			if tt.expectedParam != "" && match.Params["number"] != tt.expectedParam {
+				t.Errorf("Expected param number=%s, got %s", tt.expectedParam, match.Params["number"])
+			}
+		})
+	}
+}
+
+func TestRouter_ServeHTTP_Dispatch(t *testing.T) {
+	router, passthrough, tokenFilter := newTestRouter(t, config.DefaultRoutes())
+
+	req := httptest.NewRequest("GET", "/api/v2/tokens?limit=10", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Header().Get("X-Handler") != "token-filter" {
+		t.Errorf("Expected token-filter handler, got %s", w.Header().Get("X-Handler"))
+	}
+	if tokenFilter.request.URL.RawQuery != "limit=10" {
+		t.Errorf("Expected query to be preserved, got %s", tokenFilter.request.URL.RawQuery)
+	}
+
+	req = httptest.NewRequest("GET", "/api/v2/blocks/1?type=full", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Header().Get("X-Handler") != "passthrough" {
+		t.Errorf("Expected passthrough handler, got %s", w.Header().Get("X-Handler"))
+	}
+	if passthrough.request.URL.Path != "/blocks/1" {
+		t.Errorf("Expected /api/v2 prefix to be stripped, got %s", passthrough.request.URL.Path)
+	}
+	if passthrough.request.URL.RawQuery != "type=full" {
+		t.Errorf("Expected query to be preserved, got %s", passthrough.request.URL.RawQuery)
+	}
+	if match := GetRouteMatchFromContext(passthrough.request.Context()); match == nil || match.Route.Name != "block" {
+		t.Errorf("Expected route match block in context, got %+v", match)
+	}
+}
+
+func TestRouter_ServeHTTP_AddPrefix(t *testing.T) {
+	router, passthrough, _ := newTestRouter(t, []config.Route{
+		{Name: "legacy", Pattern: "/legacy/*", Handler: config.HandlerPassthrough, StripPrefix: "/legacy", AddPrefix: "/v1"},
+	})
+
+	req := httptest.NewRequest("GET", "/legacy/stats", nil)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	if passthrough.request.URL.Path != "/v1/stats" {
+		t.Errorf("Expected rewritten path /v1/stats, got %s", passthrough.request.URL.Path)
+	}
+}
+
+func TestRouter_ServeHTTP_MethodNotAllowed(t *testing.T) {
+	router, _, _ := newTestRouter(t, config.DefaultRoutes())
+
+	req := httptest.NewRequest("POST", "/api/v2/tokens", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405, got %d", w.Code)
+	}
+	if w.Header().Get("Allow") != "GET, HEAD" {
+		t.Errorf("Expected Allow header 'GET, HEAD', got %q", w.Header().Get("Allow"))
+	}
+
+	var errorResp models.ErrorResponse
+	if err := json.NewDecoder(w.Body).Decode(&errorResp); err != nil {
+		t.Fatalf("Failed to decode error response: %v", err)
+	}
+	if errorResp.Error != "Method not allowed" {
+		t.Errorf("Expected 'Method not allowed' error, got %q", errorResp.Error)
+	}
+}
+
+func TestRouter_ServeHTTP_StaticAndNotFound(t *testing.T) {
+	router, _, _ := newTestRouter(t, []config.Route{
+		{
+			Name:    "robots",
+			Pattern: "/robots.txt",
+			Handler: config.HandlerStatic,
+			Static:  &config.StaticResponse{ContentType: "text/plain", Body: "User-agent: *\nDisallow: /"},
+		},
+	})
+
+	req := httptest.NewRequest("GET", "/robots.txt", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+	if w.Header().Get("Content-Type") != "text/plain" {
+		t.Errorf("Expected text/plain content type, got %s", w.Header().Get("Content-Type"))
+	}
+	if w.Body.String() != "User-agent: *\nDisallow: /" {
+		t.Errorf("Unexpected static body: %q", w.Body.String())
+	}
+
+	req = httptest.NewRequest("GET", "/missing", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+}
+
+func TestNewRouter_InvalidRoutes(t *testing.T) {
+	tests := []struct {
+		name  string
+		route config.Route
+	}{
+		{"unknown handler", config.Route{Name: "bad", Pattern: "/x", Handler: "unknown"}},
+		{"static without response", config.Route{Name: "bad", Pattern: "/x", Handler: config.HandlerStatic}},
+		{"relative pattern", config.Route{Name: "bad", Pattern: "x", Handler: config.HandlerPassthrough}},
+		{"wildcard in middle", config.Route{Name: "bad", Pattern: "/x/*/y", Handler: config.HandlerPassthrough}},
+		{"credentials without key file", config.Route{Name: "bad", Pattern: "/x", Handler: config.HandlerPassthrough, Credentials: &config.Credentials{}}},
+		{"missing key file", config.Route{Name: "bad", Pattern: "/x", Handler: config.HandlerStatic, Static: &config.StaticResponse{}, Credentials: &config.Credentials{KeyFile: "/nonexistent/apikey"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewRouter([]config.Route{tt.route}, map[string]http.Handler{}); err == nil {
+				t.Error("Expected error for invalid route")
+			}
+		})
+	}
+
+	_, err := NewRouter([]config.Route{{Name: "tokens", Pattern: "/t", Handler: config.HandlerTokenFilter}}, map[string]http.Handler{})
+	if err == nil {
+		t.Error("Expected error for route without registered handler")
+	}
+}
+
+func TestRouter_ServeHTTP_BodyLimit(t *testing.T) {
+	router, passthrough, _ := newTestRouter(t, []config.Route{
+		{Name: "upload", Pattern: "/upload", Handler: config.HandlerPassthrough, MaxBodyBytes: 8},
+	})
+
+	t.Run("declared length over limit is rejected", func(t *testing.T) {
+		passthrough.request = nil
+		req := httptest.NewRequest("POST", "/upload", strings.NewReader("0123456789"))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("Expected status 413, got %d", w.Code)
+		}
+		if passthrough.request != nil {
+			t.Error("Expected oversized request not to reach the handler")
+		}
+
+		var errorResp models.ErrorResponse
+		if err := json.NewDecoder(w.Body).Decode(&errorResp); err != nil {
+			t.Fatalf("Failed to decode error response: %v", err)
+		}
+		if errorResp.Error != "Request entity too large" {
+			t.Errorf("Expected 'Request entity too large' error, got %q", errorResp.Error)
+		}
+	})
+
+	t.Run("body is wrapped with a limited reader", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/upload", strings.NewReader("0123456789"))
+		req.ContentLength = -1
+		router.ServeHTTP(httptest.NewRecorder(), req)
+
+		if passthrough.request == nil {
+			t.Fatal("Expected request to reach the handler")
+		}
+		if _, err := io.ReadAll(passthrough.request.Body); err == nil {
+			t.Error("Expected reading past the limit to fail")
+		}
+	})
+}
+
+func TestRouter_ServeHTTP_ChunkedBodyOverLimit(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	httpClient := client.NewHTTPClient(&config.Config{BackendHost: backend.URL, Timeout: 5 * time.Second})
+	router, err := NewRouter([]config.Route{
+		{Name: "upload", Pattern: "/upload", Handler: config.HandlerPassthrough, MaxBodyBytes: 8},
+	}, map[string]http.Handler{
+		config.HandlerPassthrough: NewStandardProxyHandler(httpClient),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create router: %v", err)
+	}
+
+	req := httptest.NewRequest("POST", "/upload", io.NopCloser(strings.NewReader(strings.Repeat("x", 64))))
+	req.ContentLength = -1
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status 413, got %d", w.Code)
+	}
+	if w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Expected JSON error, got Content-Type %s", w.Header().Get("Content-Type"))
+	}
+}
@@ -3,7 +3,10 @@ package middleware
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
+	"sync"
 	"time"
 
 	"go-api-proxy/client"
@@ -20,6 +23,19 @@ type HTTPClientInterface interface {
 type TokenFilterHandler struct {
 	httpClient HTTPClientInterface
 	whitelist  *models.TokenWhitelist
+	now        func() time.Time
+
+	cacheMu sync.Mutex
+	cached  map[string]*filteredTokens // keyed by route and target backend
+}
+
+// filteredTokens is a filtered token list kept for routes with a cache TTL
+type filteredTokens struct {
+	response         *models.TokenResponse
+	originalCount    int
+	upstream         string
+	storedAt         time.Time
+	whitelistVersion uint64
 }
 
 // NewTokenFilterHandler creates a new token filter handler
@@ -27,6 +43,8 @@ func NewTokenFilterHandler(httpClient HTTPClientInterface, whitelist *models.Tok
 	return &TokenFilterHandler{
 		httpClient: httpClient,
 		whitelist:  whitelist,
+		now:        time.Now,
+		cached:     make(map[string]*filteredTokens),
 	}
 }
 
@@ -36,7 +54,7 @@ func (h *TokenFilterHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	
 	// Create context with timeout
-	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(client.WithUpstreamTracking(r.Context()), 30*time.Second)
 	defer cancel()
 	
 	requestID := getRequestIDFromContext(ctx)
@@ -44,35 +62,123 @@ func (h *TokenFilterHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	
 	middlewareLogger.Debug("Processing token filter request")
 	
-	// Fetch tokens from backend API
-	tokenResponse, err := h.httpClient.GetTokens(ctx)
-	if err != nil {
-		middlewareLogger.Error("Failed to fetch tokens from backend", err)
-		h.handleError(w, r, err)
-		return
+	// Serve the filtered list from cache when the route has a cache TTL
+	ttl, cacheKey := tokenCacheTTL(r), tokenCacheKey(r)
+	tokens := h.cachedTokens(cacheKey, ttl)
+	if tokens != nil {
+		w.Header().Set("X-Cache", "HIT")
+	} else {
+		// Fetch tokens from backend API
+		tokenResponse, err := h.httpClient.GetTokens(ctx)
+		if err != nil {
+			if servedBy := client.ServedBy(ctx); servedBy != "" {
+				w.Header().Set("X-Upstream", servedBy)
+			}
+			middlewareLogger.Error("Failed to fetch tokens from backend", err)
+			h.handleError(w, r, err)
+			return
+		}
+		
+		middlewareLogger.Debug("Fetched tokens from backend", map[string]interface{}{
+			"token_count": len(tokenResponse.Items),
+		})
+		
+		// Filter tokens against whitelist
+		version := h.whitelist.Version()
+		tokens = &filteredTokens{
+			response:         h.filterTokens(tokenResponse, middlewareLogger),
+			originalCount:    len(tokenResponse.Items),
+			upstream:         client.ServedBy(ctx),
+			storedAt:         h.now(),
+			whitelistVersion: version,
+		}
+		if ttl > 0 {
+			h.storeTokens(cacheKey, tokens)
+			w.Header().Set("X-Cache", "MISS")
+		}
 	}
+	if tokens.upstream != "" {
+		w.Header().Set("X-Upstream", tokens.upstream)
+	}
+	filteredResponse := tokens.response
 	
-	middlewareLogger.Debug("Fetched tokens from backend", map[string]interface{}{
-		"token_count": len(tokenResponse.Items),
-	})
-	
-	// Filter tokens against whitelist
-	filteredResponse := h.filterTokens(tokenResponse, middlewareLogger)
+	// Optionally enrich tokens with decimal-normalised fields
+	var payload interface{} = filteredResponse
+	if isNormalizeRequested(r) {
+		middlewareLogger.Debug("Normalizing token amounts")
+		payload = models.NormalizeTokenResponse(filteredResponse)
+	}
 	
 	// Return filtered response
-	if err := json.NewEncoder(w).Encode(filteredResponse); err != nil {
+	if err := json.NewEncoder(w).Encode(payload); err != nil {
 		middlewareLogger.Error("Error encoding filtered token response", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 	
 	middlewareLogger.Info("Successfully filtered and returned tokens", map[string]interface{}{
-		"original_count": len(tokenResponse.Items),
+		"original_count": tokens.originalCount,
 		"filtered_count": len(filteredResponse.Items),
 		"whitelist_size": h.whitelist.Size(),
 	})
 }
 
+// cachedTokens returns the cached filtered token list for key if it is younger than
+// ttl and was filtered against the current whitelist
+func (h *TokenFilterHandler) cachedTokens(key string, ttl time.Duration) *filteredTokens {
+	if ttl <= 0 {
+		return nil
+	}
+	
+	h.cacheMu.Lock()
+	defer h.cacheMu.Unlock()
+	
+	cached, ok := h.cached[key]
+	if !ok {
+		return nil
+	}
+	if h.now().Sub(cached.storedAt) >= ttl || cached.whitelistVersion != h.whitelist.Version() {
+		delete(h.cached, key)
+		return nil
+	}
+	return cached
+}
+
+// storeTokens caches a filtered token list under key
+func (h *TokenFilterHandler) storeTokens(key string, tokens *filteredTokens) {
+	h.cacheMu.Lock()
+	defer h.cacheMu.Unlock()
+	h.cached[key] = tokens
+}
+
+// InvalidateCache drops every cached filtered token list. It reports whether an entry was dropped.
+func (h *TokenFilterHandler) InvalidateCache() bool {
+	h.cacheMu.Lock()
+	defer h.cacheMu.Unlock()
+	
+	invalidated := len(h.cached) > 0
+	h.cached = make(map[string]*filteredTokens)
+	return invalidated
+}
+
+// tokenCacheTTL returns the cache TTL of the route that matched the request
+func tokenCacheTTL(r *http.Request) time.Duration {
+	if match := GetRouteMatchFromContext(r.Context()); match != nil {
+		return time.Duration(match.Route.CacheTTL) * time.Second
+	}
+	return 0
+}
+
+// tokenCacheKey identifies the filtered list cached for the request. Routes with
+// their own backend or credentials get separate entries, so one route never serves
+// another backend's token list.
+func tokenCacheKey(r *http.Request) string {
+	if match := GetRouteMatchFromContext(r.Context()); match != nil {
+		return match.Route.Name + "|" + match.Route.Backend
+	}
+	return ""
+}
+
 // filterTokens filters the token response against the whitelist
 func (h *TokenFilterHandler) filterTokens(response *models.TokenResponse, logger *logger.Logger) *models.TokenResponse {
 	if response == nil || len(response.Items) == 0 {
@@ -98,11 +204,11 @@ func (h *TokenFilterHandler) filterTokens(response *models.TokenResponse, logger
 	matchedAddresses := make([]string, 0)
 	
 	for _, token := range response.Items {
-		if h.whitelist.Contains(token.Address) {
+		if address, ok := whitelistMatch(h.whitelist, token.Address, token.AddressHash); ok {
 			// Apply custom properties from whitelist
-			modifiedToken := h.applyWhitelistProperties(token)
+			modifiedToken := h.applyWhitelistProperties(token, address)
 			filteredTokens = append(filteredTokens, modifiedToken)
-			matchedAddresses = append(matchedAddresses, token.Address)
+			matchedAddresses = append(matchedAddresses, address)
 		}
 	}
 	
@@ -115,10 +221,26 @@ func (h *TokenFilterHandler) filterTokens(response *models.TokenResponse, logger
 	return &models.TokenResponse{Items: filteredTokens}
 }
 
-// applyWhitelistProperties applies custom properties from whitelist to a token
-func (h *TokenFilterHandler) applyWhitelistProperties(token models.Token) models.Token {
+// whitelistMatch is the whitelist matching rule shared by the REST and WebSocket token
+// filters. A token is matched by its address, or by its address_hash when the backend
+// reports no address. It returns the matched address and whether it is whitelisted.
+func whitelistMatch(whitelist *models.TokenWhitelist, address, addressHash string) (string, bool) {
+	if address == "" {
+		address = addressHash
+	}
+	return address, address != "" && whitelist.Contains(address)
+}
+
+// isNormalizeRequested reports whether the client opted in to normalised amounts via ?normalize=true
+func isNormalizeRequested(r *http.Request) bool {
+	normalize, err := strconv.ParseBool(r.URL.Query().Get("normalize"))
+	return err == nil && normalize
+}
+
+// applyWhitelistProperties applies custom properties from whitelist to a token matched by address
+func (h *TokenFilterHandler) applyWhitelistProperties(token models.Token, address string) models.Token {
 	// Get whitelist token info
-	whitelistToken := h.whitelist.GetTokenInfo(token.Address)
+	whitelistToken := h.whitelist.GetTokenInfo(address)
 	if whitelistToken == nil {
 		return token
 	}
@@ -137,7 +259,7 @@ func (h *TokenFilterHandler) applyWhitelistProperties(token models.Token) models
 		
 		// Log the replacement for debugging (using INFO level to ensure it shows)
 		logger.MiddlewareLogger.Info("Replaced token icon_url from whitelist", map[string]interface{}{
-			"address":          token.Address,
+			"address":          address,
 			"original_icon":    originalIcon,
 			"whitelist_icon":   *whitelistToken.IconURL,
 		})
@@ -156,6 +278,17 @@ func (h *TokenFilterHandler) handleError(w http.ResponseWriter, r *http.Request,
 		"path":   r.URL.Path,
 	})
 	
+	// Check if the backend circuit breaker rejected the request
+	var circuitErr *client.CircuitOpenError
+	if errors.As(err, &circuitErr) {
+		middlewareLogger.Warn("Backend circuit breaker open", map[string]interface{}{
+			"error_type":  "circuit_open",
+			"retry_after": circuitErr.RetryAfterSeconds(),
+		})
+		writeCircuitOpen(w, circuitErr)
+		return
+	}
+	
 	// Check if it's a network error (backend unreachable)
 	if client.IsNetworkError(err) {
 		middlewareLogger.Warn("Backend API unreachable for token request", map[string]interface{}{
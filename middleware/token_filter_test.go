@@ -6,6 +6,7 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -436,4 +437,150 @@ func TestTokenFilterHandler_Integration(t *testing.T) {
 	if response.Items[0].Address != "0x1234" {
 		t.Errorf("Expected address 0x1234, got %s", response.Items[0].Address)
 	}
-}
\ No newline at end of file
+}
+
+func TestTokenFilterHandler_ServeHTTP_Normalize(t *testing.T) {
+	rate := "2"
+	mockClient := &mockHTTPClient{
+		tokenResponse: &models.TokenResponse{
+			Items: []models.Token{
+				{Address: "0x1234", Decimals: "18", TotalSupply: "2500000000000000000", ExchangeRate: &rate},
+			},
+		},
+	}
+	handler := NewTokenFilterHandler(mockClient, models.NewTokenWhitelist())
+
+	t.Run("normalize=true adds derived fields", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/v2/tokens?normalize=true", nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		var response models.NormalizedTokenResponse
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if len(response.Items) != 1 {
+			t.Fatalf("Expected 1 token, got %d", len(response.Items))
+		}
+
+		item := response.Items[0]
+		if item.TotalSupplyFormatted == nil || *item.TotalSupplyFormatted != "2.5" {
+			t.Errorf("Expected total_supply_formatted 2.5, got %v", item.TotalSupplyFormatted)
+		}
+		if item.MarketCapUSD == nil || *item.MarketCapUSD != "5.00" {
+			t.Errorf("Expected market_cap_usd 5.00, got %v", item.MarketCapUSD)
+		}
+	})
+
+	t.Run("default response has no derived fields", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/v2/tokens", nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		var fields struct {
+			Items []map[string]interface{} `json:"items"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&fields); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if _, ok := fields.Items[0]["total_supply_formatted"]; ok {
+			t.Error("Expected total_supply_formatted to be absent without normalize=true")
+		}
+	})
+}
+
+// countingTokenClient counts GetTokens calls
+type countingTokenClient struct {
+	mockHTTPClient
+	calls int
+}
+
+func (c *countingTokenClient) GetTokens(ctx context.Context) (*models.TokenResponse, error) {
+	c.calls++
+	return c.mockHTTPClient.GetTokens(ctx)
+}
+
+func TestTokenFilterHandler_CachesFilteredTokens(t *testing.T) {
+	mockClient := &countingTokenClient{mockHTTPClient: mockHTTPClient{
+		tokenResponse: &models.TokenResponse{
+			Items: []models.Token{{Address: "0x1234", Name: "Token1"}},
+		},
+	}}
+	handler := NewTokenFilterHandler(mockClient, models.NewTokenWhitelist())
+	now := time.Now()
+	handler.now = func() time.Time { return now }
+
+	serve := func(ttl int) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/v2/tokens", nil)
+		match := &RouteMatch{Route: &config.Route{Name: "tokens", CacheTTL: ttl}}
+		req = req.WithContext(context.WithValue(req.Context(), "route_match", match))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	serve(0)
+	serve(0)
+	if mockClient.calls != 2 {
+		t.Errorf("Expected no caching without a route TTL, got %d calls", mockClient.calls)
+	}
+
+	first := serve(30)
+	second := serve(30)
+	if mockClient.calls != 3 {
+		t.Errorf("Expected filtered tokens to be cached, got %d calls", mockClient.calls)
+	}
+	if first.Header().Get("X-Cache") != "MISS" || second.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("Expected MISS then HIT, got %s then %s", first.Header().Get("X-Cache"), second.Header().Get("X-Cache"))
+	}
+	if first.Body.String() != second.Body.String() {
+		t.Errorf("Expected identical cached body, got %q and %q", first.Body.String(), second.Body.String())
+	}
+
+	if !handler.InvalidateCache() {
+		t.Error("Expected invalidation to drop the cached list")
+	}
+	serve(30)
+	now = now.Add(31 * time.Second)
+	serve(30)
+	if mockClient.calls != 5 {
+		t.Errorf("Expected refetch after invalidation and expiry, got %d calls", mockClient.calls)
+	}
+}
+
+func TestTokenFilterHandler_CacheIsPerRouteAndWhitelistVersion(t *testing.T) {
+	mockClient := &countingTokenClient{mockHTTPClient: mockHTTPClient{
+		tokenResponse: &models.TokenResponse{
+			Items: []models.Token{{Address: "0x1234", Name: "Token1"}, {Address: "0x5678", Name: "Token2"}},
+		},
+	}}
+	whitelist := models.NewTokenWhitelist()
+	whitelist.AddAddress("0x1234")
+	handler := NewTokenFilterHandler(mockClient, whitelist)
+
+	serve := func(route, backend string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/v2/tokens", nil)
+		match := &RouteMatch{Route: &config.Route{Name: route, Backend: backend, CacheTTL: 30}}
+		req = req.WithContext(context.WithValue(req.Context(), "route_match", match))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	serve("tokens", "")
+	serve("tokens", "")
+	serve("partner-tokens", "https://partner.example.com")
+	if mockClient.calls != 2 {
+		t.Errorf("Expected one cache entry per route and backend, got %d calls", mockClient.calls)
+	}
+	if w := serve("partner-tokens", "https://partner.example.com"); w.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("Expected the second route to hit its own entry, got %s", w.Header().Get("X-Cache"))
+	}
+
+	// Changing the whitelist makes every cached list stale
+	whitelist.AddAddress("0x5678")
+	w := serve("tokens", "")
+	if w.Header().Get("X-Cache") != "MISS" || !strings.Contains(w.Body.String(), "0x5678") {
+		t.Errorf("Expected a refiltered list after the whitelist changed, got %s %q", w.Header().Get("X-Cache"), w.Body.String())
+	}
+}
@@ -0,0 +1,194 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// URLRewriter replaces absolute URLs pointing at a backend origin with the public
+// origin of the proxy, so backend addresses do not leak to clients
+type URLRewriter struct {
+	public  string
+	origins []string
+	hosts   [][]byte
+}
+
+// rewrittenHeaders are the response headers that may carry absolute backend URLs
+var rewrittenHeaders = []string{"Location", "Link"}
+
+// NewURLRewriter creates a rewriter for the given backend URLs. Only the scheme and
+// host of each URL are used. It returns nil when no public origin is configured.
+func NewURLRewriter(publicOrigin string, backends []string) (*URLRewriter, error) {
+	if publicOrigin == "" {
+		return nil, nil
+	}
+	public, err := parseOrigin(publicOrigin)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public origin: %w", err)
+	}
+
+	rw := &URLRewriter{public: public}
+	for _, backend := range backends {
+		origin, err := parseOrigin(backend)
+		if err != nil {
+			return nil, fmt.Errorf("invalid backend origin: %w", err)
+		}
+		if origin == public || containsString(rw.origins, origin) {
+			continue
+		}
+		rw.origins = append(rw.origins, origin)
+		rw.hosts = append(rw.hosts, []byte(origin[strings.Index(origin, "://")+3:]))
+	}
+	return rw, nil
+}
+
+// parseOrigin returns the lower-cased scheme://host[:port] of an http(s) URL
+func parseOrigin(raw string) (string, error) {
+	parsed, err := url.Parse(raw)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return "", fmt.Errorf("%q is not an http or https URL", raw)
+	}
+	return strings.ToLower(parsed.Scheme + "://" + parsed.Host), nil
+}
+
+// RewriteString replaces backend origins in s, reporting whether anything changed.
+// An origin only matches when it is not followed by more of a host name or port,
+// so "https://backend" does not match "https://backend.example.com".
+func (rw *URLRewriter) RewriteString(s string) (string, bool) {
+	changed := false
+	for _, origin := range rw.origins {
+		var b strings.Builder
+		rest := s
+		for {
+			idx := indexFold(rest, origin)
+			if idx == -1 {
+				break
+			}
+			end := idx + len(origin)
+			b.WriteString(rest[:idx])
+			if end < len(rest) && isHostChar(rest[end]) {
+				b.WriteString(rest[idx:end])
+			} else {
+				b.WriteString(rw.public)
+				changed = true
+			}
+			rest = rest[end:]
+		}
+		if b.Len() > 0 {
+			b.WriteString(rest)
+			s = b.String()
+		}
+	}
+	return s, changed
+}
+
+// RewriteHeaders replaces backend origins in the Location and Link headers
+func (rw *URLRewriter) RewriteHeaders(header http.Header) {
+	for _, name := range rewrittenHeaders {
+		values := header.Values(name)
+		for i, value := range values {
+			values[i], _ = rw.RewriteString(value)
+		}
+	}
+}
+
+// RewriteJSON replaces backend origins in the string values of a JSON document.
+// Object keys, numbers and formatting are left untouched; bodies that are not valid
+// JSON are only rewritten inside what look like complete string literals.
+func (rw *URLRewriter) RewriteJSON(body []byte) []byte {
+	if !rw.mentionsBackend(body) {
+		return body
+	}
+
+	var out []byte
+	last := 0
+	for i := 0; i < len(body); {
+		if body[i] != '"' {
+			i++
+			continue
+		}
+
+		start := i
+		escaped := false
+		for i++; i < len(body) && body[i] != '"'; i++ {
+			if body[i] == '\\' {
+				escaped = true
+				i++
+			}
+		}
+		if i >= len(body) {
+			break
+		}
+		i++
+		literal := body[start:i]
+
+		if isObjectKey(body[i:]) {
+			continue
+		}
+		value := string(literal[1 : len(literal)-1])
+		if escaped {
+			if err := json.Unmarshal(literal, &value); err != nil {
+				continue
+			}
+		}
+		rewritten, changed := rw.RewriteString(value)
+		if !changed {
+			continue
+		}
+
+		out = append(out, body[last:start]...)
+		out = append(out, encodeJSONString(rewritten)...)
+		last = i
+	}
+
+	if out == nil {
+		return body
+	}
+	return append(out, body[last:]...)
+}
+
+// mentionsBackend is a quick check for any backend host in the body. Hosts are not
+// affected by JSON escaping of slashes, unlike full origins.
+func (rw *URLRewriter) mentionsBackend(body []byte) bool {
+	lowered := bytes.ToLower(body)
+	for _, host := range rw.hosts {
+		if bytes.Contains(lowered, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// isObjectKey reports whether a string literal followed by rest is an object key
+func isObjectKey(rest []byte) bool {
+	rest = bytes.TrimLeft(rest, " \t\r\n")
+	return len(rest) > 0 && rest[0] == ':'
+}
+
+// encodeJSONString encodes s as a JSON string without escaping HTML characters
+func encodeJSONString(s string) []byte {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(s)
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+}
+
+// indexFold returns the index of the first case-insensitive match of substr in s
+func indexFold(s, substr string) int {
+	for i := 0; i+len(substr) <= len(s); i++ {
+		if strings.EqualFold(s[i:i+len(substr)], substr) {
+			return i
+		}
+	}
+	return -1
+}
+
+// isHostChar reports whether c can continue a host name or port
+func isHostChar(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '.' || c == '-' || c == ':'
+}
@@ -0,0 +1,130 @@
+package middleware
+
+import (
+	"net/http"
+	"testing"
+)
+
+func newTestURLRewriter(t *testing.T) *URLRewriter {
+	t.Helper()
+
+	rewriter, err := NewURLRewriter("https://api.example.com/", []string{
+		"http://blockscout.internal:4000/api/v2",
+		"https://exp.internal",
+		"https://api.example.com",
+	})
+	if err != nil {
+		t.Fatalf("NewURLRewriter failed: %v", err)
+	}
+	return rewriter
+}
+
+func TestURLRewriter_RewriteString(t *testing.T) {
+	rewriter := newTestURLRewriter(t)
+
+	tests := []struct {
+		input    string
+		expected string
+		changed  bool
+	}{
+		{"http://blockscout.internal:4000/api/v2/blocks/1", "https://api.example.com/api/v2/blocks/1", true},
+		{"HTTPS://EXP.INTERNAL/icons/eth.png", "https://api.example.com/icons/eth.png", true},
+		{"https://exp.internal", "https://api.example.com", true},
+		{"https://exp.internal?x=1", "https://api.example.com?x=1", true},
+		{"see https://exp.internal/a and https://exp.internal/b", "see https://api.example.com/a and https://api.example.com/b", true},
+		{"https://exp.internal.evil.com/x", "https://exp.internal.evil.com/x", false},
+		{"https://exp.internal:8443/x", "https://exp.internal:8443/x", false},
+		{"http://exp.internal/x", "http://exp.internal/x", false},
+		{"http://blockscout.internal/x", "http://blockscout.internal/x", false},
+		{"https://cdn.example.org/x.png", "https://cdn.example.org/x.png", false},
+	}
+
+	for _, tt := range tests {
+		result, changed := rewriter.RewriteString(tt.input)
+		if result != tt.expected || changed != tt.changed {
+			t.Errorf("RewriteString(%q) = %q, %v; expected %q, %v", tt.input, result, changed, tt.expected, tt.changed)
+		}
+	}
+}
+
+func TestURLRewriter_RewriteJSON(t *testing.T) {
+	rewriter := newTestURLRewriter(t)
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "string values",
+			input:    `{"icon_url": "https://exp.internal/icons/a.png", "count": 2, "items": ["https://exp.internal/x", null]}`,
+			expected: `{"icon_url": "https://api.example.com/icons/a.png", "count": 2, "items": ["https://api.example.com/x", null]}`,
+		},
+		{
+			name:     "keys are not rewritten",
+			input:    `{"https://exp.internal/a" : "https://exp.internal/b"}`,
+			expected: `{"https://exp.internal/a" : "https://api.example.com/b"}`,
+		},
+		{
+			name:     "escaped slashes and quotes",
+			input:    `{"url":"https:\/\/exp.internal\/a?q=\"x\"&b=<c>"}`,
+			expected: `{"url":"https://api.example.com/a?q=\"x\"&b=<c>"}`,
+		},
+		{
+			name:     "no backend mentioned",
+			input:    `{"url": "https://cdn.example.org/a"}`,
+			expected: `{"url": "https://cdn.example.org/a"}`,
+		},
+		{
+			name:     "host mentioned outside a URL",
+			input:    `{"name": "exp.internal"}`,
+			expected: `{"name": "exp.internal"}`,
+		},
+		{
+			name:     "unterminated string",
+			input:    `{"a": "https://exp.internal/x", "b": "https://exp.internal`,
+			expected: `{"a": "https://api.example.com/x", "b": "https://exp.internal`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := string(rewriter.RewriteJSON([]byte(tt.input))); result != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestURLRewriter_RewriteHeaders(t *testing.T) {
+	rewriter := newTestURLRewriter(t)
+
+	header := http.Header{}
+	header.Set("Location", "https://exp.internal/api/v2/blocks/1")
+	header.Add("Link", `<https://exp.internal/api/v2/blocks?page=2>; rel="next"`)
+	header.Add("Link", `<https://exp.internal/api/v2/blocks?page=9>; rel="last"`)
+	header.Set("X-Backend", "https://exp.internal")
+	rewriter.RewriteHeaders(header)
+
+	if header.Get("Location") != "https://api.example.com/api/v2/blocks/1" {
+		t.Errorf("Unexpected Location %q", header.Get("Location"))
+	}
+	if links := header.Values("Link"); links[0] != `<https://api.example.com/api/v2/blocks?page=2>; rel="next"` || links[1] != `<https://api.example.com/api/v2/blocks?page=9>; rel="last"` {
+		t.Errorf("Unexpected Link headers %v", links)
+	}
+	if header.Get("X-Backend") != "https://exp.internal" {
+		t.Error("Expected other headers to be left alone")
+	}
+}
+
+func TestNewURLRewriter(t *testing.T) {
+	if rewriter, err := NewURLRewriter("", []string{"https://exp.internal"}); rewriter != nil || err != nil {
+		t.Errorf("Expected no rewriter without a public origin, got %v, %v", rewriter, err)
+	}
+	if _, err := NewURLRewriter("api.example.com", nil); err == nil {
+		t.Error("Expected an error for a public origin without a scheme")
+	}
+	if _, err := NewURLRewriter("https://api.example.com", []string{"ftp://exp.internal"}); err == nil {
+		t.Error("Expected an error for a non-HTTP backend")
+	}
+}
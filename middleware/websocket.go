@@ -0,0 +1,603 @@
+package middleware
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go-api-proxy/client"
+	"go-api-proxy/config"
+	"go-api-proxy/logger"
+	"go-api-proxy/tlsutil"
+)
+
+// wsCloseGracePeriod is how long the proxy waits for both peers to answer a close
+// frame it initiated before dropping the connections
+const wsCloseGracePeriod = 5 * time.Second
+
+// webSocketForwardHeaders are the handshake headers passed on to the backend.
+// Sec-WebSocket-Extensions is deliberately not forwarded so frames stay uncompressed.
+var webSocketForwardHeaders = []string{
+	"Sec-WebSocket-Key",
+	"Sec-WebSocket-Version",
+	"Sec-WebSocket-Protocol",
+	"Origin",
+	"Cookie",
+	"User-Agent",
+	"Accept-Language",
+}
+
+// WebSocketProxy tunnels WebSocket connections, such as Blockscout's Phoenix channels
+// at /socket/v2/websocket, to the backend. Frames are relayed one at a time so the
+// proxy can close both sides cleanly on idle timeout or shutdown.
+type WebSocketProxy struct {
+	backendHost      string
+	allowedOrigins   []string
+	idleTimeout      time.Duration
+	maxConnections   int
+	maxFrameBytes    int64
+	handshakeTimeout time.Duration
+	tokenFilter      *TokenEventFilter
+	backendTLS       *tlsutil.ClientTLS
+	backends         BackendPicker
+
+	mu       sync.Mutex
+	slots    int // connections being set up or open, counted against maxConnections
+	tunnels  map[*wsTunnel]struct{}
+	shutdown bool
+}
+
+// BackendPicker chooses the backend for a tunnel, such as a pool upstream or the active
+// failover backend. done is called when the tunnel ends.
+type BackendPicker interface {
+	PickBackend() (baseURL string, done func())
+}
+
+// NewWebSocketProxy creates a WebSocket tunnel handler. Connections go to the route's
+// backend when it sets one, otherwise to the backend chosen by SetBackendPicker or the
+// configured backend host. Text messages
+// from the backend are passed through the token filter, which may be nil. TLS backends
+// are dialed with the backend TLS settings shared with the HTTP client.
+func NewWebSocketProxy(cfg *config.Config, tokenFilter *TokenEventFilter, backendTLS *tlsutil.ClientTLS) *WebSocketProxy {
+	return &WebSocketProxy{
+		backendHost:      strings.TrimSuffix(cfg.BackendHost, "/"),
+		allowedOrigins:   cfg.WebSocketAllowedOrigins,
+		idleTimeout:      cfg.WebSocketIdleTimeout,
+		maxConnections:   cfg.WebSocketMaxConnections,
+		maxFrameBytes:    cfg.WebSocketMaxFrameBytes,
+		handshakeTimeout: cfg.Timeout,
+		tokenFilter:      tokenFilter,
+		backendTLS:       backendTLS,
+		tunnels:          make(map[*wsTunnel]struct{}),
+	}
+}
+
+// SetBackendPicker makes tunnels without a route backend follow the HTTP client's
+// upstream pool and failover
+func (p *WebSocketProxy) SetBackendPicker(backends BackendPicker) {
+	p.backends = backends
+}
+
+// ActiveConnections returns the number of open WebSocket tunnels
+func (p *WebSocketProxy) ActiveConnections() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.tunnels)
+}
+
+// ServeHTTP implements the http.Handler interface for WebSocket upgrades
+func (p *WebSocketProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	requestID := getRequestIDFromContext(r.Context())
+	wsLogger := logger.MiddlewareLogger.WithRequestID(requestID)
+
+	if !isUpgradeRequest(r) || !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		w.Header().Set("Upgrade", "websocket")
+		writeJSONError(w, http.StatusUpgradeRequired, "Upgrade required", "This endpoint only accepts WebSocket connections")
+		return
+	}
+	if r.Method != http.MethodGet || r.Header.Get("Sec-WebSocket-Key") == "" || r.Header.Get("Sec-WebSocket-Version") != "13" {
+		writeJSONError(w, http.StatusBadRequest, "Bad request", "Invalid WebSocket handshake")
+		return
+	}
+
+	origin := r.Header.Get("Origin")
+	if !p.originAllowed(origin, r.Host) {
+		wsLogger.Warn("Rejected WebSocket origin", map[string]interface{}{
+			"origin":      origin,
+			"remote_addr": r.RemoteAddr,
+		})
+		writeJSONError(w, http.StatusForbidden, "Forbidden", "Origin not allowed")
+		return
+	}
+
+	if err := p.reserve(); err != nil {
+		wsLogger.Warn("Rejected WebSocket connection", map[string]interface{}{
+			"reason":      err.Error(),
+			"remote_addr": r.RemoteAddr,
+		})
+		w.Header().Set("Retry-After", "5")
+		writeJSONError(w, http.StatusServiceUnavailable, "Service unavailable", err.Error())
+		return
+	}
+	defer p.release()
+
+	baseURL, done := p.backendTarget(r)
+	defer done()
+
+	backendConn, backendReader, resp, err := p.dialBackend(r, baseURL)
+	if err != nil {
+		wsLogger.Error("WebSocket backend handshake failed", err, map[string]interface{}{
+			"path": r.URL.Path,
+		})
+		http.Error(w, "Bad Gateway: WebSocket backend unreachable", http.StatusBadGateway)
+		return
+	}
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		defer backendConn.Close()
+		wsLogger.Warn("WebSocket backend refused upgrade", map[string]interface{}{
+			"path":        r.URL.Path,
+			"status_code": resp.StatusCode,
+		})
+		p.relayRefusal(w, resp)
+		return
+	}
+	if resp.Header.Get("Sec-WebSocket-Accept") != websocketAcceptKey(r.Header.Get("Sec-WebSocket-Key")) {
+		backendConn.Close()
+		wsLogger.Error("WebSocket backend sent an invalid accept key", nil, map[string]interface{}{
+			"path": r.URL.Path,
+		})
+		http.Error(w, "Bad Gateway: Invalid WebSocket handshake from backend", http.StatusBadGateway)
+		return
+	}
+
+	clientConn, clientRW, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		backendConn.Close()
+		wsLogger.Error("Failed to take over WebSocket connection", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	// Clear the server's read and write timeouts, which would otherwise cut the tunnel short
+	clientConn.SetDeadline(time.Time{})
+
+	if err := writeSwitchingProtocols(clientRW.Writer, resp); err != nil {
+		clientConn.Close()
+		backendConn.Close()
+		wsLogger.Error("Failed to complete WebSocket handshake", err)
+		return
+	}
+
+	tunnel := newWSTunnel(clientConn, clientRW.Reader, backendConn, backendReader)
+	if !p.track(tunnel) {
+		tunnel.closeWith(wsCloseGoingAway, "server shutting down")
+		tunnel.forceClose()
+		return
+	}
+	defer p.untrack(tunnel)
+
+	wsLogger.Info("WebSocket connection established", map[string]interface{}{
+		"path":        r.URL.Path,
+		"origin":      origin,
+		"remote_addr": r.RemoteAddr,
+	})
+
+	start := time.Now()
+	reason := p.run(tunnel)
+
+	fields := map[string]interface{}{
+		"path":            r.URL.Path,
+		"duration":        time.Since(start).String(),
+		"frames_upstream": tunnel.framesUp.Load(),
+		"frames_down":     tunnel.framesDown.Load(),
+		"filtered":        tunnel.filtered.Load(),
+		"reason":          reason,
+	}
+	if code := tunnel.peerCloseCode.Load(); code != 0 {
+		fields["close_code"] = code
+	}
+	wsLogger.Info("WebSocket connection closed", fields)
+}
+
+// Shutdown sends a going-away close frame on every open tunnel and waits for them
+// to finish. Tunnels still open when ctx ends are dropped.
+func (p *WebSocketProxy) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	p.shutdown = true
+	tunnels := make([]*wsTunnel, 0, len(p.tunnels))
+	for tunnel := range p.tunnels {
+		tunnels = append(tunnels, tunnel)
+	}
+	p.mu.Unlock()
+
+	if len(tunnels) > 0 {
+		logger.MiddlewareLogger.Info("Closing WebSocket connections", map[string]interface{}{
+			"connections": len(tunnels),
+		})
+	}
+
+	for _, tunnel := range tunnels {
+		tunnel.closeWith(wsCloseGoingAway, "server shutting down")
+	}
+	for _, tunnel := range tunnels {
+		select {
+		case <-tunnel.done:
+		case <-ctx.Done():
+			for _, t := range tunnels {
+				t.forceClose()
+			}
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// originAllowed checks the Origin header against the allowed origins; entries may be
+// "*" or start with "*." to match subdomains. Without a list only same-origin pages
+// (Origin host equal to the request Host) and non-browser clients that send no Origin
+// are allowed, so other sites cannot open the tunnel with a visitor's cookies.
+func (p *WebSocketProxy) originAllowed(origin, host string) bool {
+	if origin == "" {
+		return len(p.allowedOrigins) == 0
+	}
+
+	parsed, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if len(p.allowedOrigins) == 0 {
+		return parsed.Host != "" && strings.EqualFold(parsed.Host, host)
+	}
+	for _, allowed := range p.allowedOrigins {
+		switch {
+		case allowed == "*":
+			return true
+		case strings.HasPrefix(allowed, "*."):
+			if strings.HasSuffix(parsed.Hostname(), allowed[1:]) {
+				return true
+			}
+		case strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin):
+			return true
+		}
+	}
+	return false
+}
+
+// reserve claims a connection slot, failing when the limit is reached or the proxy is shutting down
+func (p *WebSocketProxy) reserve() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.shutdown {
+		return fmt.Errorf("server is shutting down")
+	}
+	if p.maxConnections > 0 && p.slots >= p.maxConnections {
+		return fmt.Errorf("too many WebSocket connections")
+	}
+	p.slots++
+	return nil
+}
+
+// release returns a slot claimed by reserve
+func (p *WebSocketProxy) release() {
+	p.mu.Lock()
+	p.slots--
+	p.mu.Unlock()
+}
+
+// track registers an established tunnel, refusing it once shutdown has started
+func (p *WebSocketProxy) track(tunnel *wsTunnel) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.shutdown {
+		return false
+	}
+	p.tunnels[tunnel] = struct{}{}
+	return true
+}
+
+// untrack removes a finished tunnel
+func (p *WebSocketProxy) untrack(tunnel *wsTunnel) {
+	p.mu.Lock()
+	delete(p.tunnels, tunnel)
+	p.mu.Unlock()
+}
+
+// backendTarget returns the base URL the request should be tunnelled to, and a function
+// to call when the tunnel ends
+func (p *WebSocketProxy) backendTarget(r *http.Request) (string, func()) {
+	if baseURL, ok := client.TargetBackend(r.Context()); ok {
+		return baseURL, func() {}
+	}
+	if p.backends != nil {
+		return p.backends.PickBackend()
+	}
+	return p.backendHost, func() {}
+}
+
+// dialBackend connects to the backend and performs the WebSocket handshake
+func (p *WebSocketProxy) dialBackend(r *http.Request, baseURL string) (net.Conn, *bufio.Reader, *http.Response, error) {
+	target, err := url.Parse(baseURL + r.URL.Path)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid backend URL: %w", err)
+	}
+	target.RawQuery = r.URL.RawQuery
+
+	timeout := p.handshakeTimeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	dialer := &net.Dialer{Timeout: timeout}
+
+	var conn net.Conn
+	switch target.Scheme {
+	case "https":
+		tlsConfig := p.backendTLS.ConfigFor(target.Hostname())
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{ServerName: target.Hostname()}
+		}
+		tlsConfig.NextProtos = []string{"http/1.1"}
+		conn, err = tls.DialWithDialer(dialer, "tcp", hostWithPort(target, "443"), tlsConfig)
+	case "http":
+		conn, err = dialer.Dial("tcp", hostWithPort(target, "80"))
+	default:
+		err = fmt.Errorf("unsupported backend scheme %q", target.Scheme)
+	}
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, target.String(), nil)
+	if err != nil {
+		conn.Close()
+		return nil, nil, nil, err
+	}
+	for _, header := range webSocketForwardHeaders {
+		if values := r.Header.Values(header); len(values) > 0 {
+			req.Header[header] = values
+		}
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	if forwardedFor := client.ForwardedFor(r); forwardedFor != "" {
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+	}
+
+	conn.SetDeadline(time.Now().Add(timeout))
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, nil, nil, err
+	}
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		conn.Close()
+		return nil, nil, nil, err
+	}
+	conn.SetDeadline(time.Time{})
+
+	return conn, reader, resp, nil
+}
+
+// relayRefusal passes a backend's non-101 handshake response on to the client
+func (p *WebSocketProxy) relayRefusal(w http.ResponseWriter, resp *http.Response) {
+	defer resp.Body.Close()
+	for key, values := range resp.Header {
+		switch key {
+		case "Connection", "Upgrade", "Keep-Alive", "Transfer-Encoding":
+			continue
+		}
+		w.Header()[key] = values
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, io.LimitReader(resp.Body, 64<<10))
+}
+
+// run relays frames until either side goes away, then closes both connections.
+// It returns the reason the tunnel ended.
+func (p *WebSocketProxy) run(t *wsTunnel) string {
+	errc := make(chan error, 2)
+	go func() { errc <- p.relay(t, t.clientReader, t.backend, &t.backendMu, &t.framesUp, nil) }()
+	go func() { errc <- p.relay(t, t.backendReader, t.client, &t.clientMu, &t.framesDown, p.tokenFilter) }()
+	if p.idleTimeout > 0 {
+		go p.watchIdle(t)
+	}
+
+	err := <-errc
+	if t.closing.Load() {
+		// Give the other side a chance to answer the close frame
+		select {
+		case <-errc:
+		case <-time.After(wsCloseGracePeriod):
+		}
+	}
+	t.forceClose()
+	close(t.done)
+
+	if reason := t.reason.Load(); reason != nil {
+		return reason.(string)
+	}
+	if err == nil || errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) {
+		return "closed"
+	}
+	return err.Error()
+}
+
+// relay copies frames from src to dst until the source fails or, once the proxy
+// has initiated a close, the source answers with its own close frame. With a token
+// filter, text messages are reassembled from their fragments and filtered as a whole.
+func (p *WebSocketProxy) relay(t *wsTunnel, src *bufio.Reader, dst net.Conn, dstMu *sync.Mutex, frames *atomic.Int64, filter *TokenEventFilter) error {
+	var message *wsFrame // text message being reassembled for the filter
+	for {
+		frame, err := readFrame(src, p.maxFrameBytes)
+		if errors.Is(err, errFrameTooLarge) {
+			t.closeWith(wsCloseMessageTooBig, "frame too large")
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		t.touch()
+		frames.Add(1)
+
+		if t.closing.Load() {
+			if frame.opcode == wsOpClose {
+				return nil
+			}
+			continue
+		}
+
+		if filter != nil && !frame.isControl() && (frame.opcode == wsOpText || message != nil) {
+			if message == nil {
+				message = frame
+			} else {
+				message.payload = append(message.payload, frame.payload...)
+				if p.maxFrameBytes > 0 && int64(len(message.payload)) > p.maxFrameBytes {
+					t.closeWith(wsCloseMessageTooBig, "message too large")
+					return errFrameTooLarge
+				}
+			}
+			if !frame.fin {
+				continue
+			}
+
+			frame, message = message, nil
+			frame.fin = true
+			payload, keep := filter.FilterMessage(frame.payload)
+			if !keep {
+				t.filtered.Add(1)
+				continue
+			}
+			frame.payload = payload
+		}
+
+		if frame.opcode == wsOpClose {
+			// Log the close code of the side that closed the tunnel first
+			t.peerCloseCode.CompareAndSwap(0, int32(closeCode(frame.payload)))
+		}
+
+		dstMu.Lock()
+		err = writeFrame(dst, frame)
+		dstMu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// watchIdle closes the tunnel once no frame has passed in either direction for the idle timeout
+func (p *WebSocketProxy) watchIdle(t *wsTunnel) {
+	interval := p.idleTimeout / 4
+	if interval < 10*time.Millisecond {
+		interval = 10 * time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-t.done:
+			return
+		case <-ticker.C:
+			if time.Since(time.Unix(0, t.lastActivity.Load())) >= p.idleTimeout {
+				t.closeWith(wsCloseGoingAway, "idle timeout")
+				return
+			}
+		}
+	}
+}
+
+// wsTunnel is an established client-to-backend WebSocket connection
+type wsTunnel struct {
+	client        net.Conn
+	clientReader  *bufio.Reader
+	clientMu      sync.Mutex
+	backend       net.Conn
+	backendReader *bufio.Reader
+	backendMu     sync.Mutex
+
+	lastActivity  atomic.Int64
+	framesUp      atomic.Int64
+	framesDown    atomic.Int64
+	filtered      atomic.Int64
+	closing       atomic.Bool
+	peerCloseCode atomic.Int32
+	reason        atomic.Value
+	closeOnce     sync.Once
+	done          chan struct{}
+}
+
+func newWSTunnel(clientConn net.Conn, clientReader *bufio.Reader, backendConn net.Conn, backendReader *bufio.Reader) *wsTunnel {
+	t := &wsTunnel{
+		client:        clientConn,
+		clientReader:  clientReader,
+		backend:       backendConn,
+		backendReader: backendReader,
+		done:          make(chan struct{}),
+	}
+	t.touch()
+	return t
+}
+
+// touch records activity on the tunnel
+func (t *wsTunnel) touch() {
+	t.lastActivity.Store(time.Now().UnixNano())
+}
+
+// closeWith starts the close handshake with both peers. Data frames received
+// afterwards are dropped. Only the first call has any effect.
+func (t *wsTunnel) closeWith(code int, reason string) {
+	if !t.closing.CompareAndSwap(false, true) {
+		return
+	}
+	t.reason.Store(reason)
+
+	t.clientMu.Lock()
+	t.client.SetWriteDeadline(time.Now().Add(wsCloseGracePeriod))
+	writeFrame(t.client, newCloseFrame(code, reason, false))
+	t.clientMu.Unlock()
+
+	t.backendMu.Lock()
+	t.backend.SetWriteDeadline(time.Now().Add(wsCloseGracePeriod))
+	writeFrame(t.backend, newCloseFrame(code, reason, true))
+	t.backendMu.Unlock()
+}
+
+// forceClose drops both connections
+func (t *wsTunnel) forceClose() {
+	t.closeOnce.Do(func() {
+		t.client.Close()
+		t.backend.Close()
+	})
+}
+
+// writeSwitchingProtocols completes the client handshake with the backend's 101 response
+func writeSwitchingProtocols(w *bufio.Writer, resp *http.Response) error {
+	fmt.Fprintf(w, "HTTP/1.1 101 Switching Protocols\r\n")
+	fmt.Fprintf(w, "Upgrade: websocket\r\nConnection: Upgrade\r\n")
+	fmt.Fprintf(w, "Sec-WebSocket-Accept: %s\r\n", resp.Header.Get("Sec-WebSocket-Accept"))
+	if protocol := resp.Header.Get("Sec-WebSocket-Protocol"); protocol != "" {
+		fmt.Fprintf(w, "Sec-WebSocket-Protocol: %s\r\n", protocol)
+	}
+	fmt.Fprintf(w, "\r\n")
+	return w.Flush()
+}
+
+// hostWithPort returns the URL's host with an explicit port
+func hostWithPort(u *url.URL, defaultPort string) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	return net.JoinHostPort(u.Hostname(), defaultPort)
+}
@@ -0,0 +1,216 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"go-api-proxy/models"
+)
+
+// Token event filter modes
+const (
+	TokenEventFilterOff      = "off"
+	TokenEventFilterDrop     = "drop"
+	TokenEventFilterAnnotate = "annotate"
+)
+
+// TokenEventFilter inspects Phoenix channel messages sent by the backend over a
+// WebSocket and removes, or marks, events about tokens that are not whitelisted.
+// Tokens are matched exactly as the REST token filter matches them (see
+// whitelistMatch), with an empty whitelist allowing every token.
+//
+// The filter understands both Phoenix serializer formats: the 2.0 array form
+// [join_ref, ref, topic, event, payload] and the 1.0 object form. It looks at:
+//   - "token_transfers" lists in the payload, by each transfer's token
+//   - "tokens" lists in the payload
+//   - a "token" object in the payload
+//   - "new_token" events, whose payload is the token itself
+//   - "tokens:<address>" topics
+//
+// Channel control events (phx_reply, phx_error, ...) are never touched.
+type TokenEventFilter struct {
+	whitelist *models.TokenWhitelist
+	annotate  bool
+}
+
+// NewTokenEventFilter creates a token event filter. It returns nil, which filters
+// nothing, when the mode is off or empty.
+func NewTokenEventFilter(whitelist *models.TokenWhitelist, mode string) *TokenEventFilter {
+	switch mode {
+	case TokenEventFilterDrop, TokenEventFilterAnnotate:
+		return &TokenEventFilter{
+			whitelist: whitelist,
+			annotate:  mode == TokenEventFilterAnnotate,
+		}
+	default:
+		return nil
+	}
+}
+
+// FilterMessage filters one text message. It returns the message to forward, which
+// is the original bytes when nothing changed, and false if the message should be dropped.
+func (f *TokenEventFilter) FilterMessage(message []byte) ([]byte, bool) {
+	if f == nil || f.whitelist == nil || f.whitelist.Size() == 0 {
+		return message, true
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(message))
+	decoder.UseNumber()
+	var decoded interface{}
+	if err := decoder.Decode(&decoded); err != nil {
+		return message, true
+	}
+
+	topic, event, payload, ok := phoenixParts(decoded)
+	if !ok || strings.HasPrefix(event, "phx_") {
+		return message, true
+	}
+
+	changed, keep := f.filterPayload(topic, event, payload)
+	if !keep {
+		return nil, false
+	}
+	if !changed {
+		return message, true
+	}
+
+	// Tokens carry URLs, which the default encoder would escape as \u0026 and the like
+	var encoded bytes.Buffer
+	encoder := json.NewEncoder(&encoded)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(decoded); err != nil {
+		return message, true
+	}
+	return bytes.TrimSuffix(encoded.Bytes(), []byte("\n")), true
+}
+
+// filterPayload applies the token rules to a message payload in place. It reports
+// whether the payload was modified and whether the message should be kept.
+func (f *TokenEventFilter) filterPayload(topic, event string, payload map[string]interface{}) (bool, bool) {
+	changed := false
+
+	if address, ok := strings.CutPrefix(topic, "tokens:"); ok && strings.HasPrefix(address, "0x") {
+		if !f.allowed(address) {
+			if !f.annotate {
+				return false, false
+			}
+			changed = setField(payload, "whitelisted", false)
+		}
+	}
+
+	if event == "new_token" {
+		keep, modified := f.checkToken(payload)
+		if !keep {
+			return false, false
+		}
+		changed = changed || modified
+	}
+
+	if token, ok := payload["token"].(map[string]interface{}); ok {
+		keep, modified := f.checkToken(token)
+		if !keep {
+			return false, false
+		}
+		changed = changed || modified
+	}
+
+	for _, field := range []string{"token_transfers", "tokens"} {
+		items, ok := payload[field].([]interface{})
+		if !ok || len(items) == 0 {
+			continue
+		}
+
+		kept := items[:0:0]
+		for _, item := range items {
+			object, ok := item.(map[string]interface{})
+			if !ok {
+				kept = append(kept, item)
+				continue
+			}
+			token := object
+			if field == "token_transfers" {
+				if token, ok = object["token"].(map[string]interface{}); !ok {
+					kept = append(kept, item)
+					continue
+				}
+			}
+			keep, modified := f.checkToken(token)
+			if keep {
+				kept = append(kept, item)
+			}
+			changed = changed || modified
+		}
+		if len(kept) == 0 {
+			return false, false
+		}
+		if len(kept) != len(items) {
+			payload[field] = kept
+			changed = true
+		}
+	}
+
+	return changed, true
+}
+
+// checkToken reports whether a token object may be forwarded and whether it was
+// modified. Whitelisted tokens get their whitelist properties applied; in annotate
+// mode every token is kept and marked with whether it is whitelisted.
+func (f *TokenEventFilter) checkToken(token map[string]interface{}) (bool, bool) {
+	address, _ := token["address"].(string)
+	addressHash, _ := token["address_hash"].(string)
+
+	changed := false
+	address, allowed := whitelistMatch(f.whitelist, address, addressHash)
+	if allowed {
+		if info := f.whitelist.GetTokenInfo(address); info != nil && info.IconURL != nil && *info.IconURL != "" {
+			changed = setField(token, "icon_url", *info.IconURL)
+		}
+	}
+	if f.annotate {
+		changed = setField(token, "whitelisted", allowed) || changed
+		return true, changed
+	}
+	return allowed, changed
+}
+
+// setField sets a field of a decoded JSON object and reports whether its value changed
+func setField(object map[string]interface{}, field string, value interface{}) bool {
+	if current, ok := object[field]; ok && current == value {
+		return false
+	}
+	object[field] = value
+	return true
+}
+
+// allowed applies the token filter's matching rule to an address
+func (f *TokenEventFilter) allowed(address string) bool {
+	_, ok := whitelistMatch(f.whitelist, address, "")
+	return ok
+}
+
+// phoenixParts extracts the topic, event and object payload from a decoded Phoenix message
+func phoenixParts(decoded interface{}) (string, string, map[string]interface{}, bool) {
+	var topic, event interface{}
+	var payload interface{}
+
+	switch message := decoded.(type) {
+	case []interface{}:
+		if len(message) != 5 {
+			return "", "", nil, false
+		}
+		topic, event, payload = message[2], message[3], message[4]
+	case map[string]interface{}:
+		topic, event, payload = message["topic"], message["event"], message["payload"]
+	default:
+		return "", "", nil, false
+	}
+
+	topicString, ok1 := topic.(string)
+	eventString, ok2 := event.(string)
+	payloadObject, ok3 := payload.(map[string]interface{})
+	if !ok1 || !ok2 || !ok3 {
+		return "", "", nil, false
+	}
+	return topicString, eventString, payloadObject, true
+}
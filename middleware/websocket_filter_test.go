@@ -0,0 +1,201 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"go-api-proxy/logger"
+	"go-api-proxy/models"
+)
+
+func newTestTokenEventFilter(t *testing.T, mode string) *TokenEventFilter {
+	t.Helper()
+
+	whitelist := models.NewTokenWhitelist()
+	if err := whitelist.LoadFromJSON([]byte(`{"tokens":[{"address":"0xgood","icon_url":"https://icons.example.com/good.png"}]}`)); err != nil {
+		t.Fatalf("Failed to load whitelist: %v", err)
+	}
+	return NewTokenEventFilter(whitelist, mode)
+}
+
+func TestTokenEventFilter_DropsNonWhitelistedTokens(t *testing.T) {
+	filter := newTestTokenEventFilter(t, TokenEventFilterDrop)
+
+	tests := []struct {
+		name    string
+		message string
+		keep    bool
+		want    string
+	}{
+		{
+			name:    "mixed token transfers are trimmed",
+			message: `["1",null,"addresses:0xabc","token_transfer",{"token_transfers":[{"token":{"address":"0xgood"}},{"token":{"address":"0xbad"}}]}]`,
+			keep:    true,
+			want:    `{"token_transfers":[{"token":{"address":"0xgood","icon_url":"https://icons.example.com/good.png"}}]}`,
+		},
+		{
+			name:    "transfers of unknown tokens only are dropped",
+			message: `["1",null,"token_transfers:new_token_transfer","token_transfer",{"token_transfers":[{"token":{"address_hash":"0xbad"}}]}]`,
+			keep:    false,
+		},
+		{
+			name:    "new token event is dropped",
+			message: `{"topic":"tokens:new_token","event":"new_token","payload":{"address":"0xbad","name":"Scam"},"ref":null}`,
+			keep:    false,
+		},
+		{
+			name:    "token topic of an unknown token is dropped",
+			message: `["1",null,"tokens:0xbad","total_supply",{"total_supply":"1"}]`,
+			keep:    false,
+		},
+		{
+			name:    "channel replies are untouched",
+			message: `["1","1","tokens:0xbad","phx_reply",{"status":"ok","response":{}}]`,
+			keep:    true,
+		},
+		{
+			name:    "unrelated events are untouched",
+			message: `["1",null,"blocks:new_block","new_block",{"block":{"height":1}}]`,
+			keep:    true,
+		},
+		{
+			name:    "non-JSON text is untouched",
+			message: `hello`,
+			keep:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filtered, keep := filter.FilterMessage([]byte(tt.message))
+			if keep != tt.keep {
+				t.Fatalf("Expected keep=%v, got %v (%s)", tt.keep, keep, filtered)
+			}
+			if !keep {
+				return
+			}
+			if tt.want == "" {
+				if string(filtered) != tt.message {
+					t.Errorf("Expected message to be forwarded unchanged, got %s", filtered)
+				}
+				return
+			}
+			var decoded []json.RawMessage
+			json.Unmarshal(filtered, &decoded)
+			if len(decoded) != 5 || string(decoded[4]) != tt.want {
+				t.Errorf("Unexpected filtered message %s", filtered)
+			}
+		})
+	}
+}
+
+func TestTokenEventFilter_Annotates(t *testing.T) {
+	filter := newTestTokenEventFilter(t, TokenEventFilterAnnotate)
+
+	filtered, keep := filter.FilterMessage([]byte(`{"topic":"token_transfers:new_token_transfer","event":"token_transfer","payload":{"token_transfers":[{"token":{"address":"0xgood"}},{"token":{"address":"0xbad"}}]}}`))
+	if !keep {
+		t.Fatal("Expected annotated message to be kept")
+	}
+	if !strings.Contains(string(filtered), `{"address":"0xbad","whitelisted":false}`) ||
+		!strings.Contains(string(filtered), `"whitelisted":true`) {
+		t.Errorf("Expected tokens to be annotated, got %s", filtered)
+	}
+}
+
+func TestTokenEventFilter_ForwardsUnmodifiedMessagesAsIs(t *testing.T) {
+	filter := newTestTokenEventFilter(t, TokenEventFilterDrop)
+
+	// The token already carries its whitelist icon, so nothing is rewritten
+	message := `["1",null,"addresses:0xabc","token_transfer",{"token_transfers":[{"token":{"address":"0xgood","icon_url":"https://icons.example.com/good.png"}}],"note":"a<b&c"}]`
+	if filtered, keep := filter.FilterMessage([]byte(message)); !keep || string(filtered) != message {
+		t.Errorf("Expected the message to be forwarded unchanged, got %s", filtered)
+	}
+
+	// Rewritten messages keep URLs and markup unescaped
+	message = `["1",null,"addresses:0xabc","token_transfer",{"token_transfers":[{"token":{"address":"0xgood"}},{"token":{"address":"0xbad"}}],"note":"a<b&c"}]`
+	filtered, keep := filter.FilterMessage([]byte(message))
+	if !keep || !strings.Contains(string(filtered), `"note":"a<b&c"`) || strings.HasSuffix(string(filtered), "\n") {
+		t.Errorf("Expected the rewritten message without HTML escaping, got %q", filtered)
+	}
+}
+
+func TestTokenEventFilter_MatchesLikeRESTFilter(t *testing.T) {
+	whitelist := models.NewTokenWhitelist()
+	if err := whitelist.LoadFromJSON([]byte(`{"addresses":["0xgood"]}`)); err != nil {
+		t.Fatalf("Failed to load whitelist: %v", err)
+	}
+	wsFilter := NewTokenEventFilter(whitelist, TokenEventFilterDrop)
+	restFilter := NewTokenFilterHandler(&mockHTTPClient{}, whitelist)
+
+	tokens := []models.Token{
+		{Address: "0xgood"},
+		{AddressHash: "0xgood"},
+		{Address: "0xbad"},
+		{Address: "0xbad", AddressHash: "0xgood"},
+		{Address: "0xGOOD"},
+		{},
+	}
+
+	for _, token := range tokens {
+		rest := restFilter.filterTokens(&models.TokenResponse{Items: []models.Token{token}}, logger.MiddlewareLogger)
+		restKept := len(rest.Items) == 1
+
+		object := map[string]interface{}{}
+		if token.Address != "" {
+			object["address"] = token.Address
+		}
+		if token.AddressHash != "" {
+			object["address_hash"] = token.AddressHash
+		}
+		payload, _ := json.Marshal(map[string]interface{}{"token": object})
+		_, wsKept := wsFilter.FilterMessage([]byte(`["1",null,"tokens:x","event",` + string(payload) + `]`))
+
+		if restKept != wsKept {
+			t.Errorf("Filters disagree for address=%q address_hash=%q: REST kept=%v, WebSocket kept=%v",
+				token.Address, token.AddressHash, restKept, wsKept)
+		}
+	}
+}
+
+func TestTokenEventFilter_DisabledOrEmptyWhitelist(t *testing.T) {
+	message := []byte(`["1",null,"tokens:0xbad","total_supply",{}]`)
+
+	if filter := NewTokenEventFilter(models.NewTokenWhitelist(), TokenEventFilterOff); filter != nil {
+		t.Error("Expected no filter when turned off")
+	}
+	var disabled *TokenEventFilter
+	if _, keep := disabled.FilterMessage(message); !keep {
+		t.Error("Expected a nil filter to keep every message")
+	}
+	if _, keep := NewTokenEventFilter(models.NewTokenWhitelist(), TokenEventFilterDrop).FilterMessage(message); !keep {
+		t.Error("Expected an empty whitelist to allow every token, like the REST filter")
+	}
+}
+
+func TestWebSocketProxy_FiltersFragmentedTokenEvents(t *testing.T) {
+	echo := newEchoWebSocketServer(t)
+	_, server := newWebSocketTestProxy(t, echo.URL, newTestTokenEventFilter(t, TokenEventFilterDrop), nil)
+
+	conn, reader, resp := dialWebSocket(t, server, nil)
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("Expected 101, got %d", resp.StatusCode)
+	}
+
+	// A dropped event split across frames with a ping in between
+	dropped := `["1",null,"tokens:0xbad","total_supply",{"total_supply":"1"}]`
+	writeFrame(conn, &wsFrame{opcode: wsOpText, masked: true, payload: []byte(dropped[:10])})
+	writeFrame(conn, &wsFrame{fin: true, opcode: wsOpPing, masked: true, payload: []byte("hb")})
+	writeFrame(conn, &wsFrame{fin: true, opcode: wsOpContinuation, masked: true, payload: []byte(dropped[10:])})
+
+	kept := `["1",null,"blocks:new_block","new_block",{}]`
+	sendText(t, conn, kept)
+
+	if frame := readTestFrame(t, conn, reader); frame.opcode != wsOpPing {
+		t.Errorf("Expected control frame to pass through immediately, got opcode %d", frame.opcode)
+	}
+	if frame := readTestFrame(t, conn, reader); !frame.fin || frame.opcode != wsOpText || string(frame.payload) != kept {
+		t.Errorf("Expected only the unrelated event to arrive, got %q", frame.payload)
+	}
+}
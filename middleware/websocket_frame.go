@@ -0,0 +1,170 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// WebSocket opcodes (RFC 6455 section 5.2)
+const (
+	wsOpContinuation = 0x0
+	wsOpText         = 0x1
+	wsOpBinary       = 0x2
+	wsOpClose        = 0x8
+	wsOpPing         = 0x9
+	wsOpPong         = 0xA
+)
+
+// WebSocket close codes used by the proxy
+const (
+	wsCloseGoingAway     = 1001
+	wsCloseMessageTooBig = 1009
+)
+
+// wsAcceptGUID is appended to the client key to compute Sec-WebSocket-Accept
+const wsAcceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+var errFrameTooLarge = errors.New("websocket frame exceeds size limit")
+
+// wsFrame is a single WebSocket frame. The payload is always held unmasked; masked
+// frames keep their key so they can be forwarded with the same mask.
+type wsFrame struct {
+	fin     bool
+	rsv     byte
+	opcode  byte
+	masked  bool
+	maskKey [4]byte
+	payload []byte
+}
+
+// isControl reports whether the frame is a close, ping or pong frame
+func (f *wsFrame) isControl() bool {
+	return f.opcode&0x8 != 0
+}
+
+// readFrame reads one frame, rejecting payloads larger than maxPayload when it is positive
+func readFrame(r io.Reader, maxPayload int64) (*wsFrame, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return nil, err
+	}
+
+	f := &wsFrame{
+		fin:    head[0]&0x80 != 0,
+		rsv:    head[0] & 0x70,
+		opcode: head[0] & 0x0f,
+		masked: head[1]&0x80 != 0,
+	}
+
+	length := int64(head[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return nil, err
+		}
+		length = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return nil, err
+		}
+		length = int64(binary.BigEndian.Uint64(ext[:]))
+		if length < 0 {
+			return nil, fmt.Errorf("invalid websocket frame length")
+		}
+	}
+	if maxPayload > 0 && length > maxPayload {
+		return nil, errFrameTooLarge
+	}
+
+	if f.masked {
+		if _, err := io.ReadFull(r, f.maskKey[:]); err != nil {
+			return nil, err
+		}
+	}
+
+	f.payload = make([]byte, length)
+	if _, err := io.ReadFull(r, f.payload); err != nil {
+		return nil, err
+	}
+	if f.masked {
+		maskBytes(f.maskKey, f.payload)
+	}
+	return f, nil
+}
+
+// writeFrame writes a frame in a single write, masking the payload if the frame is masked
+func writeFrame(w io.Writer, f *wsFrame) error {
+	buf := make([]byte, 0, 14+len(f.payload))
+
+	b0 := f.rsv | f.opcode
+	if f.fin {
+		b0 |= 0x80
+	}
+	var b1 byte
+	if f.masked {
+		b1 = 0x80
+	}
+
+	switch length := len(f.payload); {
+	case length < 126:
+		buf = append(buf, b0, b1|byte(length))
+	case length <= 0xffff:
+		buf = append(buf, b0, b1|126)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(length))
+	default:
+		buf = append(buf, b0, b1|127)
+		buf = binary.BigEndian.AppendUint64(buf, uint64(length))
+	}
+
+	if f.masked {
+		buf = append(buf, f.maskKey[:]...)
+		start := len(buf)
+		buf = append(buf, f.payload...)
+		maskBytes(f.maskKey, buf[start:])
+	} else {
+		buf = append(buf, f.payload...)
+	}
+
+	_, err := w.Write(buf)
+	return err
+}
+
+// newCloseFrame builds a close frame. Frames sent to a server must be masked.
+func newCloseFrame(code int, reason string, masked bool) *wsFrame {
+	payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+	payload = append(payload, reason...)
+
+	f := &wsFrame{fin: true, opcode: wsOpClose, masked: masked, payload: payload}
+	if masked {
+		rand.Read(f.maskKey[:])
+	}
+	return f
+}
+
+// closeCode returns the status code of a close frame, or 0 if it carries none
+func closeCode(payload []byte) int {
+	if len(payload) < 2 {
+		return 0
+	}
+	return int(binary.BigEndian.Uint16(payload))
+}
+
+// maskBytes applies the WebSocket XOR mask in place
+func maskBytes(key [4]byte, b []byte) {
+	for i := range b {
+		b[i] ^= key[i%4]
+	}
+}
+
+// websocketAcceptKey computes the Sec-WebSocket-Accept value for a client key
+func websocketAcceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + wsAcceptGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
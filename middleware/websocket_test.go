@@ -0,0 +1,270 @@
+package middleware
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go-api-proxy/config"
+)
+
+// wsCloseNormal is the close code of a normal closure, sent by the test clients
+const wsCloseNormal = 1000
+
+// newEchoWebSocketServer starts a WebSocket server that echoes every data frame
+// and answers close frames
+func newEchoWebSocketServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Sec-WebSocket-Extensions") != "" {
+			t.Error("Expected extensions not to be negotiated with the backend")
+		}
+		conn, rw, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			t.Errorf("Echo server failed to hijack: %v", err)
+			return
+		}
+		defer conn.Close()
+
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+		rw.WriteString("Sec-WebSocket-Accept: " + websocketAcceptKey(r.Header.Get("Sec-WebSocket-Key")) + "\r\n\r\n")
+		rw.Flush()
+
+		for {
+			frame, err := readFrame(rw.Reader, 0)
+			if err != nil {
+				return
+			}
+			if !frame.masked {
+				t.Error("Expected frames from the proxy to be masked")
+			}
+			frame.masked = false
+			if err := writeFrame(conn, frame); err != nil || frame.opcode == wsOpClose {
+				return
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newWebSocketTestProxy(t *testing.T, backend string, tokenFilter *TokenEventFilter, configure func(*config.Config)) (*WebSocketProxy, *httptest.Server) {
+	t.Helper()
+
+	cfg := &config.Config{
+		BackendHost:            backend,
+		Timeout:                5 * time.Second,
+		WebSocketMaxFrameBytes: 1 << 20,
+	}
+	if configure != nil {
+		configure(cfg)
+	}
+	proxy := NewWebSocketProxy(cfg, tokenFilter, nil)
+	server := httptest.NewServer(proxy)
+	t.Cleanup(server.Close)
+	return proxy, server
+}
+
+// dialWebSocket performs a client handshake against the server and returns the
+// connection and the handshake response
+func dialWebSocket(t *testing.T, server *httptest.Server, header http.Header) (net.Conn, *bufio.Reader, *http.Response) {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatalf("Failed to dial proxy: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/socket/v2/websocket?vsn=2.0.0", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Extensions", "permessage-deflate")
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Write(conn)
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		t.Fatalf("Failed to read handshake response: %v", err)
+	}
+	return conn, reader, resp
+}
+
+func sendText(t *testing.T, conn net.Conn, text string) {
+	t.Helper()
+
+	frame := &wsFrame{fin: true, opcode: wsOpText, masked: true, maskKey: [4]byte{1, 2, 3, 4}, payload: []byte(text)}
+	if err := writeFrame(conn, frame); err != nil {
+		t.Fatalf("Failed to send frame: %v", err)
+	}
+}
+
+func readTestFrame(t *testing.T, conn net.Conn, reader *bufio.Reader) *wsFrame {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	frame, err := readFrame(reader, 0)
+	if err != nil {
+		t.Fatalf("Failed to read frame: %v", err)
+	}
+	return frame
+}
+
+func TestWebSocketProxy_TunnelsFrames(t *testing.T) {
+	echo := newEchoWebSocketServer(t)
+	proxy, server := newWebSocketTestProxy(t, echo.URL, nil, nil)
+
+	conn, reader, resp := dialWebSocket(t, server, nil)
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("Expected 101, got %d", resp.StatusCode)
+	}
+	if resp.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("Unexpected accept key %q", resp.Header.Get("Sec-WebSocket-Accept"))
+	}
+	if resp.Header.Get("Sec-WebSocket-Extensions") != "" {
+		t.Error("Expected no extensions to be negotiated")
+	}
+
+	message := `["1","1","blocks:new_block","phx_join",{}]`
+	sendText(t, conn, message)
+	if frame := readTestFrame(t, conn, reader); frame.opcode != wsOpText || string(frame.payload) != message {
+		t.Errorf("Expected echoed text frame, got opcode %d payload %q", frame.opcode, frame.payload)
+	}
+
+	large := strings.Repeat("x", 70000)
+	sendText(t, conn, large)
+	if frame := readTestFrame(t, conn, reader); string(frame.payload) != large {
+		t.Errorf("Expected large frame to round-trip, got %d bytes", len(frame.payload))
+	}
+
+	if proxy.ActiveConnections() != 1 {
+		t.Errorf("Expected 1 active connection, got %d", proxy.ActiveConnections())
+	}
+
+	writeFrame(conn, newCloseFrame(wsCloseNormal, "bye", true))
+	if frame := readTestFrame(t, conn, reader); frame.opcode != wsOpClose || closeCode(frame.payload) != wsCloseNormal {
+		t.Errorf("Expected the close frame to be answered, got opcode %d", frame.opcode)
+	}
+}
+
+func TestWebSocketProxy_DefaultsToSameOrigin(t *testing.T) {
+	echo := newEchoWebSocketServer(t)
+	_, server := newWebSocketTestProxy(t, echo.URL, nil, nil)
+
+	if _, _, resp := dialWebSocket(t, server, http.Header{"Origin": {"https://evil.example.net"}}); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected 403 for a cross-site origin, got %d", resp.StatusCode)
+	}
+	if _, _, resp := dialWebSocket(t, server, http.Header{"Origin": {server.URL}}); resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Errorf("Expected the proxy's own origin to be allowed, got %d", resp.StatusCode)
+	}
+	if _, _, resp := dialWebSocket(t, server, nil); resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Errorf("Expected clients without an origin to be allowed, got %d", resp.StatusCode)
+	}
+}
+
+func TestWebSocketProxy_RejectsHandshakes(t *testing.T) {
+	echo := newEchoWebSocketServer(t)
+	_, server := newWebSocketTestProxy(t, echo.URL, nil, func(cfg *config.Config) {
+		cfg.WebSocketAllowedOrigins = []string{"https://explorer.example.com", "*.example.org"}
+	})
+
+	if _, _, resp := dialWebSocket(t, server, http.Header{"Origin": {"https://evil.example.net"}}); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected 403 for a disallowed origin, got %d", resp.StatusCode)
+	}
+	if _, _, resp := dialWebSocket(t, server, nil); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected 403 without an origin, got %d", resp.StatusCode)
+	}
+	if _, _, resp := dialWebSocket(t, server, http.Header{"Origin": {"https://app.example.org"}}); resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Errorf("Expected wildcard origin to be allowed, got %d", resp.StatusCode)
+	}
+
+	resp, err := http.Get(server.URL + "/socket/v2/websocket")
+	if err != nil {
+		t.Fatalf("Plain request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUpgradeRequired {
+		t.Errorf("Expected 426 for a plain HTTP request, got %d", resp.StatusCode)
+	}
+}
+
+func TestWebSocketProxy_EnforcesConnectionLimit(t *testing.T) {
+	echo := newEchoWebSocketServer(t)
+	_, server := newWebSocketTestProxy(t, echo.URL, nil, func(cfg *config.Config) {
+		cfg.WebSocketMaxConnections = 1
+	})
+
+	first, _, resp := dialWebSocket(t, server, nil)
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("Expected first connection to be accepted, got %d", resp.StatusCode)
+	}
+	if _, _, resp := dialWebSocket(t, server, nil); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 over the connection limit, got %d", resp.StatusCode)
+	}
+
+	first.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, _, resp := dialWebSocket(t, server, nil)
+		if resp.StatusCode == http.StatusSwitchingProtocols {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the slot to be released after the first connection closed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWebSocketProxy_ClosesIdleConnections(t *testing.T) {
+	echo := newEchoWebSocketServer(t)
+	_, server := newWebSocketTestProxy(t, echo.URL, nil, func(cfg *config.Config) {
+		cfg.WebSocketIdleTimeout = 100 * time.Millisecond
+	})
+
+	conn, reader, _ := dialWebSocket(t, server, nil)
+	frame := readTestFrame(t, conn, reader)
+	if frame.opcode != wsOpClose || closeCode(frame.payload) != wsCloseGoingAway || string(frame.payload[2:]) != "idle timeout" {
+		t.Errorf("Expected idle close frame, got opcode %d payload %q", frame.opcode, frame.payload)
+	}
+}
+
+func TestWebSocketProxy_ShutdownClosesConnections(t *testing.T) {
+	echo := newEchoWebSocketServer(t)
+	proxy, server := newWebSocketTestProxy(t, echo.URL, nil, nil)
+
+	conn, reader, _ := dialWebSocket(t, server, nil)
+	sendText(t, conn, "ping")
+	readTestFrame(t, conn, reader)
+
+	done := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		done <- proxy.Shutdown(ctx)
+	}()
+
+	frame := readTestFrame(t, conn, reader)
+	if frame.opcode != wsOpClose || closeCode(frame.payload) != wsCloseGoingAway {
+		t.Fatalf("Expected going-away close frame, got opcode %d", frame.opcode)
+	}
+	writeFrame(conn, newCloseFrame(wsCloseGoingAway, "", true))
+
+	if err := <-done; err != nil {
+		t.Errorf("Expected shutdown to finish cleanly, got %v", err)
+	}
+	if _, _, resp := dialWebSocket(t, server, nil); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected new connections to be refused after shutdown, got %d", resp.StatusCode)
+	}
+}
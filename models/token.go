@@ -43,6 +43,7 @@ type TokenWhitelist struct {
 	Tokens    []WhitelistToken `json:"tokens,omitempty"`
 	Addresses []string         `json:"addresses,omitempty"` // Legacy format support
 	mu        sync.RWMutex
+	version   uint64 // incremented on every change, so derived caches can detect reloads
 }
 
 // NewTokenWhitelist creates a new TokenWhitelist instance
@@ -97,6 +98,7 @@ func (tw *TokenWhitelist) LoadFromJSON(data []byte) error {
 		tw.Addresses = make([]string, 0)
 		tw.Tokens = make([]WhitelistToken, 0)
 	}
+	tw.version++
 	
 	logger.ModelsLogger.Debug("Successfully parsed whitelist JSON", map[string]interface{}{
 		"address_count": len(tw.Addresses),
@@ -150,6 +152,14 @@ func (tw *TokenWhitelist) Size() int {
 	return len(tw.Addresses)
 }
 
+// Version returns a counter that changes whenever the whitelist is modified or reloaded (thread-safe)
+func (tw *TokenWhitelist) Version() uint64 {
+	tw.mu.RLock()
+	defer tw.mu.RUnlock()
+	
+	return tw.version
+}
+
 // LoadFromFile loads whitelist addresses from a JSON file
 func (tw *TokenWhitelist) LoadFromFile(filename string) error {
 	logger.ModelsLogger.Debug("Loading whitelist from file", map[string]interface{}{
@@ -244,6 +254,7 @@ func (tw *TokenWhitelist) AddAddress(address string) error {
 	}
 	
 	tw.Addresses = append(tw.Addresses, address)
+	tw.version++
 	return nil
 }
 
@@ -255,6 +266,7 @@ func (tw *TokenWhitelist) RemoveAddress(address string) bool {
 	for i, addr := range tw.Addresses {
 		if addr == address {
 			tw.Addresses = append(tw.Addresses[:i], tw.Addresses[i+1:]...)
+			tw.version++
 			return true
 		}
 	}
@@ -267,4 +279,5 @@ func (tw *TokenWhitelist) Clear() {
 	defer tw.mu.Unlock()
 	
 	tw.Addresses = make([]string, 0)
+	tw.version++
 }
\ No newline at end of file
@@ -0,0 +1,196 @@
+package models
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// floatPrecision is the mantissa precision used for big.Float conversions
+const floatPrecision = 256
+
+// ratDigits is the number of decimal places kept for amounts derived by division
+const ratDigits = 18
+
+// maxDecimals is the largest decimals value a token can declare; ERC-20 decimals are a uint8.
+// Larger values come from broken contracts and would make formatting arbitrarily expensive.
+const maxDecimals = 255
+
+// NormalizedToken is a Token enriched with decimal-normalised derived fields:
+// supply and volume in token units and the market cap in USD.
+type NormalizedToken struct {
+	Token
+	TotalSupplyFormatted       *string `json:"total_supply_formatted"`
+	MarketCapUSD               *string `json:"market_cap_usd"`
+	CirculatingSupplyFormatted *string `json:"circulating_supply_formatted"`
+	Volume24hTokens            *string `json:"volume_24h_tokens"`
+}
+
+// NormalizedTokenResponse represents a token response with normalised items
+type NormalizedTokenResponse struct {
+	Items []NormalizedToken `json:"items"`
+}
+
+// DecimalsInt returns the token decimals as a big.Int
+func (t Token) DecimalsInt() (*big.Int, error) {
+	return parseBigInt("decimals", t.Decimals)
+}
+
+// HoldersInt returns the holders count as a big.Int
+func (t Token) HoldersInt() (*big.Int, error) {
+	if t.Holders == "" && t.HoldersCount != "" {
+		return parseBigInt("holders_count", t.HoldersCount)
+	}
+	return parseBigInt("holders", t.Holders)
+}
+
+// TotalSupplyInt returns the raw (base unit) total supply as a big.Int
+func (t Token) TotalSupplyInt() (*big.Int, error) {
+	return parseBigInt("total_supply", t.TotalSupply)
+}
+
+// ExchangeRateFloat returns the USD exchange rate as a big.Float, or nil if the backend did not report one
+func (t Token) ExchangeRateFloat() (*big.Float, error) {
+	return parseBigFloat("exchange_rate", t.ExchangeRate)
+}
+
+// CirculatingMarketCapFloat returns the circulating market cap as a big.Float, or nil if not reported
+func (t Token) CirculatingMarketCapFloat() (*big.Float, error) {
+	return parseBigFloat("circulating_market_cap", t.CirculatingMarketCap)
+}
+
+// Volume24hFloat returns the 24h volume as a big.Float, or nil if not reported
+func (t Token) Volume24hFloat() (*big.Float, error) {
+	return parseBigFloat("volume_24h", t.Volume24h)
+}
+
+// Normalize computes the derived decimal-normalised fields for the token.
+// Fields that cannot be derived (missing or malformed inputs, or decimals
+// above maxDecimals) are left nil.
+func (t Token) Normalize() NormalizedToken {
+	normalized := NormalizedToken{Token: t}
+
+	// Values derived from the exchange rate are computed on rationals, so
+	// neither the supply nor the rate is ever rounded before formatting
+	rate := parseRat(t.ExchangeRate)
+	if rate != nil && rate.Sign() > 0 {
+		if circulating := parseRat(t.CirculatingMarketCap); circulating != nil {
+			normalized.CirculatingSupplyFormatted = formatRat(new(big.Rat).Quo(circulating, rate))
+		}
+		if volume := parseRat(t.Volume24h); volume != nil {
+			normalized.Volume24hTokens = formatRat(new(big.Rat).Quo(volume, rate))
+		}
+	}
+
+	supply, err := t.TotalSupplyInt()
+	if err != nil {
+		return normalized
+	}
+	decimals, err := t.DecimalsInt()
+	if err != nil || decimals.Sign() < 0 || decimals.Cmp(big.NewInt(maxDecimals)) > 0 {
+		return normalized
+	}
+
+	formatted := FormatUnits(supply, int(decimals.Int64()))
+	normalized.TotalSupplyFormatted = &formatted
+
+	if rate == nil {
+		return normalized
+	}
+	scale := new(big.Int).Exp(big.NewInt(10), decimals, nil)
+	marketCap := new(big.Rat).SetFrac(supply, scale)
+	marketCap.Mul(marketCap, rate)
+	marketCapStr := marketCap.FloatString(2)
+	normalized.MarketCapUSD = &marketCapStr
+
+	return normalized
+}
+
+// NormalizeTokenResponse returns a copy of the response with every item normalised
+func NormalizeTokenResponse(response *TokenResponse) *NormalizedTokenResponse {
+	normalized := &NormalizedTokenResponse{Items: []NormalizedToken{}}
+	if response == nil {
+		return normalized
+	}
+
+	for _, token := range response.Items {
+		normalized.Items = append(normalized.Items, token.Normalize())
+	}
+	return normalized
+}
+
+// FormatUnits formats a base-unit amount as an exact decimal string using the given number of decimals
+func FormatUnits(amount *big.Int, decimals int) string {
+	if amount == nil {
+		return "0"
+	}
+
+	negative := amount.Sign() < 0
+	digits := new(big.Int).Abs(amount).String()
+
+	if decimals > 0 {
+		if len(digits) <= decimals {
+			digits = strings.Repeat("0", decimals-len(digits)+1) + digits
+		}
+		intPart := digits[:len(digits)-decimals]
+		fracPart := strings.TrimRight(digits[len(digits)-decimals:], "0")
+		digits = intPart
+		if fracPart != "" {
+			digits += "." + fracPart
+		}
+	}
+
+	if negative {
+		return "-" + digits
+	}
+	return digits
+}
+
+// parseBigInt parses a base-10 integer string field
+func parseBigInt(field, value string) (*big.Int, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, fmt.Errorf("%s is empty", field)
+	}
+
+	n, ok := new(big.Int).SetString(value, 10)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a valid integer: %q", field, value)
+	}
+	return n, nil
+}
+
+// parseBigFloat parses an optional decimal string field
+func parseBigFloat(field string, value *string) (*big.Float, error) {
+	if value == nil || strings.TrimSpace(*value) == "" {
+		return nil, nil
+	}
+
+	f, _, err := big.ParseFloat(strings.TrimSpace(*value), 10, floatPrecision, big.ToNearestEven)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a valid number: %w", field, err)
+	}
+	return f, nil
+}
+
+// parseRat parses an optional decimal string field as an exact rational, or nil
+func parseRat(value *string) *big.Rat {
+	if value == nil || strings.TrimSpace(*value) == "" {
+		return nil
+	}
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(*value))
+	if !ok {
+		return nil
+	}
+	return r
+}
+
+// formatRat formats a derived token amount with up to ratDigits decimal places,
+// trimming trailing zeros
+func formatRat(r *big.Rat) *string {
+	formatted := r.FloatString(ratDigits)
+	if strings.Contains(formatted, ".") {
+		formatted = strings.TrimRight(strings.TrimRight(formatted, "0"), ".")
+	}
+	return &formatted
+}
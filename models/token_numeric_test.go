@@ -0,0 +1,191 @@
+package models
+
+import (
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestFormatUnits(t *testing.T) {
+	tests := []struct {
+		name     string
+		amount   string
+		decimals int
+		expected string
+	}{
+		{"whole units", "1000000000000000000", 18, "1"},
+		{"fractional", "1500000000000000000", 18, "1.5"},
+		{"smaller than one unit", "1", 18, "0.000000000000000001"},
+		{"zero", "0", 18, "0"},
+		{"no decimals", "12345", 0, "12345"},
+		{"negative", "-250", 2, "-2.5"},
+		{"huge supply stays exact", "123456789012345678901234567890", 6, "123456789012345678901234.56789"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			amount, _ := new(big.Int).SetString(tt.amount, 10)
+			if got := FormatUnits(amount, tt.decimals); got != tt.expected {
+				t.Errorf("FormatUnits(%s, %d) = %s, expected %s", tt.amount, tt.decimals, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestToken_NumericHelpers(t *testing.T) {
+	rate := "1.25"
+	token := Token{
+		Decimals:     "18",
+		Holders:      "42",
+		TotalSupply:  "1000000000000000000000000",
+		ExchangeRate: &rate,
+	}
+
+	decimals, err := token.DecimalsInt()
+	if err != nil || decimals.Int64() != 18 {
+		t.Errorf("Expected decimals 18, got %v (err: %v)", decimals, err)
+	}
+
+	holders, err := token.HoldersInt()
+	if err != nil || holders.Int64() != 42 {
+		t.Errorf("Expected holders 42, got %v (err: %v)", holders, err)
+	}
+
+	supply, err := token.TotalSupplyInt()
+	if err != nil || supply.String() != "1000000000000000000000000" {
+		t.Errorf("Expected exact total supply, got %v (err: %v)", supply, err)
+	}
+
+	exchangeRate, err := token.ExchangeRateFloat()
+	if err != nil {
+		t.Fatalf("Unexpected error parsing exchange rate: %v", err)
+	}
+	if f, _ := exchangeRate.Float64(); f != 1.25 {
+		t.Errorf("Expected exchange rate 1.25, got %v", f)
+	}
+
+	volume, err := token.Volume24hFloat()
+	if err != nil || volume != nil {
+		t.Errorf("Expected nil volume for missing field, got %v (err: %v)", volume, err)
+	}
+}
+
+func TestToken_NumericHelpers_InvalidValues(t *testing.T) {
+	bad := "abc"
+	token := Token{Decimals: "", TotalSupply: "12.5", ExchangeRate: &bad}
+
+	if _, err := token.DecimalsInt(); err == nil {
+		t.Error("Expected error for empty decimals")
+	}
+	if _, err := token.TotalSupplyInt(); err == nil {
+		t.Error("Expected error for non-integer total supply")
+	}
+	if _, err := token.ExchangeRateFloat(); err == nil {
+		t.Error("Expected error for invalid exchange rate")
+	}
+}
+
+func TestToken_Normalize(t *testing.T) {
+	rate := "0.5"
+	token := Token{
+		Address:      "0x1234",
+		Decimals:     "6",
+		TotalSupply:  "123456789",
+		ExchangeRate: &rate,
+	}
+
+	normalized := token.Normalize()
+
+	if normalized.TotalSupplyFormatted == nil || *normalized.TotalSupplyFormatted != "123.456789" {
+		t.Errorf("Expected total_supply_formatted 123.456789, got %v", normalized.TotalSupplyFormatted)
+	}
+	if normalized.MarketCapUSD == nil || *normalized.MarketCapUSD != "61.73" {
+		t.Errorf("Expected market_cap_usd 61.73, got %v", normalized.MarketCapUSD)
+	}
+
+	data, err := json.Marshal(normalized)
+	if err != nil {
+		t.Fatalf("Failed to marshal normalized token: %v", err)
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatalf("Failed to unmarshal normalized token: %v", err)
+	}
+	if fields["address"] != "0x1234" {
+		t.Errorf("Expected embedded token fields to be flattened, got %v", fields["address"])
+	}
+	if fields["total_supply_formatted"] != "123.456789" {
+		t.Errorf("Expected total_supply_formatted in JSON, got %v", fields["total_supply_formatted"])
+	}
+}
+
+func TestToken_Normalize_DerivedFields(t *testing.T) {
+	rate := "2.5"
+	circulating := "1000"
+	volume := "0.1"
+	token := Token{
+		Decimals:             "18",
+		TotalSupply:          "1000000000000000000000",
+		ExchangeRate:         &rate,
+		CirculatingMarketCap: &circulating,
+		Volume24h:            &volume,
+	}
+
+	normalized := token.Normalize()
+
+	expected := map[string]*string{
+		"circulating_supply_formatted": normalized.CirculatingSupplyFormatted,
+		"volume_24h_tokens":            normalized.Volume24hTokens,
+		"market_cap_usd":               normalized.MarketCapUSD,
+	}
+	want := map[string]string{
+		"circulating_supply_formatted": "400",
+		"volume_24h_tokens":            "0.04",
+		"market_cap_usd":               "2500.00",
+	}
+	for field, got := range expected {
+		if got == nil || *got != want[field] {
+			t.Errorf("Expected %s %s, got %v", field, want[field], got)
+		}
+	}
+
+	zero := "0"
+	normalized = Token{ExchangeRate: &zero, Volume24h: &volume}.Normalize()
+	if normalized.Volume24hTokens != nil {
+		t.Errorf("Expected no derived volume for a zero rate, got %+v", normalized)
+	}
+}
+
+func TestToken_Normalize_MissingInputs(t *testing.T) {
+	normalized := Token{Address: "0x1234", TotalSupply: "1000"}.Normalize()
+
+	if normalized.TotalSupplyFormatted != nil {
+		t.Errorf("Expected nil total_supply_formatted without decimals, got %v", *normalized.TotalSupplyFormatted)
+	}
+	if normalized.MarketCapUSD != nil {
+		t.Errorf("Expected nil market_cap_usd without decimals, got %v", *normalized.MarketCapUSD)
+	}
+
+	normalized = Token{Decimals: "2", TotalSupply: "1000"}.Normalize()
+	if normalized.TotalSupplyFormatted == nil || *normalized.TotalSupplyFormatted != "10" {
+		t.Errorf("Expected total_supply_formatted 10, got %v", normalized.TotalSupplyFormatted)
+	}
+	if normalized.MarketCapUSD != nil {
+		t.Errorf("Expected nil market_cap_usd without exchange rate, got %v", *normalized.MarketCapUSD)
+	}
+}
+
+func TestToken_Normalize_RejectsExcessiveDecimals(t *testing.T) {
+	rate := "1"
+	normalized := Token{Decimals: "1000000000", TotalSupply: "1000", ExchangeRate: &rate}.Normalize()
+	if normalized.TotalSupplyFormatted != nil || normalized.MarketCapUSD != nil {
+		t.Errorf("Expected no formatted fields for decimals above %d, got %+v", maxDecimals, normalized)
+	}
+
+	normalized = Token{Decimals: "255", TotalSupply: "1"}.Normalize()
+	if normalized.TotalSupplyFormatted == nil || *normalized.TotalSupplyFormatted != "0."+strings.Repeat("0", 254)+"1" {
+		t.Errorf("Expected total_supply_formatted with 255 decimals, got %v", normalized.TotalSupplyFormatted)
+	}
+}
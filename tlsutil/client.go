@@ -0,0 +1,241 @@
+package tlsutil
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go-api-proxy/filewatch"
+	"go-api-proxy/logger"
+)
+
+// ClientOptions configures TLS for connections to the backend
+type ClientOptions struct {
+	CertFile       string   // client certificate for mutual TLS
+	KeyFile        string   // key of the client certificate
+	CAFile         string   // PEM bundle of root CAs trusted instead of the system roots
+	ServerName     string   // SNI and verification name, overriding the backend host name
+	PinSHA256      []string // SHA-256 fingerprints of acceptable backend certificates
+	Hosts          []string // backend host names the settings apply to; empty applies them to every host
+	ReloadInterval time.Duration
+}
+
+// ClientTLS is the TLS configuration for backend connections. The client certificate
+// and the CA bundle are reloaded when their files change; new connections pick up
+// the new files while established connections keep running.
+type ClientTLS struct {
+	certs      *CertReloader
+	caFile     string
+	serverName string
+	pins       [][]byte
+	hosts      map[string]bool
+	watcher    *filewatch.Watcher
+
+	mu    sync.RWMutex
+	roots *x509.CertPool
+	caMod filewatch.Version
+}
+
+// NewClientTLS loads the backend TLS files. It returns nil when no option is set,
+// in which case the standard library defaults apply.
+func NewClientTLS(opts ClientOptions) (*ClientTLS, error) {
+	if opts.CertFile == "" && opts.KeyFile == "" && opts.CAFile == "" && opts.ServerName == "" && len(opts.PinSHA256) == 0 {
+		return nil, nil
+	}
+	if (opts.CertFile == "") != (opts.KeyFile == "") {
+		return nil, fmt.Errorf("client certificate and key must be set together")
+	}
+
+	c := &ClientTLS{
+		caFile:     opts.CAFile,
+		serverName: opts.ServerName,
+	}
+
+	if len(opts.Hosts) > 0 {
+		c.hosts = make(map[string]bool, len(opts.Hosts))
+		for _, host := range opts.Hosts {
+			c.hosts[strings.ToLower(host)] = true
+		}
+	}
+
+	for _, pin := range opts.PinSHA256 {
+		decoded, err := ParseFingerprint(pin)
+		if err != nil {
+			return nil, err
+		}
+		c.pins = append(c.pins, decoded)
+	}
+
+	if opts.CertFile != "" {
+		certs, err := NewCertReloader(opts.CertFile, opts.KeyFile, 0)
+		if err != nil {
+			return nil, err
+		}
+		c.certs = certs
+	}
+
+	var sources []filewatch.Source
+	if c.certs != nil {
+		sources = append(sources, filewatch.Source{
+			Changed: c.certs.Changed,
+			Reload:  c.certs.Reload,
+			Logger:  logger.TLSLogger,
+			Failure: "Failed to reload backend client certificate, keeping the current one",
+		})
+	}
+	if c.caFile != "" {
+		if err := c.reloadCA(); err != nil {
+			return nil, err
+		}
+		sources = append(sources, filewatch.Source{
+			Changed: c.caChanged,
+			Reload:  c.reloadCA,
+			Logger:  logger.TLSLogger,
+			Failure: "Failed to reload backend CA bundle, keeping the current one",
+		})
+	}
+	c.watcher = filewatch.New(opts.ReloadInterval, sources...)
+
+	return c, nil
+}
+
+// ParseFingerprint decodes a hex SHA-256 fingerprint, with or without colons
+func ParseFingerprint(fingerprint string) ([]byte, error) {
+	cleaned := strings.ReplaceAll(strings.TrimSpace(fingerprint), ":", "")
+	decoded, err := hex.DecodeString(cleaned)
+	if err != nil || len(decoded) != sha256.Size {
+		return nil, fmt.Errorf("invalid SHA-256 fingerprint %q", fingerprint)
+	}
+	return decoded, nil
+}
+
+// ConfigFor returns a TLS configuration for dialing host, or nil for the defaults.
+// Hosts other than the configured backends, such as a route's own backend, get the
+// defaults. The configuration is built per connection so that it uses the CA bundle
+// that is current at dial time and verifies the host being dialed, including IP
+// addresses, for which no server name is sent.
+func (c *ClientTLS) ConfigFor(host string) *tls.Config {
+	if c == nil || (c.hosts != nil && !c.hosts[strings.ToLower(host)]) {
+		return nil
+	}
+
+	c.mu.RLock()
+	roots := c.roots
+	c.mu.RUnlock()
+
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: host,
+		RootCAs:    roots,
+	}
+	if c.serverName != "" {
+		cfg.ServerName = c.serverName
+	}
+	if len(c.pins) > 0 {
+		cfg.VerifyConnection = c.verifyPin
+	}
+	if c.certs != nil {
+		cfg.GetClientCertificate = c.certs.GetClientCertificate
+	}
+	return cfg
+}
+
+// DialTLSContext dials addr and performs the TLS handshake, for use as
+// http.Transport.DialTLSContext
+func (c *ClientTLS) DialTLSContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	cfg := c.ConfigFor(host)
+	if cfg == nil {
+		cfg = &tls.Config{ServerName: host}
+	}
+	cfg.NextProtos = []string{"h2", "http/1.1"}
+
+	dialer := &tls.Dialer{Config: cfg}
+	return dialer.DialContext(ctx, network, addr)
+}
+
+// Start watches the certificate and CA files for changes
+func (c *ClientTLS) Start() {
+	if c == nil {
+		return
+	}
+	c.watcher.Start()
+}
+
+// Stop stops watching the files
+func (c *ClientTLS) Stop() {
+	if c == nil {
+		return
+	}
+	c.watcher.Stop()
+}
+
+// verifyPin checks the verified backend certificate against the pinned fingerprints
+func (c *ClientTLS) verifyPin(state tls.ConnectionState) error {
+	if len(state.PeerCertificates) == 0 {
+		return errors.New("backend presented no certificate")
+	}
+
+	fingerprint := sha256.Sum256(state.PeerCertificates[0].Raw)
+	for _, pin := range c.pins {
+		if subtle.ConstantTimeCompare(fingerprint[:], pin) == 1 {
+			return nil
+		}
+	}
+	return fmt.Errorf("backend certificate fingerprint %s does not match any pinned fingerprint", hex.EncodeToString(fingerprint[:]))
+}
+
+// reloadCA loads the CA bundle, keeping the current roots if it cannot be loaded
+func (c *ClientTLS) reloadCA() error {
+	version, err := filewatch.Stat(c.caFile)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(c.caFile)
+	if err != nil {
+		return fmt.Errorf("failed to read CA file %s: %w", c.caFile, err)
+	}
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(data) {
+		return fmt.Errorf("CA file %s contains no certificates", c.caFile)
+	}
+
+	c.mu.Lock()
+	c.roots = roots
+	c.caMod = version
+	c.mu.Unlock()
+
+	logger.TLSLogger.Info("Loaded backend CA bundle", map[string]interface{}{
+		"ca_file": c.caFile,
+	})
+	return nil
+}
+
+// caChanged reports whether the CA file differs from the loaded version
+func (c *ClientTLS) caChanged() bool {
+	if c.caFile == "" {
+		return false
+	}
+	version, err := filewatch.Stat(c.caFile)
+	if err != nil {
+		return false
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return version != c.caMod
+}
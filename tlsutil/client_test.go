@@ -0,0 +1,313 @@
+package tlsutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCA is a certificate authority for issuing test certificates
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T, commonName string) *testCA {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create CA certificate: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue creates a certificate for name signed by the CA
+func (ca *testCA) issue(t *testing.T, name string, usage x509.ExtKeyUsage) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// writeKeyPair writes a certificate and its key as PEM files into dir
+func writeKeyPair(t *testing.T, dir string, cert tls.Certificate) (string, string) {
+	t.Helper()
+
+	keyDER, _ := x509.MarshalECPrivateKey(cert.PrivateKey.(*ecdsa.PrivateKey))
+	certFile := filepath.Join(dir, "client.pem")
+	keyFile := filepath.Join(dir, "client-key.pem")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}), 0o644)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600)
+	return certFile, keyFile
+}
+
+// newMutualTLSServer starts a TLS server for backend.internal that requires a
+// client certificate issued by clientCA
+func newMutualTLSServer(t *testing.T, serverCert tls.Certificate, clientCA *testCA) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCA.cert)
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server
+}
+
+// get performs a request over a fresh connection and returns the response body
+func get(clientTLS *ClientTLS, url string) (string, error) {
+	client := &http.Client{Transport: &http.Transport{
+		DialTLSContext:    clientTLS.DialTLSContext,
+		DisableKeepAlives: true,
+	}}
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	return string(body), err
+}
+
+func TestClientTLS_MutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, "Test CA")
+	server := newMutualTLSServer(t, ca.issue(t, "backend.internal", x509.ExtKeyUsageServerAuth), ca)
+
+	caFile := filepath.Join(dir, "ca.pem")
+	os.WriteFile(caFile, ca.pem, 0o644)
+	certFile, keyFile := writeKeyPair(t, dir, ca.issue(t, "proxy-client", x509.ExtKeyUsageClientAuth))
+
+	clientTLS, err := NewClientTLS(ClientOptions{
+		CertFile:   certFile,
+		KeyFile:    keyFile,
+		CAFile:     caFile,
+		ServerName: "backend.internal",
+	})
+	if err != nil {
+		t.Fatalf("Failed to load client TLS: %v", err)
+	}
+	if body, err := get(clientTLS, server.URL); err != nil || body != "proxy-client" {
+		t.Fatalf("Expected the client certificate to be presented, got %q, %v", body, err)
+	}
+
+	withoutCert, _ := NewClientTLS(ClientOptions{CAFile: caFile, ServerName: "backend.internal"})
+	if _, err := get(withoutCert, server.URL); err == nil {
+		t.Error("Expected the backend to reject a connection without a client certificate")
+	}
+
+	wrongName, _ := NewClientTLS(ClientOptions{CertFile: certFile, KeyFile: keyFile, CAFile: caFile})
+	if _, err := get(wrongName, server.URL); err == nil {
+		t.Error("Expected verification to fail without the server name override")
+	}
+
+	systemRoots, _ := NewClientTLS(ClientOptions{CertFile: certFile, KeyFile: keyFile, ServerName: "backend.internal"})
+	if _, err := get(systemRoots, server.URL); err == nil {
+		t.Error("Expected verification to fail against the system roots")
+	}
+}
+
+func TestClientTLS_Pinning(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, "Test CA")
+	serverCert := ca.issue(t, "backend.internal", x509.ExtKeyUsageServerAuth)
+	server := newMutualTLSServer(t, serverCert, ca)
+
+	caFile := filepath.Join(dir, "ca.pem")
+	os.WriteFile(caFile, ca.pem, 0o644)
+	certFile, keyFile := writeKeyPair(t, dir, ca.issue(t, "proxy-client", x509.ExtKeyUsageClientAuth))
+
+	fingerprint := sha256.Sum256(serverCert.Certificate[0])
+	encoded := strings.ToUpper(hex.EncodeToString(fingerprint[:]))
+	var colons []string
+	for i := 0; i < len(encoded); i += 2 {
+		colons = append(colons, encoded[i:i+2])
+	}
+
+	pinned, err := NewClientTLS(ClientOptions{
+		CertFile:   certFile,
+		KeyFile:    keyFile,
+		CAFile:     caFile,
+		ServerName: "backend.internal",
+		PinSHA256:  []string{strings.Repeat("ab", sha256.Size), strings.Join(colons, ":")},
+	})
+	if err != nil {
+		t.Fatalf("Failed to load client TLS: %v", err)
+	}
+	if _, err := get(pinned, server.URL); err != nil {
+		t.Errorf("Expected the pinned certificate to be accepted, got %v", err)
+	}
+
+	mismatched, _ := NewClientTLS(ClientOptions{
+		CertFile:   certFile,
+		KeyFile:    keyFile,
+		CAFile:     caFile,
+		ServerName: "backend.internal",
+		PinSHA256:  []string{strings.Repeat("ab", sha256.Size)},
+	})
+	if _, err := get(mismatched, server.URL); err == nil || !strings.Contains(err.Error(), "pinned") {
+		t.Errorf("Expected a pin mismatch error, got %v", err)
+	}
+}
+
+func TestClientTLS_AppliesOnlyToBackendHosts(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, "Test CA")
+	server := newMutualTLSServer(t, ca.issue(t, "backend.internal", x509.ExtKeyUsageServerAuth), ca)
+
+	caFile := filepath.Join(dir, "ca.pem")
+	os.WriteFile(caFile, ca.pem, 0o644)
+	certFile, keyFile := writeKeyPair(t, dir, ca.issue(t, "proxy-client", x509.ExtKeyUsageClientAuth))
+	opts := ClientOptions{
+		CertFile:   certFile,
+		KeyFile:    keyFile,
+		CAFile:     caFile,
+		ServerName: "backend.internal",
+		PinSHA256:  []string{strings.Repeat("ab", sha256.Size)},
+		Hosts:      []string{"backend.example"},
+	}
+
+	clientTLS, err := NewClientTLS(opts)
+	if err != nil {
+		t.Fatalf("Failed to load client TLS: %v", err)
+	}
+	if cfg := clientTLS.ConfigFor("other.example"); cfg != nil {
+		t.Errorf("Expected the defaults for a host that is not a configured backend, got %+v", cfg)
+	}
+	if cfg := clientTLS.ConfigFor("Backend.Example"); cfg == nil || cfg.ServerName != "backend.internal" || cfg.VerifyConnection == nil {
+		t.Errorf("Expected the server name override and pins for a configured backend, got %+v", cfg)
+	}
+
+	// The test server is not a configured backend, so it is verified against the
+	// system roots without the override, and fails
+	if _, err := get(clientTLS, server.URL); err == nil || strings.Contains(err.Error(), "pinned") {
+		t.Errorf("Expected default verification for an unconfigured host, got %v", err)
+	}
+}
+
+func TestClientTLS_ReloadsCA(t *testing.T) {
+	dir := t.TempDir()
+	oldCA := newTestCA(t, "Old CA")
+	newCA := newTestCA(t, "New CA")
+	server := newMutualTLSServer(t, newCA.issue(t, "backend.internal", x509.ExtKeyUsageServerAuth), oldCA)
+
+	caFile := filepath.Join(dir, "ca.pem")
+	os.WriteFile(caFile, oldCA.pem, 0o644)
+	certFile, keyFile := writeKeyPair(t, dir, oldCA.issue(t, "proxy-client", x509.ExtKeyUsageClientAuth))
+
+	clientTLS, err := NewClientTLS(ClientOptions{
+		CertFile:       certFile,
+		KeyFile:        keyFile,
+		CAFile:         caFile,
+		ServerName:     "backend.internal",
+		ReloadInterval: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Failed to load client TLS: %v", err)
+	}
+	clientTLS.Start()
+	defer clientTLS.Stop()
+
+	if _, err := get(clientTLS, server.URL); err == nil {
+		t.Fatal("Expected the backend certificate from the new CA to be rejected")
+	}
+
+	// Rotate the CA bundle; a later modification time makes the change visible
+	os.WriteFile(caFile, newCA.pem, 0o644)
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(caFile, future, future)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := get(clientTLS, server.URL); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the rotated CA bundle to be picked up")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestNewClientTLS_Options(t *testing.T) {
+	clientTLS, err := NewClientTLS(ClientOptions{ReloadInterval: time.Second})
+	if err != nil || clientTLS != nil {
+		t.Errorf("Expected nil without options, got %v, %v", clientTLS, err)
+	}
+	if clientTLS.ConfigFor("backend.internal") != nil {
+		t.Error("Expected a nil config from a nil ClientTLS")
+	}
+	clientTLS.Start()
+	clientTLS.Stop()
+
+	if _, err := NewClientTLS(ClientOptions{CertFile: "client.pem"}); err == nil {
+		t.Error("Expected an error for a certificate without a key")
+	}
+	if _, err := NewClientTLS(ClientOptions{PinSHA256: []string{"not-hex"}}); err == nil {
+		t.Error("Expected an error for an invalid fingerprint")
+	}
+	if _, err := NewClientTLS(ClientOptions{CAFile: filepath.Join(t.TempDir(), "missing.pem")}); err == nil {
+		t.Error("Expected an error for a missing CA file")
+	}
+
+	if _, err := ParseFingerprint(strings.Repeat("AB:", sha256.Size-1) + "AB"); err != nil {
+		t.Errorf("Expected a colon-separated fingerprint to parse, got %v", err)
+	}
+	if _, err := ParseFingerprint(strings.Repeat("ab", sha256.Size-1)); err == nil {
+		t.Error("Expected a short fingerprint to be rejected")
+	}
+}
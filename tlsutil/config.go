@@ -0,0 +1,66 @@
+package tlsutil
+
+import (
+	"crypto/tls"
+	"fmt"
+)
+
+// Cipher policies
+const (
+	// CipherPolicyModern only allows TLS 1.3, whose cipher suites are all strong
+	CipherPolicyModern = "modern"
+	// CipherPolicyIntermediate allows TLS 1.2 with forward-secret AEAD suites only
+	CipherPolicyIntermediate = "intermediate"
+	// CipherPolicyDefault uses the Go standard library's defaults
+	CipherPolicyDefault = "default"
+)
+
+// intermediateCipherSuites are the TLS 1.2 suites allowed by the intermediate policy
+var intermediateCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+}
+
+// ParseVersion converts "1.2" or "1.3" to a TLS version. An empty version means
+// the default, TLS 1.2, as it does for TLS_MIN_VERSION.
+func ParseVersion(version string) (uint16, error) {
+	switch version {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("unsupported TLS version %q", version)
+	}
+}
+
+// ServerConfig builds the TLS configuration for the proxy's listener. Certificates
+// come from the reloader so rotated files are picked up by new handshakes.
+func ServerConfig(certs *CertReloader, minVersion, cipherPolicy string) (*tls.Config, error) {
+	version, err := ParseVersion(minVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &tls.Config{
+		MinVersion:     version,
+		GetCertificate: certs.GetCertificate,
+		NextProtos:     []string{"h2", "http/1.1"},
+	}
+
+	switch cipherPolicy {
+	case CipherPolicyModern:
+		cfg.MinVersion = tls.VersionTLS13
+	case CipherPolicyIntermediate, "":
+		cfg.CipherSuites = intermediateCipherSuites
+	case CipherPolicyDefault:
+	default:
+		return nil, fmt.Errorf("unknown cipher policy %q", cipherPolicy)
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,43 @@
+package tlsutil
+
+import (
+	"crypto/tls"
+	"testing"
+)
+
+func TestServerConfig(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCert(t, dir, "proxy.example.com")
+	reloader, err := NewCertReloader(certFile, keyFile, 0)
+	if err != nil {
+		t.Fatalf("Failed to load certificate: %v", err)
+	}
+
+	cfg, err := ServerConfig(reloader, "1.2", CipherPolicyIntermediate)
+	if err != nil {
+		t.Fatalf("ServerConfig failed: %v", err)
+	}
+	if cfg.MinVersion != tls.VersionTLS12 || len(cfg.CipherSuites) != len(intermediateCipherSuites) {
+		t.Errorf("Expected TLS 1.2 with intermediate suites, got %+v", cfg)
+	}
+	if cert, _ := cfg.GetCertificate(nil); cert != reloader.Certificate() {
+		t.Error("Expected certificates to come from the reloader")
+	}
+
+	if cfg, _ := ServerConfig(reloader, "1.2", CipherPolicyModern); cfg.MinVersion != tls.VersionTLS13 {
+		t.Error("Expected the modern policy to require TLS 1.3")
+	}
+	if cfg, _ := ServerConfig(reloader, "1.3", CipherPolicyDefault); cfg.MinVersion != tls.VersionTLS13 || cfg.CipherSuites != nil {
+		t.Error("Expected the default policy to keep Go's cipher suites")
+	}
+
+	if cfg, err := ServerConfig(reloader, "", CipherPolicyDefault); err != nil || cfg.MinVersion != tls.VersionTLS12 {
+		t.Errorf("Expected an empty version to default to TLS 1.2, got %v", err)
+	}
+	if _, err := ServerConfig(reloader, "1.0", CipherPolicyDefault); err == nil {
+		t.Error("Expected an error for TLS 1.0")
+	}
+	if _, err := ServerConfig(reloader, "1.2", "legacy"); err == nil {
+		t.Error("Expected an error for an unknown cipher policy")
+	}
+}
@@ -0,0 +1,121 @@
+// Package tlsutil provides TLS configuration helpers shared by the proxy's listener
+// and its backend client, including certificates that follow rotated files.
+package tlsutil
+
+import (
+	"crypto/tls"
+	"fmt"
+	"sync"
+	"time"
+
+	"go-api-proxy/filewatch"
+	"go-api-proxy/logger"
+)
+
+// CertReloader holds a certificate loaded from a cert/key file pair and reloads it
+// when either file changes. Handshakes always use the latest certificate that loaded
+// successfully, so rotating the files never interrupts established connections.
+type CertReloader struct {
+	certFile string
+	keyFile  string
+	watcher  *filewatch.Watcher
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	certMod filewatch.Version
+	keyMod  filewatch.Version
+}
+
+// NewCertReloader loads the certificate and key. The files are checked for changes
+// every interval once Start is called.
+func NewCertReloader(certFile, keyFile string, interval time.Duration) (*CertReloader, error) {
+	r := &CertReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	r.watcher = filewatch.New(interval, filewatch.Source{
+		Changed: r.Changed,
+		Reload:  r.Reload,
+		Logger:  logger.TLSLogger,
+		Failure: "Failed to reload rotated certificate, keeping the current one",
+		Fields:  map[string]interface{}{"cert_file": r.certFile},
+	})
+	return r, nil
+}
+
+// Certificate returns the current certificate
+func (r *CertReloader) Certificate() *tls.Certificate {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert
+}
+
+// GetCertificate implements tls.Config.GetCertificate for servers
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.Certificate(), nil
+}
+
+// GetClientCertificate implements tls.Config.GetClientCertificate for clients
+func (r *CertReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return r.Certificate(), nil
+}
+
+// Reload loads the certificate and key from disk, keeping the current certificate
+// if they cannot be loaded
+func (r *CertReloader) Reload() error {
+	certMod, err := filewatch.Stat(r.certFile)
+	if err != nil {
+		return err
+	}
+	keyMod, err := filewatch.Stat(r.keyFile)
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load certificate %s: %w", r.certFile, err)
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.certMod = certMod
+	r.keyMod = keyMod
+	r.mu.Unlock()
+
+	fields := map[string]interface{}{
+		"cert_file": r.certFile,
+	}
+	if cert.Leaf != nil {
+		fields["subject"] = cert.Leaf.Subject.String()
+		fields["not_after"] = cert.Leaf.NotAfter.Format(time.RFC3339)
+	}
+	logger.TLSLogger.Info("Loaded certificate", fields)
+	return nil
+}
+
+// Changed reports whether the certificate or key file differs from the loaded version
+func (r *CertReloader) Changed() bool {
+	certMod, certErr := filewatch.Stat(r.certFile)
+	keyMod, keyErr := filewatch.Stat(r.keyFile)
+	if certErr != nil || keyErr != nil {
+		return false
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return certMod != r.certMod || keyMod != r.keyMod
+}
+
+// Start watches the files and reloads the certificate when they change
+func (r *CertReloader) Start() {
+	r.watcher.Start()
+}
+
+// Stop stops watching the files
+func (r *CertReloader) Stop() {
+	r.watcher.Stop()
+}
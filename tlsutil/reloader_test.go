@@ -0,0 +1,111 @@
+package tlsutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestCert writes a self-signed certificate and key for commonName into dir
+func writeTestCert(t *testing.T, dir, commonName string) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     []string{commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600)
+	return certFile, keyFile
+}
+
+func commonName(t *testing.T, cert *tls.Certificate) string {
+	t.Helper()
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	return leaf.Subject.CommonName
+}
+
+func TestCertReloader_ReloadsRotatedFiles(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCert(t, dir, "old.example.com")
+
+	reloader, err := NewCertReloader(certFile, keyFile, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Failed to load certificate: %v", err)
+	}
+	reloader.Start()
+	defer reloader.Stop()
+
+	if name := commonName(t, reloader.Certificate()); name != "old.example.com" {
+		t.Fatalf("Expected initial certificate, got %s", name)
+	}
+
+	// Rotate the files; a later modification time makes the change visible
+	writeTestCert(t, dir, "new.example.com")
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, future, future)
+	os.Chtimes(keyFile, future, future)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for commonName(t, reloader.Certificate()) != "new.example.com" {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected rotated certificate to be loaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCertReloader_KeepsCertificateOnBadRotation(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCert(t, dir, "good.example.com")
+
+	reloader, err := NewCertReloader(certFile, keyFile, 0)
+	if err != nil {
+		t.Fatalf("Failed to load certificate: %v", err)
+	}
+
+	os.WriteFile(keyFile, []byte("not a key"), 0o600)
+	if !reloader.Changed() {
+		t.Error("Expected the key change to be detected")
+	}
+	if err := reloader.Reload(); err == nil {
+		t.Error("Expected reload of a broken key to fail")
+	}
+	if name := commonName(t, reloader.Certificate()); name != "good.example.com" {
+		t.Errorf("Expected previous certificate to stay in use, got %s", name)
+	}
+
+	if _, err := NewCertReloader(filepath.Join(dir, "missing.pem"), keyFile, 0); err == nil {
+		t.Error("Expected an error for a missing certificate file")
+	}
+}
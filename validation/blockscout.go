@@ -0,0 +1,55 @@
+package validation
+
+import "net/url"
+
+// Rule validates the parameters of requests to paths matching a pattern. Patterns use
+// the route pattern syntax; each {name} segment is checked against Params[name].
+type Rule struct {
+	Pattern    string
+	Params     map[string]Kind
+	Pagination bool // check the pagination query parameters
+}
+
+// Validate checks the path parameters captured by the pattern and the query
+func (r Rule) Validate(params map[string]string, query url.Values) error {
+	for name, kind := range r.Params {
+		value, ok := params[name]
+		if !ok {
+			continue
+		}
+		if err := Param(name, kind, value); err != nil {
+			return err
+		}
+	}
+	if r.Pagination {
+		return Pagination(query)
+	}
+	return nil
+}
+
+// BlockscoutV2Rules covers the Blockscout v2 API paths that take an address, hash or
+// block in the path. The first matching rule applies, so fixed paths that share a
+// prefix with a parameterised path are listed first without parameters.
+var BlockscoutV2Rules = []Rule{
+	{Pattern: "/api/v2/transactions/watchlist", Pagination: true},
+	{Pattern: "/api/v2/transactions/stats"},
+	{Pattern: "/api/v2/transactions/zkevm-batch/{batch}/*", Params: map[string]Kind{"batch": KindCount}, Pagination: true},
+	{Pattern: "/api/v2/transactions/zksync-batch/{batch}/*", Params: map[string]Kind{"batch": KindCount}, Pagination: true},
+	{Pattern: "/api/v2/transactions/arbitrum-batch/{batch}/*", Params: map[string]Kind{"batch": KindCount}, Pagination: true},
+	{Pattern: "/api/v2/transactions/optimism-batch/{batch}/*", Params: map[string]Kind{"batch": KindCount}, Pagination: true},
+	{Pattern: "/api/v2/transactions/scroll-batch/{batch}/*", Params: map[string]Kind{"batch": KindCount}, Pagination: true},
+	{Pattern: "/api/v2/transactions/execution-node/*", Pagination: true},
+	{Pattern: "/api/v2/transactions/{hash}/*", Params: map[string]Kind{"hash": KindHash}, Pagination: true},
+	{Pattern: "/api/v2/blocks/arbitrum-batch/{batch}/*", Params: map[string]Kind{"batch": KindCount}, Pagination: true},
+	{Pattern: "/api/v2/blocks/optimism-batch/{batch}/*", Params: map[string]Kind{"batch": KindCount}, Pagination: true},
+	{Pattern: "/api/v2/blocks/scroll-batch/{batch}/*", Params: map[string]Kind{"batch": KindCount}, Pagination: true},
+	{Pattern: "/api/v2/blocks/{block}/*", Params: map[string]Kind{"block": KindBlock}, Pagination: true},
+	{Pattern: "/api/v2/addresses/counters"},
+	{Pattern: "/api/v2/addresses/{address}/*", Params: map[string]Kind{"address": KindAddress}, Pagination: true},
+	{Pattern: "/api/v2/tokens/bridged", Pagination: true},
+	{Pattern: "/api/v2/tokens/{address}/*", Params: map[string]Kind{"address": KindAddress}, Pagination: true},
+	{Pattern: "/api/v2/smart-contracts/counters"},
+	{Pattern: "/api/v2/smart-contracts/verification/*"},
+	{Pattern: "/api/v2/smart-contracts/{address}/*", Params: map[string]Kind{"address": KindAddress}, Pagination: true},
+	{Pattern: "/api/v2/*", Pagination: true},
+}
@@ -0,0 +1,35 @@
+package validation
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestRuleValidate(t *testing.T) {
+	rule := Rule{Pattern: "/api/v2/transactions/{hash}/*", Params: map[string]Kind{"hash": KindHash}, Pagination: true}
+
+	tests := []struct {
+		name   string
+		params map[string]string
+		query  url.Values
+		valid  bool
+	}{
+		{"valid hash", map[string]string{"hash": "0x" + "ab12ab12ab12ab12ab12ab12ab12ab12ab12ab12ab12ab12ab12ab12ab12ab12"}, nil, true},
+		{"invalid hash", map[string]string{"hash": "0x123"}, nil, false},
+		{"missing parameter is not checked", map[string]string{}, nil, true},
+		{"invalid pagination", map[string]string{}, url.Values{"index": {"first"}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := rule.Validate(tt.params, tt.query)
+			if tt.valid != (err == nil) {
+				t.Errorf("Expected valid=%v, got %v", tt.valid, err)
+			}
+		})
+	}
+
+	if err := (Rule{Pattern: "/api/v2/*"}).Validate(nil, url.Values{"index": {"first"}}); err != nil {
+		t.Errorf("Expected pagination to be skipped when disabled, got %v", err)
+	}
+}
@@ -0,0 +1,166 @@
+// Package validation checks Blockscout API path and query parameters so malformed
+// requests can be rejected without a round trip to the backend.
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Kind identifies the format a parameter must have
+type Kind string
+
+// Parameter formats
+const (
+	KindAddress     Kind = "address"      // 20-byte hex address
+	KindHash        Kind = "hash"         // 32-byte hex transaction or block hash
+	KindBlockNumber Kind = "block_number" // non-negative decimal block number
+	KindBlock       Kind = "block"        // block number or block hash
+	KindCount       Kind = "count"        // non-negative decimal integer
+)
+
+// maxValueLength bounds how much of a rejected value is echoed in error messages
+const maxValueLength = 80
+
+// Error describes a parameter that failed validation
+type Error struct {
+	Param  string
+	Value  string
+	Reason string
+}
+
+// Error implements the error interface
+func (e *Error) Error() string {
+	value := e.Value
+	if len(value) > maxValueLength {
+		value = value[:maxValueLength] + "..."
+	}
+	return fmt.Sprintf("invalid %s %q: %s", e.Param, value, e.Reason)
+}
+
+// Validate checks the value against the format
+func (k Kind) Validate(value string) error {
+	switch k {
+	case KindAddress:
+		return Address(value)
+	case KindHash:
+		return Hash(value)
+	case KindBlockNumber:
+		return BlockNumber(value)
+	case KindBlock:
+		return Block(value)
+	case KindCount:
+		return Count(value)
+	}
+	return fmt.Errorf("unknown parameter kind %q", k)
+}
+
+// Address checks for a 0x-prefixed 20-byte hex address. Mixed case is accepted
+// without verifying the EIP-55 checksum.
+func Address(value string) error {
+	if !isHex(value, 20) {
+		return &Error{Param: "address", Value: value, Reason: "must be 0x followed by 40 hex characters"}
+	}
+	return nil
+}
+
+// Hash checks for a 0x-prefixed 32-byte hex hash
+func Hash(value string) error {
+	if !isHex(value, 32) {
+		return &Error{Param: "hash", Value: value, Reason: "must be 0x followed by 64 hex characters"}
+	}
+	return nil
+}
+
+// BlockNumber checks for a non-negative decimal block number
+func BlockNumber(value string) error {
+	if !isCount(value) {
+		return &Error{Param: "block number", Value: value, Reason: "must be a non-negative decimal integer"}
+	}
+	return nil
+}
+
+// Block checks for a block number or a block hash
+func Block(value string) error {
+	if !isCount(value) && !isHex(value, 32) {
+		return &Error{Param: "block", Value: value, Reason: "must be a block number or a 0x-prefixed 32-byte hash"}
+	}
+	return nil
+}
+
+// Count checks for a non-negative decimal integer
+func Count(value string) error {
+	if !isCount(value) {
+		return &Error{Param: "number", Value: value, Reason: "must be a non-negative decimal integer"}
+	}
+	return nil
+}
+
+// PaginationParams are the Blockscout v2 keyset pagination query parameters and
+// their formats, as returned in next_page_params
+var PaginationParams = map[string]Kind{
+	"block_number":      KindBlockNumber,
+	"index":             KindCount,
+	"items_count":       KindCount,
+	"transaction_index": KindCount,
+	"batch_log_index":   KindCount,
+	"log_index":         KindCount,
+}
+
+// Pagination checks the pagination parameters present in the query. Parameters
+// that are not pagination parameters are ignored.
+func Pagination(query url.Values) error {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		if _, ok := PaginationParams[name]; ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, value := range query[name] {
+			if err := Param(name, PaginationParams[name], value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Param checks a named parameter, reporting errors under the parameter's name
+func Param(name string, kind Kind, value string) error {
+	err := kind.Validate(value)
+	var invalid *Error
+	if errors.As(err, &invalid) {
+		return &Error{Param: name, Value: value, Reason: invalid.Reason}
+	}
+	return err
+}
+
+// isHex reports whether value is 0x followed by exactly size bytes of hex
+func isHex(value string, size int) bool {
+	if len(value) != 2+2*size || !strings.HasPrefix(value, "0x") && !strings.HasPrefix(value, "0X") {
+		return false
+	}
+	for i := 2; i < len(value); i++ {
+		c := value[i]
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+// isCount reports whether value is a decimal integer that fits in 64 bits
+func isCount(value string) bool {
+	if value == "" || value[0] == '+' {
+		return false
+	}
+	_, err := strconv.ParseUint(value, 10, 64)
+	return err == nil
+}
@@ -0,0 +1,94 @@
+package validation
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestKindValidate(t *testing.T) {
+	address := "0x" + strings.Repeat("aB", 20)
+	hash := "0x" + strings.Repeat("0f", 32)
+
+	tests := []struct {
+		name  string
+		kind  Kind
+		value string
+		valid bool
+	}{
+		{"lowercase address", KindAddress, "0x" + strings.Repeat("ab", 20), true},
+		{"mixed case address", KindAddress, address, true},
+		{"uppercase prefix address", KindAddress, "0X" + strings.Repeat("AB", 20), true},
+		{"short address", KindAddress, "0x123", false},
+		{"long address", KindAddress, address + "00", false},
+		{"address without prefix", KindAddress, strings.Repeat("ab", 21), false},
+		{"address with non-hex", KindAddress, "0x" + strings.Repeat("zz", 20), false},
+		{"not an address", KindAddress, "notanaddress", false},
+		{"empty address", KindAddress, "", false},
+		{"hash", KindHash, hash, true},
+		{"short hash", KindHash, "0x123", false},
+		{"address is not a hash", KindHash, address, false},
+		{"block number", KindBlockNumber, "12345", true},
+		{"block zero", KindBlockNumber, "0", true},
+		{"negative block number", KindBlockNumber, "-1", false},
+		{"signed block number", KindBlockNumber, "+1", false},
+		{"hex block number", KindBlockNumber, "0x10", false},
+		{"block number overflow", KindBlockNumber, "99999999999999999999", false},
+		{"block by number", KindBlock, "42", true},
+		{"block by hash", KindBlock, hash, true},
+		{"block by garbage", KindBlock, "latest", false},
+		{"count", KindCount, "50", true},
+		{"fractional count", KindCount, "1.5", false},
+		{"unknown kind", Kind("email"), "a@example.com", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.kind.Validate(tt.value)
+			if tt.valid && err != nil {
+				t.Errorf("Expected %q to be valid, got %v", tt.value, err)
+			}
+			if !tt.valid && err == nil {
+				t.Errorf("Expected %q to be invalid", tt.value)
+			}
+		})
+	}
+}
+
+func TestPagination(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		expected string
+	}{
+		{"no parameters", "", ""},
+		{"valid keyset", "block_number=100&index=3&items_count=50", ""},
+		{"unrelated parameters are ignored", "type=ERC-20&q=foo", ""},
+		{"invalid block number", "block_number=abc&items_count=50", `invalid block_number "abc": must be a non-negative decimal integer`},
+		{"invalid repeated value", "items_count=50&items_count=-1", `invalid items_count "-1": must be a non-negative decimal integer`},
+		{"first invalid parameter by name", "items_count=x&index=y", `invalid index "y": must be a non-negative decimal integer`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, _ := url.ParseQuery(tt.query)
+			err := Pagination(query)
+			if tt.expected == "" {
+				if err != nil {
+					t.Errorf("Expected no error, got %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.expected {
+				t.Errorf("Expected error %q, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestErrorTruncatesLongValues(t *testing.T) {
+	err := Address(strings.Repeat("x", 500))
+	if len(err.Error()) > 200 || !strings.Contains(err.Error(), "...") {
+		t.Errorf("Expected a truncated value in %q", err.Error())
+	}
+}